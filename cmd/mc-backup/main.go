@@ -0,0 +1,109 @@
+// Command mc-backup manages deduplicating world snapshots from the command line.
+//
+//	mc-backup init <repo>
+//	mc-backup snapshot <repo> <world-dir>
+//	mc-backup list <repo>
+//	mc-backup restore <repo> <snapshot-id> <target-dir>
+//	mc-backup forget <repo> <snapshot-id>
+//	mc-backup gc <repo>
+//	mc-backup unlock <repo>
+package main
+
+import (
+	"fmt"
+	"mc-admin/internal/backupstore"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  mc-backup init <repo>
+  mc-backup snapshot <repo> <world-dir>
+  mc-backup list <repo>
+  mc-backup restore <repo> <snapshot-id> <target-dir>
+  mc-backup forget <repo> <snapshot-id>
+  mc-backup gc <repo>
+  mc-backup unlock <repo>`)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+	}
+	if err := run(os.Args[1], os.Args[2], os.Args[3:]); err != nil {
+		fmt.Fprintf(os.Stderr, "mc-backup: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(command string, repoPath string, args []string) error {
+	if command == "init" {
+		_, err := backupstore.Init(repoPath)
+		if err == nil {
+			fmt.Printf("initialized repository at %s\n", repoPath)
+		}
+		return err
+	}
+
+	repo, err := backupstore.Open(repoPath)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "snapshot":
+		if len(args) != 1 {
+			usage()
+		}
+		snapshot, stats, err := repo.CreateSnapshot(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("snapshot %s: %d files (%d unchanged), %d/%d new chunks, %d new bytes\n",
+			snapshot.ID, stats.Files, stats.SkippedFiles, stats.NewChunks, stats.Chunks, stats.NewBytes)
+	case "list":
+		snapshots, err := repo.ListSnapshots()
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%s  %s  %d files  %d bytes  %s\n", s.ID, s.CreatedAt.Format("2006-01-02 15:04:05"), len(s.Files), s.TotalSize(), s.Source)
+		}
+	case "restore":
+		if len(args) != 2 {
+			usage()
+		}
+		if err := repo.Restore(args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("restored %s to %s\n", args[0], args[1])
+	case "forget":
+		if len(args) != 1 {
+			usage()
+		}
+		if err := repo.DeleteSnapshot(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("removed snapshot %s, run gc to free its chunks\n", args[0])
+	case "gc":
+		stats, err := repo.GarbageCollect()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("removed %d chunks, freed %d bytes\n", stats.RemovedChunks, stats.FreedBytes)
+	case "unlock":
+		removed, err := repo.Unlock()
+		if err != nil {
+			return err
+		}
+		if removed {
+			fmt.Println("removed the repository lock")
+		} else {
+			fmt.Println("repository was not locked")
+		}
+	default:
+		usage()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"mc-admin/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recentAccessRequests is how many reviewed requests the whitelist page lists
+const recentAccessRequests = 10
+
+// accessRequestPageData is shared by the public request form and its responses
+func accessRequestPageData(c *gin.Context, auth *discordAuthController, requireDiscord bool) gin.H {
+	data := getCommonPageData(c)
+	data["DiscordEnabled"] = auth.enabled
+	data["RequireDiscord"] = requireDiscord
+	data["Requester"] = auth.currentPlayerFromSession(c)
+	return data
+}
+
+func handleGetAccessRequestForm(auth *discordAuthController, requireDiscord bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(http.StatusOK, "access_request.html", accessRequestPageData(c, auth, requireDiscord))
+	}
+}
+
+// handleSubmitAccessRequest is public, requests are rate limited by Discord account or client address
+func handleSubmitAccessRequest(accessRequestService *services.AccessRequestService, auth *discordAuthController, requireDiscord bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := accessRequestPageData(c, auth, requireDiscord)
+		data["PlayerName"] = c.PostForm("playerName")
+		data["Message"] = c.PostForm("message")
+
+		requester := auth.currentPlayerFromSession(c)
+		if requireDiscord && requester == nil {
+			data["Error"] = "Sign in with Discord before requesting access."
+			c.HTML(http.StatusUnauthorized, "access_request.html", data)
+			return
+		}
+
+		submission := services.AccessRequestSubmission{
+			PlayerName: c.PostForm("playerName"),
+			Message:    c.PostForm("message"),
+			Source:     "ip:" + c.ClientIP(),
+		}
+		if requester != nil {
+			submission.DiscordID = requester.ID
+			submission.DiscordName = requester.Username
+			submission.Source = "discord:" + requester.ID
+		}
+
+		request, err := accessRequestService.Submit(submission)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, services.ErrAccessRequestRateLimited) {
+				status = http.StatusTooManyRequests
+			}
+			data["Error"] = err.Error()
+			c.HTML(status, "access_request.html", data)
+			return
+		}
+		data["Submitted"] = request
+		c.HTML(http.StatusOK, "access_request.html", data)
+	}
+}
+
+func handleApproveAccessRequest(page whitelistPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, err := page.accessRequests.Approve(c.PostForm("id"), reviewerName(c))
+		triggerActionToast(c, err, "Whitelisted "+request.PlayerName)
+		page.render(c)
+	}
+}
+
+func handleDenyAccessRequest(page whitelistPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, err := page.accessRequests.Deny(c.PostForm("id"), reviewerName(c), c.PostForm("reason"))
+		triggerActionToast(c, err, "Denied the request for "+request.PlayerName)
+		page.render(c)
+	}
+}
+
+// reviewerName is recorded on reviewed requests, everyone is an admin without authentication
+func reviewerName(c *gin.Context) string {
+	if user := CurrentUser(c); user != nil && user.Username != "" {
+		return user.Username
+	}
+	return "admin"
+}
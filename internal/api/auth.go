@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mc-admin/internal/clients/discord"
 	"net/http"
 	"os"
 	"strings"
@@ -24,9 +25,19 @@ const (
 	sessionKeyUserID     = "user_id"
 	sessionKeyUserName   = "user_name"
 	sessionKeyUserAvatar = "user_avatar"
-	userContextKey       = "authenticatedUser"
+	// Players who sign in to request access or link their account are kept apart from admins
+	sessionKeyLoginPurpose = "login_purpose"
+	sessionKeyPlayerID     = "player_id"
+	sessionKeyPlayerName   = "player_name"
+	userContextKey         = "authenticatedUser"
 )
 
+// playerLoginRedirects maps the purposes of player logins to the page they return to
+var playerLoginRedirects = map[string]string{
+	"access-request": "/access-request",
+	"link":           "/link",
+}
+
 // AuthConfig describes the Discord OAuth configuration required by the server.
 type AuthConfig struct {
 	Enabled        bool
@@ -35,6 +46,8 @@ type AuthConfig struct {
 	RedirectURI    string
 	SessionSecret  string
 	AllowedUserIDs []string
+	// APIURL defaults to discord.DefaultBaseURL, tests point it at a local stand-in
+	APIURL string
 }
 
 // AuthenticatedUser represents the information persisted in the session once Discord authentication succeeds.
@@ -46,13 +59,16 @@ type AuthenticatedUser struct {
 
 type discordAuthController struct {
 	enabled        bool
+	apiURL         string
 	oauthConfig    *oauth2.Config
 	allowedUserIDs map[string]struct{}
 }
 
-var discordOAuthEndpoint = oauth2.Endpoint{
-	AuthURL:  "https://discord.com/api/oauth2/authorize",
-	TokenURL: "https://discord.com/api/oauth2/token",
+func discordOAuthEndpoint(apiURL string) oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:  apiURL + "/oauth2/authorize",
+		TokenURL: apiURL + "/oauth2/token",
+	}
 }
 
 func BuildAuthConfigFromEnv() AuthConfig {
@@ -61,6 +77,7 @@ func BuildAuthConfigFromEnv() AuthConfig {
 	clientSecret := os.Getenv("DISCORD_CLIENT_SECRET")
 	redirectURI := os.Getenv("DISCORD_REDIRECT_URI")
 	sessionSecret := os.Getenv("SESSION_SECRET")
+	apiURL := os.Getenv("DISCORD_API_URL")
 	allowedUsersEnv := os.Getenv("DISCORD_ALLOWED_USER_IDS")
 	var allowedUsers []string
 	if allowedUsersEnv != "" {
@@ -79,6 +96,7 @@ func BuildAuthConfigFromEnv() AuthConfig {
 			RedirectURI:    redirectURI,
 			SessionSecret:  sessionSecret,
 			AllowedUserIDs: allowedUsers,
+			APIURL:         apiURL,
 		}
 	}
 
@@ -88,6 +106,7 @@ func BuildAuthConfigFromEnv() AuthConfig {
 		ClientSecret:  clientSecret,
 		RedirectURI:   redirectURI,
 		SessionSecret: sessionSecret,
+		APIURL:        apiURL,
 	}
 }
 
@@ -119,14 +138,19 @@ func ConfigureDiscordAuth(r *gin.Engine, cfg AuthConfig) (*discordAuthController
 		allowedSet[trimmed] = struct{}{}
 	}
 
+	apiURL := strings.TrimRight(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = discord.DefaultBaseURL
+	}
 	controller := &discordAuthController{
 		enabled: true,
+		apiURL:  apiURL,
 		oauthConfig: &oauth2.Config{
 			ClientID:     cfg.ClientID,
 			ClientSecret: cfg.ClientSecret,
 			RedirectURL:  cfg.RedirectURI,
 			Scopes:       []string{"identify"},
-			Endpoint:     discordOAuthEndpoint,
+			Endpoint:     discordOAuthEndpoint(apiURL),
 		},
 		allowedUserIDs: allowedSet,
 	}
@@ -182,6 +206,11 @@ func (a *discordAuthController) startDiscordFlow() gin.HandlerFunc {
 		}
 		session := sessions.Default(c)
 		session.Set(sessionKeyState, state)
+		if purpose := c.Query("purpose"); playerLoginRedirects[purpose] != "" {
+			session.Set(sessionKeyLoginPurpose, purpose)
+		} else {
+			session.Delete(sessionKeyLoginPurpose)
+		}
 		if err := session.Save(); err != nil {
 			c.String(http.StatusInternalServerError, "failed to persist session state")
 			return
@@ -195,7 +224,9 @@ func (a *discordAuthController) handleDiscordCallback() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
 		savedState, _ := session.Get(sessionKeyState).(string)
+		purpose, _ := session.Get(sessionKeyLoginPurpose).(string)
 		session.Delete(sessionKeyState)
+		session.Delete(sessionKeyLoginPurpose)
 		_ = session.Save()
 
 		if savedState == "" || c.Query("state") != savedState {
@@ -218,12 +249,24 @@ func (a *discordAuthController) handleDiscordCallback() gin.HandlerFunc {
 			return
 		}
 
-		discordUser, err := fetchDiscordUser(ctx, token.AccessToken)
+		discordUser, err := fetchDiscordUser(ctx, a.apiURL, token.AccessToken)
 		if err != nil {
 			c.String(http.StatusBadGateway, "failed to fetch Discord profile")
 			return
 		}
 
+		// Players only prove who they are, they do not get access to the admin console
+		if redirect := playerLoginRedirects[purpose]; redirect != "" {
+			session.Set(sessionKeyPlayerID, discordUser.ID)
+			session.Set(sessionKeyPlayerName, discordUser.DisplayName())
+			if err := session.Save(); err != nil {
+				c.String(http.StatusInternalServerError, "failed to update session")
+				return
+			}
+			c.Redirect(http.StatusFound, redirect)
+			return
+		}
+
 		if len(a.allowedUserIDs) > 0 {
 			if _, ok := a.allowedUserIDs[discordUser.ID]; !ok {
 				c.String(http.StatusForbidden, "Discord account is not authorized")
@@ -307,8 +350,8 @@ func (u discordUserResponse) AvatarURL() string {
 	return fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.%s?size=128", u.ID, u.Avatar, format)
 }
 
-func fetchDiscordUser(ctx context.Context, accessToken string) (discordUserResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://discord.com/api/users/@me", nil)
+func fetchDiscordUser(ctx context.Context, apiURL, accessToken string) (discordUserResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"/users/@me", nil)
 	if err != nil {
 		return discordUserResponse{}, err
 	}
@@ -342,6 +385,21 @@ func currentUserFromSession(c *gin.Context) *AuthenticatedUser {
 	return &AuthenticatedUser{ID: id, Username: name, AvatarURL: avatar}
 }
 
+// currentPlayerFromSession returns the Discord account a player signed in with to request access
+// or link their account, nil if they did not sign in or Discord authentication is disabled
+func (a *discordAuthController) currentPlayerFromSession(c *gin.Context) *AuthenticatedUser {
+	if !a.enabled {
+		return nil
+	}
+	session := sessions.Default(c)
+	id, _ := session.Get(sessionKeyPlayerID).(string)
+	if strings.TrimSpace(id) == "" {
+		return nil
+	}
+	name, _ := session.Get(sessionKeyPlayerName).(string)
+	return &AuthenticatedUser{ID: id, Username: name}
+}
+
 // CurrentUser exposes the authenticated user that the RequireAuth middleware attached to the context.
 func CurrentUser(c *gin.Context) *AuthenticatedUser {
 	value, exists := c.Get(userContextKey)
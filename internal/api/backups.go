@@ -0,0 +1,140 @@
+package api
+
+import (
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func handleGetBackups(backupService *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := getCommonPageData(c)
+		backups, err := backupService.ListBackups()
+		if err != nil {
+			data["Error"] = err.Error()
+		}
+		if err := backupService.UploadStateError(); err != nil {
+			data["UploadsError"] = err.Error()
+		}
+		data["Backups"] = backups
+		data["Restore"] = backupService.GetRestoreStatus()
+		data["UploadEnabled"] = backupService.HasDestinations()
+
+		if c.GetHeader("HX-Request") == "true" {
+			c.HTML(http.StatusOK, "backups.html", data)
+			return
+		}
+
+		data["ActiveModule"] = "backups"
+		c.HTML(http.StatusOK, "index.html", data)
+	}
+}
+
+func handleGetBackupContents(backupService *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Query("name")
+		backup, err := backupService.GetBackup(name)
+		if err != nil {
+			c.HTML(http.StatusOK, "backup_contents.html", gin.H{"Name": name, "Error": err.Error()})
+			return
+		}
+		entries, err := backupService.ListBackupContents(name)
+		if err != nil {
+			c.HTML(http.StatusOK, "backup_contents.html", gin.H{"Name": name, "Error": err.Error()})
+			return
+		}
+		c.HTML(http.StatusOK, "backup_contents.html", gin.H{
+			"Name":    name,
+			"Backup":  backup,
+			"Entries": entries,
+		})
+	}
+}
+
+func handleDownloadBackup(backupService *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Query("name")
+		absPath, err := backupService.GetBackupPath(name)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Error: "+err.Error())
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=\""+name+"\"")
+		c.File(absPath)
+	}
+}
+
+func handleGetRestoreBackupDialog(backupService *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Query("name")
+		backup, err := backupService.GetBackup(name)
+		if err != nil {
+			c.HTML(http.StatusOK, "error.html", gin.H{"DetailedError": err.Error()})
+			return
+		}
+		c.HTML(http.StatusOK, "backup_restore.html", gin.H{"Backup": backup})
+	}
+}
+
+func handleRestoreBackup(backupService *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.PostForm("name")
+		warningSeconds := 0
+		if value := c.PostForm("warning_seconds"); value != "" {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 || seconds > 600 {
+				c.String(http.StatusBadRequest, "Invalid warning: must be between 0 and 600 seconds")
+				return
+			}
+			warningSeconds = seconds
+		}
+
+		if err := backupService.StartRestore(name, services.RestoreOptions{WarningSeconds: warningSeconds}); err != nil {
+			c.Header("HX-Trigger", utils.BuildToastTrigger("Failed to start restore: "+err.Error(), "error"))
+			c.String(http.StatusConflict, "Error: "+err.Error())
+			return
+		}
+
+		c.Header("HX-Trigger", utils.BuildToastTrigger("Restore of '"+name+"' started", "success"))
+		c.HTML(http.StatusOK, "backup_restore_status.html", gin.H{"Restore": backupService.GetRestoreStatus()})
+	}
+}
+
+func handleGetRestoreStatus(backupService *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(http.StatusOK, "backup_restore_status.html", gin.H{"Restore": backupService.GetRestoreStatus()})
+	}
+}
+
+func handleUploadBackup(backupService *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Query("name")
+		if err := backupService.StartUpload(name); err != nil {
+			c.Header("HX-Trigger", utils.BuildToastTrigger("Failed to start upload: "+err.Error(), "error"))
+			c.String(http.StatusConflict, "Error: "+err.Error())
+			return
+		}
+
+		backup, err := backupService.GetBackup(name)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Error: "+err.Error())
+			return
+		}
+		c.Header("HX-Trigger", utils.BuildToastTrigger("Upload of '"+name+"' started", "success"))
+		c.HTML(http.StatusOK, "backup_uploads.html", backup)
+	}
+}
+
+func handleGetBackupUploads(backupService *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		backup, err := backupService.GetBackup(c.Query("name"))
+		if err != nil {
+			c.String(http.StatusBadRequest, "Error: "+err.Error())
+			return
+		}
+		c.HTML(http.StatusOK, "backup_uploads.html", backup)
+	}
+}
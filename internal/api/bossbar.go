@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countdownInputLayout is the value format of <input type="datetime-local">
+const countdownInputLayout = "2006-01-02T15:04"
+
+// bossbarsPageData collects the bossbars and the choices for bossbars.html
+func bossbarsPageData(bossbarService *services.BossbarService) gin.H {
+	data := gin.H{
+		"Colors":               services.BossbarColors,
+		"Styles":               services.BossbarStyles,
+		"FinishActions":        services.CountdownFinishActions,
+		"RemainingPlaceholder": services.BossbarRemainingPlaceholder,
+		"ServerTime":           time.Now(),
+	}
+	bars, err := bossbarService.GetBossbars()
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	data["Bossbars"] = bars
+	return data
+}
+
+func handleGetBossbars(bossbarService *services.BossbarService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(http.StatusOK, "bossbars.html", bossbarsPageData(bossbarService))
+	}
+}
+
+// respondBossbarAction re-renders the bossbar section with a toast for the outcome of an action
+func respondBossbarAction(c *gin.Context, bossbarService *services.BossbarService, err error, success string) {
+	if err != nil {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(err.Error(), "error"))
+	} else {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(success, "success"))
+	}
+	c.HTML(http.StatusOK, "bossbars.html", bossbarsPageData(bossbarService))
+}
+
+// bossbarSettingsFromForm reads the fields shared by the create and update forms
+func bossbarSettingsFromForm(c *gin.Context) (services.BossbarSettings, error) {
+	settings := services.BossbarSettings{
+		Name:    strings.TrimSpace(c.PostForm("name")),
+		Color:   c.PostForm("color"),
+		Style:   c.PostForm("style"),
+		Visible: c.PostForm("visible") == "on",
+		Players: strings.TrimSpace(c.PostForm("players")),
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(c.PostForm("value")))
+	if err != nil {
+		return settings, errors.New("value must be a whole number")
+	}
+	maximum, err := strconv.Atoi(strings.TrimSpace(c.PostForm("max")))
+	if err != nil {
+		return settings, errors.New("maximum must be a whole number")
+	}
+	settings.Value = value
+	settings.Max = maximum
+	return settings, nil
+}
+
+func handleCreateBossbar(bossbarService *services.BossbarService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.PostForm("id"))
+		settings, err := bossbarSettingsFromForm(c)
+		if err == nil {
+			err = bossbarService.CreateBossbar(id, settings)
+		}
+		respondBossbarAction(c, bossbarService, err, "Created bossbar "+id)
+	}
+}
+
+func handleUpdateBossbar(bossbarService *services.BossbarService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.PostForm("id")
+		settings, err := bossbarSettingsFromForm(c)
+		if err == nil {
+			err = bossbarService.UpdateBossbar(id, settings)
+		}
+		respondBossbarAction(c, bossbarService, err, "Updated bossbar "+id)
+	}
+}
+
+func handleRemoveBossbar(bossbarService *services.BossbarService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.PostForm("id")
+		err := bossbarService.RemoveBossbar(id)
+		respondBossbarAction(c, bossbarService, err, "Removed bossbar "+id)
+	}
+}
+
+func handleStartCountdown(bossbarService *services.BossbarService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.PostForm("id")
+		target, err := time.ParseInLocation(countdownInputLayout, c.PostForm("target"), time.Local)
+		if err != nil {
+			err = errors.New("countdown target must be a date and time")
+		} else {
+			err = bossbarService.StartCountdown(id, target, c.PostForm("on_finish"))
+		}
+		respondBossbarAction(c, bossbarService, err, "Started countdown on "+id)
+	}
+}
+
+func handleStopCountdown(bossbarService *services.BossbarService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.PostForm("id")
+		err := bossbarService.StopCountdown(id)
+		respondBossbarAction(c, bossbarService, err, "Stopped countdown on "+id)
+	}
+}
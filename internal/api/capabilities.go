@@ -0,0 +1,77 @@
+package api
+
+import (
+	"maps"
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const capabilitiesContextKey = "serverCapabilities"
+
+// probedCommands lists the capabilities in the order they are shown, with the command probed
+var probedCommands = []struct {
+	Capability string
+	Command    string
+}{
+	{services.CapabilityTick, "tick query"},
+	{services.CapabilityTPS, "tps"},
+	{services.CapabilityMSPT, "mspt"},
+	{services.CapabilityDatapack, "datapack"},
+}
+
+// RequireCapabilities makes the capability service available to the handlers of a group, which
+// read the detection with currentCapabilities
+func RequireCapabilities(capabilityService *services.CapabilityService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(capabilitiesContextKey, capabilityService)
+		c.Next()
+	}
+}
+
+// currentCapabilities returns the last detection without waiting for RCON. Without a capability
+// service nothing is known, which the templates treat as every capability being available.
+func currentCapabilities(c *gin.Context) services.ServerCapabilities {
+	value, exists := c.Get(capabilitiesContextKey)
+	if !exists {
+		return services.ServerCapabilities{}
+	}
+	if capabilityService, ok := value.(*services.CapabilityService); ok {
+		return capabilityService.Cached()
+	}
+	return services.ServerCapabilities{}
+}
+
+func serverSoftwareData(capabilities services.ServerCapabilities) gin.H {
+	commands := make([]gin.H, 0, len(probedCommands))
+	for _, probed := range probedCommands {
+		commands = append(commands, gin.H{
+			"Command":   probed.Command,
+			"Supported": capabilities.Capabilities[probed.Capability],
+		})
+	}
+	return gin.H{"Server": capabilities, "Commands": commands}
+}
+
+func handleGetServerSoftware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(http.StatusOK, "server_software.html", serverSoftwareData(currentCapabilities(c)))
+	}
+}
+
+// handleDetectServerSoftware runs the detection again, e.g. after the server was updated. The
+// page is reloaded when the capabilities changed so hidden controls follow them.
+func handleDetectServerSoftware(capabilityService *services.CapabilityService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		previous := capabilityService.Cached()
+		capabilities := capabilityService.Detect()
+		if !maps.Equal(previous.Capabilities, capabilities.Capabilities) || previous.Reachable != capabilities.Reachable {
+			c.Header("HX-Refresh", "true")
+		} else {
+			c.Header("HX-Trigger", utils.BuildToastTrigger("Detected "+capabilities.Description(), "success"))
+		}
+		c.HTML(http.StatusOK, "server_software.html", serverSoftwareData(capabilities))
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enabledDatapack is an enabled pack with its neighbours in the load order, used for the move buttons
+type enabledDatapack struct {
+	services.Datapack
+	Previous string
+	Next     string
+}
+
+// datapackPageData collects the pack lists and the server's pack format for datapacks.html
+func datapackPageData(c *gin.Context, datapackService *services.DatapackService) gin.H {
+	data := getCommonPageData(c)
+	list, err := datapackService.GetDatapacks()
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	enabled := make([]enabledDatapack, len(list.Enabled))
+	for i, pack := range list.Enabled {
+		enabled[i].Datapack = pack
+		if i > 0 {
+			enabled[i].Previous = list.Enabled[i-1].ID
+		}
+		if i < len(list.Enabled)-1 {
+			enabled[i].Next = list.Enabled[i+1].ID
+		}
+	}
+	data["Enabled"] = enabled
+	data["Available"] = list.Available
+	data["Positions"] = services.DatapackPositions
+	if format, err := datapackService.GetServerFormat(); err == nil {
+		data["Format"] = format
+	}
+	return data
+}
+
+func handleGetDatapacks(datapackService *services.DatapackService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := datapackPageData(c, datapackService)
+
+		if c.GetHeader("HX-Request") == "true" {
+			c.HTML(http.StatusOK, "datapacks.html", data)
+			return
+		}
+
+		data["ActiveModule"] = "datapacks"
+		c.HTML(http.StatusOK, "index.html", data)
+	}
+}
+
+// respondDatapackAction re-renders the datapacks page with a toast for the outcome of an action
+func respondDatapackAction(c *gin.Context, datapackService *services.DatapackService, err error, success string) {
+	if err != nil {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(err.Error(), "error"))
+	} else {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(success, "success"))
+	}
+	c.HTML(http.StatusOK, "datapacks.html", datapackPageData(c, datapackService))
+}
+
+func handleEnableDatapack(datapackService *services.DatapackService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.PostForm("id")
+		err := datapackService.EnableDatapack(id, c.PostForm("position"), c.PostForm("reference"))
+		respondDatapackAction(c, datapackService, err, "Enabled "+id)
+	}
+}
+
+func handleDisableDatapack(datapackService *services.DatapackService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.PostForm("id")
+		err := datapackService.DisableDatapack(id)
+		respondDatapackAction(c, datapackService, err, "Disabled "+id)
+	}
+}
+
+func handleMoveDatapack(datapackService *services.DatapackService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.PostForm("id")
+		err := datapackService.MoveDatapack(id, c.PostForm("position"), c.PostForm("reference"))
+		respondDatapackAction(c, datapackService, err, "Moved "+id)
+	}
+}
+
+func handleUploadDatapack(datapackService *services.DatapackService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			respondDatapackAction(c, datapackService, errors.New("no file uploaded"), "")
+			return
+		}
+		src, err := file.Open()
+		if err != nil {
+			respondDatapackAction(c, datapackService, err, "")
+			return
+		}
+		defer src.Close()
+
+		result, err := datapackService.InstallDatapack(file.Filename, src, file.Size, c.PostForm("force") == "on")
+		if err != nil {
+			data := datapackPageData(c, datapackService)
+			data["UploadError"] = err.Error()
+			data["Incompatible"] = errors.Is(err, services.ErrDatapackIncompatible)
+			c.Header("HX-Trigger", utils.BuildToastTrigger("Upload rejected", "error"))
+			c.HTML(http.StatusOK, "datapacks.html", data)
+			return
+		}
+
+		data := datapackPageData(c, datapackService)
+		data["Installed"] = result
+		c.Header("HX-Trigger", utils.BuildToastTrigger("Installed "+result.FileName, "success"))
+		c.HTML(http.StatusOK, "datapacks.html", data)
+	}
+}
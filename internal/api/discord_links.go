@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// discordSyncTimeout bounds a reconcile started from the whitelist page
+const discordSyncTimeout = time.Minute
+
+// linkPageData describes the signed in player and their link for link.html
+func linkPageData(c *gin.Context, discordSyncService *services.DiscordSyncService, auth *discordAuthController) gin.H {
+	data := getCommonPageData(c)
+	player := auth.currentPlayerFromSession(c)
+	data["Player"] = player
+	if player != nil {
+		link, ok, err := discordSyncService.GetLink(player.ID)
+		if err != nil {
+			data["Error"] = err.Error()
+		} else if ok {
+			data["Link"] = link
+		}
+	}
+	return data
+}
+
+func handleGetLinkPage(discordSyncService *services.DiscordSyncService, auth *discordAuthController) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(http.StatusOK, "link.html", linkPageData(c, discordSyncService, auth))
+	}
+}
+
+// handleLinkPlayer links the signed in Discord account to a Minecraft name
+func handleLinkPlayer(discordSyncService *services.DiscordSyncService, auth *discordAuthController) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		player := auth.currentPlayerFromSession(c)
+		if player == nil {
+			data := linkPageData(c, discordSyncService, auth)
+			data["Error"] = "Sign in with Discord before linking a player."
+			c.HTML(http.StatusUnauthorized, "link.html", data)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+		_, err := discordSyncService.Link(ctx, player.ID, player.Username, c.PostForm("playerName"))
+		data := linkPageData(c, discordSyncService, auth)
+		if err != nil {
+			data["Error"] = err.Error()
+			c.HTML(http.StatusBadRequest, "link.html", data)
+			return
+		}
+		c.HTML(http.StatusOK, "link.html", data)
+	}
+}
+
+func handleUnlinkPlayer(discordSyncService *services.DiscordSyncService, auth *discordAuthController) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		player := auth.currentPlayerFromSession(c)
+		if player == nil {
+			c.Redirect(http.StatusSeeOther, "/link")
+			return
+		}
+		if _, err := discordSyncService.Unlink(player.ID); err != nil {
+			data := linkPageData(c, discordSyncService, auth)
+			data["Error"] = err.Error()
+			c.HTML(http.StatusBadRequest, "link.html", data)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/link")
+	}
+}
+
+// handleSyncDiscordLinks reconciles the whitelist with the Discord role right away
+func handleSyncDiscordLinks(page whitelistPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), discordSyncTimeout)
+		defer cancel()
+		result, err := page.discordSync.Reconcile(ctx)
+		if err != nil {
+			triggerActionToast(c, err, "")
+			page.render(c)
+			return
+		}
+		message := fmt.Sprintf("Added %d and removed %d players", len(result.Added), len(result.Removed))
+		if result.Failed > 0 {
+			c.Header("HX-Trigger", utils.BuildToastTrigger(fmt.Sprintf("%s, %d links failed", message, result.Failed), "error"))
+		} else {
+			c.Header("HX-Trigger", utils.BuildToastTrigger(message, "success"))
+		}
+		page.render(c)
+	}
+}
+
+// handleRemoveDiscordLink unlinks an account for an admin, removing the player if the sync added them
+func handleRemoveDiscordLink(page whitelistPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link, err := page.discordSync.Unlink(c.PostForm("discordId"))
+		triggerActionToast(c, err, "Unlinked "+link.PlayerName)
+		page.render(c)
+	}
+}
+
+// handleApproveDiscordLink confirms a linked name for an admin, whitelisting it if the account has the role
+func handleApproveDiscordLink(page whitelistPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+		link, err := page.discordSync.Approve(ctx, c.PostForm("discordId"))
+		triggerActionToast(c, err, "Approved "+link.PlayerName)
+		page.render(c)
+	}
+}
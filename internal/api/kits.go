@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// kitDeliveryLogSize is how many deliveries the kits page lists
+const kitDeliveryLogSize = 50
+
+// kitView is a kit with its editor fields prepared for kits.html
+type kitView struct {
+	services.Kit
+	ItemsText       string
+	CooldownMinutes int
+}
+
+func kitsPageData(c *gin.Context, kitService *services.KitService, serverService *services.ServerService) gin.H {
+	data := getCommonPageData(c)
+	kits := []kitView{}
+	savedKits, err := kitService.GetKits()
+	if err != nil {
+		data["StateError"] = err.Error()
+	}
+	for _, kit := range savedKits {
+		lines := make([]string, len(kit.Items))
+		for i, item := range kit.Items {
+			lines[i] = item.String()
+		}
+		kits = append(kits, kitView{
+			Kit:             kit,
+			ItemsText:       strings.Join(lines, "\n"),
+			CooldownMinutes: int(kit.Cooldown / time.Minute),
+		})
+	}
+	data["Kits"] = kits
+	data["Deliveries"], _ = kitService.GetDeliveries(kitDeliveryLogSize)
+	info, err := serverService.GetServerPlayerInfo()
+	if err != nil {
+		data["PlayersError"] = err.Error()
+	}
+	data["OnlinePlayers"] = info.PlayerNames
+	return data
+}
+
+func handleGetKits(kitService *services.KitService, serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := kitsPageData(c, kitService, serverService)
+
+		if c.GetHeader("HX-Request") == "true" {
+			c.HTML(http.StatusOK, "kits.html", data)
+			return
+		}
+
+		data["ActiveModule"] = "kits"
+		c.HTML(http.StatusOK, "index.html", data)
+	}
+}
+
+// respondKitAction re-renders the kits page with a toast for the outcome of an action
+func respondKitAction(c *gin.Context, kitService *services.KitService, serverService *services.ServerService, err error, success string) {
+	if err != nil {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(err.Error(), "error"))
+	} else {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(success, "success"))
+	}
+	c.HTML(http.StatusOK, "kits.html", kitsPageData(c, kitService, serverService))
+}
+
+func handleSaveKit(kitService *services.KitService, serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kit := services.Kit{Name: strings.TrimSpace(c.PostForm("name"))}
+		items, err := services.ParseKitItems(c.PostForm("items"))
+		if err == nil {
+			kit.Items = items
+			minutes := 0
+			if value := strings.TrimSpace(c.PostForm("cooldown")); value != "" {
+				minutes, err = strconv.Atoi(value)
+				if err != nil {
+					err = errors.New("cooldown must be a whole number of minutes")
+				}
+			}
+			kit.Cooldown = time.Duration(minutes) * time.Minute
+		}
+		if err == nil {
+			err = kitService.SaveKit(kit)
+		}
+		respondKitAction(c, kitService, serverService, err, "Saved kit "+kit.Name)
+	}
+}
+
+func handleDeleteKit(kitService *services.KitService, serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.PostForm("name")
+		err := kitService.DeleteKit(name)
+		respondKitAction(c, kitService, serverService, err, "Deleted kit "+name)
+	}
+}
+
+func handleResetKitCooldowns(kitService *services.KitService, serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.PostForm("name")
+		err := kitService.ResetCooldowns(name)
+		respondKitAction(c, kitService, serverService, err, "Reset cooldowns of kit "+name)
+	}
+}
+
+// handleGiveKit gives a kit to the chosen player, or to every online player when none is chosen
+func handleGiveKit(kitService *services.KitService, serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.PostForm("name")
+		player := c.PostForm("player")
+		if player != "" {
+			err := kitService.GiveKit(name, player)
+			respondKitAction(c, kitService, serverService, err, fmt.Sprintf("Gave kit %s to %s", name, player))
+			return
+		}
+
+		result, err := kitService.GiveKitToAll(name)
+		if err == nil && len(result.Delivered) == 0 && len(result.Failed) == 0 && len(result.OnCooldown) == 0 {
+			err = errors.New("no players are online")
+		}
+		if err == nil && len(result.Failed) > 0 {
+			failed := make([]string, 0, len(result.Failed))
+			for player, reason := range result.Failed {
+				failed = append(failed, player+": "+reason)
+			}
+			sort.Strings(failed)
+			err = fmt.Errorf("gave kit %s to %d player(s), failed for %s", name, len(result.Delivered), strings.Join(failed, "; "))
+		}
+		success := fmt.Sprintf("Gave kit %s to %d player(s)", name, len(result.Delivered))
+		if len(result.OnCooldown) > 0 {
+			success += fmt.Sprintf(", %d on cooldown", len(result.OnCooldown))
+		}
+		respondKitAction(c, kitService, serverService, err, success)
+	}
+}
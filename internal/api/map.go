@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"mc-admin/internal/maprender"
+	"mc-admin/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func handleGetMap(mapService *services.MapService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := getCommonPageData(c)
+		dimensions, err := mapService.Dimensions()
+		if err != nil {
+			data["Error"] = err.Error()
+		}
+		data["Dimensions"] = dimensions
+		data["MaxZoomOut"] = maprender.MaxZoomOut
+		data["TileSize"] = maprender.TileSize
+
+		if c.GetHeader("HX-Request") == "true" {
+			c.HTML(http.StatusOK, "map.html", data)
+			return
+		}
+
+		data["ActiveModule"] = "map"
+		c.HTML(http.StatusOK, "index.html", data)
+	}
+}
+
+func handleGetMapTile(mapService *services.MapService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		zoom, errZoom := strconv.Atoi(c.Param("zoom"))
+		x, errX := strconv.Atoi(c.Param("x"))
+		y, errY := strconv.Atoi(strings.TrimSuffix(c.Param("y"), ".png"))
+		if errZoom != nil || errX != nil || errY != nil {
+			c.String(http.StatusBadRequest, "Invalid tile coordinates")
+			return
+		}
+
+		path, err := mapService.GetTilePath(c.Param("dimension"), zoom, x, y)
+		if errors.Is(err, maprender.ErrNoTile) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Error: "+err.Error())
+			return
+		}
+		// tiles are re-rendered when their regions change, so clients must revalidate
+		c.Header("Cache-Control", "no-cache")
+		c.File(path)
+	}
+}
+
+func handleGetMapMarkers(mapService *services.MapService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		markers, err := mapService.GetMarkers(c.Query("dimension"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, markers)
+	}
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"fmt"
+	"mc-admin/internal/services"
+	"mc-admin/internal/textcomponent"
+	"mc-admin/internal/utils"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// messagePart is one styled run of the composer form
+type messagePart struct {
+	Type          string
+	Text          string
+	Color         string
+	Bold          bool
+	Italic        bool
+	Underlined    bool
+	Strikethrough bool
+	Obfuscated    bool
+	ClickAction   string
+	ClickValue    string
+	Hover         string
+}
+
+func (p messagePart) component() textcomponent.Component {
+	c := textcomponent.Component{
+		Color:         p.Color,
+		Bold:          p.Bold,
+		Italic:        p.Italic,
+		Underlined:    p.Underlined,
+		Strikethrough: p.Strikethrough,
+		Obfuscated:    p.Obfuscated,
+	}
+	if p.Type == "selector" {
+		c.Selector = strings.TrimSpace(p.Text)
+	} else {
+		c.Text = p.Text
+	}
+	if p.ClickAction != "" {
+		c.Click = &textcomponent.ClickEvent{Action: p.ClickAction, Value: strings.TrimSpace(p.ClickValue)}
+	}
+	if p.Hover != "" {
+		hover := textcomponent.Text(p.Hover)
+		c.Hover = &hover
+	}
+	return c
+}
+
+// messageForm is the state of the composer, read back from every request
+type messageForm struct {
+	Kind    string
+	Targets string
+	FadeIn  string
+	Stay    string
+	FadeOut string
+	Parts   []messagePart
+}
+
+func defaultMessageForm() messageForm {
+	return messageForm{
+		Kind:    services.MessageChat,
+		Targets: "@a",
+		FadeIn:  strconv.Itoa(services.DefaultTitleFadeIn),
+		Stay:    strconv.Itoa(services.DefaultTitleStay),
+		FadeOut: strconv.Itoa(services.DefaultTitleFadeOut),
+		Parts:   []messagePart{{Type: "text"}},
+	}
+}
+
+// messageFormFromRequest reads the composer fields. Part fields are parallel arrays and
+// style checkboxes submit the index of their part.
+func messageFormFromRequest(c *gin.Context) messageForm {
+	form := messageForm{
+		Kind:    c.PostForm("kind"),
+		Targets: c.PostForm("targets"),
+		FadeIn:  c.PostForm("fade_in"),
+		Stay:    c.PostForm("stay"),
+		FadeOut: c.PostForm("fade_out"),
+	}
+	types := c.PostFormArray("part_type")
+	texts := c.PostFormArray("part_text")
+	colors := c.PostFormArray("part_color")
+	clickActions := c.PostFormArray("part_click_action")
+	clickValues := c.PostFormArray("part_click_value")
+	hovers := c.PostFormArray("part_hover")
+	at := func(values []string, i int) string {
+		if i < len(values) {
+			return values[i]
+		}
+		return ""
+	}
+	checked := func(name string, i int) bool {
+		return slices.Contains(c.PostFormArray(name), strconv.Itoa(i))
+	}
+	for i := range types {
+		form.Parts = append(form.Parts, messagePart{
+			Type:          types[i],
+			Text:          at(texts, i),
+			Color:         at(colors, i),
+			Bold:          checked("part_bold", i),
+			Italic:        checked("part_italic", i),
+			Underlined:    checked("part_underlined", i),
+			Strikethrough: checked("part_strikethrough", i),
+			Obfuscated:    checked("part_obfuscated", i),
+			ClickAction:   at(clickActions, i),
+			ClickValue:    at(clickValues, i),
+			Hover:         at(hovers, i),
+		})
+	}
+	return form
+}
+
+func (f messageForm) broadcast() (services.Broadcast, error) {
+	b := services.Broadcast{Kind: f.Kind, Targets: f.Targets}
+	var parts []textcomponent.Component
+	for _, part := range f.Parts {
+		if strings.TrimSpace(part.Text) != "" {
+			parts = append(parts, part.component())
+		}
+	}
+	b.Message = textcomponent.Join(parts...)
+	if f.Kind == services.MessageChat {
+		return b, nil
+	}
+	for _, timing := range []struct {
+		name  string
+		value string
+		dest  *int
+	}{{"fade in", f.FadeIn, &b.FadeIn}, {"stay", f.Stay, &b.Stay}, {"fade out", f.FadeOut, &b.FadeOut}} {
+		n, err := strconv.Atoi(strings.TrimSpace(timing.value))
+		if err != nil {
+			return b, fmt.Errorf("%s must be a whole number of ticks", timing.name)
+		}
+		*timing.dest = n
+	}
+	return b, nil
+}
+
+// messagePreviewData renders the styled preview and the commands of the composed message
+func messagePreviewData(messageService *services.MessageService, form messageForm) gin.H {
+	data := gin.H{}
+	b, err := form.broadcast()
+	if err == nil && len(b.Message.Extra) == 0 {
+		// nothing composed yet
+		return data
+	}
+	if err == nil {
+		data["Segments"] = b.Message.Segments()
+		data["Commands"], err = messageService.Commands(b)
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	return data
+}
+
+func messagesPageData(c *gin.Context, messageService *services.MessageService, form messageForm) gin.H {
+	data := getCommonPageData(c)
+	data["Form"] = form
+	data["Kinds"] = services.MessageKinds
+	data["Colors"] = textcomponent.ColorNames
+	data["ClickActions"] = textcomponent.ClickActions
+	data["Preview"] = messagePreviewData(messageService, form)
+	return data
+}
+
+func handleGetMessages(messageService *services.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := messagesPageData(c, messageService, defaultMessageForm())
+
+		if c.GetHeader("HX-Request") == "true" {
+			c.HTML(http.StatusOK, "messages.html", data)
+			return
+		}
+
+		data["ActiveModule"] = "messages"
+		c.HTML(http.StatusOK, "index.html", data)
+	}
+}
+
+// handleComposeMessage adds or removes a part and re-renders the composer
+func handleComposeMessage(messageService *services.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		form := messageFormFromRequest(c)
+		action := c.PostForm("action")
+		if action == "add" {
+			form.Parts = append(form.Parts, messagePart{Type: "text"})
+		} else if index, ok := strings.CutPrefix(action, "remove:"); ok {
+			if i, err := strconv.Atoi(index); err == nil && i >= 0 && i < len(form.Parts) && len(form.Parts) > 1 {
+				form.Parts = slices.Delete(form.Parts, i, i+1)
+			}
+		}
+		c.HTML(http.StatusOK, "messages.html", messagesPageData(c, messageService, form))
+	}
+}
+
+func handlePreviewMessage(messageService *services.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(http.StatusOK, "message_preview.html", messagePreviewData(messageService, messageFormFromRequest(c)))
+	}
+}
+
+func handleSendMessage(messageService *services.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		form := messageFormFromRequest(c)
+		b, err := form.broadcast()
+		if err == nil {
+			err = messageService.Send(b)
+		}
+		if err != nil {
+			c.Header("HX-Trigger", utils.BuildToastTrigger(err.Error(), "error"))
+		} else {
+			c.Header("HX-Trigger", utils.BuildToastTrigger("Message sent", "success"))
+		}
+		c.HTML(http.StatusOK, "message_preview.html", messagePreviewData(messageService, form))
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"mc-admin/internal/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// performanceSampleInterval and performanceHistorySize keep one hour of samples
+	performanceSampleInterval = 10 * time.Second
+	performanceHistorySize    = 360
+
+	performanceGraphWidth  = 300.0
+	performanceGraphHeight = 80.0
+)
+
+// performanceGraph is a polyline of one metric over the sample history, scaled to the viewBox
+type performanceGraph struct {
+	Metric string
+	Points string
+	Max    float64
+	// LimitY is the height of the 50 ms per tick line when the metric is MSPT, 0 otherwise
+	LimitY float64
+}
+
+// buildPerformanceGraph plots MSPT where the source reports it, otherwise TPS, otherwise the
+// number of "Can't keep up" warnings per sample
+func buildPerformanceGraph(history []services.PerformanceSample) performanceGraph {
+	graph := performanceGraph{Metric: "warnings"}
+	value := func(s services.PerformanceSample) float64 { return float64(s.Warnings) }
+	for _, sample := range history {
+		if sample.MSPT > 0 {
+			graph.Metric = "MSPT"
+			value = func(s services.PerformanceSample) float64 { return s.MSPT }
+			break
+		}
+		if sample.TPS > 0 {
+			graph.Metric = "TPS"
+			value = func(s services.PerformanceSample) float64 { return s.TPS }
+		}
+	}
+
+	graph.Max = 1
+	switch graph.Metric {
+	case "MSPT":
+		graph.Max = 60
+	case "TPS":
+		graph.Max = 20
+	}
+	for _, sample := range history {
+		graph.Max = max(graph.Max, value(sample))
+	}
+	if graph.Metric == "MSPT" {
+		graph.LimitY = performanceGraphHeight - 50/graph.Max*performanceGraphHeight
+	}
+
+	points := make([]string, len(history))
+	step := performanceGraphWidth / float64(max(1, len(history)-1))
+	for i, sample := range history {
+		y := performanceGraphHeight - value(sample)/graph.Max*performanceGraphHeight
+		points[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*step, y)
+	}
+	graph.Points = strings.Join(points, " ")
+	return graph
+}
+
+func handleGetPerformance(performanceService *services.PerformanceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		history := performanceService.History()
+		data := gin.H{
+			"Samples": len(history),
+			"Graph":   buildPerformanceGraph(history),
+			"Width":   performanceGraphWidth,
+			"Height":  performanceGraphHeight,
+		}
+		if latest, ok := performanceService.Latest(); ok {
+			data["Latest"] = latest
+		}
+		c.HTML(http.StatusOK, "performance.html", data)
+	}
+}
@@ -1,14 +1,18 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func handleGetServerInfo(serverService *services.ServerService) gin.HandlerFunc {
+func handleGetServerInfo(serverService *services.ServerService, floodgate services.Floodgate) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		info, err := serverService.GetServerPlayerInfo()
 		if err != nil {
@@ -16,8 +20,13 @@ func handleGetServerInfo(serverService *services.ServerService) gin.HandlerFunc
 			c.HTML(http.StatusOK, "error.html", nil)
 			return
 		}
+		bedrock := map[string]bool{}
+		for _, name := range info.PlayerNames {
+			bedrock[name] = floodgate.IsBedrockName(name)
+		}
 		c.HTML(http.StatusOK, "player_list.html", gin.H{
 			"Players":     info.PlayerNames,
+			"Bedrock":     bedrock,
 			"OnlineCount": info.OnlineCount,
 			"MaxCount":    info.MaxCount,
 		})
@@ -58,3 +67,115 @@ func handleKickPlayer(serverService *services.ServerService) gin.HandlerFunc {
 		})
 	}
 }
+
+// playerActionsData collects the choices of the player actions dialog
+func playerActionsData(serverService *services.ServerService, name string) gin.H {
+	others := []string{}
+	if info, err := serverService.GetServerPlayerInfo(); err == nil {
+		for _, player := range info.PlayerNames {
+			if player != name {
+				others = append(others, player)
+			}
+		}
+	}
+	return gin.H{
+		"PlayerName":   name,
+		"OtherPlayers": others,
+		"GameModes":    services.GameModes,
+		"Dimensions":   services.TeleportDimensions,
+		"Items":        services.CommonItems,
+	}
+}
+
+func handleGetPlayerActionsDialog(serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := strings.TrimSpace(c.Param("name"))
+		if name == "" {
+			c.HTML(http.StatusOK, "error.html", nil)
+			return
+		}
+		c.HTML(http.StatusOK, "player_actions.html", playerActionsData(serverService, name))
+	}
+}
+
+// respondPlayerAction re-renders the player actions dialog with a toast for the outcome
+func respondPlayerAction(c *gin.Context, serverService *services.ServerService, name string, err error, success string) {
+	if err != nil {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(err.Error(), "error"))
+	} else {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(success, "success"))
+	}
+	c.HTML(http.StatusOK, "player_actions.html", playerActionsData(serverService, name))
+}
+
+func handleSetGameMode(serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		mode := c.PostForm("mode")
+		err := serverService.SetGameMode(name, mode)
+		respondPlayerAction(c, serverService, name, err, "Set "+name+" to "+mode)
+	}
+}
+
+func handleTeleportPlayer(serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		var err error
+		switch c.PostForm("destination") {
+		case "player":
+			err = serverService.TeleportToPlayer(name, c.PostForm("target"))
+		case "spawn":
+			err = serverService.TeleportToSpawn(name)
+		default:
+			var coordinates [3]float64
+			for i, field := range []string{"x", "y", "z"} {
+				value, convErr := strconv.ParseFloat(strings.TrimSpace(c.PostForm(field)), 64)
+				if convErr != nil {
+					err = errors.New("coordinates must be numbers")
+					break
+				}
+				coordinates[i] = value
+			}
+			if err == nil {
+				err = serverService.TeleportToCoordinates(name, c.PostForm("dimension"), coordinates[0], coordinates[1], coordinates[2])
+			}
+		}
+		respondPlayerAction(c, serverService, name, err, "Teleported "+name)
+	}
+}
+
+func handlePlayerEffects(serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		var err error
+		var success string
+		switch c.PostForm("action") {
+		case "heal":
+			err = serverService.Heal(name)
+			success = "Healed " + name
+		case "feed":
+			err = serverService.Feed(name)
+			success = "Fed " + name
+		case "clear":
+			err = serverService.ClearEffects(name)
+			success = "Cleared the effects of " + name
+		default:
+			err = errors.New("unknown effect action")
+		}
+		respondPlayerAction(c, serverService, name, err, success)
+	}
+}
+
+func handleGiveItem(serverService *services.ServerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		item := strings.TrimSpace(c.PostForm("item"))
+		count, err := strconv.Atoi(strings.TrimSpace(c.PostForm("count")))
+		if err != nil {
+			err = errors.New("count must be a whole number")
+		} else {
+			err = serverService.GiveItem(name, item, count)
+		}
+		respondPlayerAction(c, serverService, name, err, fmt.Sprintf("Gave %d %s to %s", count, item, name))
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pluginPageData collects the jar inventory and the last update check for plugins.html.
+// Updates and staged updates are keyed by "<directory>/<file name>" for the table rows.
+func pluginPageData(c *gin.Context, pluginService *services.PluginService, updateService *services.PluginUpdateService) gin.H {
+	data := getCommonPageData(c)
+	inventory, err := pluginService.GetInventory()
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	data["Inventory"] = inventory
+
+	updates := map[string]*services.PluginUpdate{}
+	if report, ok := updateService.LastReport(); ok {
+		for i := range report.Updates {
+			update := &report.Updates[i]
+			updates[update.Plugin.Directory+"/"+update.Plugin.FileName] = update
+		}
+		data["UpdateReport"] = report
+	}
+	stagedUpdates, err := updateService.Staged()
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	staged := map[string]*services.StagedUpdate{}
+	for _, update := range stagedUpdates {
+		staged[update.Directory+"/"+update.FileName] = &update
+	}
+	data["Updates"] = updates
+	data["Staged"] = staged
+	return data
+}
+
+func handleGetPlugins(pluginService *services.PluginService, updateService *services.PluginUpdateService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := pluginPageData(c, pluginService, updateService)
+
+		if c.GetHeader("HX-Request") == "true" {
+			c.HTML(http.StatusOK, "plugins.html", data)
+			return
+		}
+
+		data["ActiveModule"] = "plugins"
+		c.HTML(http.StatusOK, "index.html", data)
+	}
+}
+
+// respondPluginAction re-renders the plugins page with a toast for the outcome of an action
+func respondPluginAction(c *gin.Context, pluginService *services.PluginService, updateService *services.PluginUpdateService, err error, success string) {
+	if err != nil {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(err.Error(), "error"))
+	} else {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(success, "success"))
+	}
+	c.HTML(http.StatusOK, "plugins.html", pluginPageData(c, pluginService, updateService))
+}
+
+func handleDisablePlugin(pluginService *services.PluginService, updateService *services.PluginUpdateService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, err := pluginService.DisableJar(c.PostForm("directory"), c.PostForm("file"))
+		respondPluginAction(c, pluginService, updateService, err, "Renamed to "+name+", restart the server to unload it")
+	}
+}
+
+func handleEnablePlugin(pluginService *services.PluginService, updateService *services.PluginUpdateService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, err := pluginService.EnableJar(c.PostForm("directory"), c.PostForm("file"))
+		respondPluginAction(c, pluginService, updateService, err, "Renamed to "+name+", restart the server to load it")
+	}
+}
+
+func handleCheckPluginUpdates(pluginService *services.PluginService, updateService *services.PluginUpdateService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := updateService.CheckUpdates(c.Request.Context())
+		respondPluginAction(c, pluginService, updateService, err, fmt.Sprintf("%d updates available", report.AvailableCount()))
+	}
+}
+
+func handleStagePluginUpdate(pluginService *services.PluginService, updateService *services.PluginUpdateService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		staged, err := updateService.StageUpdate(c.Request.Context(), c.PostForm("directory"), c.PostForm("file"))
+		respondPluginAction(c, pluginService, updateService, err, "Downloaded "+staged.FileName+", restart the server to load it")
+	}
+}
+
+func handleRollbackPluginUpdate(pluginService *services.PluginService, updateService *services.PluginUpdateService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		staged, err := updateService.Rollback(c.PostForm("directory"), c.PostForm("file"))
+		respondPluginAction(c, pluginService, updateService, err, "Restored "+staged.Replaced)
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"mc-admin/internal/regions"
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func handleGetRegions(regionService *services.RegionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := getCommonPageData(c)
+		reports, err := regionService.AnalyzeWorld()
+		if err != nil {
+			data["Error"] = err.Error()
+		}
+		data["Reports"] = reports
+
+		if c.GetHeader("HX-Request") == "true" {
+			c.HTML(http.StatusOK, "regions.html", data)
+			return
+		}
+
+		data["ActiveModule"] = "regions"
+		c.HTML(http.StatusOK, "index.html", data)
+	}
+}
+
+// parseFormInt reads an optional integer form value, empty means zero
+func parseFormInt(c *gin.Context, name string) (int, error) {
+	value := c.PostForm(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q", name, value)
+	}
+	return n, nil
+}
+
+func handlePlanTrim(regionService *services.RegionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var opts regions.TrimOptions
+		var minutes int
+		fields := []struct {
+			name   string
+			target *int
+		}{
+			{"min_inhabited_minutes", &minutes},
+			{"radius", &opts.Radius},
+			{"center_x", &opts.CenterX},
+			{"center_z", &opts.CenterZ},
+		}
+		for _, field := range fields {
+			n, err := parseFormInt(c, field.name)
+			if err != nil {
+				c.HTML(http.StatusOK, "regions_trim.html", gin.H{"Error": err.Error()})
+				return
+			}
+			*field.target = n
+		}
+		opts.MinInhabited = time.Duration(minutes) * time.Minute
+
+		plan, err := regionService.PlanTrim(opts)
+		if err != nil {
+			c.HTML(http.StatusOK, "regions_trim.html", gin.H{"Error": err.Error()})
+			return
+		}
+		c.HTML(http.StatusOK, "regions_trim.html", gin.H{"Plan": plan})
+	}
+}
+
+func handleApplyTrim(regionService *services.RegionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := regionService.ApplyTrim()
+		if err != nil {
+			c.Header("HX-Trigger", utils.BuildToastTrigger("Trim failed: "+err.Error(), "error"))
+			c.HTML(http.StatusOK, "regions_trim.html", gin.H{"Error": err.Error()})
+			return
+		}
+		c.Header("HX-Trigger", utils.BuildToastTrigger(fmt.Sprintf("Deleted %d chunks", result.Chunks), "success"))
+		c.HTML(http.StatusOK, "regions_trim.html", gin.H{"Result": result})
+	}
+}
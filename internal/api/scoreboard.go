@@ -0,0 +1,154 @@
+package api
+
+import (
+	"errors"
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scoreboardPageData collects objectives, teams and the sorted score table for scoreboard.html
+func scoreboardPageData(c *gin.Context, scoreboardService *services.ScoreboardService) gin.H {
+	data := getCommonPageData(c)
+	board, err := scoreboardService.GetScoreboard()
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	sortBy := c.Query("sort")
+	descending := c.Query("order") == "desc"
+	data["Board"] = board
+	data["Rows"] = board.Rows(sortBy, descending)
+	data["SortBy"] = sortBy
+	data["Descending"] = descending
+	data["DisplaySlots"] = services.DisplaySlots
+	data["TeamColors"] = services.TeamColors
+	data["NameTagVisibilities"] = services.NameTagVisibilities
+	return data
+}
+
+func handleGetScoreboard(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := scoreboardPageData(c, scoreboardService)
+
+		if c.GetHeader("HX-Request") == "true" {
+			c.HTML(http.StatusOK, "scoreboard.html", data)
+			return
+		}
+
+		data["ActiveModule"] = "scoreboard"
+		c.HTML(http.StatusOK, "index.html", data)
+	}
+}
+
+// respondScoreboardAction re-renders the scoreboard page with a toast for the outcome of an action
+func respondScoreboardAction(c *gin.Context, scoreboardService *services.ScoreboardService, err error, success string) {
+	if err != nil {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(err.Error(), "error"))
+	} else {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(success, "success"))
+	}
+	c.HTML(http.StatusOK, "scoreboard.html", scoreboardPageData(c, scoreboardService))
+}
+
+// splitMembers accepts members separated by commas or whitespace
+func splitMembers(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+}
+
+func handleAddObjective(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := strings.TrimSpace(c.PostForm("name"))
+		err := scoreboardService.AddObjective(name, strings.TrimSpace(c.PostForm("criteria")), strings.TrimSpace(c.PostForm("display_name")))
+		respondScoreboardAction(c, scoreboardService, err, "Created objective "+name)
+	}
+}
+
+func handleRemoveObjective(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.PostForm("name")
+		err := scoreboardService.RemoveObjective(name)
+		respondScoreboardAction(c, scoreboardService, err, "Removed objective "+name)
+	}
+}
+
+func handleSetDisplay(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slot := c.PostForm("slot")
+		err := scoreboardService.SetDisplay(slot, c.PostForm("objective"))
+		respondScoreboardAction(c, scoreboardService, err, "Updated display slot "+slot)
+	}
+}
+
+func handleChangeScore(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		holder := strings.TrimSpace(c.PostForm("holder"))
+		objective := c.PostForm("objective")
+		mode := c.PostForm("mode")
+
+		var err error
+		if mode == "reset" {
+			err = scoreboardService.ResetScore(holder, objective)
+		} else {
+			value, convErr := strconv.Atoi(strings.TrimSpace(c.PostForm("value")))
+			switch {
+			case convErr != nil:
+				err = errors.New("score must be a whole number")
+			case mode == "add":
+				err = scoreboardService.AddScore(holder, objective, value)
+			default:
+				err = scoreboardService.SetScore(holder, objective, value)
+			}
+		}
+		respondScoreboardAction(c, scoreboardService, err, "Updated score of "+holder)
+	}
+}
+
+func handleAddTeam(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := strings.TrimSpace(c.PostForm("name"))
+		err := scoreboardService.AddTeam(name)
+		respondScoreboardAction(c, scoreboardService, err, "Created team "+name)
+	}
+}
+
+func handleRemoveTeam(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.PostForm("name")
+		err := scoreboardService.RemoveTeam(name)
+		respondScoreboardAction(c, scoreboardService, err, "Removed team "+name)
+	}
+}
+
+func handleConfigureTeam(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.PostForm("name")
+		err := scoreboardService.ConfigureTeam(name, services.TeamOptions{
+			Color:             c.PostForm("color"),
+			Prefix:            c.PostForm("prefix"),
+			FriendlyFire:      c.PostForm("friendly_fire") == "on",
+			NameTagVisibility: c.PostForm("nametag_visibility"),
+		})
+		respondScoreboardAction(c, scoreboardService, err, "Updated team "+name)
+	}
+}
+
+func handleJoinTeam(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		team := c.PostForm("team")
+		err := scoreboardService.JoinTeam(team, splitMembers(c.PostForm("members")))
+		respondScoreboardAction(c, scoreboardService, err, "Updated members of "+team)
+	}
+}
+
+func handleLeaveTeam(scoreboardService *services.ScoreboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := scoreboardService.LeaveTeam(splitMembers(c.PostForm("members")))
+		respondScoreboardAction(c, scoreboardService, err, "Removed from team")
+	}
+}
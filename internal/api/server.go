@@ -1,15 +1,24 @@
 package api
 
 import (
-	"mc-admin/internal/clients/ashcon"
+	"fmt"
+	"mc-admin/internal/clients/destinations"
+	"mc-admin/internal/clients/discord"
 	"mc-admin/internal/clients/files"
+	"mc-admin/internal/clients/geyser"
+	"mc-admin/internal/clients/modrinth"
+	"mc-admin/internal/clients/profiles"
 	"mc-admin/internal/clients/rcon"
+	"mc-admin/internal/clients/slp"
+	"mc-admin/internal/clients/webhook"
 	"mc-admin/internal/services"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -65,6 +74,47 @@ func initializeWebServer() *gin.Engine {
 			}
 			return strconv.FormatInt(secs, 10) + "s"
 		},
+		// formatBytes converts a byte count to a human-readable size like "1.5 MB"
+		"formatBytes": func(size int64) string {
+			const unit = 1024
+			if size < unit {
+				return strconv.FormatInt(size, 10) + " B"
+			}
+			div, exp := int64(unit), 0
+			for n := size / unit; n >= unit; n /= unit {
+				div *= unit
+				exp++
+			}
+			return strconv.FormatFloat(float64(size)/float64(div), 'f', 1, 64) + " " + string("KMGTPE"[exp]) + "B"
+		},
+		// timeAgo formats the age of a timestamp like "5m ago" or "3d ago"
+		"timeAgo": func(t time.Time) string {
+			age := time.Since(t)
+			switch {
+			case age < time.Minute:
+				return "just now"
+			case age < time.Hour:
+				return strconv.Itoa(int(age.Minutes())) + "m ago"
+			case age < 24*time.Hour:
+				return strconv.Itoa(int(age.Hours())) + "h ago"
+			}
+			return strconv.Itoa(int(age.Hours()/24)) + "d ago"
+		},
+		// timeLeft formats the time until a timestamp like "5m 10s" or "2d 4h"
+		"timeLeft": func(t time.Time) string {
+			left := time.Until(t)
+			switch {
+			case left <= 0:
+				return "expired"
+			case left < time.Minute:
+				return strconv.Itoa(int(left.Seconds())) + "s"
+			case left < time.Hour:
+				return fmt.Sprintf("%dm %ds", int(left.Minutes()), int(left.Seconds())%60)
+			case left < 24*time.Hour:
+				return fmt.Sprintf("%dh %dm", int(left.Hours()), int(left.Minutes())%60)
+			}
+			return fmt.Sprintf("%dd %dh", int(left.Hours()/24), int(left.Hours())%24)
+		},
 	})
 	r.Static("/static", "./static")
 	r.LoadHTMLGlob("templates/*")
@@ -84,7 +134,11 @@ func getCommonPageData(c *gin.Context) gin.H {
 	if serverPort == "" {
 		serverPort = "25565"
 	}
+	capabilities := currentCapabilities(c)
 	serverVersion := os.Getenv("SERVER_VERSION")
+	if serverVersion == "" && capabilities.Version != "" {
+		serverVersion = capabilities.Description()
+	}
 	if serverVersion == "" {
 		serverVersion = "Unknown Version"
 	}
@@ -103,6 +157,7 @@ func getCommonPageData(c *gin.Context) gin.H {
 		"ServerVersion":     serverVersion,
 		"ServerDescription": serverDescription,
 		"User":              user,
+		"Server":            capabilities,
 		"FilesEnabled":      minecraftDataDir != "",
 		"ActiveModule":      "world",
 	}
@@ -115,56 +170,339 @@ func getIndexPageHandler() gin.HandlerFunc {
 }
 
 type WebServerParts struct {
-	AuthController   *discordAuthController
-	ServerService    *services.ServerService
-	WhitelistService *services.WhitelistService
-	CommandService   *services.CommandService
-	FileService      *services.FileService
-	WorldService     *services.WorldService
+	AuthController      *discordAuthController
+	ServerService       *services.ServerService
+	WhitelistService    *services.WhitelistService
+	CommandService      *services.CommandService
+	FileService         *services.FileService
+	WorldService        *services.WorldService
+	BackupService       *services.BackupService
+	RegionService       *services.RegionService
+	MapService          *services.MapService
+	DatapackService     *services.DatapackService
+	PluginService       *services.PluginService
+	PluginUpdateService *services.PluginUpdateService
+	ScoreboardService   *services.ScoreboardService
+	BossbarService      *services.BossbarService
+	MessageService      *services.MessageService
+	KitService          *services.KitService
+	PerformanceService  *services.PerformanceService
+	TickService         *services.TickService
+	CapabilityService   *services.CapabilityService
+	// AccessRequestService is nil when players cannot request whitelist access
+	AccessRequestService         *services.AccessRequestService
+	AccessRequestsRequireDiscord bool
+	// DiscordSyncService is nil unless the whitelist follows a Discord role
+	DiscordSyncService        *services.DiscordSyncService
+	TemporaryWhitelistService *services.TemporaryWhitelistService
+	SkinService               *services.SkinService
+	// UserStatsService is nil without a data directory
+	UserStatsService *services.UserStatsService
+	// Floodgate is the zero value unless Bedrock players join through Geyser/Floodgate
+	Floodgate services.Floodgate
 }
 
 func initializeWebServerRoutes(r *gin.Engine, parts WebServerParts) {
+	// The access request form is public, players are not admins
+	if parts.AccessRequestService != nil {
+		r.GET("/access-request", handleGetAccessRequestForm(parts.AuthController, parts.AccessRequestsRequireDiscord))
+		r.POST("/access-request", handleSubmitAccessRequest(parts.AccessRequestService, parts.AuthController, parts.AccessRequestsRequireDiscord))
+	}
+	if parts.DiscordSyncService != nil {
+		r.GET("/link", handleGetLinkPage(parts.DiscordSyncService, parts.AuthController))
+		r.POST("/link", handleLinkPlayer(parts.DiscordSyncService, parts.AuthController))
+		r.POST("/link/unlink", handleUnlinkPlayer(parts.DiscordSyncService, parts.AuthController))
+	}
+
 	protected := r.Group("/")
 	protected.Use(parts.AuthController.RequireAuth())
+	protected.Use(RequireCapabilities(parts.CapabilityService))
 	protected.GET("/", getIndexPageHandler())
-	protected.GET("/server-info", handleGetServerInfo(parts.ServerService))
-	protected.GET("/whitelist", handleGetWhitelist(parts.WhitelistService))
+	protected.GET("/server-info", handleGetServerInfo(parts.ServerService, parts.Floodgate))
+	protected.GET("/server/software", handleGetServerSoftware())
+	protected.POST("/server/software/detect", handleDetectServerSoftware(parts.CapabilityService))
+	whitelist := whitelistPage{
+		whitelist:      parts.WhitelistService,
+		accessRequests: parts.AccessRequestService,
+		discordSync:    parts.DiscordSyncService,
+		temporary:      parts.TemporaryWhitelistService,
+	}
+	protected.GET("/whitelist", handleGetWhitelist(whitelist))
 	protected.POST("/whitelist/toggle", handleToggleWhitelist(parts.WhitelistService))
 	protected.POST("/whitelist/player", handleAddNameToWhitelist(parts.WhitelistService))
-	protected.DELETE("/whitelist/player/:name", handleRemoveNameFromWhitelist(parts.WhitelistService))
+	protected.DELETE("/whitelist/player/:name", handleRemoveNameFromWhitelist(parts.WhitelistService, parts.TemporaryWhitelistService))
+	protected.DELETE("/whitelist/uuid/:uuid", handleRemoveUUIDFromWhitelist(parts.WhitelistService, parts.TemporaryWhitelistService))
+	protected.GET("/whitelist/export", handleExportWhitelist(parts.WhitelistService))
+	protected.POST("/whitelist/import", handleStartWhitelistImport(parts.WhitelistService))
+	protected.GET("/whitelist/import/status", handleGetWhitelistImport(parts.WhitelistService))
+	protected.POST("/whitelist/import/apply", handleApplyWhitelistImport(whitelist))
+	protected.POST("/whitelist/import/discard", handleDiscardWhitelistImport(parts.WhitelistService))
+	protected.POST("/whitelist/guests", handleAddGuest(whitelist))
+	protected.POST("/whitelist/guests/revoke", handleRevokeGuest(whitelist))
+	protected.POST("/whitelist/guests/permanent", handleMakeGuestPermanent(whitelist))
+	if parts.AccessRequestService != nil {
+		protected.POST("/access-requests/approve", handleApproveAccessRequest(whitelist))
+		protected.POST("/access-requests/deny", handleDenyAccessRequest(whitelist))
+	}
+	if parts.DiscordSyncService != nil {
+		protected.POST("/discord-links/sync", handleSyncDiscordLinks(whitelist))
+		protected.POST("/discord-links/approve", handleApproveDiscordLink(whitelist))
+		protected.POST("/discord-links/remove", handleRemoveDiscordLink(whitelist))
+	}
+	protected.GET("/skins/:player/head", handleGetPlayerHead(parts.SkinService))
+	protected.GET("/skins/:player/body", handleGetPlayerBody(parts.SkinService))
 	protected.GET("/world/stats", handleGetWorldStats(parts.WorldService))
-	protected.GET("/users/stats", handleGetUserStats())             // New endpoint for user stats
-	protected.GET("/users/stats/:uuid", handleGetUserStatsByUUID()) // New endpoint for user stats by UUID
+	protected.GET("/users/stats", handleGetUserStats(parts.UserStatsService))
+	protected.GET("/users/stats/:uuid", handleGetUserStatsByUUID(parts.UserStatsService))
 	protected.GET("/world/clock", handleGetClock(parts.WorldService))
 	protected.GET("/world/clock/edit", handleGetClockEdit(parts.WorldService))
 	protected.POST("/world/time", handleSetTime(parts.WorldService))
 	protected.POST("/world/difficulty", handleSetDifficulty(parts.WorldService))
 	protected.POST("/world/weather", handleSetWeather(parts.WorldService))
+	protected.GET("/world/performance", handleGetPerformance(parts.PerformanceService))
+	protected.GET("/world/tick", handleGetTick(parts.TickService))
+	protected.POST("/world/tick/freeze", handleFreezeTick(parts.TickService))
+	protected.POST("/world/tick/unfreeze", handleUnfreezeTick(parts.TickService))
+	protected.POST("/world/tick/rate", handleSetTickRate(parts.TickService))
+	protected.POST("/world/tick/step", handleStepTick(parts.TickService))
+	protected.POST("/world/tick/sprint", handleSprintTick(parts.TickService))
+	protected.GET("/world/bossbars", handleGetBossbars(parts.BossbarService))
+	protected.POST("/world/bossbars", handleCreateBossbar(parts.BossbarService))
+	protected.POST("/world/bossbars/update", handleUpdateBossbar(parts.BossbarService))
+	protected.POST("/world/bossbars/remove", handleRemoveBossbar(parts.BossbarService))
+	protected.POST("/world/bossbars/countdown", handleStartCountdown(parts.BossbarService))
+	protected.POST("/world/bossbars/countdown/stop", handleStopCountdown(parts.BossbarService))
 	protected.GET("/players/:name/kick", handleGetKickPlayerDialog())
 	protected.POST("/players/:name/kick", handleKickPlayer(parts.ServerService))
+	protected.GET("/players/:name/actions", handleGetPlayerActionsDialog(parts.ServerService))
+	protected.POST("/players/:name/gamemode", handleSetGameMode(parts.ServerService))
+	protected.POST("/players/:name/teleport", handleTeleportPlayer(parts.ServerService))
+	protected.POST("/players/:name/effects", handlePlayerEffects(parts.ServerService))
+	protected.POST("/players/:name/give", handleGiveItem(parts.ServerService))
 	protected.GET("/rcon", handleGetCommandConsole())
 	protected.POST("/commands/execute", handleExecuteRawCommand(parts.CommandService))
+	protected.GET("/messages", handleGetMessages(parts.MessageService))
+	protected.POST("/messages/compose", handleComposeMessage(parts.MessageService))
+	protected.POST("/messages/preview", handlePreviewMessage(parts.MessageService))
+	protected.POST("/messages/send", handleSendMessage(parts.MessageService))
+	protected.GET("/kits", handleGetKits(parts.KitService, parts.ServerService))
+	protected.POST("/kits", handleSaveKit(parts.KitService, parts.ServerService))
+	protected.POST("/kits/delete", handleDeleteKit(parts.KitService, parts.ServerService))
+	protected.POST("/kits/give", handleGiveKit(parts.KitService, parts.ServerService))
+	protected.POST("/kits/cooldowns/reset", handleResetKitCooldowns(parts.KitService, parts.ServerService))
 	protected.GET("/files", handleGetFiles(parts.FileService))
 	protected.GET("/files/content", handleGetFileContent(parts.FileService))
 	protected.GET("/files/download", handleDownloadFile(parts.FileService))
 	protected.POST("/files/create", handleCreateFile(parts.FileService))
 	protected.POST("/files/upload", handleUploadFile(parts.FileService))
 	protected.DELETE("/files/delete", handleDeleteFile(parts.FileService))
+	protected.GET("/backups", handleGetBackups(parts.BackupService))
+	protected.GET("/backups/contents", handleGetBackupContents(parts.BackupService))
+	protected.GET("/backups/download", handleDownloadBackup(parts.BackupService))
+	protected.GET("/backups/restore", handleGetRestoreBackupDialog(parts.BackupService))
+	protected.POST("/backups/restore", handleRestoreBackup(parts.BackupService))
+	protected.GET("/backups/restore/status", handleGetRestoreStatus(parts.BackupService))
+	protected.POST("/backups/upload", handleUploadBackup(parts.BackupService))
+	protected.GET("/backups/uploads", handleGetBackupUploads(parts.BackupService))
+
+	protected.GET("/regions", handleGetRegions(parts.RegionService))
+	protected.POST("/regions/trim/plan", handlePlanTrim(parts.RegionService))
+	protected.POST("/regions/trim/apply", handleApplyTrim(parts.RegionService))
+
+	protected.GET("/map", handleGetMap(parts.MapService))
+	protected.GET("/map/tiles/:dimension/:zoom/:x/:y", handleGetMapTile(parts.MapService))
+	protected.GET("/map/markers", handleGetMapMarkers(parts.MapService))
+
+	protected.GET("/datapacks", handleGetDatapacks(parts.DatapackService))
+	protected.POST("/datapacks/enable", handleEnableDatapack(parts.DatapackService))
+	protected.POST("/datapacks/disable", handleDisableDatapack(parts.DatapackService))
+	protected.POST("/datapacks/move", handleMoveDatapack(parts.DatapackService))
+	protected.POST("/datapacks/upload", handleUploadDatapack(parts.DatapackService))
+
+	protected.GET("/plugins", handleGetPlugins(parts.PluginService, parts.PluginUpdateService))
+	protected.POST("/plugins/disable", handleDisablePlugin(parts.PluginService, parts.PluginUpdateService))
+	protected.POST("/plugins/enable", handleEnablePlugin(parts.PluginService, parts.PluginUpdateService))
+	protected.POST("/plugins/updates/check", handleCheckPluginUpdates(parts.PluginService, parts.PluginUpdateService))
+	protected.POST("/plugins/updates/stage", handleStagePluginUpdate(parts.PluginService, parts.PluginUpdateService))
+	protected.POST("/plugins/updates/rollback", handleRollbackPluginUpdate(parts.PluginService, parts.PluginUpdateService))
+
+	protected.GET("/scoreboard", handleGetScoreboard(parts.ScoreboardService))
+	protected.POST("/scoreboard/objectives", handleAddObjective(parts.ScoreboardService))
+	protected.POST("/scoreboard/objectives/remove", handleRemoveObjective(parts.ScoreboardService))
+	protected.POST("/scoreboard/display", handleSetDisplay(parts.ScoreboardService))
+	protected.POST("/scoreboard/scores", handleChangeScore(parts.ScoreboardService))
+	protected.POST("/scoreboard/teams", handleAddTeam(parts.ScoreboardService))
+	protected.POST("/scoreboard/teams/remove", handleRemoveTeam(parts.ScoreboardService))
+	protected.POST("/scoreboard/teams/configure", handleConfigureTeam(parts.ScoreboardService))
+	protected.POST("/scoreboard/teams/join", handleJoinTeam(parts.ScoreboardService))
+	protected.POST("/scoreboard/teams/leave", handleLeaveTeam(parts.ScoreboardService))
+}
+
+// serverListPingAddress is the game port of the server, which usually runs next to RCON
+func serverListPingAddress() string {
+	if address := os.Getenv("SERVER_LIST_PING_ADDRESS"); address != "" {
+		return address
+	}
+	host := os.Getenv("RCON_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port := os.Getenv("GAME_PORT")
+	if port == "" {
+		port = "25565"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// trustedProxiesFromEnv lists the proxies whose X-Forwarded-For header is believed. None by default,
+// otherwise anyone could pick the client address the public pages rate limit by.
+func trustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// newAccessRequestServiceFromEnv returns nil unless ENABLE_ACCESS_REQUESTS is set
+func newAccessRequestServiceFromEnv(whitelistService *services.WhitelistService, authConfig AuthConfig) (*services.AccessRequestService, bool, error) {
+	if os.Getenv("ENABLE_ACCESS_REQUESTS") != "true" {
+		return nil, false, nil
+	}
+	requireDiscord := os.Getenv("ACCESS_REQUEST_REQUIRE_DISCORD") == "true"
+	if requireDiscord && !authConfig.Enabled {
+		return nil, false, fmt.Errorf("ACCESS_REQUEST_REQUIRE_DISCORD needs Discord authentication to be enabled")
+	}
+
+	var notifier services.AccessRequestWebhook
+	if webhookURL := os.Getenv("ACCESS_REQUEST_WEBHOOK_URL"); webhookURL != "" {
+		client, err := webhook.NewClient(webhookURL)
+		if err != nil {
+			return nil, false, fmt.Errorf("ACCESS_REQUEST_WEBHOOK_URL: %w", err)
+		}
+		notifier = client
+	}
+
+	stateFile := os.Getenv("ACCESS_REQUEST_STATE_FILE")
+	if stateFile == "" {
+		stateFile = "access_requests.json"
+	}
+	return services.NewAccessRequestService(whitelistService, notifier, stateFile), requireDiscord, nil
+}
+
+// newDiscordSyncServiceFromEnv returns nil unless ENABLE_DISCORD_ROLE_SYNC is set
+func newDiscordSyncServiceFromEnv(whitelistService *services.WhitelistService, authConfig AuthConfig) (*services.DiscordSyncService, time.Duration, error) {
+	if os.Getenv("ENABLE_DISCORD_ROLE_SYNC") != "true" {
+		return nil, 0, nil
+	}
+	if !authConfig.Enabled {
+		return nil, 0, fmt.Errorf("ENABLE_DISCORD_ROLE_SYNC needs Discord authentication to be enabled")
+	}
+	guildID := os.Getenv("DISCORD_GUILD_ID")
+	roleID := os.Getenv("DISCORD_WHITELIST_ROLE_ID")
+	if guildID == "" || roleID == "" {
+		return nil, 0, fmt.Errorf("ENABLE_DISCORD_ROLE_SYNC needs DISCORD_GUILD_ID and DISCORD_WHITELIST_ROLE_ID")
+	}
+	interval := 5 * time.Minute
+	if intervalStr := os.Getenv("DISCORD_SYNC_INTERVAL"); intervalStr != "" {
+		var err error
+		interval, err = time.ParseDuration(intervalStr)
+		if err != nil || interval < time.Minute {
+			return nil, 0, fmt.Errorf("DISCORD_SYNC_INTERVAL must be a duration of at least 1m")
+		}
+	}
+	client, err := discord.NewClient(discord.Config{BaseURL: authConfig.APIURL, BotToken: os.Getenv("DISCORD_BOT_TOKEN")})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stateFile := os.Getenv("DISCORD_LINK_STATE_FILE")
+	if stateFile == "" {
+		stateFile = "discord_links.json"
+	}
+	return services.NewDiscordSyncService(client, whitelistService, guildID, roleID, stateFile), interval, nil
+}
+
+// newFloodgateFromEnv detects Floodgate in the data directory unless ENABLE_FLOODGATE turns it on
+// or off. FLOODGATE_PREFIX and FLOODGATE_WHITELIST_COMMAND override what was detected.
+func newFloodgateFromEnv(fileClient services.CapabilityFileSystemAccessor) (services.Floodgate, services.XUIDResolver, error) {
+	enabled := strings.ToLower(os.Getenv("ENABLE_FLOODGATE"))
+	if enabled == "false" {
+		return services.Floodgate{}, nil, nil
+	}
+	var floodgate services.Floodgate
+	detected := false
+	if fileClient != nil {
+		var err error
+		if floodgate, detected, err = services.DetectFloodgate(fileClient); err != nil {
+			return services.Floodgate{}, nil, err
+		}
+	}
+	if !detected && enabled != "true" {
+		return services.Floodgate{}, nil, nil
+	}
+	prefix := services.DefaultFloodgatePrefix
+	if detected {
+		prefix = floodgate.Prefix
+	}
+	if value := os.Getenv("FLOODGATE_PREFIX"); value != "" {
+		prefix = value
+	}
+	whitelistCommand := floodgate.WhitelistCommand
+	if value := os.Getenv("FLOODGATE_WHITELIST_COMMAND"); value != "" {
+		whitelistCommand = strings.ToLower(value) == "true"
+	}
+	floodgate, err := services.NewFloodgate(prefix, whitelistCommand)
+	if err != nil {
+		return services.Floodgate{}, nil, fmt.Errorf("FLOODGATE_PREFIX: %w", err)
+	}
+	client, err := geyser.NewClient(geyser.Config{BaseURL: os.Getenv("GEYSER_API_URL")})
+	if err != nil {
+		return services.Floodgate{}, nil, err
+	}
+	return floodgate, client, nil
+}
+
+// newSkinServiceFromEnv looks up Java skins on the session server given by MOJANG_SESSION_URL and
+// downloads textures from SKIN_TEXTURE_URL. Names are resolved with the profile resolver if
+// username checks are on, otherwise only players in the usercache.json are found.
+func newSkinServiceFromEnv(resolver profiles.Resolver, bedrock services.BedrockSkinLookup, minecraftDataDir string) (*services.SkinService, error) {
+	mojang, err := profiles.NewMojangProvider(profiles.MojangConfig{SessionURL: os.Getenv("MOJANG_SESSION_URL")})
+	if err != nil {
+		return nil, err
+	}
+	textures, err := profiles.NewTextureClient(profiles.TextureConfig{BaseURL: os.Getenv("SKIN_TEXTURE_URL")})
+	if err != nil {
+		return nil, err
+	}
+	if resolver == nil && minecraftDataDir != "" {
+		userCache := profiles.NewUserCacheProvider(filepath.Join(minecraftDataDir, "usercache.json"))
+		resolver = profiles.NewChainResolver([]profiles.Provider{userCache}, profiles.DefaultCacheTTL, profiles.DefaultNegativeCacheTTL)
+	}
+	cacheDir := os.Getenv("SKIN_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = "skin-cache"
+	}
+	return services.NewSkinService(mojang, bedrock, textures, resolver, cacheDir), nil
 }
 
 type WebServerOptions struct {
 	MinecraftRconClient rcon.CommandExecutor
-	AshconClient        ashcon.MojangUserNameChecker
+	ProfileResolver     profiles.Resolver
 	AuthConfig          AuthConfig
 }
 
 func InitializeWebServer(options WebServerOptions) (*gin.Engine, error) {
 	r := initializeWebServer()
+	if err := r.SetTrustedProxies(trustedProxiesFromEnv()); err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
 	authController, err := ConfigureDiscordAuth(r, options.AuthConfig)
 	if err != nil {
 		return nil, err
 	}
-	serverService := services.NewServerServiceFromRconClient(options.MinecraftRconClient)
 	commandService := services.NewCommandServiceFromRconClient(options.MinecraftRconClient)
 
 	maxDisplaySizeStr := os.Getenv("MAX_FILE_DISPLAY_SIZE")
@@ -178,17 +516,139 @@ func InitializeWebServer(options WebServerOptions) (*gin.Engine, error) {
 		fileClient = files.NewMinecraftFilesClient(minecraftDataDir, maxDisplaySize)
 	}
 
-	whitelistService := services.NewWhitelistService(options.MinecraftRconClient, options.AshconClient, &fileClient)
+	serverService := services.NewServerServiceFromRconClient(options.MinecraftRconClient)
+	var userStatsService *services.UserStatsService
+	if minecraftDataDir != "" {
+		serverService = services.NewServerService(options.MinecraftRconClient, &fileClient)
+		if userStatsService, err = services.NewUserStatsService(minecraftDataDir); err != nil {
+			return nil, err
+		}
+	}
+	whitelistService := services.NewWhitelistService(options.MinecraftRconClient, options.ProfileResolver, &fileClient)
 	fileService := services.NewFileService(&fileClient)
 	worldService := services.NewWorldService(options.MinecraftRconClient)
 
+	backupDir := os.Getenv("BACKUP_DIR")
+	if backupDir == "" {
+		backupDir = "backups"
+	}
+	uploadTargets, err := destinations.BuildDestinationsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	retention := 0
+	if retentionStr := os.Getenv("BACKUP_REMOTE_RETENTION"); retentionStr != "" {
+		retention, err = strconv.Atoi(retentionStr)
+		if err != nil || retention < 0 {
+			return nil, fmt.Errorf("BACKUP_REMOTE_RETENTION must be a non-negative integer")
+		}
+	}
+	backupService := services.NewBackupService(options.MinecraftRconClient, &fileClient, backupDir, uploadTargets, retention)
+
+	mapCacheDir := os.Getenv("MAP_CACHE_DIR")
+	if mapCacheDir == "" {
+		mapCacheDir = "map-cache"
+	}
+
+	bossbarStateFile := os.Getenv("BOSSBAR_STATE_FILE")
+	if bossbarStateFile == "" {
+		bossbarStateFile = "bossbars.json"
+	}
+	bossbarService := services.NewBossbarService(options.MinecraftRconClient, &fileClient, bossbarStateFile)
+	bossbarService.StartCountdowns(time.Second)
+
+	// the data directory is inspected for server jars and configuration files when it is mounted
+	var capabilityFiles services.CapabilityFileSystemAccessor
+	if minecraftDataDir != "" {
+		capabilityFiles = &fileClient
+	}
+	capabilityService := services.NewCapabilityService(options.MinecraftRconClient, capabilityFiles, slp.NewClient(serverListPingAddress()))
+
+	// the log fallback needs the data directory
+	var performanceFiles services.PerformanceFileSystemAccessor
+	if minecraftDataDir != "" {
+		performanceFiles = &fileClient
+	}
+	performanceService := services.NewPerformanceService(options.MinecraftRconClient, performanceFiles, capabilityService, performanceHistorySize)
+	performanceService.StartSampling(performanceSampleInterval)
+
+	floodgate, xuids, err := newFloodgateFromEnv(capabilityFiles)
+	if err != nil {
+		return nil, err
+	}
+	whitelistService.UseFloodgate(floodgate, xuids)
+
+	// the Global API client behind xuids also knows the skins of Bedrock players
+	bedrockSkins, _ := xuids.(services.BedrockSkinLookup)
+	skinService, err := newSkinServiceFromEnv(options.ProfileResolver, bedrockSkins, minecraftDataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	modrinthClient, err := modrinth.NewClient(modrinth.Config{BaseURL: os.Getenv("MODRINTH_API_URL")})
+	if err != nil {
+		return nil, err
+	}
+	pluginService := services.NewPluginService(&fileClient)
+
+	kitStateFile := os.Getenv("KIT_STATE_FILE")
+	if kitStateFile == "" {
+		kitStateFile = "kits.json"
+	}
+
+	pluginUpdateStateFile := os.Getenv("PLUGIN_UPDATE_STATE_FILE")
+	if pluginUpdateStateFile == "" {
+		pluginUpdateStateFile = "plugin_updates.json"
+	}
+
+	accessRequestService, requireDiscord, err := newAccessRequestServiceFromEnv(whitelistService, options.AuthConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	temporaryWhitelistStateFile := os.Getenv("TEMPORARY_WHITELIST_STATE_FILE")
+	if temporaryWhitelistStateFile == "" {
+		temporaryWhitelistStateFile = "temporary_whitelist.json"
+	}
+	temporaryWhitelistService := services.NewTemporaryWhitelistService(whitelistService, serverService, temporaryWhitelistStateFile)
+	temporaryWhitelistService.StartReaper(temporaryWhitelistReapInterval)
+
+	discordSyncService, discordSyncInterval, err := newDiscordSyncServiceFromEnv(whitelistService, options.AuthConfig)
+	if err != nil {
+		return nil, err
+	}
+	if discordSyncService != nil {
+		discordSyncService.StartReconcile(discordSyncInterval)
+	}
+
 	parts := WebServerParts{
-		AuthController:   authController,
-		ServerService:    serverService,
-		WhitelistService: whitelistService,
-		CommandService:   commandService,
-		FileService:      fileService,
-		WorldService:     worldService,
+		AuthController:      authController,
+		ServerService:       serverService,
+		WhitelistService:    whitelistService,
+		CommandService:      commandService,
+		FileService:         fileService,
+		WorldService:        worldService,
+		BackupService:       backupService,
+		RegionService:       services.NewRegionService(options.MinecraftRconClient, &fileClient),
+		MapService:          services.NewMapService(options.MinecraftRconClient, &fileClient, mapCacheDir),
+		DatapackService:     services.NewDatapackService(options.MinecraftRconClient, &fileClient),
+		PluginService:       pluginService,
+		PluginUpdateService: services.NewPluginUpdateService(pluginService, &fileClient, modrinthClient, capabilityService, pluginUpdateStateFile),
+		ScoreboardService:   services.NewScoreboardService(options.MinecraftRconClient, &fileClient),
+		BossbarService:      bossbarService,
+		MessageService:      services.NewMessageService(options.MinecraftRconClient, &fileClient),
+		KitService:          services.NewKitService(options.MinecraftRconClient, serverService, kitStateFile),
+		PerformanceService:  performanceService,
+		TickService:         services.NewTickService(options.MinecraftRconClient),
+		CapabilityService:   capabilityService,
+
+		AccessRequestService:         accessRequestService,
+		AccessRequestsRequireDiscord: requireDiscord,
+		DiscordSyncService:           discordSyncService,
+		TemporaryWhitelistService:    temporaryWhitelistService,
+		SkinService:                  skinService,
+		UserStatsService:             userStatsService,
+		Floodgate:                    floodgate,
 	}
 
 	initializeWebServerRoutes(r, parts)
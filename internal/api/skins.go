@@ -0,0 +1,50 @@
+package api
+
+import (
+	"mc-admin/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHeadSize  = 32
+	defaultBodyScale = 4
+)
+
+// handleGetPlayerHead serves the head of a player, addressed by UUID or name
+func handleGetPlayerHead(skinService *services.SkinService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(defaultHeadSize)))
+		if err != nil {
+			c.String(http.StatusBadRequest, "Invalid size")
+			return
+		}
+		path, err := skinService.HeadPath(c.Request.Context(), c.Param("player"), size)
+		serveSkinRender(c, path, err)
+	}
+}
+
+// handleGetPlayerBody serves the front view of a player, addressed by UUID or name
+func handleGetPlayerBody(skinService *services.SkinService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scale, err := strconv.Atoi(c.DefaultQuery("scale", strconv.Itoa(defaultBodyScale)))
+		if err != nil {
+			c.String(http.StatusBadRequest, "Invalid scale")
+			return
+		}
+		path, err := skinService.BodyPath(c.Request.Context(), c.Param("player"), scale)
+		serveSkinRender(c, path, err)
+	}
+}
+
+func serveSkinRender(c *gin.Context, path string, err error) {
+	if err != nil {
+		c.String(http.StatusBadRequest, "Error: "+err.Error())
+		return
+	}
+	// skins are looked up again after a few hours, a changed skin may take that long to show
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.File(path)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAutoUnfreezeMinutes prefills the auto-unfreeze timeout of the freeze form
+const defaultAutoUnfreezeMinutes = 30
+
+func tickPageData(tickService *services.TickService) gin.H {
+	data := gin.H{
+		"MinRate":             services.MinTickRate,
+		"MaxRate":             services.MaxTickRate,
+		"AutoUnfreezeMinutes": defaultAutoUnfreezeMinutes,
+	}
+	state, err := tickService.GetState()
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	data["State"] = state
+	return data
+}
+
+func handleGetTick(tickService *services.TickService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(http.StatusOK, "tick.html", tickPageData(tickService))
+	}
+}
+
+// respondTickAction re-renders the tick panel with a toast for the outcome of an action
+func respondTickAction(c *gin.Context, tickService *services.TickService, err error, success string) {
+	if err != nil {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(err.Error(), "error"))
+	} else {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(success, "success"))
+	}
+	c.HTML(http.StatusOK, "tick.html", tickPageData(tickService))
+}
+
+// positiveTicksFromForm reads a whole number of ticks
+func positiveTicksFromForm(c *gin.Context, field string) (int, error) {
+	ticks, err := strconv.Atoi(strings.TrimSpace(c.PostForm(field)))
+	if err != nil {
+		return 0, errors.New(field + " must be a whole number of ticks")
+	}
+	return ticks, nil
+}
+
+func handleFreezeTick(tickService *services.TickService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		minutes, err := strconv.Atoi(strings.TrimSpace(c.PostForm("timeout")))
+		if err != nil {
+			err = errors.New("auto-unfreeze timeout must be a whole number of minutes")
+		} else {
+			err = tickService.Freeze(time.Duration(minutes) * time.Minute)
+		}
+		respondTickAction(c, tickService, err, "Game frozen")
+	}
+}
+
+func handleUnfreezeTick(tickService *services.TickService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondTickAction(c, tickService, tickService.Unfreeze(), "Game unfrozen")
+	}
+}
+
+func handleSetTickRate(tickService *services.TickService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rate, err := strconv.ParseFloat(strings.TrimSpace(c.PostForm("rate")), 64)
+		if err != nil {
+			err = errors.New("tick rate must be a number")
+		} else {
+			err = tickService.SetRate(rate)
+		}
+		respondTickAction(c, tickService, err, "Tick rate set to "+c.PostForm("rate"))
+	}
+}
+
+func handleStepTick(tickService *services.TickService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticks, err := positiveTicksFromForm(c, "steps")
+		if err == nil {
+			err = tickService.Step(ticks)
+		}
+		respondTickAction(c, tickService, err, "Stepping "+strconv.Itoa(ticks)+" tick(s)")
+	}
+}
+
+func handleSprintTick(tickService *services.TickService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticks, err := positiveTicksFromForm(c, "ticks")
+		if err == nil {
+			err = tickService.Sprint(ticks)
+		}
+		respondTickAction(c, tickService, err, "Sprinting "+strconv.Itoa(ticks)+" ticks")
+	}
+}
@@ -3,26 +3,21 @@ package api
 import (
 	"encoding/json"
 	"net/http"
-	"os"
 
 	"mc-admin/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// handleGetUserStats renders a user stats overview using the UserStatsService.
-func handleGetUserStats() gin.HandlerFunc {
+// handleGetUserStats renders a user stats overview using the UserStatsService, which is nil
+// without a data directory.
+func handleGetUserStats(userStatsService *services.UserStatsService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := CurrentUser(c)
 
-		minecraftDataDir := os.Getenv("MINECRAFT_DATA_DIR")
 		var stats []*services.PlayerStats
-		if minecraftDataDir != "" {
-			svc, err := services.NewUserStatsService(minecraftDataDir)
-			if err == nil {
-				ps, _ := svc.GetAllPlayerStats()
-				stats = ps
-			}
+		if userStatsService != nil {
+			stats, _ = userStatsService.GetAllPlayerStats()
 		}
 
 		// marshal stats to JSON for client-side rendering
@@ -52,20 +47,14 @@ func handleGetUserStats() gin.HandlerFunc {
 }
 
 // handleGetUserStatsByUUID returns a server-rendered partial for a single player's stats.
-func handleGetUserStatsByUUID() gin.HandlerFunc {
+func handleGetUserStatsByUUID(userStatsService *services.UserStatsService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uuid := c.Param("uuid")
-		minecraftDataDir := os.Getenv("MINECRAFT_DATA_DIR")
-		if minecraftDataDir == "" {
+		if userStatsService == nil {
 			c.String(http.StatusInternalServerError, "MINECRAFT_DATA_DIR not configured")
 			return
 		}
-		svc, err := services.NewUserStatsService(minecraftDataDir)
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Error initializing stats service: %v", err)
-			return
-		}
-		ps, err := svc.GetStatsForUUID(uuid)
+		ps, err := userStatsService.GetStatsForUUID(uuid)
 		if err != nil {
 			// If not found and this is an HTMX request, return 404 text; otherwise render full page
 			if c.GetHeader("HX-Request") == "true" {
@@ -88,10 +77,8 @@ func handleGetUserStatsByUUID() gin.HandlerFunc {
 		data := getCommonPageData(c)
 		data["ActiveModule"] = "users"
 		// include stats list so the user list shows
-		if svcAll, err := services.NewUserStatsService(minecraftDataDir); err == nil {
-			if alls, err2 := svcAll.GetAllPlayerStats(); err2 == nil {
-				data["Stats"] = alls
-			}
+		if alls, err := userStatsService.GetAllPlayerStats(); err == nil {
+			data["Stats"] = alls
 		}
 		data["InitialUUID"] = uuid
 		c.HTML(http.StatusOK, "index.html", data)
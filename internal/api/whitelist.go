@@ -1,15 +1,28 @@
 package api
 
 import (
+	"fmt"
+	"io"
 	"mc-admin/internal/services"
+	"mc-admin/internal/utils"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func handleGetWhitelist(whitelistService *services.WhitelistService) gin.HandlerFunc {
+// whitelistPage holds the services shown on the whitelist page. accessRequests and discordSync are
+// nil when those features are disabled.
+type whitelistPage struct {
+	whitelist      *services.WhitelistService
+	accessRequests *services.AccessRequestService
+	discordSync    *services.DiscordSyncService
+	temporary      *services.TemporaryWhitelistService
+}
+
+func handleGetWhitelist(page whitelistPage) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		whitelistInfo, err := whitelistService.GetWhitelistInfo()
+		whitelistInfo, err := page.whitelist.GetWhitelistInfo()
 		if err != nil {
 			c.HTML(http.StatusOK, "error.html", gin.H{
 				"DetailedError": err.Error(),
@@ -18,30 +31,120 @@ func handleGetWhitelist(whitelistService *services.WhitelistService) gin.Handler
 		}
 
 		if c.GetHeader("HX-Request") == "true" {
-			c.HTML(http.StatusOK, "whitelist.html", gin.H{
-				"Players": whitelistInfo.PlayerNames,
-				"Count":   len(whitelistInfo.PlayerNames),
-				"Enabled": whitelistInfo.Enabled,
-			})
+			c.HTML(http.StatusOK, "whitelist.html", page.data(whitelistInfo, gin.H{}))
 			return
 		}
 
-		data := getCommonPageData(c)
+		data := page.data(whitelistInfo, getCommonPageData(c))
 		data["ActiveModule"] = "whitelist"
-		data["Players"] = whitelistInfo.PlayerNames
-		data["Count"] = len(whitelistInfo.PlayerNames)
-		data["Enabled"] = whitelistInfo.Enabled
 		c.HTML(http.StatusOK, "index.html", data)
 	}
 }
 
+func (page whitelistPage) data(whitelistInfo services.WhitelistInfo, data gin.H) gin.H {
+	data["Players"] = whitelistInfo.Entries
+	data["Count"] = len(whitelistInfo.Entries)
+	data["Enabled"] = whitelistInfo.Enabled
+	data["OnlineMode"] = whitelistInfo.OnlineMode
+	data["FromFile"] = whitelistInfo.FromFile
+	data["BedrockPrefix"] = whitelistInfo.BedrockPrefix
+	data["Import"] = page.whitelist.GetWhitelistImport()
+	data["Formats"] = services.WhitelistFormats
+
+	// Guests are matched to whitelist entries by name for the countdowns in the player list
+	guests, err := page.temporary.Entries()
+	if err != nil {
+		data["GuestsError"] = err.Error()
+	}
+	guestsByName := map[string]*services.TemporaryWhitelistEntry{}
+	for i := range guests {
+		for _, entry := range whitelistInfo.Entries {
+			if strings.EqualFold(entry.Name, guests[i].PlayerName) {
+				guestsByName[entry.Name] = &guests[i]
+			}
+		}
+	}
+	data["Guests"] = guests
+	data["GuestsByName"] = guestsByName
+	data["GuestDurations"] = guestDurations
+
+	data["AccessRequestsEnabled"] = page.accessRequests != nil
+	if page.accessRequests != nil {
+		pending, err := page.accessRequests.Pending()
+		if err != nil {
+			data["AccessRequestsError"] = err.Error()
+		}
+		data["PendingRequests"] = pending
+		data["ReviewedRequests"], _ = page.accessRequests.Reviewed(recentAccessRequests)
+	}
+	data["DiscordSyncEnabled"] = page.discordSync != nil
+	if page.discordSync != nil {
+		links, err := page.discordSync.Links()
+		if err != nil {
+			data["DiscordLinksError"] = err.Error()
+		}
+		data["DiscordLinks"] = links
+		data["DiscordLastSync"], _ = page.discordSync.LastSync()
+	}
+	return data
+}
+
+// render re-renders the whitelist partial after an action
+func (page whitelistPage) render(c *gin.Context) {
+	whitelistInfo, err := page.whitelist.GetWhitelistInfo()
+	if err != nil {
+		c.HTML(http.StatusOK, "error.html", gin.H{
+			"DetailedError": err.Error(),
+		})
+		return
+	}
+	c.HTML(http.StatusOK, "whitelist.html", page.data(whitelistInfo, gin.H{}))
+}
+
+// triggerActionToast reports the outcome of an action on the whitelist page
+func triggerActionToast(c *gin.Context, err error, success string) {
+	if err != nil {
+		c.Header("HX-Trigger", utils.BuildToastTrigger("Error: "+err.Error(), "error"))
+	} else {
+		c.Header("HX-Trigger", utils.BuildToastTrigger(success, "success"))
+	}
+}
+
 // get name from path parameter and remove from whitelist
-func handleRemoveNameFromWhitelist(whitelistService *services.WhitelistService) gin.HandlerFunc {
+func handleRemoveNameFromWhitelist(whitelistService *services.WhitelistService, temporaryService *services.TemporaryWhitelistService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		name := c.Param("name")
 		err := whitelistService.RemoveNameFromWhitelist(name)
 		if err != nil {
-			c.HTML(http.StatusOK, "error.html", nil)
+			c.HTML(http.StatusOK, "error.html", gin.H{
+				"DetailedError": err.Error(),
+			})
+			return
+		}
+		if err := temporaryService.Forget(name); err != nil {
+			c.HTML(http.StatusOK, "error.html", gin.H{
+				"DetailedError": err.Error(),
+			})
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/whitelist")
+	}
+}
+
+// get uuid from path parameter and remove that profile from whitelist.json
+func handleRemoveUUIDFromWhitelist(whitelistService *services.WhitelistService, temporaryService *services.TemporaryWhitelistService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry, err := whitelistService.RemoveUUIDFromWhitelist(c.Param("uuid"))
+		if err != nil {
+			c.HTML(http.StatusOK, "error.html", gin.H{
+				"DetailedError": err.Error(),
+			})
+			return
+		}
+		if err := temporaryService.Forget(entry.Name); err != nil {
+			c.HTML(http.StatusOK, "error.html", gin.H{
+				"DetailedError": err.Error(),
+			})
 			return
 		}
 		c.Redirect(http.StatusSeeOther, "/whitelist")
@@ -53,7 +156,9 @@ func handleAddNameToWhitelist(whitelistService *services.WhitelistService) gin.H
 		name := c.PostForm("playerName")
 		err := whitelistService.AddNameToWhitelist(name)
 		if err != nil {
-			c.HTML(http.StatusOK, "error.html", nil)
+			c.HTML(http.StatusOK, "error.html", gin.H{
+				"DetailedError": err.Error(),
+			})
 			return
 		}
 		c.Redirect(http.StatusSeeOther, "/whitelist")
@@ -86,3 +191,88 @@ func handleToggleWhitelist(whitelistService *services.WhitelistService) gin.Hand
 		c.Redirect(http.StatusSeeOther, "/whitelist")
 	}
 }
+
+// maxWhitelistImportSize bounds uploaded and pasted imports
+const maxWhitelistImportSize = 1 << 20
+
+var whitelistExportContentTypes = map[string]string{
+	services.WhitelistFormatJSON:  "application/json",
+	services.WhitelistFormatCSV:   "text/csv; charset=utf-8",
+	services.WhitelistFormatNames: "text/plain; charset=utf-8",
+}
+
+func handleExportWhitelist(whitelistService *services.WhitelistService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", services.WhitelistFormatJSON)
+		data, fileName, err := whitelistService.ExportWhitelist(format)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Error: "+err.Error())
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+		c.Data(http.StatusOK, whitelistExportContentTypes[format], data)
+	}
+}
+
+// handleStartWhitelistImport reads the import from an uploaded file or the pasted text and starts
+// checking it in the background
+func handleStartWhitelistImport(whitelistService *services.WhitelistService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		content := c.PostForm("content")
+		if file, err := c.FormFile("file"); err == nil {
+			src, err := file.Open()
+			if err != nil {
+				c.String(http.StatusBadRequest, "Error: "+err.Error())
+				return
+			}
+			defer src.Close()
+			upload, err := io.ReadAll(io.LimitReader(src, maxWhitelistImportSize+1))
+			if err != nil {
+				c.String(http.StatusBadRequest, "Error: "+err.Error())
+				return
+			}
+			content = string(upload)
+		}
+		if len(content) > maxWhitelistImportSize {
+			c.Header("HX-Trigger", utils.BuildToastTrigger("The import is larger than 1 MB", "error"))
+			c.String(http.StatusRequestEntityTooLarge, "Error: the import is larger than 1 MB")
+			return
+		}
+
+		format := c.PostForm("format")
+		if format == "auto" {
+			format = ""
+		}
+		if err := whitelistService.StartWhitelistImport(content, format, c.PostForm("replace") == "on"); err != nil {
+			c.Header("HX-Trigger", utils.BuildToastTrigger("Import failed: "+err.Error(), "error"))
+			c.String(http.StatusBadRequest, "Error: "+err.Error())
+			return
+		}
+		c.HTML(http.StatusOK, "whitelist_import.html", gin.H{"Import": whitelistService.GetWhitelistImport()})
+	}
+}
+
+func handleGetWhitelistImport(whitelistService *services.WhitelistService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(http.StatusOK, "whitelist_import.html", gin.H{"Import": whitelistService.GetWhitelistImport()})
+	}
+}
+
+func handleApplyWhitelistImport(page whitelistPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plan, err := page.whitelist.ApplyWhitelistImport()
+		if err != nil {
+			c.Header("HX-Trigger", utils.BuildToastTrigger("Import failed: "+err.Error(), "error"))
+		} else {
+			c.Header("HX-Trigger", utils.BuildToastTrigger(fmt.Sprintf("Added %d and removed %d players", len(plan.Adds), len(plan.Removes)), "success"))
+		}
+		page.render(c)
+	}
+}
+
+func handleDiscardWhitelistImport(whitelistService *services.WhitelistService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		whitelistService.DiscardWhitelistImport()
+		c.HTML(http.StatusOK, "whitelist_import.html", gin.H{"Import": whitelistService.GetWhitelistImport()})
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"mc-admin/internal/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// temporaryWhitelistReapInterval is how late a guest may be removed after their access expired
+const temporaryWhitelistReapInterval = 30 * time.Second
+
+// guestDurationOption is a choice of how long guests stay whitelisted
+type guestDurationOption struct {
+	Label string
+	Value time.Duration
+}
+
+var guestDurations = []guestDurationOption{
+	{"1 hour", time.Hour},
+	{"3 hours", 3 * time.Hour},
+	{"1 day", 24 * time.Hour},
+	{"3 days", 3 * 24 * time.Hour},
+	{"1 week", 7 * 24 * time.Hour},
+	{"30 days", 30 * 24 * time.Hour},
+}
+
+// handleAddGuest whitelists a guest for the chosen duration, or extends their access
+func handleAddGuest(page whitelistPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		duration, err := time.ParseDuration(c.PostForm("duration"))
+		var entry services.TemporaryWhitelistEntry
+		if err == nil {
+			entry, err = page.temporary.Add(services.TemporaryWhitelistRequest{
+				PlayerName:   c.PostForm("playerName"),
+				Duration:     duration,
+				Sponsor:      c.PostForm("sponsor"),
+				KickOnExpiry: c.PostForm("kick") == "on",
+				AddedBy:      reviewerName(c),
+			})
+		}
+		triggerActionToast(c, err, "Whitelisted "+entry.PlayerName+" until "+entry.ExpiresAt.Format("2006-01-02 15:04"))
+		page.render(c)
+	}
+}
+
+func handleRevokeGuest(page whitelistPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry, err := page.temporary.Revoke(c.PostForm("playerName"))
+		triggerActionToast(c, err, "Removed "+entry.PlayerName+" from the whitelist")
+		page.render(c)
+	}
+}
+
+func handleMakeGuestPermanent(page whitelistPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry, err := page.temporary.MakePermanent(c.PostForm("playerName"))
+		triggerActionToast(c, err, entry.PlayerName+" stays on the whitelist")
+		page.render(c)
+	}
+}
@@ -0,0 +1,113 @@
+package backupstore
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+	"strings"
+)
+
+// defaultChunkSize is the fixed chunk size used for files that are not region files
+const defaultChunkSize = 1 << 20
+
+const (
+	regionSectorSize = 4096
+	regionHeaderSize = 2 * regionSectorSize
+	regionEntries    = 1024
+)
+
+// isRegionFile reports whether the file uses the Anvil region layout
+func isRegionFile(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".mca") || strings.HasSuffix(lower, ".mcr")
+}
+
+// regionBoundaries returns the offsets at which a region file should be split: the
+// header and each chunk's sector run become separate pieces. ok=false if the header
+// is not consistent and the file should be chunked by size instead.
+func regionBoundaries(data []byte) ([]int, bool) {
+	if len(data) < regionHeaderSize {
+		return nil, false
+	}
+	type span struct{ start, end int }
+	spans := []span{}
+	for i := 0; i < regionEntries; i++ {
+		location := binary.BigEndian.Uint32(data[i*4 : i*4+4])
+		offset := int(location>>8) * regionSectorSize
+		length := int(location&0xff) * regionSectorSize
+		if offset == 0 || length == 0 {
+			continue
+		}
+		if offset < regionHeaderSize || offset >= len(data) {
+			return nil, false
+		}
+		end := offset + length
+		if end > len(data) {
+			end = len(data)
+		}
+		spans = append(spans, span{offset, end})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	boundaries := []int{0, regionHeaderSize}
+	last := regionHeaderSize
+	for _, sp := range spans {
+		if sp.start < last {
+			return nil, false
+		}
+		if sp.start > last {
+			boundaries = append(boundaries, sp.start)
+		}
+		boundaries = append(boundaries, sp.end)
+		last = sp.end
+	}
+	if last < len(data) {
+		boundaries = append(boundaries, len(data))
+	}
+	return boundaries, true
+}
+
+// splitRegion splits region file contents into pieces along chunk boundaries
+func splitRegion(data []byte, fallbackSize int) [][]byte {
+	boundaries, ok := regionBoundaries(data)
+	if !ok {
+		return splitFixed(data, fallbackSize)
+	}
+	pieces := make([][]byte, 0, len(boundaries))
+	for i := 1; i < len(boundaries); i++ {
+		pieces = append(pieces, data[boundaries[i-1]:boundaries[i]])
+	}
+	return pieces
+}
+
+// splitFixed splits data into pieces of at most size bytes
+func splitFixed(data []byte, size int) [][]byte {
+	pieces := [][]byte{}
+	for len(data) > size {
+		pieces = append(pieces, data[:size])
+		data = data[size:]
+	}
+	if len(data) > 0 {
+		pieces = append(pieces, data)
+	}
+	return pieces
+}
+
+// readFixedChunks streams r in pieces of at most size bytes, calling fn for each
+func readFixedChunks(r io.Reader, size int, fn func(piece []byte) error) error {
+	buf := make([]byte, size)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := fn(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
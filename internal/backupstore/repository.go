@@ -0,0 +1,335 @@
+// Package backupstore implements a deduplicating snapshot repository for Minecraft worlds.
+//
+// Files are split into content-addressed chunks stored under objects/. Region files
+// (.mca) are split along their Anvil chunk boundaries so that only the chunks that
+// changed between two snapshots are written again. Each snapshot is a JSON manifest
+// under snapshots/ listing the chunk hashes of every file.
+package backupstore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	objectsDir   = "objects"
+	snapshotsDir = "snapshots"
+	configFile   = "config.json"
+	lockFile     = "lock"
+	// staleLockAge is when a lock whose owner cannot be checked is considered abandoned. It is far
+	// longer than any snapshot or garbage collection should take.
+	staleLockAge = 24 * time.Hour
+)
+
+// ErrSnapshotNotFound is returned when a snapshot ID does not exist in the repository
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// ErrRepositoryLocked is returned when another process holds the repository lock
+var ErrRepositoryLocked = errors.New("repository is locked by another operation")
+
+// heldLocks are the lock files this process holds. A lock with our own process ID that is not in
+// here was left by an earlier process that got the same ID, as the first process in a container does.
+var (
+	heldLocksMu sync.Mutex
+	heldLocks   = map[string]bool{}
+)
+
+type repositoryConfig struct {
+	Version   int `json:"version"`
+	ChunkSize int `json:"chunk_size"`
+}
+
+// Repository is a snapshot store rooted at a directory
+type Repository struct {
+	root      string
+	chunkSize int
+	now       func() time.Time
+}
+
+// FileEntry describes one file of a snapshot
+type FileEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Mode    uint32    `json:"mode"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"`
+	Chunks  []string  `json:"chunks"`
+}
+
+// Snapshot is the manifest of a directory at a point in time
+type Snapshot struct {
+	ID        string      `json:"id"`
+	Source    string      `json:"source"`
+	CreatedAt time.Time   `json:"created_at"`
+	Files     []FileEntry `json:"files"`
+}
+
+// TotalSize returns the combined size of all files in the snapshot
+func (s *Snapshot) TotalSize() int64 {
+	var total int64
+	for _, f := range s.Files {
+		total += f.Size
+	}
+	return total
+}
+
+// Init creates a new repository at root, or opens it if it already exists
+func Init(root string) (*Repository, error) {
+	if _, err := os.Stat(filepath.Join(root, configFile)); err == nil {
+		return Open(root)
+	}
+	for _, dir := range []string{root, filepath.Join(root, objectsDir), filepath.Join(root, snapshotsDir)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create repository: %w", err)
+		}
+	}
+	cfg := repositoryConfig{Version: 1, ChunkSize: defaultChunkSize}
+	buf, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(root, configFile), buf); err != nil {
+		return nil, fmt.Errorf("failed to write repository config: %w", err)
+	}
+	return Open(root)
+}
+
+// Open opens an existing repository
+func Open(root string) (*Repository, error) {
+	buf, err := os.ReadFile(filepath.Join(root, configFile))
+	if err != nil {
+		return nil, fmt.Errorf("not a backup repository: %w", err)
+	}
+	var cfg repositoryConfig
+	if err := json.Unmarshal(buf, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid repository config: %w", err)
+	}
+	if cfg.Version != 1 {
+		return nil, fmt.Errorf("unsupported repository version %d", cfg.Version)
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = defaultChunkSize
+	}
+	return &Repository{root: root, chunkSize: cfg.ChunkSize, now: time.Now}, nil
+}
+
+// Root returns the repository directory
+func (r *Repository) Root() string {
+	return r.root
+}
+
+// lock takes an exclusive lock on the repository so snapshots and garbage collection do not overlap.
+// A lock left behind by a process that died is taken over.
+func (r *Repository) lock() (func(), error) {
+	path := filepath.Join(r.root, lockFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		owner, ownerErr := r.readLock()
+		if ownerErr != nil {
+			return nil, ErrRepositoryLocked
+		}
+		if !owner.stale(path, r.now()) {
+			return nil, fmt.Errorf("%w (%s), run mc-backup unlock if it is not running anymore", ErrRepositoryLocked, owner)
+		}
+		// Removing and creating again keeps O_EXCL deciding between processes taking over at once
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	}
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, ErrRepositoryLocked
+		}
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	fmt.Fprintf(f, "%d\n%s\n", os.Getpid(), hostname)
+	f.Close()
+	heldLocksMu.Lock()
+	heldLocks[path] = true
+	heldLocksMu.Unlock()
+	return func() {
+		heldLocksMu.Lock()
+		delete(heldLocks, path)
+		heldLocksMu.Unlock()
+		os.Remove(path)
+	}, nil
+}
+
+// Unlock removes the lock of a process that did not clean up after itself and reports whether
+// there was one. It must only be used when no snapshot or garbage collection is running.
+func (r *Repository) Unlock() (bool, error) {
+	err := os.Remove(filepath.Join(r.root, lockFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// lockOwner is the process recorded in the lock file
+type lockOwner struct {
+	pid      int
+	hostname string
+	since    time.Time
+}
+
+func (r *Repository) readLock() (lockOwner, error) {
+	path := filepath.Join(r.root, lockFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		return lockOwner{}, err
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return lockOwner{}, err
+	}
+	owner := lockOwner{since: info.ModTime()}
+	lines := strings.Split(string(buf), "\n")
+	owner.pid, _ = strconv.Atoi(strings.TrimSpace(lines[0]))
+	if len(lines) > 1 {
+		owner.hostname = strings.TrimSpace(lines[1])
+	}
+	return owner, nil
+}
+
+// stale reports whether the owner of the lock at path is gone. The process is only checked on the
+// same host, other hosts sharing the repository have their own process IDs, so their locks expire
+// by age.
+func (o lockOwner) stale(path string, now time.Time) bool {
+	hostname, _ := os.Hostname()
+	if o.pid > 0 && o.hostname != "" && o.hostname == hostname {
+		if o.pid == os.Getpid() {
+			heldLocksMu.Lock()
+			defer heldLocksMu.Unlock()
+			return !heldLocks[path]
+		}
+		return !processAlive(o.pid)
+	}
+	return now.Sub(o.since) > staleLockAge
+}
+
+func (o lockOwner) String() string {
+	return fmt.Sprintf("held by process %d on %q since %s", o.pid, o.hostname, o.since.Format(time.DateTime))
+}
+
+// processAlive checks a process with signal 0. Where that is not supported the process counts as
+// alive, leaving the lock to expire by age.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
+
+func (r *Repository) snapshotPath(id string) string {
+	return filepath.Join(r.root, snapshotsDir, id+".json")
+}
+
+func (r *Repository) newSnapshotID() (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return r.now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix), nil
+}
+
+func validSnapshotID(id string) bool {
+	return id != "" && id == filepath.Base(id) && !strings.HasPrefix(id, ".")
+}
+
+// LoadSnapshot reads a snapshot manifest by ID
+func (r *Repository) LoadSnapshot(id string) (*Snapshot, error) {
+	if !validSnapshotID(id) {
+		return nil, ErrSnapshotNotFound
+	}
+	buf, err := os.ReadFile(r.snapshotPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(buf, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", id, err)
+	}
+	return &snapshot, nil
+}
+
+// ListSnapshots returns all snapshots, oldest first
+func (r *Repository) ListSnapshots() ([]*Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(r.root, snapshotsDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	snapshots := []*Snapshot{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		snapshot, err := r.LoadSnapshot(id)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// latestSnapshotFor returns the newest snapshot of the given source directory, nil if there is none
+func (r *Repository) latestSnapshotFor(source string) (*Snapshot, error) {
+	snapshots, err := r.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].Source == source {
+			return snapshots[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteSnapshot removes a snapshot manifest. Its chunks are freed by the next GarbageCollect
+func (r *Repository) DeleteSnapshot(id string) error {
+	if _, err := r.LoadSnapshot(id); err != nil {
+		return err
+	}
+	return os.Remove(r.snapshotPath(id))
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
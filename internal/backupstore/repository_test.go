@@ -0,0 +1,249 @@
+package backupstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildRegion creates a region file whose chunks (index -> payload byte) occupy one sector each
+func buildRegion(chunks map[int]byte) []byte {
+	data := make([]byte, regionHeaderSize+len(chunks)*regionSectorSize)
+	sector := 2
+	for i := 0; i < regionEntries; i++ {
+		fill, ok := chunks[i]
+		if !ok {
+			continue
+		}
+		binary.BigEndian.PutUint32(data[i*4:], uint32(sector<<8|1))
+		copy(data[sector*regionSectorSize:], bytes.Repeat([]byte{fill}, regionSectorSize))
+		sector++
+	}
+	return data
+}
+
+func writeTestFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+	repo, err := Init(filepath.Join(t.TempDir(), "repo"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return repo
+}
+
+func TestRegionBoundaries(t *testing.T) {
+	data := buildRegion(map[int]byte{0: 'a', 5: 'b'})
+	got, ok := regionBoundaries(data)
+	if !ok {
+		t.Fatalf("expected valid region")
+	}
+	want := []int{0, regionHeaderSize, regionHeaderSize + regionSectorSize, regionHeaderSize + 2*regionSectorSize}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("boundaries = %v, want %v", got, want)
+	}
+
+	if _, ok := regionBoundaries([]byte("short")); ok {
+		t.Fatalf("expected short file to be rejected")
+	}
+
+	corrupt := buildRegion(map[int]byte{0: 'a'})
+	binary.BigEndian.PutUint32(corrupt[4:], uint32(1<<8|1)) // points into the header
+	if _, ok := regionBoundaries(corrupt); ok {
+		t.Fatalf("expected corrupt header to be rejected")
+	}
+}
+
+func TestRepository_IncrementalSnapshots(t *testing.T) {
+	repo := newTestRepository(t)
+	world := filepath.Join(t.TempDir(), "world")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writeTestFile(t, filepath.Join(world, "level.dat"), []byte("level"), base)
+	writeTestFile(t, filepath.Join(world, "region", "r.0.0.mca"), buildRegion(map[int]byte{0: 'a', 1: 'b', 2: 'c'}), base)
+	writeTestFile(t, filepath.Join(world, "region", "r.1.0.mca"), buildRegion(map[int]byte{0: 'x'}), base)
+	writeTestFile(t, filepath.Join(world, "session.lock"), []byte("lock"), base)
+
+	first, stats, err := repo.CreateSnapshot(world)
+	if err != nil {
+		t.Fatalf("first snapshot: %v", err)
+	}
+	if stats.Files != 3 || stats.SkippedFiles != 0 {
+		t.Fatalf("first stats = %+v", stats)
+	}
+	// level.dat + header and 3 chunks of r.0.0 + header and 1 chunk of r.1.0 (the two headers differ)
+	if stats.NewChunks != 7 {
+		t.Fatalf("first snapshot stored %d chunks, want 7", stats.NewChunks)
+	}
+
+	// change one chunk of r.0.0, touch r.1.0 without changing it, leave level.dat alone
+	writeTestFile(t, filepath.Join(world, "region", "r.0.0.mca"), buildRegion(map[int]byte{0: 'a', 1: 'B', 2: 'c'}), base.Add(time.Hour))
+	writeTestFile(t, filepath.Join(world, "region", "r.1.0.mca"), buildRegion(map[int]byte{0: 'x'}), base.Add(time.Hour))
+
+	second, stats, err := repo.CreateSnapshot(world)
+	if err != nil {
+		t.Fatalf("second snapshot: %v", err)
+	}
+	if stats.SkippedFiles != 1 {
+		t.Fatalf("expected level.dat to be skipped by mtime, stats = %+v", stats)
+	}
+	if stats.NewChunks != 1 || stats.NewBytes != regionSectorSize {
+		t.Fatalf("second snapshot stored %d chunks (%d bytes), want only the changed chunk", stats.NewChunks, stats.NewBytes)
+	}
+
+	snapshots, err := repo.ListSnapshots()
+	if err != nil || len(snapshots) != 2 || snapshots[0].ID != first.ID || snapshots[1].ID != second.ID {
+		t.Fatalf("ListSnapshots = %v, %v", snapshots, err)
+	}
+
+	for _, tc := range []struct {
+		id     string
+		region byte
+	}{{first.ID, 'b'}, {second.ID, 'B'}} {
+		target := filepath.Join(t.TempDir(), "restore")
+		if err := repo.Restore(tc.id, target); err != nil {
+			t.Fatalf("Restore(%s): %v", tc.id, err)
+		}
+		got, err := os.ReadFile(filepath.Join(target, "region", "r.0.0.mca"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got[regionHeaderSize+regionSectorSize] != tc.region {
+			t.Fatalf("restored chunk = %q, want %q", got[regionHeaderSize+regionSectorSize], tc.region)
+		}
+		if _, err := os.Stat(filepath.Join(target, "session.lock")); !os.IsNotExist(err) {
+			t.Fatalf("session.lock should not be restored")
+		}
+		info, err := os.Stat(filepath.Join(target, "level.dat"))
+		if err != nil || !info.ModTime().Equal(base) {
+			t.Fatalf("level.dat mtime not restored: %v %v", info, err)
+		}
+	}
+}
+
+func TestRepository_GarbageCollect(t *testing.T) {
+	repo := newTestRepository(t)
+	world := filepath.Join(t.TempDir(), "world")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writeTestFile(t, filepath.Join(world, "region", "r.0.0.mca"), buildRegion(map[int]byte{0: 'a'}), base)
+	first, _, err := repo.CreateSnapshot(world)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(world, "region", "r.0.0.mca"), buildRegion(map[int]byte{0: 'b'}), base.Add(time.Hour))
+	second, _, err := repo.CreateSnapshot(world)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := repo.GarbageCollect()
+	if err != nil || stats.RemovedChunks != 0 {
+		t.Fatalf("GC with all snapshots present = %+v, %v", stats, err)
+	}
+
+	if err := repo.DeleteSnapshot(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = repo.GarbageCollect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.RemovedChunks != 1 || stats.FreedBytes != regionSectorSize {
+		t.Fatalf("GC stats = %+v, want the old chunk removed", stats)
+	}
+
+	if err := repo.Restore(second.ID, filepath.Join(t.TempDir(), "restore")); err != nil {
+		t.Fatalf("restore after GC: %v", err)
+	}
+	if err := repo.Restore(first.ID, t.TempDir()); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Fatalf("restore of deleted snapshot = %v, want ErrSnapshotNotFound", err)
+	}
+}
+
+func TestRepository_Lock(t *testing.T) {
+	repo := newTestRepository(t)
+	unlock, err := repo.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GarbageCollect(); !errors.Is(err, ErrRepositoryLocked) {
+		t.Fatalf("GarbageCollect while locked = %v, want ErrRepositoryLocked", err)
+	}
+	unlock()
+	if _, err := repo.GarbageCollect(); err != nil {
+		t.Fatalf("GarbageCollect after unlock: %v", err)
+	}
+}
+
+func TestRepository_StaleLock(t *testing.T) {
+	repo := newTestRepository(t)
+	path := filepath.Join(repo.Root(), lockFile)
+	hostname, _ := os.Hostname()
+	writeLock := func(pid int, host string, age time.Duration) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n%s\n", pid, host)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		since := time.Now().Add(-age)
+		os.Chtimes(path, since, since)
+	}
+
+	for name, lock := range map[string]struct {
+		pid  int
+		host string
+		age  time.Duration
+	}{
+		"dead process":             {pid: 1<<31 - 1, host: hostname},
+		"earlier run with our PID": {pid: os.Getpid(), host: hostname},
+		"other host, expired":      {pid: 1, host: "other", age: staleLockAge + time.Hour},
+		"old format, expired":      {pid: 1, age: staleLockAge + time.Hour},
+	} {
+		writeLock(lock.pid, lock.host, lock.age)
+		if _, err := repo.GarbageCollect(); err != nil {
+			t.Fatalf("%s: GarbageCollect = %v, want the lock taken over", name, err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s: lock file left behind: %v", name, err)
+		}
+	}
+
+	writeLock(1, "other", time.Hour)
+	if _, err := repo.GarbageCollect(); !errors.Is(err, ErrRepositoryLocked) || !strings.Contains(err.Error(), "mc-backup unlock") {
+		t.Fatalf("GarbageCollect with a live lock = %v, want ErrRepositoryLocked", err)
+	}
+	if removed, err := repo.Unlock(); !removed || err != nil {
+		t.Fatalf("Unlock = %v, %v", removed, err)
+	}
+	if removed, err := repo.Unlock(); removed || err != nil {
+		t.Fatalf("Unlock without a lock = %v, %v", removed, err)
+	}
+	if _, err := repo.GarbageCollect(); err != nil {
+		t.Fatalf("GarbageCollect after Unlock: %v", err)
+	}
+}
+
+func TestOpen_NotARepository(t *testing.T) {
+	if _, err := Open(t.TempDir()); err == nil {
+		t.Fatalf("expected error for directory without config")
+	}
+}
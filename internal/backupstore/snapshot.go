@@ -0,0 +1,290 @@
+package backupstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotStats summarizes the work done by CreateSnapshot
+type SnapshotStats struct {
+	Files        int
+	SkippedFiles int
+	Chunks       int
+	NewChunks    int
+	Bytes        int64
+	NewBytes     int64
+}
+
+// GCStats summarizes the work done by GarbageCollect
+type GCStats struct {
+	RemovedChunks int
+	FreedBytes    int64
+}
+
+func (r *Repository) objectPath(hash string) string {
+	return filepath.Join(r.root, objectsDir, hash[:2], hash)
+}
+
+// storeChunk writes a chunk unless an object with the same hash already exists
+func (r *Repository) storeChunk(piece []byte, stats *SnapshotStats) (string, error) {
+	sum := sha256.Sum256(piece)
+	hash := hex.EncodeToString(sum[:])
+	stats.Chunks++
+	path := r.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(path, piece); err != nil {
+		return "", fmt.Errorf("failed to store chunk: %w", err)
+	}
+	stats.NewChunks++
+	stats.NewBytes += int64(len(piece))
+	return hash, nil
+}
+
+// CreateSnapshot records the contents of sourceDir. Files whose size and modification
+// time match the previous snapshot of the same directory are not read again, and files
+// whose content hash is unchanged reuse their previous chunks.
+func (r *Repository) CreateSnapshot(sourceDir string) (*Snapshot, SnapshotStats, error) {
+	var stats SnapshotStats
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, stats, err
+	}
+	defer unlock()
+
+	source, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return nil, stats, err
+	}
+	previous := map[string]FileEntry{}
+	if last, err := r.latestSnapshotFor(source); err != nil {
+		return nil, stats, err
+	} else if last != nil {
+		for _, f := range last.Files {
+			previous[f.Path] = f
+		}
+	}
+
+	id, err := r.newSnapshotID()
+	if err != nil {
+		return nil, stats, err
+	}
+	snapshot := &Snapshot{ID: id, Source: source, CreatedAt: r.now().UTC(), Files: []FileEntry{}}
+
+	err = filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// session.lock is held open by a running server and is meaningless in a backup
+		if rel == "session.lock" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stats.Files++
+		stats.Bytes += info.Size()
+
+		if prev, ok := previous[rel]; ok && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime().UTC()) {
+			stats.SkippedFiles++
+			stats.Chunks += len(prev.Chunks)
+			snapshot.Files = append(snapshot.Files, prev)
+			return nil
+		}
+
+		entry, err := r.snapshotFile(path, rel, info, previous[rel], &stats)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		snapshot.Files = append(snapshot.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to snapshot %s: %w", source, err)
+	}
+
+	buf, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, stats, err
+	}
+	if err := writeFileAtomic(r.snapshotPath(id), buf); err != nil {
+		return nil, stats, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return snapshot, stats, nil
+}
+
+func (r *Repository) snapshotFile(path string, rel string, info fs.FileInfo, prev FileEntry, stats *SnapshotStats) (FileEntry, error) {
+	entry := FileEntry{
+		Path:    rel,
+		Size:    info.Size(),
+		Mode:    uint32(info.Mode().Perm()),
+		ModTime: info.ModTime().UTC(),
+	}
+
+	if isRegionFile(rel) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return entry, err
+		}
+		sum := sha256.Sum256(data)
+		entry.Hash = hex.EncodeToString(sum[:])
+		if prev.Hash == entry.Hash {
+			entry.Chunks = prev.Chunks
+			stats.Chunks += len(prev.Chunks)
+			return entry, nil
+		}
+		for _, piece := range splitRegion(data, r.chunkSize) {
+			hash, err := r.storeChunk(piece, stats)
+			if err != nil {
+				return entry, err
+			}
+			entry.Chunks = append(entry.Chunks, hash)
+		}
+		return entry, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return entry, err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	err = readFixedChunks(io.TeeReader(f, hasher), r.chunkSize, func(piece []byte) error {
+		hash, err := r.storeChunk(piece, stats)
+		if err != nil {
+			return err
+		}
+		entry.Chunks = append(entry.Chunks, hash)
+		return nil
+	})
+	if err != nil {
+		return entry, err
+	}
+	entry.Hash = hex.EncodeToString(hasher.Sum(nil))
+	return entry, nil
+}
+
+// Restore writes every file of a snapshot below targetDir and verifies its hash
+func (r *Repository) Restore(id string, targetDir string) error {
+	snapshot, err := r.LoadSnapshot(id)
+	if err != nil {
+		return err
+	}
+	target, err := filepath.Abs(targetDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	for _, f := range snapshot.Files {
+		if err := r.restoreFile(f, target); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) restoreFile(f FileEntry, target string) error {
+	fullPath := filepath.Join(target, filepath.FromSlash(f.Path))
+	if !strings.HasPrefix(fullPath, target+string(filepath.Separator)) {
+		return fmt.Errorf("path escapes target directory")
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	mode := os.FileMode(f.Mode)
+	if mode == 0 {
+		mode = 0644
+	}
+	out, err := os.OpenFile(fullPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	hasher := sha256.New()
+	w := io.MultiWriter(out, hasher)
+	for _, hash := range f.Chunks {
+		piece, err := os.ReadFile(r.objectPath(hash))
+		if err != nil {
+			out.Close()
+			return fmt.Errorf("missing chunk %s: %w", hash, err)
+		}
+		if _, err := w.Write(piece); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != f.Hash {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", got, f.Hash)
+	}
+	return os.Chtimes(fullPath, f.ModTime, f.ModTime)
+}
+
+// GarbageCollect deletes chunks that are not referenced by any snapshot
+func (r *Repository) GarbageCollect() (GCStats, error) {
+	var stats GCStats
+	unlock, err := r.lock()
+	if err != nil {
+		return stats, err
+	}
+	defer unlock()
+
+	snapshots, err := r.ListSnapshots()
+	if err != nil {
+		return stats, err
+	}
+	referenced := map[string]struct{}{}
+	for _, snapshot := range snapshots {
+		for _, f := range snapshot.Files {
+			for _, hash := range f.Chunks {
+				referenced[hash] = struct{}{}
+			}
+		}
+	}
+
+	err = filepath.WalkDir(filepath.Join(r.root, objectsDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if _, ok := referenced[name]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(name, ".tmp-") {
+			stats.RemovedChunks++
+			stats.FreedBytes += info.Size()
+		}
+		return nil
+	})
+	return stats, err
+}
@@ -0,0 +1,100 @@
+package destinations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mc-admin/internal/config"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrChecksumMismatch is returned when the uploaded data does not match the local file
+var ErrChecksumMismatch = errors.New("checksum mismatch after upload")
+
+// RemoteObject is a backup stored at a destination
+type RemoteObject struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Destination stores backup archives outside of the Minecraft data directory
+type Destination interface {
+	Name() string
+	Upload(ctx context.Context, key string, src io.ReaderAt, size int64, progress func(uploaded int64)) error
+	List(ctx context.Context) ([]RemoteObject, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// PruneRetention deletes all but the newest keep backups at the destination and returns the deleted keys.
+// Only objects for which isBackup returns true count, anything else stored there is left alone.
+// A keep value of zero or less disables pruning.
+func PruneRetention(ctx context.Context, d Destination, keep int, isBackup func(key string) bool) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	listed, err := d.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", d.Name(), err)
+	}
+	objects := slices.DeleteFunc(listed, func(obj RemoteObject) bool {
+		return !isBackup(obj.Key)
+	})
+	if len(objects) <= keep {
+		return nil, nil
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].ModTime.After(objects[j].ModTime)
+	})
+	var deleted []string
+	for _, obj := range objects[keep:] {
+		if err := d.Delete(ctx, obj.Key); err != nil {
+			return deleted, fmt.Errorf("failed to delete %s from %s: %w", obj.Key, d.Name(), err)
+		}
+		deleted = append(deleted, obj.Key)
+	}
+	return deleted, nil
+}
+
+// BuildDestinationsFromEnv creates the destinations configured for this server.
+// A local mirror is enabled by BACKUP_LOCAL_DESTINATION_DIR, S3 by BACKUP_S3_BUCKET.
+func BuildDestinationsFromEnv() ([]Destination, error) {
+	var destinations []Destination
+	if dir := config.GetEnv("BACKUP_LOCAL_DESTINATION_DIR"); dir != nil {
+		destinations = append(destinations, NewLocalDestination(*dir))
+	}
+	if bucket := config.GetEnv("BACKUP_S3_BUCKET"); bucket != nil {
+		cfg := S3Config{
+			Bucket:    *bucket,
+			Endpoint:  envOrDefault("BACKUP_S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:    envOrDefault("BACKUP_S3_REGION", "us-east-1"),
+			Prefix:    envOrDefault("BACKUP_S3_PREFIX", ""),
+			AccessKey: envOrDefault("BACKUP_S3_ACCESS_KEY", ""),
+			SecretKey: envOrDefault("BACKUP_S3_SECRET_KEY", ""),
+		}
+		if partSize := config.GetEnv("BACKUP_S3_PART_SIZE_MB"); partSize != nil {
+			mb, err := strconv.Atoi(*partSize)
+			if err != nil || mb < 5 {
+				return nil, fmt.Errorf("BACKUP_S3_PART_SIZE_MB must be an integer of at least 5")
+			}
+			cfg.PartSize = int64(mb) << 20
+		}
+		destination, err := NewS3Destination(cfg)
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, destination)
+	}
+	return destinations, nil
+}
+
+func envOrDefault(name string, fallback string) string {
+	if value := config.GetEnv(name); value != nil {
+		return *value
+	}
+	return fallback
+}
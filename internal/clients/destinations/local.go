@@ -0,0 +1,125 @@
+package destinations
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalDestination copies backups into another directory, typically a separate disk or network mount
+type LocalDestination struct {
+	dir string
+}
+
+// NewLocalDestination creates a destination that writes into dir
+func NewLocalDestination(dir string) *LocalDestination {
+	return &LocalDestination{dir: dir}
+}
+
+func (d *LocalDestination) Name() string {
+	return "local:" + d.dir
+}
+
+func (d *LocalDestination) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid key: %q", key)
+	}
+	return filepath.Join(d.dir, key), nil
+}
+
+// Upload copies src into the directory and verifies the written file's SHA-256
+func (d *LocalDestination) Upload(ctx context.Context, key string, src io.ReaderAt, size int64, progress func(uploaded int64)) error {
+	target, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(d.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	srcHash := sha256.New()
+	reader := &progressReader{ctx: ctx, r: io.NewSectionReader(src, 0, size), progress: progress}
+	if _, err := io.Copy(io.MultiWriter(tmp, srcHash), reader); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	written, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	dstHash := sha256.New()
+	_, err = io.Copy(dstHash, written)
+	written.Close()
+	if err != nil {
+		return err
+	}
+	if string(srcHash.Sum(nil)) != string(dstHash.Sum(nil)) {
+		return ErrChecksumMismatch
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// List returns the files in the destination directory
+func (d *LocalDestination) List(ctx context.Context) ([]RemoteObject, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []RemoteObject{}, nil
+		}
+		return nil, err
+	}
+	objects := []RemoteObject{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, RemoteObject{Key: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return objects, nil
+}
+
+// Delete removes a file from the destination directory
+func (d *LocalDestination) Delete(ctx context.Context, key string) error {
+	target, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(target)
+}
+
+// progressReader reports the number of bytes read and stops when the context is cancelled
+type progressReader struct {
+	ctx      context.Context
+	r        io.Reader
+	read     int64
+	progress func(int64)
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := p.r.Read(buf)
+	p.read += int64(n)
+	if p.progress != nil && n > 0 {
+		p.progress(p.read)
+	}
+	return n, err
+}
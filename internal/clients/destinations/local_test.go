@@ -0,0 +1,69 @@
+package destinations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalDestination_UploadListPrune(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mirror")
+	d := NewLocalDestination(dir)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"old.zip", "notes.txt", "mid.zip", "new.zip"} {
+		if err := d.Upload(context.Background(), name, strings.NewReader(name), int64(len(name)), nil); err != nil {
+			t.Fatalf("Upload(%s): %v", name, err)
+		}
+		modTime := base.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, name), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "mid.zip")); err != nil || string(got) != "mid.zip" {
+		t.Fatalf("uploaded content = %q, %v", got, err)
+	}
+
+	objects, err := d.List(context.Background())
+	if err != nil || len(objects) != 4 {
+		t.Fatalf("List = %v, %v", objects, err)
+	}
+
+	deleted, err := PruneRetention(context.Background(), d, 2, isZip)
+	if err != nil {
+		t.Fatalf("PruneRetention: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "old.zip" {
+		t.Fatalf("deleted = %v, want [old.zip]", deleted)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatalf("a file that is not a backup was pruned: %v", err)
+	}
+}
+
+func isZip(key string) bool {
+	return strings.HasSuffix(key, ".zip")
+}
+
+func TestLocalDestination_RejectsUnsafeKeys(t *testing.T) {
+	d := NewLocalDestination(t.TempDir())
+	for _, key := range []string{"", "../escape.zip", "sub/dir.zip", ".hidden"} {
+		if err := d.Upload(context.Background(), key, strings.NewReader("x"), 1, nil); err == nil {
+			t.Fatalf("Upload(%q) should fail", key)
+		}
+	}
+}
+
+func TestPruneRetention_Disabled(t *testing.T) {
+	d := NewLocalDestination(t.TempDir())
+	if err := d.Upload(context.Background(), "a.zip", strings.NewReader("a"), 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := PruneRetention(context.Background(), d, 0, isZip)
+	if err != nil || len(deleted) != 0 {
+		t.Fatalf("PruneRetention(0) = %v, %v", deleted, err)
+	}
+}
@@ -0,0 +1,409 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	minPartSize     = 5 << 20
+	defaultPartSize = 16 << 20
+)
+
+// S3Config configures an S3-compatible bucket (AWS, MinIO, Backblaze B2, ...)
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// PartSize is the multipart chunk size; files no larger than this are uploaded in a single request
+	PartSize   int64
+	HTTPClient *http.Client
+}
+
+// S3Destination uploads backups to an S3-compatible bucket using path-style requests signed with SigV4
+type S3Destination struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Destination validates cfg and creates the destination
+func NewS3Destination(cfg S3Config) (*S3Destination, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint: %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = defaultPartSize
+	}
+	if cfg.PartSize < minPartSize {
+		return nil, fmt.Errorf("S3 part size must be at least %d bytes", minPartSize)
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Destination{cfg: cfg, endpoint: endpoint, client: client, now: time.Now}, nil
+}
+
+func (d *S3Destination) Name() string {
+	name := "s3:" + d.cfg.Bucket
+	if d.cfg.Prefix != "" {
+		name += "/" + d.cfg.Prefix
+	}
+	return name
+}
+
+func (d *S3Destination) objectKey(key string) string {
+	if d.cfg.Prefix == "" {
+		return key
+	}
+	return d.cfg.Prefix + "/" + key
+}
+
+// Upload stores src under key. Every request carries Content-MD5 and a signed SHA-256 of its body
+// so the server rejects corrupted transfers. ETags are not compared, with SSE-KMS or SSE-C and on
+// some S3-compatible stores they are not the MD5 of the data.
+func (d *S3Destination) Upload(ctx context.Context, key string, src io.ReaderAt, size int64, progress func(uploaded int64)) error {
+	objectKey := d.objectKey(key)
+	if size <= d.cfg.PartSize {
+		part, err := readPart(src, 0, size)
+		if err != nil {
+			return err
+		}
+		if _, err := d.putPart(ctx, objectKey, nil, part); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(size)
+		}
+		return nil
+	}
+	return d.uploadMultipart(ctx, objectKey, src, size, progress)
+}
+
+func (d *S3Destination) uploadMultipart(ctx context.Context, objectKey string, src io.ReaderAt, size int64, progress func(uploaded int64)) error {
+	uploadID, err := d.createMultipartUpload(ctx, objectKey)
+	if err != nil {
+		return err
+	}
+
+	var parts []completedPart
+	var uploaded int64
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+d.cfg.PartSize, number+1 {
+		part, err := readPart(src, offset, min(d.cfg.PartSize, size-offset))
+		if err != nil {
+			d.abortMultipartUpload(objectKey, uploadID)
+			return err
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		etag, err := d.putPart(ctx, objectKey, query, part)
+		if err != nil {
+			d.abortMultipartUpload(objectKey, uploadID)
+			return fmt.Errorf("failed to upload part %d: %w", number, err)
+		}
+		parts = append(parts, completedPart{PartNumber: number, ETag: `"` + etag + `"`})
+		uploaded += int64(len(part))
+		if progress != nil {
+			progress(uploaded)
+		}
+	}
+
+	if err := d.completeMultipartUpload(ctx, objectKey, uploadID, parts); err != nil {
+		d.abortMultipartUpload(objectKey, uploadID)
+		return err
+	}
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+func (d *S3Destination) createMultipartUpload(ctx context.Context, objectKey string) (string, error) {
+	resp, err := d.do(ctx, http.MethodPost, objectKey, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("invalid multipart upload response: %v", err)
+	}
+	return result.UploadID, nil
+}
+
+func (d *S3Destination) completeMultipartUpload(ctx context.Context, objectKey string, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := d.do(ctx, http.MethodPost, objectKey, url.Values{"uploadId": {uploadID}}, body, nil)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+	// S3 may answer 200 with an error document, so look for the result element explicitly
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid complete multipart upload response: %w", err)
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("failed to complete multipart upload: %s: %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// abortMultipartUpload discards uploaded parts so they do not accrue storage costs
+func (d *S3Destination) abortMultipartUpload(objectKey string, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	resp, err := d.do(ctx, http.MethodDelete, objectKey, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// putPart uploads a whole object (query == nil) or a single part and returns the unquoted ETag
+func (d *S3Destination) putPart(ctx context.Context, objectKey string, query url.Values, part []byte) (string, error) {
+	sum := md5.Sum(part)
+	headers := map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(sum[:])}
+	resp, err := d.do(ctx, http.MethodPut, objectKey, query, part, headers)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// List returns the objects below the configured prefix
+func (d *S3Destination) List(ctx context.Context) ([]RemoteObject, error) {
+	prefix := ""
+	if d.cfg.Prefix != "" {
+		prefix = d.cfg.Prefix + "/"
+	}
+	objects := []RemoteObject{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := d.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid list response: %w", err)
+		}
+		for _, c := range result.Contents {
+			key := strings.TrimPrefix(c.Key, prefix)
+			if key == "" || strings.Contains(key, "/") {
+				continue
+			}
+			objects = append(objects, RemoteObject{Key: key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete removes an object below the configured prefix
+func (d *S3Destination) Delete(ctx context.Context, key string) error {
+	resp, err := d.do(ctx, http.MethodDelete, d.objectKey(key), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for the bucket (objectKey == "") or an object and fails on non-2xx responses
+func (d *S3Destination) do(ctx context.Context, method string, objectKey string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	u := *d.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + d.cfg.Bucket
+	if objectKey != "" {
+		u.Path += "/" + objectKey
+	}
+	// send the path exactly as it is signed
+	u.RawPath = canonicalURI(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	d.sign(req, body)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		var s3Err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3Err) == nil && s3Err.Code != "" {
+			if s3Err.Code == "BadDigest" || s3Err.Code == "XAmzContentSHA256Mismatch" {
+				return nil, fmt.Errorf("%s: %w", s3Err.Code, ErrChecksumMismatch)
+			}
+			return nil, fmt.Errorf("unexpected response code: %d (%s: %s)", resp.StatusCode, s3Err.Code, s3Err.Message)
+		}
+		return nil, fmt.Errorf("unexpected response code: %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req
+func (d *S3Destination) sign(req *http.Request, body []byte) {
+	now := d.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	var names []string
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "host" || lower == "content-md5" || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + d.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+d.cfg.SecretKey), date)
+	key = hmacSHA256(key, d.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		d.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by key with SigV4's percent-encoding rules
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, sigV4Escape(k)+"="+sigV4Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// canonicalURI percent-encodes every path segment with SigV4's rules. Go's own path escaping
+// leaves characters such as ':' and '+' alone, which SigV4 requires to be encoded.
+func canonicalURI(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func readPart(src io.ReaderAt, offset int64, length int64) ([]byte, error) {
+	buf := make([]byte, length)
+	if _, err := src.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
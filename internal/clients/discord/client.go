@@ -0,0 +1,114 @@
+// Package discord reads guild members with a bot token, using the v10 API documented at
+// https://discord.com/developers/docs/resources/guild.
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mc-admin/internal/clients/httpapi"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://discord.com/api/v10"
+	// defaultUserAgent follows the format Discord requires for bots
+	defaultUserAgent = "DiscordBot (https://github.com/tekikaito/mc-admin, 1.0)"
+	maxResponseSize  = 1 << 20
+)
+
+// JSON error codes returned with a 404
+const (
+	codeUnknownMember = 10007
+	codeUnknownUser   = 10013
+)
+
+// User is a Discord account
+type User struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+}
+
+// Member is a user's membership of a guild
+type Member struct {
+	User  User     `json:"user"`
+	Nick  string   `json:"nick"`
+	Roles []string `json:"roles"`
+}
+
+// HasRole reports whether the member has the role with the given ID
+func (m Member) HasRole(roleID string) bool {
+	return slices.Contains(m.Roles, roleID)
+}
+
+// Config configures a Client
+type Config struct {
+	// BaseURL is shared with the OAuth login, so the bot uses the same API version
+	BaseURL    string
+	BotToken   string
+	HTTPClient *http.Client
+}
+
+// Client calls the Discord API as a bot
+type Client struct {
+	baseURL  string
+	botToken string
+	client   *http.Client
+}
+
+// NewClient validates cfg and creates a Client
+func NewClient(cfg Config) (*Client, error) {
+	baseURL, err := httpapi.BaseURL(cfg.BaseURL, DefaultBaseURL, "Discord API")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(cfg.BotToken) == "" {
+		return nil, fmt.Errorf("missing Discord bot token")
+	}
+	return &Client{baseURL: baseURL, botToken: cfg.BotToken, client: httpapi.Client(cfg.HTTPClient, 30*time.Second)}, nil
+}
+
+// GuildMember returns the member of a guild. found is false if the user is not a member, any
+// other failure, such as an unknown guild, is an error so callers never mistake it for a departure.
+func (c *Client) GuildMember(ctx context.Context, guildID, userID string) (member Member, found bool, err error) {
+	path := "/guilds/" + url.PathEscape(guildID) + "/members/" + url.PathEscape(userID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return Member{}, false, err
+	}
+	req.Header.Set("Authorization", "Bot "+c.botToken)
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Member{}, false, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return Member{}, false, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.Unmarshal(body, &member); err != nil {
+			return Member{}, false, fmt.Errorf("discord: invalid response from %s: %w", path, err)
+		}
+		return member, true, nil
+	case http.StatusNotFound:
+		var apiError struct {
+			Code int `json:"code"`
+		}
+		if json.Unmarshal(body, &apiError) == nil && (apiError.Code == codeUnknownMember || apiError.Code == codeUnknownUser) {
+			return Member{}, false, nil
+		}
+	case http.StatusTooManyRequests:
+		return Member{}, false, fmt.Errorf("discord: rate limited, retry after %ss", resp.Header.Get("Retry-After"))
+	}
+	return Member{}, false, fmt.Errorf("discord: %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body[:min(len(body), 512)])))
+}
@@ -0,0 +1,61 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_GuildMember(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/api/guilds/g1/members/u1":
+			w.Write([]byte(`{"user": {"id": "u1", "username": "steve"}, "nick": "Steve", "roles": ["r1", "r2"]}`))
+		case "/api/guilds/g1/members/u2":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Unknown Member", "code": 10007}`))
+		case "/api/guilds/g2/members/u1":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Unknown Guild", "code": 10004}`))
+		default:
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(Config{BaseURL: srv.URL + "/api/", BotToken: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	member, found, err := client.GuildMember(ctx, "g1", "u1")
+	if err != nil || !found || member.User.Username != "steve" || !member.HasRole("r2") || member.HasRole("r3") {
+		t.Fatalf("member = %+v, found = %v, err = %v", member, found, err)
+	}
+	if _, found, err := client.GuildMember(ctx, "g1", "u2"); err != nil || found {
+		t.Fatalf("unknown member: found = %v, err = %v", found, err)
+	}
+	// An unknown guild is a configuration problem, not a departure
+	if _, _, err := client.GuildMember(ctx, "g2", "u1"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("unknown guild: err = %v", err)
+	}
+	if _, _, err := client.GuildMember(ctx, "g1", "u3"); err == nil || !strings.Contains(err.Error(), "retry after 2s") {
+		t.Fatalf("rate limited: err = %v", err)
+	}
+}
+
+func TestNewClient_Validation(t *testing.T) {
+	if _, err := NewClient(Config{BaseURL: "not a url", BotToken: "secret"}); err == nil {
+		t.Fatal("expected an error for an invalid URL")
+	}
+	if _, err := NewClient(Config{}); err == nil {
+		t.Fatal("expected an error without a bot token")
+	}
+}
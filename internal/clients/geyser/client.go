@@ -0,0 +1,123 @@
+// Package geyser looks up Xbox accounts and skins of Bedrock players with the GeyserMC Global API, documented
+// at https://api.geysermc.org/docs.
+package geyser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mc-admin/internal/clients/httpapi"
+	"mc-admin/internal/clients/profiles"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://api.geysermc.org/v2"
+	// defaultUserAgent identifies the application to the Global API
+	defaultUserAgent = "tekikaito/mc-admin (github.com/tekikaito/mc-admin)"
+	maxResponseSize  = 64 << 10
+)
+
+// Config configures a Client
+type Config struct {
+	// BaseURL includes the version path, requests are built for v2
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// Client calls the GeyserMC Global API
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient validates cfg and creates a Client
+func NewClient(cfg Config) (*Client, error) {
+	baseURL, err := httpapi.BaseURL(cfg.BaseURL, DefaultBaseURL, "GeyserMC API")
+	if err != nil {
+		return nil, err
+	}
+	return &Client{baseURL: baseURL, client: httpapi.Client(cfg.HTTPClient, 10*time.Second)}, nil
+}
+
+// XUID returns the Xbox user ID of a gamertag, found=false if the Global API does not know it.
+// It only knows gamertags of players who joined a server running Geyser.
+func (c *Client) XUID(ctx context.Context, gamertag string) (xuid uint64, found bool, err error) {
+	var result struct {
+		XUID uint64 `json:"xuid"`
+	}
+	found, err = c.get(ctx, "/xbox/xuid/"+url.PathEscape(gamertag), &result)
+	if err != nil || !found || result.XUID == 0 {
+		return 0, false, err
+	}
+	return result.XUID, true, nil
+}
+
+// Gamertag returns the gamertag of an Xbox user ID, found=false if the Global API does not know it
+func (c *Client) Gamertag(ctx context.Context, xuid uint64) (gamertag string, found bool, err error) {
+	var result struct {
+		Gamertag string `json:"gamertag"`
+	}
+	found, err = c.get(ctx, fmt.Sprintf("/xbox/gamertag/%d", xuid), &result)
+	if err != nil || !found || result.Gamertag == "" {
+		return "", false, err
+	}
+	return result.Gamertag, true, nil
+}
+
+// Skin is the texture the Global API uploaded for a Bedrock skin. The texture ID names a texture on
+// textures.minecraft.net, like those of Java skins.
+type Skin struct {
+	TextureID string
+	// Slim is true for skins with the three pixel wide arms of the Alex model
+	Slim bool
+}
+
+// Skin returns the skin of the Bedrock player with the XUID, found=false if the player has not
+// joined a server running Geyser since the Global API started converting skins
+func (c *Client) Skin(ctx context.Context, xuid uint64) (skin Skin, found bool, err error) {
+	var result struct {
+		TextureID string `json:"texture_id"`
+		IsSteve   bool   `json:"is_steve"`
+	}
+	found, err = c.get(ctx, fmt.Sprintf("/skin/%d", xuid), &result)
+	if err != nil || !found || result.TextureID == "" {
+		return Skin{}, false, err
+	}
+	if !profiles.ValidTextureID(result.TextureID) {
+		return Skin{}, false, fmt.Errorf("geyser: invalid texture ID %q", result.TextureID)
+	}
+	return Skin{TextureID: result.TextureID, Slim: !result.IsSteve}, true, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, result any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(result); err != nil {
+			return false, fmt.Errorf("geyser: invalid response from %s: %w", path, err)
+		}
+		return true, nil
+	case http.StatusNoContent, http.StatusNotFound:
+		return false, nil
+	case http.StatusTooManyRequests:
+		return false, fmt.Errorf("geyser: rate limited, retry after %ss", resp.Header.Get("Retry-After"))
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return false, fmt.Errorf("geyser: %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(message)))
+}
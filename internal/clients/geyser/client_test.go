@@ -0,0 +1,84 @@
+package geyser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_XUIDAndGamertag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/xbox/xuid/Steve Bedrock":
+			w.Write([]byte(`{"xuid": 2535428650000001}`))
+		case "/v2/xbox/xuid/Nobody":
+			w.Write([]byte(`{}`))
+		case "/v2/xbox/xuid/Busy":
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/v2/xbox/gamertag/2535428650000001":
+			w.Write([]byte(`{"gamertag": "Steve Bedrock"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(Config{BaseURL: srv.URL + "/v2/"})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	ctx := context.Background()
+
+	if xuid, found, err := c.XUID(ctx, "Steve Bedrock"); err != nil || !found || xuid != 2535428650000001 {
+		t.Fatalf("XUID = %d, %v, %v", xuid, found, err)
+	}
+	for _, gamertag := range []string{"Nobody", "Missing"} {
+		if _, found, err := c.XUID(ctx, gamertag); found || err != nil {
+			t.Fatalf("%s: found = %v, err = %v", gamertag, found, err)
+		}
+	}
+	if _, _, err := c.XUID(ctx, "Busy"); err == nil || !strings.Contains(err.Error(), "retry after 5s") {
+		t.Fatalf("err = %v", err)
+	}
+	if gamertag, found, err := c.Gamertag(ctx, 2535428650000001); err != nil || !found || gamertag != "Steve Bedrock" {
+		t.Fatalf("Gamertag = %q, %v, %v", gamertag, found, err)
+	}
+	if _, err := NewClient(Config{BaseURL: "api.geysermc.org"}); err == nil {
+		t.Fatal("expected an error for a URL without scheme")
+	}
+}
+
+func TestClient_Skin(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/skin/2535428650000001":
+			w.Write([]byte(`{"hash": "a1", "is_steve": false, "texture_id": "5b2f8e1c", "value": "", "signature": ""}`))
+		case "/v2/skin/2535428650000002":
+			w.Write([]byte(`{}`))
+		case "/v2/skin/2535428650000003":
+			w.Write([]byte(`{"is_steve": true, "texture_id": "../../renders/x"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(Config{BaseURL: srv.URL + "/v2"})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	skin, found, err := c.Skin(context.Background(), 2535428650000001)
+	if err != nil || !found || skin != (Skin{TextureID: "5b2f8e1c", Slim: true}) {
+		t.Fatalf("Skin = %+v, %v, %v", skin, found, err)
+	}
+	if _, found, err := c.Skin(context.Background(), 2535428650000002); found || err != nil {
+		t.Fatalf("found = %v, err = %v", found, err)
+	}
+	// the texture ID becomes a file name in the skin cache
+	if skin, found, err := c.Skin(context.Background(), 2535428650000003); found || err == nil {
+		t.Fatalf("Skin with a path as texture ID = %+v, %v, %v", skin, found, err)
+	}
+}
@@ -0,0 +1,33 @@
+// Package httpapi holds the setup shared by the clients of third-party HTTP APIs: every one of
+// them takes an optional base URL, so it can be pointed at a mirror or a local test server, and
+// an optional *http.Client.
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BaseURL returns value without a trailing slash, or fallback when value is empty. label names
+// the API in the error for a URL that is not absolute.
+func BaseURL(value string, fallback string, label string) (string, error) {
+	baseURL := strings.TrimRight(value, "/")
+	if baseURL == "" {
+		baseURL = fallback
+	}
+	if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid %s URL: %q", label, value)
+	}
+	return baseURL, nil
+}
+
+// Client returns client, or a new client with timeout when it is nil
+func Client(client *http.Client, timeout time.Duration) *http.Client {
+	if client == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	return client
+}
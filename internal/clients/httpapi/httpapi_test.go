@@ -0,0 +1,37 @@
+package httpapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: "https://api.example.com/v2"},
+		{value: "http://127.0.0.1:8080/", want: "http://127.0.0.1:8080"},
+		{value: "http://mirror.local/api/v2//", want: "http://mirror.local/api/v2"},
+		{value: "mirror.local/api", wantErr: true},
+		{value: "://", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := BaseURL(tt.value, "https://api.example.com/v2", "Example API")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("BaseURL(%q) = %q, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	if client := Client(nil, 5*time.Second); client.Timeout != 5*time.Second {
+		t.Fatalf("default client timeout = %v", client.Timeout)
+	}
+	custom := &http.Client{}
+	if Client(custom, 5*time.Second) != custom {
+		t.Fatalf("configured client was replaced")
+	}
+}
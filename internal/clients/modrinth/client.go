@@ -0,0 +1,177 @@
+// Package modrinth looks up plugin and mod versions on Modrinth by the hashes of their jars,
+// using the v2 API documented at https://docs.modrinth.com/api/.
+package modrinth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mc-admin/internal/clients/httpapi"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://api.modrinth.com/v2"
+	// defaultUserAgent identifies the application, Modrinth blocks generic user agents
+	defaultUserAgent = "tekikaito/mc-admin (github.com/tekikaito/mc-admin)"
+	// maxResponseSize bounds API responses, which list one version per requested hash
+	maxResponseSize = 16 << 20
+	// maxDownloadSize bounds downloads of files that do not state their size
+	maxDownloadSize = 256 << 20
+)
+
+// Hash algorithms accepted by the version file endpoints
+const (
+	AlgorithmSHA1   = "sha1"
+	AlgorithmSHA512 = "sha512"
+)
+
+// Version is a published version of a project
+type Version struct {
+	ID            string    `json:"id"`
+	ProjectID     string    `json:"project_id"`
+	Name          string    `json:"name"`
+	VersionNumber string    `json:"version_number"`
+	VersionType   string    `json:"version_type"`
+	GameVersions  []string  `json:"game_versions"`
+	Loaders       []string  `json:"loaders"`
+	DatePublished time.Time `json:"date_published"`
+	Files         []File    `json:"files"`
+}
+
+// File is a downloadable file of a version
+type File struct {
+	Hashes   map[string]string `json:"hashes"`
+	URL      string            `json:"url"`
+	Filename string            `json:"filename"`
+	Primary  bool              `json:"primary"`
+	Size     int64             `json:"size"`
+}
+
+// PrimaryFile returns the file marked primary, or the first file
+func (v Version) PrimaryFile() (File, bool) {
+	for _, f := range v.Files {
+		if f.Primary {
+			return f, true
+		}
+	}
+	if len(v.Files) > 0 {
+		return v.Files[0], true
+	}
+	return File{}, false
+}
+
+// Config configures a Client
+type Config struct {
+	// BaseURL replaces the public API, e.g. with a self-hosted Labrinth instance
+	BaseURL    string
+	UserAgent  string
+	HTTPClient *http.Client
+}
+
+// Client calls the Modrinth API
+type Client struct {
+	baseURL   string
+	userAgent string
+	client    *http.Client
+}
+
+// NewClient validates cfg and creates a Client
+func NewClient(cfg Config) (*Client, error) {
+	baseURL, err := httpapi.BaseURL(cfg.BaseURL, DefaultBaseURL, "Modrinth API")
+	if err != nil {
+		return nil, err
+	}
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	// jar downloads share the client, so it allows more time than the lookups of other APIs
+	return &Client{baseURL: baseURL, userAgent: userAgent, client: httpapi.Client(cfg.HTTPClient, 30*time.Second)}, nil
+}
+
+// VersionsFromHashes returns the versions the files with the given hashes belong to, keyed by
+// hash. Files Modrinth does not know are missing from the result.
+func (c *Client) VersionsFromHashes(ctx context.Context, hashes []string, algorithm string) (map[string]Version, error) {
+	versions := map[string]Version{}
+	err := c.post(ctx, "/version_files", map[string]any{
+		"hashes":    hashes,
+		"algorithm": algorithm,
+	}, &versions)
+	return versions, err
+}
+
+// LatestVersionsFromHashes returns the newest version of each file's project that supports one
+// of the loaders and game versions, keyed by hash
+func (c *Client) LatestVersionsFromHashes(ctx context.Context, hashes []string, algorithm string, loaders, gameVersions []string) (map[string]Version, error) {
+	versions := map[string]Version{}
+	err := c.post(ctx, "/version_files/update", map[string]any{
+		"hashes":        hashes,
+		"algorithm":     algorithm,
+		"loaders":       loaders,
+		"game_versions": gameVersions,
+	}, &versions)
+	return versions, err
+}
+
+// Download writes a version file to w. The body may not be larger than the size the API stated.
+func (c *Client) Download(ctx context.Context, file File, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("modrinth: download returned %d", resp.StatusCode)
+	}
+	limit := int64(maxDownloadSize)
+	if file.Size > 0 {
+		limit = file.Size
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return fmt.Errorf("modrinth: download is larger than %d bytes", limit)
+	}
+	if file.Size > 0 && n != file.Size {
+		return fmt.Errorf("modrinth: download has %d bytes, expected %d", n, file.Size)
+	}
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, path string, body any, result any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("modrinth: %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(result); err != nil {
+		return fmt.Errorf("modrinth: invalid response from %s: %w", path, err)
+	}
+	return nil
+}
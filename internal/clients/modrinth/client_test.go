@@ -0,0 +1,115 @@
+package modrinth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestClient_VersionsFromHashes(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v2/version_files" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("User-Agent") != "test-agent" {
+			t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"abc": {"id": "v1", "project_id": "AANobbMI", "version_number": "0.6.0",
+			"loaders": ["fabric"], "game_versions": ["1.21.1"], "date_published": "2024-08-01T12:00:00Z",
+			"files": [{"filename": "sodium-sources.jar", "primary": false}, {"filename": "sodium.jar", "primary": true, "url": "https://cdn.modrinth.com/x", "hashes": {"sha1": "s1"}}]}}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(Config{BaseURL: srv.URL + "/v2/", UserAgent: "test-agent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := client.VersionsFromHashes(context.Background(), []string{"abc", "def"}, AlgorithmSHA512)
+	if err != nil {
+		t.Fatalf("VersionsFromHashes: %v", err)
+	}
+	if body["algorithm"] != "sha512" || len(body["hashes"].([]any)) != 2 {
+		t.Fatalf("body = %v", body)
+	}
+	version, ok := versions["abc"]
+	if !ok || len(versions) != 1 || version.VersionNumber != "0.6.0" || !slices.Equal(version.Loaders, []string{"fabric"}) ||
+		version.DatePublished.Year() != 2024 {
+		t.Fatalf("versions = %+v", versions)
+	}
+	if file, ok := version.PrimaryFile(); !ok || file.Filename != "sodium.jar" || file.Hashes["sha1"] != "s1" {
+		t.Fatalf("primary file = %+v", file)
+	}
+}
+
+func TestClient_LatestVersionsFromHashes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Loaders      []string `json:"loaders"`
+			GameVersions []string `json:"game_versions"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/version_files/update" || !slices.Equal(body.Loaders, []string{"paper", "spigot"}) ||
+			!slices.Equal(body.GameVersions, []string{"1.21.4"}) {
+			t.Errorf("request = %s %+v", r.URL.Path, body)
+		}
+		w.Write([]byte(`{"abc": {"id": "v2", "version_number": "2.0"}}`))
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(Config{BaseURL: srv.URL})
+	versions, err := client.LatestVersionsFromHashes(context.Background(), []string{"abc"}, AlgorithmSHA512, []string{"paper", "spigot"}, []string{"1.21.4"})
+	if err != nil || versions["abc"].ID != "v2" {
+		t.Fatalf("versions = %+v, %v", versions, err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_input"}`))
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(Config{BaseURL: srv.URL})
+	if _, err := client.VersionsFromHashes(context.Background(), []string{"abc"}, AlgorithmSHA1); err == nil || !strings.Contains(err.Error(), "invalid_input") {
+		t.Fatalf("err = %v, want the API error", err)
+	}
+	if err := client.Download(context.Background(), File{URL: srv.URL + "/file.jar"}, &bytes.Buffer{}); err == nil {
+		t.Fatalf("Download of a failed response succeeded")
+	}
+	if _, err := NewClient(Config{BaseURL: "not a url"}); err == nil {
+		t.Fatalf("NewClient accepted an invalid URL")
+	}
+}
+
+func TestClient_Download(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("jar contents"))
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(Config{BaseURL: srv.URL})
+	url := srv.URL + "/data/x/versions/y/file.jar"
+	var b bytes.Buffer
+	if err := client.Download(context.Background(), File{URL: url}, &b); err != nil || b.String() != "jar contents" {
+		t.Fatalf("Download = %q, %v", b.String(), err)
+	}
+	b.Reset()
+	if err := client.Download(context.Background(), File{URL: url, Size: 12}, &b); err != nil || b.String() != "jar contents" {
+		t.Fatalf("Download with size = %q, %v", b.String(), err)
+	}
+	b.Reset()
+	if err := client.Download(context.Background(), File{URL: url, Size: 3}, &b); err == nil || b.Len() > 4 {
+		t.Fatalf("Download past the stated size = %q, %v", b.String(), err)
+	}
+	if err := client.Download(context.Background(), File{URL: url, Size: 20}, &bytes.Buffer{}); err == nil {
+		t.Fatalf("Download shorter than the stated size succeeded")
+	}
+}
@@ -0,0 +1,74 @@
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"mc-admin/internal/clients/httpapi"
+	"net/http"
+	"net/url"
+)
+
+const DefaultAshconAPIURL = "https://api.ashcon.app/mojang/v2/user"
+
+// AshconConfig configures an AshconProvider
+type AshconConfig struct {
+	// APIURL is the user endpoint the player name is appended to
+	APIURL     string
+	HTTPClient *http.Client
+}
+
+// AshconProvider looks up profiles with Ashcon, a caching proxy of the Mojang API that returns
+// the UUID and textures in one request
+type AshconProvider struct {
+	apiURL string
+	client *http.Client
+}
+
+// NewAshconProvider validates cfg and creates an AshconProvider
+func NewAshconProvider(cfg AshconConfig) (*AshconProvider, error) {
+	apiURL, err := httpapi.BaseURL(cfg.APIURL, DefaultAshconAPIURL, "Ashcon API")
+	if err != nil {
+		return nil, err
+	}
+	return &AshconProvider{apiURL: apiURL, client: httpapi.Client(cfg.HTTPClient, defaultTimeout)}, nil
+}
+
+func (p *AshconProvider) Name() string {
+	return "ashcon"
+}
+
+func (p *AshconProvider) Lookup(ctx context.Context, name string) (Profile, bool, error) {
+	var user struct {
+		UUID     string `json:"uuid"`
+		Username string `json:"username"`
+		Textures struct {
+			Skin struct {
+				URL string `json:"url"`
+			} `json:"skin"`
+			Cape struct {
+				URL string `json:"url"`
+			} `json:"cape"`
+		} `json:"textures"`
+	}
+	found, err := getJSON(ctx, p.client, p.Name(), p.apiURL+"/"+url.PathEscape(name), &user)
+	if err != nil || !found {
+		return Profile{}, false, err
+	}
+	if user.UUID == "" {
+		return Profile{}, false, fmt.Errorf("%s: profile response has no uuid", p.Name())
+	}
+	uuid, ok := normalizeUUID(user.UUID)
+	if !ok {
+		return Profile{}, false, fmt.Errorf("%s: invalid UUID %q for '%s'", p.Name(), user.UUID, name)
+	}
+	profile := Profile{
+		UUID:    uuid,
+		Name:    user.Username,
+		SkinURL: user.Textures.Skin.URL,
+		CapeURL: user.Textures.Cape.URL,
+	}
+	if profile.Name == "" {
+		profile.Name = name
+	}
+	return profile, true, nil
+}
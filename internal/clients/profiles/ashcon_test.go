@@ -0,0 +1,75 @@
+package profiles
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAshconProvider_Lookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user/notch":
+			w.Write([]byte(`{"uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "username": "Notch", "textures": {"skin": {"url": "http://textures.minecraft.net/texture/skin"}, "cape": {"url": "http://textures.minecraft.net/texture/cape"}}}`))
+		case "/user/broken":
+			w.Write([]byte(`{"username": "broken"}`))
+		case "/user/busy":
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/user/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	// A trailing slash, as in the old ASHCON_API_URL default, is accepted
+	p, err := NewAshconProvider(AshconConfig{APIURL: srv.URL + "/user/"})
+	if err != nil {
+		t.Fatalf("NewAshconProvider: %v", err)
+	}
+	ctx := context.Background()
+
+	profile, ok, err := p.Lookup(ctx, "notch")
+	want := Profile{
+		UUID:    "069a79f4-44e9-4726-a5be-fca90e38aaf5",
+		Name:    "Notch",
+		SkinURL: "http://textures.minecraft.net/texture/skin",
+		CapeURL: "http://textures.minecraft.net/texture/cape",
+	}
+	if err != nil || !ok || profile != want {
+		t.Fatalf("Lookup = %+v, %v, %v", profile, ok, err)
+	}
+	if _, ok, err := p.Lookup(ctx, "nobody"); ok || err != nil {
+		t.Fatalf("unknown name: ok = %v, err = %v", ok, err)
+	}
+	if _, _, err := p.Lookup(ctx, "broken"); err == nil {
+		t.Fatal("expected an error for a profile without uuid")
+	}
+	if _, _, err := p.Lookup(ctx, "error"); err == nil || !strings.Contains(err.Error(), "unexpected response code") {
+		t.Fatalf("err = %v", err)
+	}
+	var limitErr *RateLimitError
+	if _, _, err := p.Lookup(ctx, "busy"); !errors.As(err, &limitErr) || limitErr.RetryAfter != 7*time.Second {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestAshconProvider_networkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// Close immediately so requests fail.
+	srv.Close()
+
+	p, _ := NewAshconProvider(AshconConfig{APIURL: srv.URL})
+	if _, _, err := p.Lookup(context.Background(), "Steve"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if _, err := NewAshconProvider(AshconConfig{APIURL: "not a url"}); err == nil {
+		t.Fatal("expected an error for an invalid URL")
+	}
+}
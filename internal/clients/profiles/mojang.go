@@ -0,0 +1,123 @@
+package profiles
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mc-admin/internal/clients/httpapi"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	DefaultMojangAPIURL     = "https://api.mojang.com"
+	DefaultMojangSessionURL = "https://sessionserver.mojang.com"
+)
+
+// MojangConfig configures a MojangProvider
+type MojangConfig struct {
+	// APIURL serves the name lookup and SessionURL the textures
+	APIURL     string
+	SessionURL string
+	HTTPClient *http.Client
+}
+
+// MojangProvider looks up profiles with the official Mojang API. The name lookup and the
+// textures are two requests to different hosts with separate rate limits.
+type MojangProvider struct {
+	apiURL     string
+	sessionURL string
+	client     *http.Client
+}
+
+// NewMojangProvider validates cfg and creates a MojangProvider
+func NewMojangProvider(cfg MojangConfig) (*MojangProvider, error) {
+	apiURL, err := httpapi.BaseURL(cfg.APIURL, DefaultMojangAPIURL, "Mojang API")
+	if err != nil {
+		return nil, err
+	}
+	sessionURL, err := httpapi.BaseURL(cfg.SessionURL, DefaultMojangSessionURL, "Mojang session server")
+	if err != nil {
+		return nil, err
+	}
+	return &MojangProvider{apiURL: apiURL, sessionURL: sessionURL, client: httpapi.Client(cfg.HTTPClient, defaultTimeout)}, nil
+}
+
+func (p *MojangProvider) Name() string {
+	return "mojang"
+}
+
+// Lookup resolves the name and fetches the skin and cape of the account. A profile whose
+// textures cannot be fetched is returned without them.
+func (p *MojangProvider) Lookup(ctx context.Context, name string) (Profile, bool, error) {
+	var account struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	found, err := getJSON(ctx, p.client, p.Name(), p.apiURL+"/users/profiles/minecraft/"+url.PathEscape(name), &account)
+	if err != nil || !found {
+		return Profile{}, false, err
+	}
+	uuid, ok := normalizeUUID(account.ID)
+	if !ok {
+		return Profile{}, false, fmt.Errorf("%s: invalid UUID %q for '%s'", p.Name(), account.ID, name)
+	}
+	profile := Profile{UUID: uuid, Name: account.Name}
+	if profile.Name == "" {
+		profile.Name = name
+	}
+	if textures, found, err := p.Textures(ctx, account.ID); err == nil && found {
+		profile.SkinURL, profile.CapeURL = textures.SkinURL, textures.CapeURL
+	}
+	return profile, true, nil
+}
+
+// Textures returns the skin and cape of the account with the UUID from the session server,
+// found=false if there is no such account
+func (p *MojangProvider) Textures(ctx context.Context, uuid string) (Textures, bool, error) {
+	var session struct {
+		Properties []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"properties"`
+	}
+	id := strings.ReplaceAll(uuid, "-", "")
+	found, err := getJSON(ctx, p.client, p.Name(), p.sessionURL+"/session/minecraft/profile/"+url.PathEscape(id), &session)
+	if err != nil || !found {
+		return Textures{}, false, err
+	}
+	for _, property := range session.Properties {
+		if property.Name != "textures" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(property.Value)
+		if err != nil {
+			return Textures{}, false, fmt.Errorf("%s: invalid textures property: %w", p.Name(), err)
+		}
+		var value struct {
+			Textures struct {
+				Skin struct {
+					URL      string `json:"url"`
+					Metadata struct {
+						Model string `json:"model"`
+					} `json:"metadata"`
+				} `json:"SKIN"`
+				Cape struct {
+					URL string `json:"url"`
+				} `json:"CAPE"`
+			} `json:"textures"`
+		}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return Textures{}, false, fmt.Errorf("%s: invalid textures property: %w", p.Name(), err)
+		}
+		return Textures{
+			SkinURL: value.Textures.Skin.URL,
+			CapeURL: value.Textures.Cape.URL,
+			Slim:    value.Textures.Skin.Metadata.Model == "slim",
+		}, true, nil
+	}
+	// An account without the property uses the default skin
+	return Textures{}, true, nil
+}
@@ -0,0 +1,73 @@
+package profiles
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMojangProvider_Lookup(t *testing.T) {
+	textures := base64.StdEncoding.EncodeToString([]byte(`{"textures": {"SKIN": {"url": "http://textures.minecraft.net/texture/skin"}}}`))
+	sessionDown := false
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/profiles/minecraft/notch":
+			w.Write([]byte(`{"id": "069a79f444e94726a5befca90e38aaf5", "name": "Notch"}`))
+		case "/users/profiles/minecraft/gone":
+			// Older API versions answer unknown names with 204
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+	session := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sessionDown || r.URL.Path != "/session/minecraft/profile/069a79f444e94726a5befca90e38aaf5" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"id": "069a79f444e94726a5befca90e38aaf5", "name": "Notch", "properties": [{"name": "textures", "value": "` + textures + `"}]}`))
+	}))
+	defer session.Close()
+
+	p, err := NewMojangProvider(MojangConfig{APIURL: api.URL, SessionURL: session.URL})
+	if err != nil {
+		t.Fatalf("NewMojangProvider: %v", err)
+	}
+	ctx := context.Background()
+
+	profile, ok, err := p.Lookup(ctx, "notch")
+	want := Profile{UUID: "069a79f4-44e9-4726-a5be-fca90e38aaf5", Name: "Notch", SkinURL: "http://textures.minecraft.net/texture/skin"}
+	if err != nil || !ok || profile != want {
+		t.Fatalf("Lookup = %+v, %v, %v", profile, ok, err)
+	}
+	for _, name := range []string{"nobody", "gone"} {
+		if _, ok, err := p.Lookup(ctx, name); ok || err != nil {
+			t.Fatalf("%s: ok = %v, err = %v", name, ok, err)
+		}
+	}
+
+	// The profile is still returned when the session server refuses the textures
+	sessionDown = true
+	profile, ok, err = p.Lookup(ctx, "notch")
+	if err != nil || !ok || profile.UUID != want.UUID || profile.SkinURL != "" {
+		t.Fatalf("Lookup = %+v, %v, %v", profile, ok, err)
+	}
+}
+
+func TestMojangProvider_timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	p, _ := NewMojangProvider(MojangConfig{APIURL: srv.URL, SessionURL: srv.URL, HTTPClient: &http.Client{Timeout: 50 * time.Millisecond}})
+	if _, _, err := p.Lookup(context.Background(), "Steve"); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+}
@@ -0,0 +1,112 @@
+// Package profiles resolves Minecraft usernames to account profiles. A ChainResolver asks an
+// ordered list of providers (the Mojang API, Ashcon and the server's usercache.json) and caches
+// the answers.
+package profiles
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTimeout bounds a single provider request, a slow provider must not stall the chain
+	defaultTimeout = 10 * time.Second
+	// defaultRetryAfter is how long a rate limited provider is skipped when it does not say
+	defaultRetryAfter = time.Minute
+	// defaultUserAgent identifies the application to the profile APIs
+	defaultUserAgent = "tekikaito/mc-admin (github.com/tekikaito/mc-admin)"
+	// maxResponseSize bounds API responses, Ashcon inlines the skin PNG
+	maxResponseSize = 1 << 20
+)
+
+// Profile is the Minecraft account a username currently belongs to
+type Profile struct {
+	// UUID is in the lower case, dashed form used by whitelist.json
+	UUID string `json:"uuid"`
+	// Name is the username with the casing of the account
+	Name    string `json:"name"`
+	SkinURL string `json:"skin_url,omitempty"`
+	CapeURL string `json:"cape_url,omitempty"`
+	// Source is the name of the provider that resolved the profile
+	Source string `json:"source"`
+}
+
+// Provider looks up profiles at one source
+type Provider interface {
+	Name() string
+	// Lookup returns ok=false when the provider knows that no account has the name. Providers
+	// that cannot tell, like the user cache, return an error so the next provider is asked.
+	Lookup(ctx context.Context, name string) (Profile, bool, error)
+}
+
+// Resolver returns the profile of a username, ok=false if no account has that name
+type Resolver interface {
+	Resolve(ctx context.Context, name string) (Profile, bool, error)
+}
+
+// RateLimitError is returned by providers that answered 429
+type RateLimitError struct {
+	Provider   string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: rate limited, retry after %s", e.Provider, e.RetryAfter)
+}
+
+// getJSON decodes the response of a GET request into result, found=false if the API answered
+// 404 or 204
+func getJSON(ctx context.Context, client *http.Client, provider string, url string, result any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(result); err != nil {
+			return false, fmt.Errorf("%s: invalid response: %w", provider, err)
+		}
+		return true, nil
+	case http.StatusNoContent, http.StatusNotFound:
+		return false, nil
+	case http.StatusTooManyRequests:
+		return false, &RateLimitError{Provider: provider, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	default:
+		return false, fmt.Errorf("%s: unexpected response code: %d", provider, resp.StatusCode)
+	}
+}
+
+// parseRetryAfter reads a Retry-After header in seconds, falling back to defaultRetryAfter
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return defaultRetryAfter
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// normalizeUUID returns uuid in the lower case, dashed form, ok=false if it is not a UUID. The
+// Mojang API returns UUIDs without dashes.
+func normalizeUUID(uuid string) (string, bool) {
+	b, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(uuid), "-", ""))
+	if err != nil || len(b) != 16 {
+		return "", false
+	}
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], true
+}
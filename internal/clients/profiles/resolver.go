@@ -0,0 +1,192 @@
+package profiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mc-admin/internal/config"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheTTL = time.Hour
+	// DefaultNegativeCacheTTL is shorter, a free name may be registered any time
+	DefaultNegativeCacheTTL = 5 * time.Minute
+	// maxCacheEntries bounds the cache, expired entries are dropped when it is full
+	maxCacheEntries = 10000
+)
+
+// DefaultProviders is the provider order used when PROFILE_PROVIDERS is not set
+var DefaultProviders = []string{"mojang", "ashcon", "usercache"}
+
+// ChainResolver asks its providers in order until one knows the answer. A provider that fails or
+// is rate limited is skipped, an answer that a name does not exist ends the chain. Answers are
+// cached per name, errors are not.
+type ChainResolver struct {
+	providers   []Provider
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedProfile
+	// limitedUntil holds the providers that are rate limited, by name
+	limitedUntil map[string]time.Time
+}
+
+type cachedProfile struct {
+	profile   Profile
+	found     bool
+	expiresAt time.Time
+}
+
+// NewChainResolver creates a ChainResolver caching found profiles for ttl and unknown names for
+// negativeTTL
+func NewChainResolver(providers []Provider, ttl time.Duration, negativeTTL time.Duration) *ChainResolver {
+	return &ChainResolver{
+		providers:    providers,
+		ttl:          ttl,
+		negativeTTL:  negativeTTL,
+		now:          time.Now,
+		cache:        map[string]cachedProfile{},
+		limitedUntil: map[string]time.Time{},
+	}
+}
+
+func (r *ChainResolver) Resolve(ctx context.Context, name string) (Profile, bool, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Profile{}, false, fmt.Errorf("player name is required")
+	}
+	key := strings.ToLower(name)
+	if cached, ok := r.cached(key); ok {
+		return cached.profile, cached.found, nil
+	}
+
+	var errs []error
+	for _, provider := range r.providers {
+		if until, limited := r.rateLimited(provider.Name()); limited {
+			errs = append(errs, fmt.Errorf("%s: rate limited until %s", provider.Name(), until.Format(time.TimeOnly)))
+			continue
+		}
+		profile, found, err := provider.Lookup(ctx, name)
+		if err != nil {
+			var limitErr *RateLimitError
+			if errors.As(err, &limitErr) {
+				r.limit(provider.Name(), limitErr.RetryAfter)
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if found {
+			profile.Source = provider.Name()
+		}
+		r.store(key, profile, found)
+		return profile, found, nil
+	}
+	if len(errs) == 0 {
+		return Profile{}, false, fmt.Errorf("no profile providers are configured")
+	}
+	return Profile{}, false, errors.Join(errs...)
+}
+
+func (r *ChainResolver) cached(key string) (cachedProfile, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cached, ok := r.cache[key]
+	if !ok || !r.now().Before(cached.expiresAt) {
+		return cachedProfile{}, false
+	}
+	return cached, true
+}
+
+func (r *ChainResolver) store(key string, profile Profile, found bool) {
+	ttl := r.ttl
+	if !found {
+		ttl = r.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if len(r.cache) >= maxCacheEntries {
+		for cachedKey, cached := range r.cache {
+			if !now.Before(cached.expiresAt) {
+				delete(r.cache, cachedKey)
+			}
+		}
+		if len(r.cache) >= maxCacheEntries {
+			clear(r.cache)
+		}
+	}
+	r.cache[key] = cachedProfile{profile: profile, found: found, expiresAt: now.Add(ttl)}
+}
+
+func (r *ChainResolver) rateLimited(provider string) (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	until, ok := r.limitedUntil[provider]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !r.now().Before(until) {
+		delete(r.limitedUntil, provider)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+func (r *ChainResolver) limit(provider string, retryAfter time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limitedUntil[provider] = r.now().Add(retryAfter)
+}
+
+// NewResolverFromEnv builds the chain listed in PROFILE_PROVIDERS, a comma separated list of
+// mojang, ashcon and usercache. MOJANG_API_URL, MOJANG_SESSION_URL and ASHCON_API_URL override the
+// provider URLs. The usercache provider is left out when userCachePath is empty.
+func NewResolverFromEnv(userCachePath string) (*ChainResolver, error) {
+	names := DefaultProviders
+	if value := config.GetEnv("PROFILE_PROVIDERS"); value != nil {
+		names = strings.Split(*value, ",")
+	}
+	var providers []Provider
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "mojang":
+			provider, err := NewMojangProvider(MojangConfig{
+				APIURL:     os.Getenv("MOJANG_API_URL"),
+				SessionURL: os.Getenv("MOJANG_SESSION_URL"),
+			})
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		case "ashcon":
+			provider, err := NewAshconProvider(AshconConfig{APIURL: os.Getenv("ASHCON_API_URL")})
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		case "usercache":
+			if userCachePath != "" {
+				providers = append(providers, NewUserCacheProvider(userCachePath))
+			}
+		case "":
+		default:
+			return nil, fmt.Errorf("PROFILE_PROVIDERS: unknown provider %q", strings.TrimSpace(name))
+		}
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("PROFILE_PROVIDERS lists no usable provider")
+	}
+	return NewChainResolver(providers, DefaultCacheTTL, DefaultNegativeCacheTTL), nil
+}
@@ -0,0 +1,108 @@
+package profiles
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeProvider struct {
+	name     string
+	profiles map[string]Profile
+	err      error
+	calls    int
+}
+
+func (f *fakeProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeProvider) Lookup(ctx context.Context, name string) (Profile, bool, error) {
+	f.calls++
+	if f.err != nil {
+		return Profile{}, false, f.err
+	}
+	profile, ok := f.profiles[name]
+	return profile, ok, nil
+}
+
+func TestChainResolver_CachesAnswers(t *testing.T) {
+	mojang := &fakeProvider{name: "mojang", profiles: map[string]Profile{"Steve": {UUID: "u1", Name: "Steve"}}}
+	r := NewChainResolver([]Provider{mojang}, time.Hour, time.Minute)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	profile, ok, err := r.Resolve(ctx, "Steve")
+	if err != nil || !ok || profile.Source != "mojang" {
+		t.Fatalf("Resolve = %+v, %v, %v", profile, ok, err)
+	}
+	if _, ok, _ := r.Resolve(ctx, "steve "); !ok || mojang.calls != 1 {
+		t.Fatalf("cached lookup: ok = %v, calls = %d", ok, mojang.calls)
+	}
+	if _, ok, err := r.Resolve(ctx, "Nobody"); ok || err != nil {
+		t.Fatalf("unknown name: ok = %v, err = %v", ok, err)
+	}
+	r.Resolve(ctx, "Nobody")
+	if mojang.calls != 2 {
+		t.Fatalf("calls = %d, the unknown name should be cached", mojang.calls)
+	}
+
+	// Unknown names expire from the cache sooner than profiles
+	now = now.Add(2 * time.Minute)
+	r.Resolve(ctx, "Nobody")
+	r.Resolve(ctx, "Steve")
+	if mojang.calls != 3 {
+		t.Fatalf("calls = %d", mojang.calls)
+	}
+}
+
+func TestChainResolver_FallsBack(t *testing.T) {
+	mojang := &fakeProvider{name: "mojang", err: &RateLimitError{Provider: "mojang", RetryAfter: time.Minute}}
+	ashcon := &fakeProvider{name: "ashcon", err: errors.New("ashcon: timeout")}
+	userCachePath := filepath.Join(t.TempDir(), "usercache.json")
+	os.WriteFile(userCachePath, []byte(`[{"name": "Steve", "uuid": "8667ba71b85a4004af54457a9734eed7", "expiresOn": "2026-06-01 12:00:00 +0000"}]`), 0644)
+	r := NewChainResolver([]Provider{mojang, ashcon, NewUserCacheProvider(userCachePath)}, time.Hour, time.Minute)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	profile, ok, err := r.Resolve(ctx, "steve")
+	if err != nil || !ok || profile != (Profile{UUID: "8667ba71-b85a-4004-af54-457a9734eed7", Name: "Steve", Source: "usercache"}) {
+		t.Fatalf("Resolve = %+v, %v, %v", profile, ok, err)
+	}
+	// The user cache cannot tell that a name is free, so failing providers leave an error
+	if _, ok, err := r.Resolve(ctx, "Alex"); ok || err == nil || !errors.Is(err, ErrNotCached) {
+		t.Fatalf("ok = %v, err = %v", ok, err)
+	}
+	// The rate limited provider is skipped until it may be asked again
+	if mojang.calls != 1 || ashcon.calls != 2 {
+		t.Fatalf("mojang calls = %d, ashcon calls = %d", mojang.calls, ashcon.calls)
+	}
+	now = now.Add(time.Minute)
+	mojang.err = nil
+	mojang.profiles = map[string]Profile{"Alex": {UUID: "u2", Name: "Alex"}}
+	if profile, ok, err := r.Resolve(ctx, "Alex"); err != nil || !ok || profile.Source != "mojang" {
+		t.Fatalf("Resolve = %+v, %v, %v", profile, ok, err)
+	}
+}
+
+func TestNewResolverFromEnv(t *testing.T) {
+	t.Setenv("PROFILE_PROVIDERS", "ashcon, usercache")
+	r, err := NewResolverFromEnv("")
+	if err != nil || len(r.providers) != 1 || r.providers[0].Name() != "ashcon" {
+		t.Fatalf("providers = %v, err = %v", r, err)
+	}
+	t.Setenv("PROFILE_PROVIDERS", "mojang,crafatar")
+	if _, err := NewResolverFromEnv(""); err == nil {
+		t.Fatal("expected an error for an unknown provider")
+	}
+	t.Setenv("PROFILE_PROVIDERS", "")
+	t.Setenv("MOJANG_API_URL", "://bad")
+	if _, err := NewResolverFromEnv(""); err == nil {
+		t.Fatal("expected an error for an invalid MOJANG_API_URL")
+	}
+}
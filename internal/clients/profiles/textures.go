@@ -0,0 +1,108 @@
+package profiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mc-admin/internal/clients/httpapi"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+)
+
+const DefaultTextureURL = "https://textures.minecraft.net/texture"
+
+// maxTextureSize bounds texture downloads, HD skins are at most a few hundred kilobytes
+const maxTextureSize = 2 << 20
+
+// ErrNoTexture is returned for texture IDs the texture server does not have
+var ErrNoTexture = errors.New("texture not found")
+
+// textureIDPattern matches the hashes naming textures
+var textureIDPattern = regexp.MustCompile(`^[0-9a-f]{1,128}$`)
+
+// Textures are the skin and cape of an account. SkinURL is empty for accounts using the default skin.
+type Textures struct {
+	SkinURL string
+	CapeURL string
+	// Slim is true for skins with the three pixel wide arms of the Alex model
+	Slim bool
+}
+
+// TextureID returns the hash naming a texture URL on the texture server, empty if the URL has none
+func TextureID(textureURL string) string {
+	u, err := url.Parse(textureURL)
+	if err != nil {
+		return ""
+	}
+	id := path.Base(u.Path)
+	if !ValidTextureID(id) {
+		return ""
+	}
+	return id
+}
+
+// ValidTextureID reports whether id looks like a hash naming a texture. IDs from other APIs must
+// pass it before they are used in URLs or file names.
+func ValidTextureID(id string) bool {
+	return textureIDPattern.MatchString(id)
+}
+
+// TextureConfig configures a TextureClient
+type TextureConfig struct {
+	// BaseURL is the directory texture IDs are appended to
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// TextureClient downloads skin textures by their ID. Only IDs are accepted, so URLs from a
+// profile cannot make it fetch from other hosts.
+type TextureClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewTextureClient validates cfg and creates a TextureClient
+func NewTextureClient(cfg TextureConfig) (*TextureClient, error) {
+	baseURL, err := httpapi.BaseURL(cfg.BaseURL, DefaultTextureURL, "texture")
+	if err != nil {
+		return nil, err
+	}
+	return &TextureClient{baseURL: baseURL, client: httpapi.Client(cfg.HTTPClient, defaultTimeout)}, nil
+}
+
+// Texture returns the PNG of a texture
+func (c *TextureClient) Texture(ctx context.Context, id string) ([]byte, error) {
+	if !textureIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid texture ID %q", id)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("textures: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNoTexture
+	case http.StatusTooManyRequests:
+		return nil, &RateLimitError{Provider: "textures", RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	default:
+		return nil, fmt.Errorf("textures: unexpected response code: %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTextureSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("textures: %w", err)
+	}
+	if len(data) > maxTextureSize {
+		return nil, fmt.Errorf("textures: %s is larger than %d bytes", id, maxTextureSize)
+	}
+	return data, nil
+}
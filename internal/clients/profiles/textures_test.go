@@ -0,0 +1,72 @@
+package profiles
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMojangProvider_Textures(t *testing.T) {
+	textures := base64.StdEncoding.EncodeToString([]byte(`{"textures": {"SKIN": {"url": "http://textures.minecraft.net/texture/3b60a1f6d562f52aaebbf1434f1de147933a3affe0e764fa49ea057536623cd3", "metadata": {"model": "slim"}}}}`))
+	session := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/minecraft/profile/069a79f444e94726a5befca90e38aaf5":
+			w.Write([]byte(`{"properties": [{"name": "textures", "value": "` + textures + `"}]}`))
+		case "/session/minecraft/profile/853c80ef3c3749fdaa49938b674adae6":
+			w.Write([]byte(`{"properties": []}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer session.Close()
+
+	p, err := NewMojangProvider(MojangConfig{SessionURL: session.URL})
+	if err != nil {
+		t.Fatalf("NewMojangProvider: %v", err)
+	}
+	ctx := context.Background()
+
+	got, found, err := p.Textures(ctx, "069a79f4-44e9-4726-a5be-fca90e38aaf5")
+	if err != nil || !found || !got.Slim || TextureID(got.SkinURL) != "3b60a1f6d562f52aaebbf1434f1de147933a3affe0e764fa49ea057536623cd3" {
+		t.Fatalf("Textures = %+v, %v, %v", got, found, err)
+	}
+	if got, found, err := p.Textures(ctx, "853c80ef3c3749fdaa49938b674adae6"); err != nil || !found || got.SkinURL != "" {
+		t.Fatalf("default skin: %+v, %v, %v", got, found, err)
+	}
+	if _, found, err := p.Textures(ctx, "00000000000000000000000000000000"); found || err != nil {
+		t.Fatalf("unknown: found = %v, err = %v", found, err)
+	}
+}
+
+func TestTextureClient_Texture(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/texture/abc123" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+
+	c, err := NewTextureClient(TextureConfig{BaseURL: srv.URL + "/texture/"})
+	if err != nil {
+		t.Fatalf("NewTextureClient: %v", err)
+	}
+	ctx := context.Background()
+	if data, err := c.Texture(ctx, "abc123"); err != nil || string(data) != "png" {
+		t.Fatalf("Texture = %q, %v", data, err)
+	}
+	if _, err := c.Texture(ctx, "def456"); !errors.Is(err, ErrNoTexture) {
+		t.Fatalf("err = %v, want ErrNoTexture", err)
+	}
+	// IDs are hashes, anything else could point the request elsewhere
+	if _, err := c.Texture(ctx, "../abc123"); err == nil {
+		t.Fatal("expected an error for a path as texture ID")
+	}
+	if TextureID("http://textures.minecraft.net/texture/") != "" {
+		t.Fatal("expected no texture ID for a URL without one")
+	}
+}
@@ -0,0 +1,81 @@
+package profiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotCached is returned by the UserCacheProvider for names that never joined the server,
+// which says nothing about whether the account exists
+var ErrNotCached = errors.New("usercache: name not cached")
+
+// UserCacheProvider looks up players who joined the server in its usercache.json. It works
+// without network access but only knows names as they were when the player last joined.
+type UserCacheProvider struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	byName  map[string]Profile
+}
+
+// NewUserCacheProvider creates a UserCacheProvider reading the usercache.json at path
+func NewUserCacheProvider(path string) *UserCacheProvider {
+	return &UserCacheProvider{path: path}
+}
+
+func (p *UserCacheProvider) Name() string {
+	return "usercache"
+}
+
+func (p *UserCacheProvider) Lookup(ctx context.Context, name string) (Profile, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.loadLocked(); err != nil {
+		return Profile{}, false, err
+	}
+	profile, ok := p.byName[strings.ToLower(name)]
+	if !ok {
+		return Profile{}, false, ErrNotCached
+	}
+	return profile, true, nil
+}
+
+// loadLocked parses the file again when the server has written it since the last lookup
+func (p *UserCacheProvider) loadLocked() error {
+	stat, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("usercache: %w", err)
+	}
+	if p.byName != nil && stat.ModTime().Equal(p.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("usercache: %w", err)
+	}
+	var entries []struct {
+		Name string `json:"name"`
+		UUID string `json:"uuid"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("usercache: invalid %s: %w", p.path, err)
+	}
+	byName := make(map[string]Profile, len(entries))
+	for _, entry := range entries {
+		uuid, ok := normalizeUUID(entry.UUID)
+		if !ok || entry.Name == "" {
+			continue
+		}
+		byName[strings.ToLower(entry.Name)] = Profile{UUID: uuid, Name: entry.Name}
+	}
+	p.byName = byName
+	p.modTime = stat.ModTime()
+	return nil
+}
@@ -5,8 +5,6 @@ import (
 	"mc-admin/internal/config"
 	"sync"
 	"time"
-
-	"github.com/gorcon/rcon"
 )
 
 type CommandExecutor interface {
@@ -17,7 +15,7 @@ type MinecraftRconClient struct {
 	Host     string
 	Port     string
 	Password string
-	conn     *rcon.Conn
+	conn     *conn
 	mu       sync.Mutex
 }
 
@@ -52,7 +50,7 @@ func (c *MinecraftRconClient) getConnectionString() string {
 // connect establishes a new RCON connection
 func (c *MinecraftRconClient) connect() error {
 	connectionString := c.getConnectionString()
-	conn, err := rcon.Dial(connectionString, c.Password)
+	conn, err := dial(connectionString, c.Password)
 	if err != nil {
 		return fmt.Errorf("failed to connect to RCON server at %s: %w", connectionString, err)
 	}
@@ -63,7 +61,7 @@ func (c *MinecraftRconClient) connect() error {
 // disconnect closes the current RCON connection
 func (c *MinecraftRconClient) disconnect() {
 	if c.conn != nil {
-		c.conn.Close()
+		c.conn.close()
 		c.conn = nil
 	}
 }
@@ -74,7 +72,7 @@ func (c *MinecraftRconClient) isConnected() bool {
 		return false
 	}
 	// Try a simple command to verify connection is alive
-	_, err := c.conn.Execute("list")
+	_, err := c.conn.execute("list")
 	return err == nil
 }
 
@@ -112,7 +110,7 @@ func (c *MinecraftRconClient) ExecuteCommand(command string) (string, error) {
 		return "", err
 	}
 
-	response, err := c.conn.Execute(command)
+	response, err := c.conn.execute(command)
 	if err != nil {
 		// Try to reconnect once if command fails
 		c.disconnect()
@@ -121,7 +119,7 @@ func (c *MinecraftRconClient) ExecuteCommand(command string) (string, error) {
 		}
 
 		// Retry the command once
-		response, err = c.conn.Execute(command)
+		response, err = c.conn.execute(command)
 		if err != nil {
 			return "", fmt.Errorf("command failed after reconnection: %w", err)
 		}
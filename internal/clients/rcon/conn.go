@@ -0,0 +1,167 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	packetTypeResponse = 0
+	packetTypeCommand  = 2
+	packetTypeAuth     = 3
+
+	// maxCommandLength is the longest command body the server accepts
+	maxCommandLength = 1446
+	// maxResponseChunk is the body size at which the server splits a response into several packets
+	maxResponseChunk = 4096
+	// maxPacketSize bounds incoming packets; chunks can grow past 4096 bytes when the split
+	// cuts a multi-byte character, which the server re-encodes as replacement characters
+	maxPacketSize = 3*maxResponseChunk + 10
+
+	dialTimeout    = 5 * time.Second
+	commandTimeout = 10 * time.Second
+)
+
+var (
+	errAuthFailed      = errors.New("rcon: authentication failed")
+	errCommandTooLong  = fmt.Errorf("rcon: command exceeds %d bytes", maxCommandLength)
+	errInvalidPacket   = errors.New("rcon: invalid packet size")
+	errEmptyCommand    = errors.New("rcon: empty command")
+	errUnexpectedReply = errors.New("rcon: unexpected reply")
+)
+
+type packet struct {
+	id         int32
+	packetType int32
+	body       string
+}
+
+// conn is a single RCON connection. It reassembles responses the server splits into
+// several packets, which a plain request/response exchange would truncate.
+type conn struct {
+	netConn net.Conn
+	nextID  int32
+}
+
+func dial(address, password string) (*conn, error) {
+	netConn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{netConn: netConn, nextID: 1}
+	if err := c.auth(password); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *conn) close() error {
+	return c.netConn.Close()
+}
+
+func (c *conn) auth(password string) error {
+	id := c.id()
+	c.netConn.SetDeadline(time.Now().Add(dialTimeout))
+	defer c.netConn.SetDeadline(time.Time{})
+	if err := c.write(packet{id: id, packetType: packetTypeAuth, body: password}); err != nil {
+		return err
+	}
+	reply, err := c.read()
+	if err != nil {
+		return err
+	}
+	if reply.id == -1 {
+		return errAuthFailed
+	}
+	if reply.id != id {
+		return errUnexpectedReply
+	}
+	return nil
+}
+
+func (c *conn) id() int32 {
+	id := c.nextID
+	c.nextID++
+	if c.nextID <= 0 {
+		c.nextID = 1
+	}
+	return id
+}
+
+// execute sends a command and returns the full response. The server sends responses longer
+// than maxResponseChunk bytes as consecutive packets and does not mark the last one. So an
+// empty response packet follows the command: the server handles requests in order and answers
+// it with "Unknown request 0" once every chunk of the command's response was sent.
+func (c *conn) execute(command string) (string, error) {
+	if command == "" {
+		return "", errEmptyCommand
+	}
+	if len(command) > maxCommandLength {
+		return "", errCommandTooLong
+	}
+	id := c.id()
+	c.netConn.SetDeadline(time.Now().Add(commandTimeout))
+	defer c.netConn.SetDeadline(time.Time{})
+	if err := c.write(packet{id: id, packetType: packetTypeCommand, body: command}); err != nil {
+		return "", err
+	}
+	endID := c.id()
+	if err := c.write(packet{id: endID, packetType: packetTypeResponse}); err != nil {
+		return "", err
+	}
+
+	var response bytes.Buffer
+	for {
+		reply, err := c.read()
+		if err != nil {
+			return "", err
+		}
+		switch reply.id {
+		case id:
+			response.WriteString(reply.body)
+		case endID:
+			return response.String(), nil
+		}
+		// anything else is a stale reply to a command that timed out and is skipped
+	}
+}
+
+func (c *conn) write(p packet) error {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, int32(4+4+len(p.body)+2))
+	binary.Write(&b, binary.LittleEndian, p.id)
+	binary.Write(&b, binary.LittleEndian, p.packetType)
+	b.WriteString(p.body)
+	b.Write([]byte{0, 0})
+	_, err := c.netConn.Write(b.Bytes())
+	return err
+}
+
+func (c *conn) read() (packet, error) {
+	var size int32
+	if err := binary.Read(c.netConn, binary.LittleEndian, &size); err != nil {
+		return packet{}, err
+	}
+	return c.readBody(size)
+}
+
+func (c *conn) readBody(size int32) (packet, error) {
+	if size < 10 || size > maxPacketSize {
+		return packet{}, errInvalidPacket
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.netConn, data); err != nil {
+		return packet{}, err
+	}
+	return packet{
+		id:         int32(binary.LittleEndian.Uint32(data[0:4])),
+		packetType: int32(binary.LittleEndian.Uint32(data[4:8])),
+		body:       string(bytes.TrimRight(data[8:], "\x00")),
+	}, nil
+}
@@ -0,0 +1,110 @@
+package rcon
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeServer answers like the Minecraft server: auth replies echo the request id (-1 on a
+// wrong password), command responses are split into packets of maxResponseChunk bytes and
+// other requests are answered with "Unknown request".
+func fakeServer(t *testing.T, password string, responses map[string]string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer netConn.Close()
+				c := &conn{netConn: netConn}
+				for {
+					request, err := c.read()
+					if err != nil {
+						return
+					}
+					switch request.packetType {
+					case packetTypeAuth:
+						id := request.id
+						if request.body != password {
+							id = -1
+						}
+						c.write(packet{id: id, packetType: packetTypeCommand})
+					case packetTypeCommand:
+						response := responses[request.body]
+						for {
+							n := min(len(response), maxResponseChunk)
+							c.write(packet{id: request.id, packetType: packetTypeResponse, body: response[:n]})
+							response = response[n:]
+							if response == "" {
+								break
+							}
+						}
+					default:
+						c.write(packet{id: request.id, packetType: packetTypeResponse, body: fmt.Sprintf("Unknown request %x", request.packetType)})
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestConn_Execute(t *testing.T) {
+	long := strings.Repeat("Steve, ", 2000)
+	exact := strings.Repeat("x", maxResponseChunk)
+	address := fakeServer(t, "secret", map[string]string{
+		"list":                    "There are 0 of a max of 20 players online:",
+		"scoreboard players list": long,
+		"exact":                   exact,
+		"double":                  exact + exact,
+		"say hi":                  "",
+	})
+
+	c, err := dial(address, "secret")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.close()
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"list", "There are 0 of a max of 20 players online:"},
+		{"scoreboard players list", long},
+		{"exact", exact},
+		{"double", exact + exact},
+		{"say hi", ""},
+		// the connection stays in sync after multi-packet responses
+		{"list", "There are 0 of a max of 20 players online:"},
+	}
+	for _, tt := range tests {
+		got, err := c.execute(tt.command)
+		if err != nil {
+			t.Fatalf("execute(%q): %v", tt.command, err)
+		}
+		if got != tt.want {
+			t.Fatalf("execute(%q) returned %d bytes, want %d", tt.command, len(got), len(tt.want))
+		}
+	}
+
+	if _, err := c.execute(strings.Repeat("a", maxCommandLength+1)); err != errCommandTooLong {
+		t.Fatalf("long command = %v, want errCommandTooLong", err)
+	}
+}
+
+func TestDial_WrongPassword(t *testing.T) {
+	address := fakeServer(t, "secret", nil)
+	if _, err := dial(address, "wrong"); err != errAuthFailed {
+		t.Fatalf("dial with wrong password = %v, want errAuthFailed", err)
+	}
+}
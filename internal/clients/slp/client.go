@@ -0,0 +1,178 @@
+// Package slp queries the status of a Minecraft server with the Server List Ping protocol,
+// the same request the multiplayer screen sends.
+package slp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// statusState is the next state of the handshake that asks for the status
+	statusState = 1
+	// maxResponseLength bounds the status JSON, which carries the favicon as base64
+	maxResponseLength = 1 << 20
+	defaultTimeout    = 3 * time.Second
+)
+
+var errInvalidResponse = errors.New("slp: invalid status response")
+
+// StatusPinger queries the status of a server
+type StatusPinger interface {
+	Ping() (Status, error)
+}
+
+// Status is the part of the status response mc-admin uses
+type Status struct {
+	// VersionName is the version shown in the server list, e.g. "1.21.4" or "Paper 1.21.4"
+	VersionName string
+	Protocol    int
+	Online      int
+	Max         int
+	// Modded is true when the response carries Forge or NeoForge mod data
+	Modded bool
+}
+
+// Client pings a single server
+type Client struct {
+	Address string
+	Timeout time.Duration
+}
+
+// NewClient creates a Client for a host:port address
+func NewClient(address string) *Client {
+	return &Client{Address: address, Timeout: defaultTimeout}
+}
+
+// Ping sends a handshake and a status request and parses the JSON response
+func (c *Client) Ping() (Status, error) {
+	host, portText, err := net.SplitHostPort(c.Address)
+	if err != nil {
+		return Status{}, err
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return Status{}, fmt.Errorf("slp: invalid port %q", portText)
+	}
+	conn, err := net.DialTimeout("tcp", c.Address, c.Timeout)
+	if err != nil {
+		return Status{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.Timeout))
+
+	var handshake bytes.Buffer
+	writeVarInt(&handshake, 0x00)
+	// -1 asks for the status without claiming a protocol version
+	writeVarInt(&handshake, -1)
+	writeVarInt(&handshake, int32(len(host)))
+	handshake.WriteString(host)
+	binary.Write(&handshake, binary.BigEndian, uint16(port))
+	writeVarInt(&handshake, statusState)
+
+	var request bytes.Buffer
+	writePacket(&request, handshake.Bytes())
+	writePacket(&request, []byte{0x00})
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return Status{}, err
+	}
+
+	body, err := readStatusJSON(bufio.NewReader(conn))
+	if err != nil {
+		return Status{}, err
+	}
+	return parseStatus(body)
+}
+
+func writeVarInt(w *bytes.Buffer, value int32) {
+	v := uint32(value)
+	for {
+		if v&^0x7F == 0 {
+			w.WriteByte(byte(v))
+			return
+		}
+		w.WriteByte(byte(v&0x7F | 0x80))
+		v >>= 7
+	}
+}
+
+func writePacket(w *bytes.Buffer, payload []byte) {
+	writeVarInt(w, int32(len(payload)))
+	w.Write(payload)
+}
+
+func readVarInt(r io.ByteReader) (int32, error) {
+	var value uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint32(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return int32(value), nil
+		}
+	}
+	return 0, errInvalidResponse
+}
+
+// readStatusJSON reads the status response packet and returns its JSON string
+func readStatusJSON(r *bufio.Reader) ([]byte, error) {
+	length, err := readVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if length < 1 || length > maxResponseLength {
+		return nil, errInvalidResponse
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, err
+	}
+	payload := bytes.NewReader(packet)
+	if id, err := readVarInt(payload); err != nil || id != 0x00 {
+		return nil, errInvalidResponse
+	}
+	size, err := readVarInt(payload)
+	if err != nil || size < 0 || int(size) > payload.Len() {
+		return nil, errInvalidResponse
+	}
+	body := make([]byte, size)
+	payload.Read(body)
+	return body, nil
+}
+
+func parseStatus(body []byte) (Status, error) {
+	var raw struct {
+		Version struct {
+			Name     string `json:"name"`
+			Protocol int    `json:"protocol"`
+		} `json:"version"`
+		Players struct {
+			Max    int `json:"max"`
+			Online int `json:"online"`
+		} `json:"players"`
+		ForgeData json.RawMessage `json:"forgeData"`
+		ModInfo   json.RawMessage `json:"modinfo"`
+		IsModded  bool            `json:"isModded"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return Status{}, fmt.Errorf("slp: %w", err)
+	}
+	return Status{
+		VersionName: strings.TrimSpace(raw.Version.Name),
+		Protocol:    raw.Version.Protocol,
+		Online:      raw.Players.Online,
+		Max:         raw.Players.Max,
+		Modded:      raw.ForgeData != nil || raw.ModInfo != nil || raw.IsModded,
+	}, nil
+}
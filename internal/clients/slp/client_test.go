@@ -0,0 +1,117 @@
+package slp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeServer answers one status request per connection with the given JSON and records the
+// handshake it received
+func fakeServer(t *testing.T, status string, handshakes chan<- []byte) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				var packets [][]byte
+				for len(packets) < 2 {
+					length, err := readVarInt(r)
+					if err != nil {
+						return
+					}
+					packet := make([]byte, length)
+					if _, err := io.ReadFull(r, packet); err != nil {
+						return
+					}
+					packets = append(packets, packet)
+				}
+				handshakes <- packets[0]
+
+				var payload bytes.Buffer
+				writeVarInt(&payload, 0x00)
+				writeVarInt(&payload, int32(len(status)))
+				payload.WriteString(status)
+				var response bytes.Buffer
+				writePacket(&response, payload.Bytes())
+				conn.Write(response.Bytes())
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestClient_Ping(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		want   Status
+	}{
+		{
+			name:   "vanilla",
+			status: `{"version":{"name":"1.21.4","protocol":769},"players":{"max":20,"online":2},"description":{"text":"A Minecraft Server"}}`,
+			want:   Status{VersionName: "1.21.4", Protocol: 769, Online: 2, Max: 20},
+		},
+		{
+			name:   "paper",
+			status: `{"version":{"name":"Paper 1.21.4","protocol":769},"players":{"max":50,"online":0},"description":"hi"}`,
+			want:   Status{VersionName: "Paper 1.21.4", Protocol: 769, Max: 50},
+		},
+		{
+			name:   "forge",
+			status: `{"version":{"name":"1.20.1","protocol":763},"players":{"max":20,"online":0},"forgeData":{"channels":[],"mods":[],"fmlNetworkVersion":3}}`,
+			want:   Status{VersionName: "1.20.1", Protocol: 763, Max: 20, Modded: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handshakes := make(chan []byte, 1)
+			address := fakeServer(t, tt.status, handshakes)
+			client := NewClient(address)
+			client.Timeout = time.Second
+
+			status, err := client.Ping()
+			if err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+			if status != tt.want {
+				t.Fatalf("status = %+v, want %+v", status, tt.want)
+			}
+
+			handshake := <-handshakes
+			host, _, _ := net.SplitHostPort(address)
+			// packet id, protocol -1 as a 5 byte VarInt, then the host string
+			if handshake[0] != 0x00 || !bytes.Equal(handshake[1:6], []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x0F}) ||
+				string(handshake[7:7+len(host)]) != host || handshake[len(handshake)-1] != statusState {
+				t.Fatalf("handshake = %x", handshake)
+			}
+		})
+	}
+}
+
+func TestClient_PingUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	if _, err := NewClient(address).Ping(); err == nil {
+		t.Fatalf("Ping of a closed port succeeded")
+	}
+}
@@ -0,0 +1,52 @@
+// Package webhook posts JSON notifications to an incoming webhook, such as a Discord or Slack
+// channel webhook.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client posts to one webhook URL
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+// NewClient validates webhookURL and creates a Client
+func NewClient(webhookURL string) (*Client, error) {
+	if u, err := url.Parse(webhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL: %q", webhookURL)
+	}
+	return &Client{url: webhookURL, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Post sends payload as JSON, any 2xx response counts as delivered
+func (c *Client) Post(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook: returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
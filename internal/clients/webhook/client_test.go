@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_Post(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL + "/api/webhooks/1/token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Post(context.Background(), map[string]string{"content": "hello"}); err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if body["content"] != "hello" {
+		t.Fatalf("body = %v", body)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message": "Cannot send an empty message"}`))
+	}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL)
+	if err := client.Post(context.Background(), map[string]string{}); err == nil || !strings.Contains(err.Error(), "empty message") {
+		t.Fatalf("err = %v, want the response body", err)
+	}
+	for _, invalid := range []string{"", "discord.com/api/webhooks", "ftp://example.com"} {
+		if _, err := NewClient(invalid); err == nil {
+			t.Errorf("NewClient(%q) succeeded", invalid)
+		}
+	}
+}
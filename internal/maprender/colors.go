@@ -0,0 +1,184 @@
+package maprender
+
+import (
+	"image/color"
+	"strings"
+)
+
+// blockColors are the map colors of common surface blocks, keyed without the minecraft: namespace
+var blockColors = map[string]color.NRGBA{
+	"grass_block":       {R: 0x7f, G: 0xb2, B: 0x38, A: 0xff},
+	"short_grass":       {R: 0x7f, G: 0xb2, B: 0x38, A: 0xff},
+	"tall_grass":        {R: 0x7f, G: 0xb2, B: 0x38, A: 0xff},
+	"grass":             {R: 0x7f, G: 0xb2, B: 0x38, A: 0xff},
+	"fern":              {R: 0x6a, G: 0x9c, B: 0x2f, A: 0xff},
+	"dirt":              {R: 0x97, G: 0x6d, B: 0x4d, A: 0xff},
+	"coarse_dirt":       {R: 0x88, G: 0x61, B: 0x44, A: 0xff},
+	"rooted_dirt":       {R: 0x90, G: 0x68, B: 0x4b, A: 0xff},
+	"dirt_path":         {R: 0x94, G: 0x7a, B: 0x41, A: 0xff},
+	"farmland":          {R: 0x8f, G: 0x66, B: 0x45, A: 0xff},
+	"podzol":            {R: 0x81, G: 0x56, B: 0x31, A: 0xff},
+	"mycelium":          {R: 0x6f, G: 0x62, B: 0x65, A: 0xff},
+	"mud":               {R: 0x3c, G: 0x3a, B: 0x3d, A: 0xff},
+	"sand":              {R: 0xf7, G: 0xe9, B: 0xa3, A: 0xff},
+	"red_sand":          {R: 0xd8, G: 0x7f, B: 0x33, A: 0xff},
+	"gravel":            {R: 0x88, G: 0x82, B: 0x80, A: 0xff},
+	"clay":              {R: 0xa4, G: 0xa8, B: 0xb8, A: 0xff},
+	"stone":             {R: 0x70, G: 0x70, B: 0x70, A: 0xff},
+	"cobblestone":       {R: 0x7a, G: 0x7a, B: 0x7a, A: 0xff},
+	"deepslate":         {R: 0x50, G: 0x50, B: 0x55, A: 0xff},
+	"andesite":          {R: 0x88, G: 0x88, B: 0x88, A: 0xff},
+	"diorite":           {R: 0xbc, G: 0xbc, B: 0xbc, A: 0xff},
+	"granite":           {R: 0x95, G: 0x67, B: 0x55, A: 0xff},
+	"calcite":           {R: 0xdf, G: 0xe0, B: 0xdc, A: 0xff},
+	"tuff":              {R: 0x6c, G: 0x6d, B: 0x66, A: 0xff},
+	"bedrock":           {R: 0x55, G: 0x55, B: 0x55, A: 0xff},
+	"water":             {R: 0x40, G: 0x40, B: 0xff, A: 0xff},
+	"bubble_column":     {R: 0x40, G: 0x40, B: 0xff, A: 0xff},
+	"kelp":              {R: 0x40, G: 0x40, B: 0xff, A: 0xff},
+	"kelp_plant":        {R: 0x40, G: 0x40, B: 0xff, A: 0xff},
+	"seagrass":          {R: 0x40, G: 0x40, B: 0xff, A: 0xff},
+	"tall_seagrass":     {R: 0x40, G: 0x40, B: 0xff, A: 0xff},
+	"lava":              {R: 0xff, G: 0x00, B: 0x00, A: 0xff},
+	"ice":               {R: 0xa0, G: 0xa0, B: 0xff, A: 0xff},
+	"packed_ice":        {R: 0x8d, G: 0xb4, B: 0xfa, A: 0xff},
+	"blue_ice":          {R: 0x74, G: 0xa8, B: 0xfd, A: 0xff},
+	"snow":              {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	"snow_block":        {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	"powder_snow":       {R: 0xf8, G: 0xfd, B: 0xfd, A: 0xff},
+	"netherrack":        {R: 0x70, G: 0x02, B: 0x00, A: 0xff},
+	"nether_wart_block": {R: 0x73, G: 0x03, B: 0x02, A: 0xff},
+	"warped_wart_block": {R: 0x16, G: 0x7e, B: 0x86, A: 0xff},
+	"crimson_nylium":    {R: 0xbd, G: 0x30, B: 0x31, A: 0xff},
+	"warped_nylium":     {R: 0x16, G: 0x7e, B: 0x86, A: 0xff},
+	"soul_sand":         {R: 0x66, G: 0x4c, B: 0x33, A: 0xff},
+	"soul_soil":         {R: 0x66, G: 0x4c, B: 0x33, A: 0xff},
+	"basalt":            {R: 0x19, G: 0x19, B: 0x19, A: 0xff},
+	"blackstone":        {R: 0x19, G: 0x19, B: 0x19, A: 0xff},
+	"glowstone":         {R: 0xf7, G: 0xe9, B: 0xa3, A: 0xff},
+	"magma_block":       {R: 0x70, G: 0x02, B: 0x00, A: 0xff},
+	"end_stone":         {R: 0xf7, G: 0xe9, B: 0xa3, A: 0xff},
+	"obsidian":          {R: 0x19, G: 0x19, B: 0x19, A: 0xff},
+	"chorus_plant":      {R: 0x7f, G: 0x3f, B: 0xb2, A: 0xff},
+	"chorus_flower":     {R: 0x7f, G: 0x3f, B: 0xb2, A: 0xff},
+	"purpur_block":      {R: 0xb2, G: 0x4c, B: 0xd8, A: 0xff},
+	"cactus":            {R: 0x00, G: 0x7c, B: 0x00, A: 0xff},
+	"sugar_cane":        {R: 0x00, G: 0x7c, B: 0x00, A: 0xff},
+	"bamboo":            {R: 0x00, G: 0x7c, B: 0x00, A: 0xff},
+	"lily_pad":          {R: 0x00, G: 0x7c, B: 0x00, A: 0xff},
+	"vine":              {R: 0x00, G: 0x7c, B: 0x00, A: 0xff},
+	"moss_block":        {R: 0x59, G: 0x6d, B: 0x2d, A: 0xff},
+	"moss_carpet":       {R: 0x59, G: 0x6d, B: 0x2d, A: 0xff},
+	"pumpkin":           {R: 0xd8, G: 0x7f, B: 0x33, A: 0xff},
+	"melon":             {R: 0x7f, G: 0xcc, B: 0x19, A: 0xff},
+	"hay_block":         {R: 0xe5, G: 0xe5, B: 0x33, A: 0xff},
+	"bricks":            {R: 0x99, G: 0x33, B: 0x33, A: 0xff},
+	"glass":             {R: 0xd0, G: 0xe8, B: 0xf0, A: 0xff},
+}
+
+// dyeColors are used for wool, concrete, carpet and similar blocks prefixed with a dye name
+var dyeColors = []struct {
+	name  string
+	color color.NRGBA
+}{
+	// longer names first so "light_gray" wins over "gray"
+	{"light_blue", color.NRGBA{R: 0x66, G: 0x99, B: 0xd8, A: 0xff}},
+	{"light_gray", color.NRGBA{R: 0x99, G: 0x99, B: 0x99, A: 0xff}},
+	{"magenta", color.NRGBA{R: 0xb2, G: 0x4c, B: 0xd8, A: 0xff}},
+	{"orange", color.NRGBA{R: 0xd8, G: 0x7f, B: 0x33, A: 0xff}},
+	{"yellow", color.NRGBA{R: 0xe5, G: 0xe5, B: 0x33, A: 0xff}},
+	{"purple", color.NRGBA{R: 0x7f, G: 0x3f, B: 0xb2, A: 0xff}},
+	{"white", color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+	{"brown", color.NRGBA{R: 0x66, G: 0x4c, B: 0x33, A: 0xff}},
+	{"green", color.NRGBA{R: 0x66, G: 0x7f, B: 0x33, A: 0xff}},
+	{"black", color.NRGBA{R: 0x19, G: 0x19, B: 0x19, A: 0xff}},
+	{"lime", color.NRGBA{R: 0x7f, G: 0xcc, B: 0x19, A: 0xff}},
+	{"pink", color.NRGBA{R: 0xf2, G: 0x7f, B: 0xa5, A: 0xff}},
+	{"gray", color.NRGBA{R: 0x4c, G: 0x4c, B: 0x4c, A: 0xff}},
+	{"cyan", color.NRGBA{R: 0x4c, G: 0x7f, B: 0x99, A: 0xff}},
+	{"blue", color.NRGBA{R: 0x33, G: 0x4c, B: 0xb2, A: 0xff}},
+	{"red", color.NRGBA{R: 0x99, G: 0x33, B: 0x33, A: 0xff}},
+}
+
+// keywordColors give a plausible color to blocks missing from blockColors
+var keywordColors = []struct {
+	keyword string
+	color   color.NRGBA
+}{
+	{"leaves", color.NRGBA{R: 0x00, G: 0x7c, B: 0x00, A: 0xff}},
+	{"log", color.NRGBA{R: 0x66, G: 0x4c, B: 0x33, A: 0xff}},
+	{"wood", color.NRGBA{R: 0x66, G: 0x4c, B: 0x33, A: 0xff}},
+	{"stem", color.NRGBA{R: 0x5c, G: 0x19, B: 0x1d, A: 0xff}},
+	{"planks", color.NRGBA{R: 0x8f, G: 0x77, B: 0x48, A: 0xff}},
+	{"slab", color.NRGBA{R: 0x8f, G: 0x77, B: 0x48, A: 0xff}},
+	{"stairs", color.NRGBA{R: 0x8f, G: 0x77, B: 0x48, A: 0xff}},
+	{"fence", color.NRGBA{R: 0x8f, G: 0x77, B: 0x48, A: 0xff}},
+	{"door", color.NRGBA{R: 0x8f, G: 0x77, B: 0x48, A: 0xff}},
+	{"terracotta", color.NRGBA{R: 0x98, G: 0x5e, B: 0x43, A: 0xff}},
+	{"sandstone", color.NRGBA{R: 0xf7, G: 0xe9, B: 0xa3, A: 0xff}},
+	{"deepslate", color.NRGBA{R: 0x50, G: 0x50, B: 0x55, A: 0xff}},
+	{"copper", color.NRGBA{R: 0xd8, G: 0x7f, B: 0x33, A: 0xff}},
+	{"ore", color.NRGBA{R: 0x70, G: 0x70, B: 0x70, A: 0xff}},
+	{"stone", color.NRGBA{R: 0x70, G: 0x70, B: 0x70, A: 0xff}},
+	{"coral", color.NRGBA{R: 0xf2, G: 0x7f, B: 0xa5, A: 0xff}},
+	{"flower", color.NRGBA{R: 0x7f, G: 0xb2, B: 0x38, A: 0xff}},
+	{"mushroom", color.NRGBA{R: 0x97, G: 0x6d, B: 0x4d, A: 0xff}},
+	{"ice", color.NRGBA{R: 0xa0, G: 0xa0, B: 0xff, A: 0xff}},
+}
+
+var fallbackColor = color.NRGBA{R: 0x90, G: 0x90, B: 0x90, A: 0xff}
+
+// blockColor returns the base map color of a block such as "minecraft:oak_leaves"
+func blockColor(name string) color.NRGBA {
+	name = strings.TrimPrefix(name, "minecraft:")
+	if c, ok := blockColors[name]; ok {
+		return c
+	}
+	if strings.HasSuffix(name, "wool") || strings.HasSuffix(name, "carpet") || strings.HasSuffix(name, "concrete") ||
+		strings.HasSuffix(name, "concrete_powder") || strings.HasSuffix(name, "stained_glass") || strings.HasSuffix(name, "bed") {
+		for _, dye := range dyeColors {
+			if strings.HasPrefix(name, dye.name+"_") {
+				return dye.color
+			}
+		}
+	}
+	for _, k := range keywordColors {
+		if strings.Contains(name, k.keyword) {
+			return k.color
+		}
+	}
+	return fallbackColor
+}
+
+// isWater reports whether the block is rendered as water, which gets depth shading
+func isWater(name string) bool {
+	switch strings.TrimPrefix(name, "minecraft:") {
+	case "water", "bubble_column", "kelp", "kelp_plant", "seagrass", "tall_seagrass":
+		return true
+	}
+	return false
+}
+
+// isAir reports whether the block is invisible from above
+func isAir(name string) bool {
+	switch strings.TrimPrefix(name, "minecraft:") {
+	case "", "air", "cave_air", "void_air", "barrier", "light", "structure_void":
+		return true
+	}
+	return false
+}
+
+// shade multiplies the color channels by factor, clamping to the valid range
+func shade(c color.NRGBA, factor float64) color.NRGBA {
+	scale := func(v uint8) uint8 {
+		f := float64(v) * factor
+		if f > 255 {
+			return 255
+		}
+		if f < 0 {
+			return 0
+		}
+		return uint8(f)
+	}
+	return color.NRGBA{R: scale(c.R), G: scale(c.G), B: scale(c.B), A: c.A}
+}
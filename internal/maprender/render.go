@@ -0,0 +1,214 @@
+// Package maprender draws top-down map tiles from Anvil region files.
+package maprender
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+	"mc-admin/internal/regions"
+)
+
+const (
+	// TileSize is the edge length in pixels of a region tile, one pixel per block
+	TileSize = 512
+	// legacyMinY is the bottom of the world before 1.18 added negative heights
+	legacyMinY = 0
+	// defaultMinY is the bottom of the overworld since 1.18
+	defaultMinY = -64
+	// maxSurfaceScan limits the downward search when a heightmap points at air
+	maxSurfaceScan = 32
+)
+
+// column is the visible block of one x/z position
+type column struct {
+	block      string
+	y          int
+	waterDepth int
+	ok         bool
+}
+
+// section is one 16x16x16 cube of a chunk
+type section struct {
+	palette []string
+	data    []int64
+	bits    int
+}
+
+func (s *section) block(x, y, z int) string {
+	if len(s.palette) == 0 {
+		return ""
+	}
+	if len(s.palette) == 1 || len(s.data) == 0 {
+		return s.palette[0]
+	}
+	index := y*256 + z*16 + x
+	perLong := 64 / s.bits
+	if index/perLong >= len(s.data) {
+		return ""
+	}
+	value := int((uint64(s.data[index/perLong]) >> ((index % perLong) * s.bits)) & (1<<s.bits - 1))
+	if value >= len(s.palette) {
+		return ""
+	}
+	return s.palette[value]
+}
+
+// chunkBlocks gives access to the blocks of a decoded chunk
+type chunkBlocks struct {
+	sections map[int]*section
+	heights  []int64
+	minY     int
+}
+
+// parseChunk extracts the sections and surface heightmap of a chunk. Chunks written since
+// 1.18 keep them at the root, older ones (1.16+) in a Level compound with different names.
+func parseChunk(data regions.Compound) (*chunkBlocks, bool) {
+	root, legacy := data, false
+	if level := data.Compound("Level"); level != nil {
+		root, legacy = level, true
+	}
+	heightmaps := root.Compound("Heightmaps")
+	heights := heightmaps.LongArray("WORLD_SURFACE")
+	if len(heights) == 0 {
+		heights = heightmaps.LongArray("MOTION_BLOCKING")
+	}
+	if len(heights) == 0 {
+		// proto chunks that are not fully generated have no usable surface yet
+		return nil, false
+	}
+
+	chunk := &chunkBlocks{sections: map[int]*section{}, heights: heights, minY: defaultMinY}
+	sectionList := root.List("sections")
+	if legacy {
+		chunk.minY = legacyMinY
+		sectionList = root.List("Sections")
+	} else if yPos, ok := root.Int("yPos"); ok {
+		chunk.minY = int(yPos) * 16
+	}
+
+	for _, item := range sectionList {
+		raw, ok := item.(regions.Compound)
+		if !ok {
+			continue
+		}
+		y, ok := raw.Int("Y")
+		if !ok {
+			continue
+		}
+		paletteList, data := raw.List("Palette"), raw.LongArray("BlockStates")
+		if states := raw.Compound("block_states"); states != nil {
+			paletteList, data = states.List("palette"), states.LongArray("data")
+		}
+		if len(paletteList) == 0 {
+			continue
+		}
+		s := &section{data: data, bits: max(4, bits.Len(uint(len(paletteList)-1)))}
+		for _, entry := range paletteList {
+			block, _ := entry.(regions.Compound)
+			s.palette = append(s.palette, block.String("Name"))
+		}
+		chunk.sections[int(y)] = s
+	}
+	return chunk, true
+}
+
+// height returns the heightmap value (number of blocks above minY) of a column
+func (c *chunkBlocks) height(x, z int) int {
+	const bitsPerEntry, perLong = 9, 7
+	index := z*16 + x
+	if index/perLong >= len(c.heights) {
+		return 0
+	}
+	return int((uint64(c.heights[index/perLong]) >> ((index % perLong) * bitsPerEntry)) & (1<<bitsPerEntry - 1))
+}
+
+func (c *chunkBlocks) block(x, y, z int) string {
+	s, ok := c.sections[y>>4]
+	if !ok {
+		return ""
+	}
+	return s.block(x, y&15, z)
+}
+
+// surface finds the visible block of a column and how deep the water above the ground is
+func (c *chunkBlocks) surface(x, z int) column {
+	h := c.height(x, z)
+	if h == 0 {
+		return column{}
+	}
+	top := c.minY + h - 1
+	for y := top; y >= top-maxSurfaceScan && y >= c.minY; y-- {
+		name := c.block(x, y, z)
+		if isAir(name) {
+			continue
+		}
+		col := column{block: name, y: y, ok: true}
+		if isWater(name) {
+			for d := y; d > y-maxSurfaceScan && d >= c.minY && isWater(c.block(x, d, z)); d-- {
+				col.waterDepth++
+			}
+		}
+		return col
+	}
+	return column{}
+}
+
+// RenderRegion draws a region file as a TileSize x TileSize image. Missing or
+// unreadable chunks stay transparent.
+func RenderRegion(path string) (*image.NRGBA, error) {
+	columns := make([][TileSize]column, TileSize)
+	err := regions.ForEachChunk(path, func(info regions.ChunkInfo, data regions.Compound) error {
+		if data == nil {
+			return nil
+		}
+		chunk, ok := parseChunk(data)
+		if !ok {
+			return nil
+		}
+		baseX, baseZ := (info.Index%32)*16, (info.Index/32)*16
+		for z := 0; z < 16; z++ {
+			for x := 0; x < 16; x++ {
+				columns[baseZ+z][baseX+x] = chunk.surface(x, z)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, TileSize, TileSize))
+	for z := 0; z < TileSize; z++ {
+		for x := 0; x < TileSize; x++ {
+			col := columns[z][x]
+			if !col.ok {
+				continue
+			}
+			north := col
+			if z > 0 && columns[z-1][x].ok {
+				north = columns[z-1][x]
+			}
+			img.SetNRGBA(x, z, columnColor(col, north))
+		}
+	}
+	return img, nil
+}
+
+// columnColor shades a block like vanilla maps: lighter when higher than the block to the
+// north, darker when lower. Water gets darker with depth instead.
+func columnColor(col column, north column) color.NRGBA {
+	base := blockColor(col.block)
+	if col.waterDepth > 0 {
+		return shade(base, 1.1-0.05*float64(min(col.waterDepth, 10)))
+	}
+	factor := 1.0
+	switch {
+	case col.y > north.y:
+		factor = 1.12
+	case col.y < north.y:
+		factor = 0.82
+	}
+	// a slight tint by absolute height keeps plateaus distinguishable from valleys
+	factor += float64(col.y-64) * 0.0015
+	return shade(base, factor)
+}
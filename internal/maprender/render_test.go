@@ -0,0 +1,242 @@
+package maprender
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"mc-admin/internal/regions"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// writeNBT encodes a value as NBT. Only the types needed by the tests are supported.
+func writeNBT(b *bytes.Buffer, name string, value any) {
+	writeName := func(tagType byte) {
+		b.WriteByte(tagType)
+		binary.Write(b, binary.BigEndian, uint16(len(name)))
+		b.WriteString(name)
+	}
+	switch v := value.(type) {
+	case int8:
+		writeName(1)
+		b.WriteByte(byte(v))
+	case int32:
+		writeName(3)
+		binary.Write(b, binary.BigEndian, v)
+	case string:
+		writeName(8)
+		binary.Write(b, binary.BigEndian, uint16(len(v)))
+		b.WriteString(v)
+	case []int64:
+		writeName(12)
+		binary.Write(b, binary.BigEndian, int32(len(v)))
+		binary.Write(b, binary.BigEndian, v)
+	case []map[string]any:
+		writeName(9)
+		b.WriteByte(10)
+		binary.Write(b, binary.BigEndian, int32(len(v)))
+		for _, elem := range v {
+			writeCompoundBody(b, elem)
+		}
+	case map[string]any:
+		writeName(10)
+		writeCompoundBody(b, v)
+	}
+}
+
+func writeCompoundBody(b *bytes.Buffer, c map[string]any) {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeNBT(b, k, c[k])
+	}
+	b.WriteByte(0)
+}
+
+// packHeights packs 256 heightmap values with 9 bits each, 7 per long
+func packHeights(height func(x, z int) int) []int64 {
+	packed := make([]int64, 37)
+	for i := 0; i < 256; i++ {
+		packed[i/7] |= int64(height(i%16, i/16)) << ((i % 7) * 9)
+	}
+	return packed
+}
+
+// testChunk has grass at y=64 in the west half and water from y=60 to y=64 in the east half
+func testChunk() []byte {
+	// palette: 0 air, 1 grass_block, 2 water, 3 stone; 4 bits per block, 16 per long
+	data := make([]int64, 256)
+	set := func(x, y, z int, v int64) {
+		i := y*256 + z*16 + x
+		data[i/16] |= v << ((i % 16) * 4)
+	}
+	for z := 0; z < 16; z++ {
+		for x := 0; x < 16; x++ {
+			if x < 8 {
+				set(x, 0, z, 1) // y = 64
+			} else {
+				set(x, 0, z, 2)
+			}
+		}
+	}
+	// section 3 is stone with water from y=60 to y=63 under the east half
+	waterBelow := make([]int64, 256)
+	for i := 0; i < 4096; i++ {
+		x, y := i%16, i/256
+		v := int64(3)
+		if x >= 8 && y >= 12 {
+			v = 2
+		}
+		waterBelow[i/16] |= v << ((i % 16) * 4)
+	}
+	palette := []map[string]any{
+		{"Name": "minecraft:air"}, {"Name": "minecraft:grass_block"}, {"Name": "minecraft:water"}, {"Name": "minecraft:stone"},
+	}
+	chunk := map[string]any{
+		"DataVersion": int32(3465),
+		"yPos":        int32(-4),
+		"Status":      "minecraft:full",
+		"Heightmaps": map[string]any{
+			// height counts blocks above minY: y=64 -> 64+64+1
+			"WORLD_SURFACE": packHeights(func(x, z int) int { return 129 }),
+		},
+		"sections": []map[string]any{
+			{"Y": int8(4), "block_states": map[string]any{"palette": palette, "data": data}},
+			{"Y": int8(3), "block_states": map[string]any{"palette": palette, "data": waterBelow}},
+		},
+	}
+	var b bytes.Buffer
+	writeNBT(&b, "", chunk)
+	return b.Bytes()
+}
+
+// writeRegion writes a region file whose chunks (index -> nbt) are zlib compressed
+func writeRegion(t *testing.T, path string, chunks map[int][]byte) {
+	t.Helper()
+	data := make([]byte, 8192)
+	for i, payload := range chunks {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(payload)
+		zw.Close()
+		body := make([]byte, 5, 5+compressed.Len())
+		binary.BigEndian.PutUint32(body, uint32(compressed.Len()+1))
+		body[4] = 2
+		body = append(body, compressed.Bytes()...)
+		sectors := (len(body) + 4095) / 4096
+		body = append(body, make([]byte, sectors*4096-len(body))...)
+		binary.BigEndian.PutUint32(data[i*4:], uint32(len(data)/4096<<8|sectors))
+		data = append(data, body...)
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderRegion(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "r.0.0.mca")
+	writeRegion(t, path, map[int][]byte{0: testChunk()})
+
+	img, err := RenderRegion(path)
+	if err != nil {
+		t.Fatalf("RenderRegion: %v", err)
+	}
+	grass := img.NRGBAAt(2, 5)
+	if grass.A == 0 || grass.G <= grass.R || grass.G <= grass.B {
+		t.Fatalf("grass pixel = %v, expected green", grass)
+	}
+	water := img.NRGBAAt(12, 5)
+	if water.B <= water.R || water.B <= water.G {
+		t.Fatalf("water pixel = %v, expected blue", water)
+	}
+	if deep := shade(blockColor("minecraft:water"), 1.1-0.05*5); water != deep {
+		t.Fatalf("water pixel = %v, expected depth shading for 5 blocks (%v)", water, deep)
+	}
+	if empty := img.NRGBAAt(100, 100); empty.A != 0 {
+		t.Fatalf("pixel outside generated chunks = %v, expected transparent", empty)
+	}
+}
+
+func TestBlockColor(t *testing.T) {
+	tests := map[string]color.NRGBA{
+		"minecraft:light_blue_wool": dyeColors[0].color,
+		"minecraft:oak_leaves":      keywordColors[0].color,
+		"minecraft:unknown_modded":  fallbackColor,
+		"minecraft:sand":            blockColors["sand"],
+	}
+	for name, want := range tests {
+		if got := blockColor(name); got != want {
+			t.Errorf("blockColor(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestTileStore_Incremental(t *testing.T) {
+	world := t.TempDir()
+	dim := regions.Dimension{Name: "overworld", Dir: world}
+	writeRegion(t, dim.RegionPath(0, 0), map[int][]byte{0: testChunk()})
+	writeRegion(t, dim.RegionPath(1, 1), map[int][]byte{0: testChunk()})
+	store := NewTileStore(filepath.Join(t.TempDir(), "cache"))
+
+	path, err := store.Tile(dim, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Tile: %v", err)
+	}
+	first, _ := os.Stat(path)
+	os.WriteFile(path, []byte("stale marker"), 0644)
+	os.Chtimes(path, first.ModTime(), first.ModTime())
+
+	// unchanged region: the cached file is served as is
+	if _, err := store.Tile(dim, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "stale marker" {
+		t.Fatalf("unchanged region was re-rendered")
+	}
+
+	// changed region: the tile is rendered again
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(dim.RegionPath(0, 0), later, later)
+	if _, err := store.Tile(dim, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) == "stale marker" {
+		t.Fatalf("changed region was not re-rendered")
+	}
+
+	// zoomed out tile covers both regions and takes the newest source time
+	outPath, err := store.Tile(dim, -1, 0, 0)
+	if err != nil {
+		t.Fatalf("zoomed out Tile: %v", err)
+	}
+	img, err := readTile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(1, 2).RGBA(); a == 0 {
+		t.Fatalf("zoomed out tile is missing region 0,0")
+	}
+	if _, _, _, a := img.At(TileSize/2+1, TileSize/2+2).RGBA(); a == 0 {
+		t.Fatalf("zoomed out tile is missing region 1,1")
+	}
+	if info, _ := os.Stat(outPath); !info.ModTime().Equal(later) {
+		t.Fatalf("zoomed out tile time = %v, want %v", info.ModTime(), later)
+	}
+
+	if _, err := store.Tile(dim, 0, 5, 5); !errors.Is(err, ErrNoTile) {
+		t.Fatalf("missing region = %v, want ErrNoTile", err)
+	}
+	if _, err := store.Tile(dim, -1, 4, 4); !errors.Is(err, ErrNoTile) {
+		t.Fatalf("missing zoomed out tile = %v, want ErrNoTile", err)
+	}
+}
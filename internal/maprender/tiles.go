@@ -0,0 +1,182 @@
+package maprender
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"mc-admin/internal/regions"
+	"mc-admin/internal/utils"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MaxZoomOut is the number of zoom levels below the region level. At zoom -k a tile covers 2^k x 2^k regions.
+const MaxZoomOut = 5
+
+// ErrNoTile is returned for tiles without any generated region
+var ErrNoTile = errors.New("no tile at this position")
+
+// TileStore renders tiles on demand and caches them as PNG files. A cached tile carries the
+// modification time of its newest source, so only tiles whose regions changed are re-rendered.
+type TileStore struct {
+	cacheDir string
+
+	locks utils.KeyedMutex
+}
+
+// NewTileStore creates a TileStore that caches tiles below cacheDir
+func NewTileStore(cacheDir string) *TileStore {
+	return &TileStore{cacheDir: cacheDir}
+}
+
+// lock serializes work on one tile while letting different tiles render in parallel
+func (s *TileStore) lock(key string) func() {
+	return s.locks.Lock(key)
+}
+
+func (s *TileStore) tilePath(d regions.Dimension, zoom int, x, y int) string {
+	return filepath.Join(s.cacheDir, d.Name, fmt.Sprintf("%d", zoom), fmt.Sprintf("%d_%d.png", x, y))
+}
+
+// Tile returns the path of an up-to-date PNG for tile x, y at zoom (0 = one region per tile, negative = zoomed out)
+func (s *TileStore) Tile(d regions.Dimension, zoom int, x, y int) (string, error) {
+	path, _, err := s.tile(d, zoom, x, y)
+	return path, err
+}
+
+// tile returns the tile path and the modification time of its newest source
+func (s *TileStore) tile(d regions.Dimension, zoom int, x, y int) (string, time.Time, error) {
+	if zoom > 0 || zoom < -MaxZoomOut {
+		return "", time.Time{}, fmt.Errorf("zoom %d out of range", zoom)
+	}
+	path := s.tilePath(d, zoom, x, y)
+	unlock := s.lock(path)
+	defer unlock()
+
+	if zoom == 0 {
+		return s.regionTile(d, path, x, y)
+	}
+	return s.composedTile(d, path, zoom, x, y)
+}
+
+func (s *TileStore) regionTile(d regions.Dimension, path string, x, y int) (string, time.Time, error) {
+	source, err := os.Stat(d.RegionPath(x, y))
+	if err != nil {
+		return "", time.Time{}, ErrNoTile
+	}
+	if cached, err := os.Stat(path); err == nil && cached.ModTime().Equal(source.ModTime()) {
+		return path, source.ModTime(), nil
+	}
+	img, err := RenderRegion(d.RegionPath(x, y))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := writeTile(path, img, source.ModTime()); err != nil {
+		return "", time.Time{}, err
+	}
+	return path, source.ModTime(), nil
+}
+
+// composedTile downscales the four tiles of the next zoom level into one
+func (s *TileStore) composedTile(d regions.Dimension, path string, zoom int, x, y int) (string, time.Time, error) {
+	type child struct {
+		path    string
+		offsetX int
+		offsetY int
+	}
+	var children []child
+	var newest time.Time
+	for dy := 0; dy < 2; dy++ {
+		for dx := 0; dx < 2; dx++ {
+			childPath, modTime, err := s.tile(d, zoom+1, 2*x+dx, 2*y+dy)
+			if errors.Is(err, ErrNoTile) {
+				continue
+			}
+			if err != nil {
+				return "", time.Time{}, err
+			}
+			children = append(children, child{childPath, dx * TileSize / 2, dy * TileSize / 2})
+			if modTime.After(newest) {
+				newest = modTime
+			}
+		}
+	}
+	if len(children) == 0 {
+		return "", time.Time{}, ErrNoTile
+	}
+	if cached, err := os.Stat(path); err == nil && cached.ModTime().Equal(newest) {
+		return path, newest, nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, TileSize, TileSize))
+	for _, c := range children {
+		childImg, err := readTile(c.path)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		draw.Draw(img, image.Rect(c.offsetX, c.offsetY, c.offsetX+TileSize/2, c.offsetY+TileSize/2), downscale(childImg), image.Point{}, draw.Src)
+	}
+	if err := writeTile(path, img, newest); err != nil {
+		return "", time.Time{}, err
+	}
+	return path, newest, nil
+}
+
+// downscale halves an image by averaging 2x2 pixel blocks
+func downscale(src image.Image) *image.NRGBA {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx()/2, bounds.Dy()/2))
+	for y := 0; y < bounds.Dy()/2; y++ {
+		for x := 0; x < bounds.Dx()/2; x++ {
+			var r, g, b, a, n uint32
+			for _, p := range [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				c := color.NRGBAModel.Convert(src.At(bounds.Min.X+2*x+p[0], bounds.Min.Y+2*y+p[1])).(color.NRGBA)
+				if c.A == 0 {
+					continue
+				}
+				r, g, b, a, n = r+uint32(c.R), g+uint32(c.G), b+uint32(c.B), a+uint32(c.A), n+1
+			}
+			if n > 0 {
+				// transparent pixels only reduce coverage, they do not darken the color
+				dst.SetNRGBA(x, y, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / 4)})
+			}
+		}
+	}
+	return dst
+}
+
+func readTile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// writeTile stores a tile atomically and stamps it with the modification time of its source
+func writeTile(path string, img image.Image, sourceTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tile-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), sourceTime, sourceTime); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,158 @@
+package regions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Dimension is a directory holding the region/, entities/ and poi/ folders of one dimension
+type Dimension struct {
+	Name string
+	Dir  string
+}
+
+// WorldDimensions returns the dimensions of a world that have region files. Both the vanilla
+// layout (world/DIM-1) and the Bukkit layout (world_nether/DIM-1) are recognized.
+func WorldDimensions(worldDir string) []Dimension {
+	candidates := []Dimension{
+		{Name: "overworld", Dir: worldDir},
+		{Name: "nether", Dir: filepath.Join(worldDir, "DIM-1")},
+		{Name: "nether", Dir: filepath.Join(worldDir+"_nether", "DIM-1")},
+		{Name: "end", Dir: filepath.Join(worldDir, "DIM1")},
+		{Name: "end", Dir: filepath.Join(worldDir+"_the_end", "DIM1")},
+	}
+	seen := map[string]bool{}
+	var dimensions []Dimension
+	for _, d := range candidates {
+		if seen[d.Name] {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(d.Dir, "region")); err == nil && info.IsDir() {
+			seen[d.Name] = true
+			dimensions = append(dimensions, d)
+		}
+	}
+	return dimensions
+}
+
+// InhabitedBucket counts chunks whose InhabitedTime falls into a range
+type InhabitedBucket struct {
+	Label string
+	// Below is the exclusive upper bound, zero for the last bucket
+	Below time.Duration
+	Count int
+	Size  int64
+}
+
+// inhabitedBuckets are the ranges of the InhabitedTime distribution
+var inhabitedBuckets = []InhabitedBucket{
+	{Label: "never", Below: time.Second / ticksPerSecond},
+	{Label: "< 1 min", Below: time.Minute},
+	{Label: "< 10 min", Below: 10 * time.Minute},
+	{Label: "< 1 h", Below: time.Hour},
+	{Label: "< 10 h", Below: 10 * time.Hour},
+	{Label: "10 h or more"},
+}
+
+// DimensionReport summarizes the chunks of one dimension
+type DimensionReport struct {
+	Name        string
+	Dir         string
+	RegionFiles int
+	Chunks      int
+	TotalSize   int64
+	// UnreadableChunks could not be parsed and are excluded from the distribution
+	UnreadableChunks int
+	Inhabited        []InhabitedBucket
+	Statuses         map[string]int
+	Errors           []string
+}
+
+// InhabitedDuration converts InhabitedTime ticks to a duration
+func InhabitedDuration(ticks int64) time.Duration {
+	return time.Duration(ticks) * time.Second / ticksPerSecond
+}
+
+// listRegionFiles returns the region files below dir/sub sorted by name
+func listRegionFiles(dir string, sub string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, sub))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if _, _, ok := parseRegionName(entry.Name()); ok && !entry.IsDir() {
+			paths = append(paths, filepath.Join(dir, sub, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// AnalyzeDimension reads every region file of a dimension
+func AnalyzeDimension(d Dimension) (DimensionReport, error) {
+	report := DimensionReport{
+		Name:      d.Name,
+		Dir:       d.Dir,
+		Inhabited: append([]InhabitedBucket(nil), inhabitedBuckets...),
+		Statuses:  map[string]int{},
+	}
+	paths, err := listRegionFiles(d.Dir, "region")
+	if err != nil {
+		return report, err
+	}
+	for _, path := range paths {
+		region, err := ReadRegion(path)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.RegionFiles++
+		for _, chunk := range region.Chunks {
+			report.Chunks++
+			report.TotalSize += chunk.Size
+			if chunk.MetaError != "" {
+				report.UnreadableChunks++
+				continue
+			}
+			status := chunk.Status
+			if status == "" {
+				status = "unknown"
+			}
+			report.Statuses[status]++
+			bucket := bucketFor(InhabitedDuration(chunk.InhabitedTime))
+			report.Inhabited[bucket].Count++
+			report.Inhabited[bucket].Size += chunk.Size
+		}
+	}
+	return report, nil
+}
+
+func bucketFor(inhabited time.Duration) int {
+	for i, b := range inhabitedBuckets {
+		if b.Below == 0 || inhabited < b.Below {
+			return i
+		}
+	}
+	return len(inhabitedBuckets) - 1
+}
+
+// Share returns the percentage of readable chunks that fall into bucket
+func (r DimensionReport) Share(bucket InhabitedBucket) int {
+	readable := r.Chunks - r.UnreadableChunks
+	if readable <= 0 {
+		return 0
+	}
+	return bucket.Count * 100 / readable
+}
+
+// RegionPath returns the path of the region file with region coordinates x and z
+func (d Dimension) RegionPath(x, z int) string {
+	return filepath.Join(d.Dir, "region", fmt.Sprintf("r.%d.%d.mca", x, z))
+}
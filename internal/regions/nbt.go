@@ -0,0 +1,350 @@
+package regions
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NBT tag types
+const (
+	tagEnd byte = iota
+	tagByte
+	tagShort
+	tagInt
+	tagLong
+	tagFloat
+	tagDouble
+	tagByteArray
+	tagString
+	tagList
+	tagCompound
+	tagIntArray
+	tagLongArray
+)
+
+// maxNBTDepth guards against maliciously nested data
+const maxNBTDepth = 512
+
+// maxNBTLength bounds array and list lengths so corrupt chunks cannot exhaust memory
+const maxNBTLength = 1 << 24
+
+// chunkMeta holds the chunk NBT fields used for analysis
+type chunkMeta struct {
+	InhabitedTime int64
+	LastUpdate    int64
+	Status        string
+}
+
+type nbtReader struct {
+	r *bufio.Reader
+}
+
+// parseChunkMeta reads InhabitedTime, LastUpdate and Status from a decompressed chunk.
+// Chunks written before 1.18 keep these fields in a "Level" compound instead of the root.
+func parseChunkMeta(r io.Reader) (chunkMeta, error) {
+	var meta chunkMeta
+	nr := &nbtReader{r: bufio.NewReader(r)}
+	rootType, err := nr.r.ReadByte()
+	if err != nil {
+		return meta, err
+	}
+	if rootType != tagCompound {
+		return meta, fmt.Errorf("root tag is %d, not a compound", rootType)
+	}
+	if _, err := nr.readString(); err != nil {
+		return meta, err
+	}
+	err = nr.readCompound(&meta, 0)
+	return meta, err
+}
+
+func (n *nbtReader) readCompound(meta *chunkMeta, depth int) error {
+	if depth > maxNBTDepth {
+		return errors.New("nbt nested too deeply")
+	}
+	for {
+		tagType, err := n.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if tagType == tagEnd {
+			return nil
+		}
+		name, err := n.readString()
+		if err != nil {
+			return err
+		}
+		// fields are only read from the root and the legacy Level compound
+		if meta != nil {
+			switch {
+			case name == "InhabitedTime" && tagType == tagLong:
+				meta.InhabitedTime, err = n.readInt64()
+				if err != nil {
+					return err
+				}
+				continue
+			case name == "LastUpdate" && tagType == tagLong:
+				meta.LastUpdate, err = n.readInt64()
+				if err != nil {
+					return err
+				}
+				continue
+			case name == "Status" && tagType == tagString:
+				meta.Status, err = n.readString()
+				if err != nil {
+					return err
+				}
+				continue
+			case name == "Level" && tagType == tagCompound && depth == 0:
+				if err := n.readCompound(meta, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := n.skip(tagType, depth+1); err != nil {
+			return err
+		}
+	}
+}
+
+func (n *nbtReader) skip(tagType byte, depth int) error {
+	if depth > maxNBTDepth {
+		return errors.New("nbt nested too deeply")
+	}
+	switch tagType {
+	case tagByte:
+		return n.discard(1)
+	case tagShort:
+		return n.discard(2)
+	case tagInt, tagFloat:
+		return n.discard(4)
+	case tagLong, tagDouble:
+		return n.discard(8)
+	case tagByteArray, tagIntArray, tagLongArray:
+		length, err := n.readLength()
+		if err != nil {
+			return err
+		}
+		width := map[byte]int64{tagByteArray: 1, tagIntArray: 4, tagLongArray: 8}[tagType]
+		return n.discard(length * width)
+	case tagString:
+		_, err := n.readString()
+		return err
+	case tagList:
+		elemType, err := n.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		length, err := n.readLength()
+		if err != nil {
+			return err
+		}
+		for i := int64(0); i < length; i++ {
+			if err := n.skip(elemType, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case tagCompound:
+		return n.readCompound(nil, depth)
+	case tagEnd:
+		return nil
+	}
+	return fmt.Errorf("unknown nbt tag type %d", tagType)
+}
+
+func (n *nbtReader) discard(count int64) error {
+	_, err := io.CopyN(io.Discard, n.r, count)
+	return err
+}
+
+func (n *nbtReader) readLength() (int64, error) {
+	var length int32
+	if err := binary.Read(n.r, binary.BigEndian, &length); err != nil {
+		return 0, err
+	}
+	if length < 0 || length > maxNBTLength {
+		return 0, fmt.Errorf("invalid nbt length %d", length)
+	}
+	return int64(length), nil
+}
+
+func (n *nbtReader) readInt64() (int64, error) {
+	var value int64
+	err := binary.Read(n.r, binary.BigEndian, &value)
+	return value, err
+}
+
+func (n *nbtReader) readString() (string, error) {
+	var length uint16
+	if err := binary.Read(n.r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(n.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// Compound is a decoded NBT compound. Values are int8, int16, int32, int64, float32, float64,
+// string, []byte, []int32, []int64, []any (lists) or Compound.
+type Compound map[string]any
+
+// DecodeNBT decodes an uncompressed NBT document whose root is a compound
+func DecodeNBT(r io.Reader) (Compound, error) {
+	nr := &nbtReader{r: bufio.NewReader(r)}
+	rootType, err := nr.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if rootType != tagCompound {
+		return nil, fmt.Errorf("root tag is %d, not a compound", rootType)
+	}
+	if _, err := nr.readString(); err != nil {
+		return nil, err
+	}
+	value, err := nr.decode(tagCompound, 0)
+	if err != nil {
+		return nil, err
+	}
+	return value.(Compound), nil
+}
+
+func (n *nbtReader) decode(tagType byte, depth int) (any, error) {
+	if depth > maxNBTDepth {
+		return nil, errors.New("nbt nested too deeply")
+	}
+	switch tagType {
+	case tagByte:
+		var v int8
+		err := binary.Read(n.r, binary.BigEndian, &v)
+		return v, err
+	case tagShort:
+		var v int16
+		err := binary.Read(n.r, binary.BigEndian, &v)
+		return v, err
+	case tagInt:
+		var v int32
+		err := binary.Read(n.r, binary.BigEndian, &v)
+		return v, err
+	case tagLong:
+		return n.readInt64()
+	case tagFloat:
+		var v float32
+		err := binary.Read(n.r, binary.BigEndian, &v)
+		return v, err
+	case tagDouble:
+		var v float64
+		err := binary.Read(n.r, binary.BigEndian, &v)
+		return v, err
+	case tagString:
+		return n.readString()
+	case tagByteArray:
+		length, err := n.readLength()
+		if err != nil {
+			return nil, err
+		}
+		v := make([]byte, length)
+		_, err = io.ReadFull(n.r, v)
+		return v, err
+	case tagIntArray:
+		length, err := n.readLength()
+		if err != nil {
+			return nil, err
+		}
+		v := make([]int32, length)
+		err = binary.Read(n.r, binary.BigEndian, v)
+		return v, err
+	case tagLongArray:
+		length, err := n.readLength()
+		if err != nil {
+			return nil, err
+		}
+		v := make([]int64, length)
+		err = binary.Read(n.r, binary.BigEndian, v)
+		return v, err
+	case tagList:
+		elemType, err := n.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length, err := n.readLength()
+		if err != nil {
+			return nil, err
+		}
+		list := make([]any, 0, min(length, 1024))
+		for i := int64(0); i < length; i++ {
+			elem, err := n.decode(elemType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, elem)
+		}
+		return list, nil
+	case tagCompound:
+		compound := Compound{}
+		for {
+			childType, err := n.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if childType == tagEnd {
+				return compound, nil
+			}
+			name, err := n.readString()
+			if err != nil {
+				return nil, err
+			}
+			value, err := n.decode(childType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			compound[name] = value
+		}
+	}
+	return nil, fmt.Errorf("unknown nbt tag type %d", tagType)
+}
+
+// Compound returns the child compound with the given name, nil if missing
+func (c Compound) Compound(name string) Compound {
+	v, _ := c[name].(Compound)
+	return v
+}
+
+// List returns the child list with the given name, nil if missing
+func (c Compound) List(name string) []any {
+	v, _ := c[name].([]any)
+	return v
+}
+
+// String returns the child string with the given name, "" if missing
+func (c Compound) String(name string) string {
+	v, _ := c[name].(string)
+	return v
+}
+
+// LongArray returns the child long array with the given name, nil if missing
+func (c Compound) LongArray(name string) []int64 {
+	v, _ := c[name].([]int64)
+	return v
+}
+
+// Int returns any integer child as int64, ok=false if missing or not an integer
+func (c Compound) Int(name string) (int64, bool) {
+	switch v := c[name].(type) {
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
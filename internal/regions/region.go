@@ -0,0 +1,293 @@
+// Package regions reads Anvil region files (.mca) and removes chunks from them.
+package regions
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+const (
+	sectorSize     = 4096
+	headerSize     = 2 * sectorSize
+	chunksPerAxis  = 32
+	chunksPerFile  = chunksPerAxis * chunksPerAxis
+	externalFlag   = 0x80
+	compressGzip   = 1
+	compressZlib   = 2
+	compressNone   = 3
+	compressLZ4    = 4
+	ticksPerSecond = 20
+)
+
+var regionFilePattern = regexp.MustCompile(`^r\.(-?\d+)\.(-?\d+)\.mca$`)
+
+// ChunkInfo describes one chunk stored in a region file
+type ChunkInfo struct {
+	// Index is the position in the region header, X and Z are absolute chunk coordinates
+	Index int
+	X     int
+	Z     int
+	// Size is the allocated size including sectors and an external .mcc file
+	Size          int64
+	Timestamp     int64
+	InhabitedTime int64
+	LastUpdate    int64
+	Status        string
+	// MetaError is set when the chunk NBT could not be read, for example LZ4 compressed chunks
+	MetaError string
+}
+
+// Region is a parsed region file
+type Region struct {
+	Path    string
+	RegionX int
+	RegionZ int
+	Chunks  []ChunkInfo
+}
+
+// parseRegionName returns the region coordinates encoded in an r.<x>.<z>.mca file name
+func parseRegionName(name string) (int, int, bool) {
+	m := regionFilePattern.FindStringSubmatch(name)
+	if m == nil {
+		return 0, 0, false
+	}
+	x, _ := strconv.Atoi(m[1])
+	z, _ := strconv.Atoi(m[2])
+	return x, z, true
+}
+
+type chunkLocation struct {
+	offset  int64
+	sectors int64
+}
+
+// readHeader returns the location and timestamp tables of a region file
+func readHeader(data []byte) ([chunksPerFile]chunkLocation, [chunksPerFile]int64, error) {
+	var locations [chunksPerFile]chunkLocation
+	var timestamps [chunksPerFile]int64
+	if len(data) < headerSize {
+		if len(data) == 0 {
+			// the server creates empty region files before writing the first chunk
+			return locations, timestamps, nil
+		}
+		return locations, timestamps, fmt.Errorf("region file is truncated (%d bytes)", len(data))
+	}
+	for i := 0; i < chunksPerFile; i++ {
+		entry := binary.BigEndian.Uint32(data[i*4:])
+		offset, sectors := int64(entry>>8), int64(entry&0xff)
+		if offset == 0 && sectors == 0 {
+			continue
+		}
+		if offset < 2 || (offset+sectors)*sectorSize > int64(len(data)) {
+			// treat out-of-range entries like the server does: as missing chunks
+			continue
+		}
+		locations[i] = chunkLocation{offset: offset, sectors: sectors}
+		timestamps[i] = int64(binary.BigEndian.Uint32(data[sectorSize+i*4:]))
+	}
+	return locations, timestamps, nil
+}
+
+// ReadRegion parses the header of a region file and the metadata of every chunk in it
+func ReadRegion(path string) (*Region, error) {
+	regionX, regionZ, ok := parseRegionName(filepath.Base(path))
+	if !ok {
+		return nil, fmt.Errorf("not a region file: %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	locations, timestamps, err := readHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	region := &Region{Path: path, RegionX: regionX, RegionZ: regionZ}
+	for i, loc := range locations {
+		if loc.sectors == 0 {
+			continue
+		}
+		chunk := ChunkInfo{
+			Index:     i,
+			X:         regionX*chunksPerAxis + i%chunksPerAxis,
+			Z:         regionZ*chunksPerAxis + i/chunksPerAxis,
+			Size:      loc.sectors * sectorSize,
+			Timestamp: timestamps[i],
+		}
+		payload, external, err := readChunkPayload(path, data, loc, chunk.X, chunk.Z)
+		chunk.Size += external
+		if err == nil {
+			var meta chunkMeta
+			meta, err = parseChunkMeta(payload)
+			chunk.InhabitedTime, chunk.LastUpdate, chunk.Status = meta.InhabitedTime, meta.LastUpdate, meta.Status
+		}
+		if err != nil {
+			chunk.MetaError = err.Error()
+		}
+		region.Chunks = append(region.Chunks, chunk)
+	}
+	return region, nil
+}
+
+// readChunkPayload returns a decompressing reader for a chunk and the size of its external file, if any
+func readChunkPayload(path string, data []byte, loc chunkLocation, chunkX, chunkZ int) (io.Reader, int64, error) {
+	start := loc.offset * sectorSize
+	if start+5 > int64(len(data)) {
+		return nil, 0, fmt.Errorf("chunk header out of range")
+	}
+	length := int64(binary.BigEndian.Uint32(data[start:]))
+	compression := data[start+4]
+	var raw []byte
+	var external int64
+	if compression&externalFlag != 0 {
+		mcc, err := os.ReadFile(externalChunkPath(path, chunkX, chunkZ))
+		if err != nil {
+			return nil, 0, fmt.Errorf("missing external chunk: %w", err)
+		}
+		raw = mcc
+		external = int64(len(mcc))
+		compression &^= externalFlag
+	} else {
+		if length < 1 || start+4+length > int64(len(data)) {
+			return nil, 0, fmt.Errorf("chunk length %d out of range", length)
+		}
+		raw = data[start+5 : start+4+length]
+	}
+
+	switch compression {
+	case compressGzip:
+		r, err := gzip.NewReader(bytes.NewReader(raw))
+		return r, external, err
+	case compressZlib:
+		r, err := zlib.NewReader(bytes.NewReader(raw))
+		return r, external, err
+	case compressNone:
+		return bytes.NewReader(raw), external, nil
+	case compressLZ4:
+		return nil, external, fmt.Errorf("LZ4 compressed chunks are not supported")
+	}
+	return nil, external, fmt.Errorf("unknown compression type %d", compression)
+}
+
+// externalChunkPath returns the .mcc file that holds an oversized chunk
+func externalChunkPath(regionPath string, chunkX, chunkZ int) string {
+	return filepath.Join(filepath.Dir(regionPath), fmt.Sprintf("c.%d.%d.mcc", chunkX, chunkZ))
+}
+
+// removeChunks rewrites a region file without the given chunk indices, compacting the
+// remaining sectors. The file is deleted when no chunk is left. It returns the bytes freed.
+func removeChunks(path string, remove map[int]bool) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	regionX, regionZ, ok := parseRegionName(filepath.Base(path))
+	if !ok {
+		return 0, fmt.Errorf("not a region file: %s", path)
+	}
+	locations, timestamps, err := readHeader(data)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < headerSize {
+		// an empty file holds no chunks, the server keeps many of them under entities/ and poi/
+		return 0, nil
+	}
+
+	var freed int64
+	out := make([]byte, headerSize, len(data))
+	kept := 0
+	for i, loc := range locations {
+		if loc.sectors == 0 {
+			continue
+		}
+		start := loc.offset * sectorSize
+		if remove[i] {
+			if data[start+4]&externalFlag != 0 {
+				mcc := externalChunkPath(path, regionX*chunksPerAxis+i%chunksPerAxis, regionZ*chunksPerAxis+i/chunksPerAxis)
+				if info, err := os.Stat(mcc); err == nil {
+					freed += info.Size()
+				}
+				if err := os.Remove(mcc); err != nil && !os.IsNotExist(err) {
+					return freed, err
+				}
+			}
+			continue
+		}
+		offset := int64(len(out)) / sectorSize
+		binary.BigEndian.PutUint32(out[i*4:], uint32(offset<<8|loc.sectors))
+		binary.BigEndian.PutUint32(out[sectorSize+i*4:], uint32(timestamps[i]))
+		out = append(out, data[start:start+loc.sectors*sectorSize]...)
+		kept++
+	}
+
+	if kept == 0 {
+		if err := os.Remove(path); err != nil {
+			return freed, err
+		}
+		return freed + int64(len(data)), nil
+	}
+	tmp := path + ".trim"
+	if err := os.WriteFile(tmp, out, 0644); err != nil {
+		return freed, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return freed, err
+	}
+	return freed + int64(len(data)-len(out)), nil
+}
+
+// ForEachChunk decodes the NBT of every chunk in a region file. Chunks that cannot be
+// decompressed or decoded are passed to fn with a nil compound and the error in ChunkInfo.MetaError.
+func ForEachChunk(path string, fn func(chunk ChunkInfo, data Compound) error) error {
+	regionX, regionZ, ok := parseRegionName(filepath.Base(path))
+	if !ok {
+		return fmt.Errorf("not a region file: %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	locations, timestamps, err := readHeader(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	for i, loc := range locations {
+		if loc.sectors == 0 {
+			continue
+		}
+		chunk := ChunkInfo{
+			Index:     i,
+			X:         regionX*chunksPerAxis + i%chunksPerAxis,
+			Z:         regionZ*chunksPerAxis + i/chunksPerAxis,
+			Size:      loc.sectors * sectorSize,
+			Timestamp: timestamps[i],
+		}
+		var compound Compound
+		payload, external, err := readChunkPayload(path, data, loc, chunk.X, chunk.Z)
+		chunk.Size += external
+		if err == nil {
+			compound, err = DecodeNBT(payload)
+		}
+		if err != nil {
+			chunk.MetaError = err.Error()
+		}
+		if err := fn(chunk, compound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package regions
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nbtChunk builds chunk NBT. With legacy set, the fields are nested in a Level compound like before 1.18.
+func nbtChunk(inhabited int64, status string, legacy bool) []byte {
+	var b bytes.Buffer
+	name := func(tagType byte, n string) {
+		b.WriteByte(tagType)
+		binary.Write(&b, binary.BigEndian, uint16(len(n)))
+		b.WriteString(n)
+	}
+	name(tagCompound, "")
+	name(tagInt, "DataVersion")
+	binary.Write(&b, binary.BigEndian, int32(3465))
+	if legacy {
+		name(tagCompound, "Level")
+	}
+	// fields that must be skipped
+	name(tagList, "sections")
+	b.WriteByte(tagCompound)
+	binary.Write(&b, binary.BigEndian, int32(1))
+	name(tagLongArray, "data")
+	binary.Write(&b, binary.BigEndian, int32(2))
+	binary.Write(&b, binary.BigEndian, []int64{1, 2})
+	b.WriteByte(tagEnd)
+	name(tagLong, "InhabitedTime")
+	binary.Write(&b, binary.BigEndian, inhabited)
+	name(tagLong, "LastUpdate")
+	binary.Write(&b, binary.BigEndian, int64(99))
+	name(tagString, "Status")
+	binary.Write(&b, binary.BigEndian, uint16(len(status)))
+	b.WriteString(status)
+	if legacy {
+		b.WriteByte(tagEnd)
+	}
+	b.WriteByte(tagEnd)
+	return b.Bytes()
+}
+
+// writeRegion writes a region file with one zlib compressed chunk per sector
+func writeRegion(t *testing.T, path string, chunks map[int][]byte) {
+	t.Helper()
+	data := make([]byte, headerSize)
+	for i := 0; i < chunksPerFile; i++ {
+		payload, ok := chunks[i]
+		if !ok {
+			continue
+		}
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(payload)
+		zw.Close()
+		sector := make([]byte, sectorSize)
+		binary.BigEndian.PutUint32(sector, uint32(compressed.Len()+1))
+		sector[4] = compressZlib
+		copy(sector[5:], compressed.Bytes())
+
+		offset := len(data) / sectorSize
+		binary.BigEndian.PutUint32(data[i*4:], uint32(offset<<8|1))
+		binary.BigEndian.PutUint32(data[sectorSize+i*4:], uint32(1000+i))
+		data = append(data, sector...)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseChunkMeta(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		meta, err := parseChunkMeta(bytes.NewReader(nbtChunk(1234, "minecraft:full", legacy)))
+		if err != nil {
+			t.Fatalf("legacy=%v: %v", legacy, err)
+		}
+		if meta.InhabitedTime != 1234 || meta.LastUpdate != 99 || meta.Status != "minecraft:full" {
+			t.Fatalf("legacy=%v: meta = %+v", legacy, meta)
+		}
+	}
+	if _, err := parseChunkMeta(bytes.NewReader([]byte{tagCompound, 0})); err == nil {
+		t.Fatalf("expected error for truncated nbt")
+	}
+}
+
+func TestReadRegionAndAnalyze(t *testing.T) {
+	world := t.TempDir()
+	writeRegion(t, filepath.Join(world, "region", "r.-1.2.mca"), map[int][]byte{
+		0:  nbtChunk(0, "minecraft:full", false),
+		33: nbtChunk(20*60*30, "minecraft:full", false),
+		40: nbtChunk(0, "minecraft:features", false),
+	})
+	os.WriteFile(filepath.Join(world, "region", "r.0.0.mca"), nil, 0644)
+
+	region, err := ReadRegion(filepath.Join(world, "region", "r.-1.2.mca"))
+	if err != nil {
+		t.Fatalf("ReadRegion: %v", err)
+	}
+	if len(region.Chunks) != 3 {
+		t.Fatalf("chunks = %d, want 3", len(region.Chunks))
+	}
+	c := region.Chunks[1]
+	if c.X != -32+1 || c.Z != 64+1 || c.Timestamp != 1033 || c.InhabitedTime != 36000 {
+		t.Fatalf("chunk 33 = %+v", c)
+	}
+
+	dims := WorldDimensions(world)
+	if len(dims) != 1 || dims[0].Name != "overworld" {
+		t.Fatalf("dimensions = %+v", dims)
+	}
+	report, err := AnalyzeDimension(dims[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.RegionFiles != 2 || report.Chunks != 3 || report.TotalSize != 3*sectorSize {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Inhabited[0].Count != 2 || report.Inhabited[3].Count != 1 {
+		t.Fatalf("distribution = %+v", report.Inhabited)
+	}
+	if report.Statuses["minecraft:features"] != 1 {
+		t.Fatalf("statuses = %v", report.Statuses)
+	}
+}
+
+func TestWorldDimensions_BukkitLayout(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "world")
+	for _, dir := range []string{filepath.Join(base, "region"), filepath.Join(base+"_nether", "DIM-1", "region")} {
+		os.MkdirAll(dir, 0755)
+	}
+	dims := WorldDimensions(base)
+	if len(dims) != 2 || dims[1].Name != "nether" || dims[1].Dir != filepath.Join(base+"_nether", "DIM-1") {
+		t.Fatalf("dimensions = %+v", dims)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	world := t.TempDir()
+	regionPath := filepath.Join(world, "region", "r.0.0.mca")
+	chunks := map[int][]byte{
+		0:        nbtChunk(20*3600, "minecraft:full", false), // chunk 0,0: visited for an hour
+		1:        nbtChunk(0, "minecraft:full", false),       // chunk 1,0: never visited
+		31 * 32:  nbtChunk(20*3600, "minecraft:full", false), // chunk 0,31: visited but far away
+		2*32 + 2: []byte("corrupt"),                          // chunk 2,2: unreadable, kept by inhabited check
+	}
+	writeRegion(t, regionPath, chunks)
+	writeRegion(t, filepath.Join(world, "entities", "r.0.0.mca"), map[int][]byte{1: nbtChunk(0, "", false), 0: nbtChunk(0, "", false)})
+	writeRegion(t, filepath.Join(world, "region", "r.5.5.mca"), map[int][]byte{0: nbtChunk(0, "minecraft:full", false)})
+
+	dims := WorldDimensions(world)
+	if _, err := PlanTrim(dims, TrimOptions{}); err == nil {
+		t.Fatalf("expected error without criteria")
+	}
+
+	plan, err := PlanTrim(dims, TrimOptions{MinInhabited: time.Minute, Radius: 256})
+	if err != nil {
+		t.Fatalf("PlanTrim: %v", err)
+	}
+	// chunk 1,0 (never visited), chunk 0,31 (outside radius) and the whole of r.5.5
+	if plan.Chunks != 3 || plan.Dimensions[0].KeptChunks != 2 {
+		t.Fatalf("plan = %+v", plan)
+	}
+	before, _ := os.ReadFile(regionPath)
+
+	result, err := ApplyTrim(plan)
+	if err != nil {
+		t.Fatalf("ApplyTrim: %v", err)
+	}
+	if result.Chunks != 3 || result.FreedBytes <= 0 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	region, err := ReadRegion(regionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(region.Chunks) != 2 || region.Chunks[0].Index != 0 || region.Chunks[1].Index != 2*32+2 {
+		t.Fatalf("remaining chunks = %+v", region.Chunks)
+	}
+	if region.Chunks[0].InhabitedTime != 20*3600 || region.Chunks[0].Timestamp != 1000 {
+		t.Fatalf("kept chunk was altered: %+v", region.Chunks[0])
+	}
+	after, _ := os.ReadFile(regionPath)
+	if len(after) != len(before)-2*sectorSize {
+		t.Fatalf("region file was not compacted: %d -> %d bytes", len(before), len(after))
+	}
+	entities, err := ReadRegion(filepath.Join(world, "entities", "r.0.0.mca"))
+	if err != nil || len(entities.Chunks) != 1 || entities.Chunks[0].Index != 0 {
+		t.Fatalf("entities = %+v, %v", entities, err)
+	}
+	if _, err := os.Stat(filepath.Join(world, "region", "r.5.5.mca")); !os.IsNotExist(err) {
+		t.Fatalf("empty region file should be removed")
+	}
+}
+
+func TestApplyTrim_EmptyFiles(t *testing.T) {
+	world := t.TempDir()
+	regionPath := filepath.Join(world, "region", "r.0.0.mca")
+	writeRegion(t, regionPath, map[int][]byte{
+		0: nbtChunk(20*3600, "minecraft:full", false),
+		1: nbtChunk(0, "minecraft:full", false),
+	})
+	for _, folder := range []string{"entities", "poi"} {
+		os.MkdirAll(filepath.Join(world, folder), 0755)
+		if err := os.WriteFile(filepath.Join(world, folder, "r.0.0.mca"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := PlanTrim(WorldDimensions(world), TrimOptions{MinInhabited: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ApplyTrim(plan)
+	if err != nil {
+		t.Fatalf("ApplyTrim: %v", err)
+	}
+	if result.Chunks != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	region, err := ReadRegion(regionPath)
+	if err != nil || len(region.Chunks) != 1 || region.Chunks[0].Index != 0 {
+		t.Fatalf("region = %+v, %v", region, err)
+	}
+	if info, err := os.Stat(filepath.Join(world, "entities", "r.0.0.mca")); err != nil || info.Size() != 0 {
+		t.Fatalf("empty entities file should be left alone: %v", err)
+	}
+}
+
+func TestApplyTrim_WorldChanged(t *testing.T) {
+	world := t.TempDir()
+	regionPath := filepath.Join(world, "region", "r.0.0.mca")
+	writeRegion(t, regionPath, map[int][]byte{0: nbtChunk(0, "minecraft:full", false)})
+
+	plan, err := PlanTrim(WorldDimensions(world), TrimOptions{MinInhabited: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(regionPath, later, later)
+
+	if _, err := ApplyTrim(plan); !errors.Is(err, ErrWorldChanged) {
+		t.Fatalf("ApplyTrim = %v, want ErrWorldChanged", err)
+	}
+	if _, err := os.Stat(regionPath); err != nil {
+		t.Fatalf("region file should be untouched: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package regions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// trimFolders are the per-dimension folders that share the region file layout
+var trimFolders = []string{"region", "entities", "poi"}
+
+// ErrWorldChanged is returned when region files were modified after the trim was planned
+var ErrWorldChanged = errors.New("region files changed since the dry run")
+
+// TrimOptions selects the chunks to delete. A chunk is deleted if it matches any enabled criterion.
+type TrimOptions struct {
+	// MinInhabited deletes chunks players spent less time in. Zero disables the check.
+	MinInhabited time.Duration
+	// Radius deletes chunks whose center is farther than Radius blocks from CenterX/CenterZ. Zero disables the check.
+	Radius  int
+	CenterX int
+	CenterZ int
+}
+
+// RegionTrim lists the chunks to delete from one region file
+type RegionTrim struct {
+	Name    string
+	ModTime time.Time
+	Indices []int
+	Size    int64
+}
+
+// DimensionTrim is the part of a plan for one dimension
+type DimensionTrim struct {
+	Name    string
+	Dir     string
+	Chunks  int
+	Size    int64
+	Regions []RegionTrim
+	// KeptChunks is the number of chunks that stay in the dimension
+	KeptChunks int
+}
+
+// TrimPlan is the result of a dry run and the input of ApplyTrim
+type TrimPlan struct {
+	Options    TrimOptions
+	Dimensions []DimensionTrim
+	Chunks     int
+	Size       int64
+	CreatedAt  time.Time
+}
+
+// TrimResult reports what ApplyTrim removed
+type TrimResult struct {
+	Chunks     int
+	FreedBytes int64
+}
+
+func (o TrimOptions) validate() error {
+	if o.MinInhabited < 0 || o.Radius < 0 {
+		return fmt.Errorf("trim thresholds must not be negative")
+	}
+	if o.MinInhabited == 0 && o.Radius == 0 {
+		return fmt.Errorf("select at least one trim criterion")
+	}
+	return nil
+}
+
+// shouldTrim reports whether a chunk matches the options. Chunks with unreadable
+// metadata are only trimmed by radius, never by inhabited time.
+func (o TrimOptions) shouldTrim(chunk ChunkInfo) bool {
+	if o.Radius > 0 {
+		dx := float64(chunk.X*16 + 8 - o.CenterX)
+		dz := float64(chunk.Z*16 + 8 - o.CenterZ)
+		if math.Hypot(dx, dz) > float64(o.Radius) {
+			return true
+		}
+	}
+	if o.MinInhabited > 0 && chunk.MetaError == "" {
+		return InhabitedDuration(chunk.InhabitedTime) < o.MinInhabited
+	}
+	return false
+}
+
+// PlanTrim performs a dry run and returns the chunks that ApplyTrim would delete
+func PlanTrim(dimensions []Dimension, opts TrimOptions) (*TrimPlan, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	plan := &TrimPlan{Options: opts, CreatedAt: time.Now()}
+	for _, d := range dimensions {
+		paths, err := listRegionFiles(d.Dir, "region")
+		if err != nil {
+			return nil, err
+		}
+		dimTrim := DimensionTrim{Name: d.Name, Dir: d.Dir}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			region, err := ReadRegion(path)
+			if err != nil {
+				return nil, err
+			}
+			regionTrim := RegionTrim{Name: filepath.Base(path), ModTime: info.ModTime()}
+			for _, chunk := range region.Chunks {
+				if !opts.shouldTrim(chunk) {
+					dimTrim.KeptChunks++
+					continue
+				}
+				regionTrim.Indices = append(regionTrim.Indices, chunk.Index)
+				regionTrim.Size += chunk.Size
+			}
+			if len(regionTrim.Indices) == 0 {
+				continue
+			}
+			dimTrim.Regions = append(dimTrim.Regions, regionTrim)
+			dimTrim.Chunks += len(regionTrim.Indices)
+			dimTrim.Size += regionTrim.Size
+		}
+		plan.Dimensions = append(plan.Dimensions, dimTrim)
+		plan.Chunks += dimTrim.Chunks
+		plan.Size += dimTrim.Size
+	}
+	return plan, nil
+}
+
+// ApplyTrim deletes the planned chunks from the region, entities and poi files.
+// The server must be stopped, otherwise it overwrites the files with its cached chunks.
+func ApplyTrim(plan *TrimPlan) (TrimResult, error) {
+	var result TrimResult
+	// verify everything first so a changed world is not trimmed halfway
+	for _, d := range plan.Dimensions {
+		for _, r := range d.Regions {
+			info, err := os.Stat(filepath.Join(d.Dir, "region", r.Name))
+			if err != nil || !info.ModTime().Equal(r.ModTime) {
+				return result, fmt.Errorf("%s/%s: %w", d.Name, r.Name, ErrWorldChanged)
+			}
+		}
+	}
+
+	for _, d := range plan.Dimensions {
+		for _, r := range d.Regions {
+			remove := map[int]bool{}
+			for _, i := range r.Indices {
+				remove[i] = true
+			}
+			for _, folder := range trimFolders {
+				freed, err := removeChunks(filepath.Join(d.Dir, folder, r.Name), remove)
+				result.FreedBytes += freed
+				if err != nil {
+					return result, fmt.Errorf("failed to trim %s/%s/%s: %w", d.Name, folder, r.Name, err)
+				}
+			}
+			result.Chunks += len(r.Indices)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Access request statuses
+const (
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+)
+
+const (
+	// maxAccessRequestsPerSource is how many requests one Discord account or address may submit
+	// within accessRequestWindow
+	maxAccessRequestsPerSource = 3
+	accessRequestWindow        = time.Hour
+	// maxPendingAccessRequests stops a flood of anonymous requests from burying the queue. Players
+	// signed in with Discord are limited per account instead, so a flood cannot lock them out.
+	maxPendingAccessRequests = 100
+	// maxReviewedAccessRequests is how many reviewed requests are kept, oldest dropped first
+	maxReviewedAccessRequests = 200
+	maxAccessRequestMessage   = 500
+)
+
+// ErrAccessRequestRateLimited is returned when a requester submits too many requests
+var ErrAccessRequestRateLimited = errors.New("too many requests, try again later")
+
+// AccessRequest is a player's request to be whitelisted
+type AccessRequest struct {
+	ID          string `json:"id"`
+	PlayerName  string `json:"player_name"`
+	Message     string `json:"message,omitempty"`
+	DiscordID   string `json:"discord_id,omitempty"`
+	DiscordName string `json:"discord_name,omitempty"`
+	Status      string `json:"status"`
+	// Reason explains a denial
+	Reason     string    `json:"reason,omitempty"`
+	ReviewedBy string    `json:"reviewed_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReviewedAt time.Time `json:"reviewed_at,omitzero"`
+}
+
+// AccessRequestSubmission is the form a player fills in
+type AccessRequestSubmission struct {
+	PlayerName  string
+	Message     string
+	DiscordID   string
+	DiscordName string
+	// Source identifies the requester for rate limiting, the Discord account or the client address
+	Source string
+}
+
+// AccessRequestWhitelister adds approved players to the whitelist
+type AccessRequestWhitelister interface {
+	AddNameToWhitelist(name string) error
+}
+
+// AccessRequestWebhook receives a notification for every new request
+type AccessRequestWebhook interface {
+	Post(ctx context.Context, payload any) error
+}
+
+// accessRequestState is the persisted state of AccessRequestService
+type accessRequestState struct {
+	Requests []AccessRequest `json:"requests"`
+}
+
+// AccessRequestService collects whitelist requests from players for admins to approve or deny
+type AccessRequestService struct {
+	whitelist AccessRequestWhitelister
+	webhook   AccessRequestWebhook
+	stateFile jsonStateFile
+	now       func() time.Time
+
+	mu    sync.Mutex
+	state *accessRequestState
+	// submissions maps a source to the times of its recent requests
+	submissions map[string][]time.Time
+}
+
+// NewAccessRequestService creates an AccessRequestService persisting requests to statePath.
+// webhook is optional.
+func NewAccessRequestService(whitelist AccessRequestWhitelister, webhook AccessRequestWebhook, statePath string) *AccessRequestService {
+	return &AccessRequestService{
+		whitelist:   whitelist,
+		webhook:     webhook,
+		stateFile:   jsonStateFile{path: statePath},
+		now:         time.Now,
+		submissions: map[string][]time.Time{},
+	}
+}
+
+// loadLocked reads the state once. An unreadable file is left alone and reported on every call.
+func (s *AccessRequestService) loadLocked() error {
+	if s.state != nil {
+		return nil
+	}
+	state := &accessRequestState{}
+	if err := s.stateFile.load(state); err != nil {
+		return err
+	}
+	s.state = state
+	return nil
+}
+
+func (s *AccessRequestService) saveLocked() error {
+	return s.stateFile.save(s.state)
+}
+
+// Pending returns the requests waiting for review, oldest first
+func (s *AccessRequestService) Pending() ([]AccessRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	var pending []AccessRequest
+	for _, request := range s.state.Requests {
+		if request.Status == AccessRequestPending {
+			pending = append(pending, request)
+		}
+	}
+	return pending, nil
+}
+
+// Reviewed returns up to limit reviewed requests, most recently reviewed first
+func (s *AccessRequestService) Reviewed(limit int) ([]AccessRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	var reviewed []AccessRequest
+	for _, request := range s.state.Requests {
+		if request.Status != AccessRequestPending {
+			reviewed = append(reviewed, request)
+		}
+	}
+	slices.SortStableFunc(reviewed, func(a, b AccessRequest) int {
+		return b.ReviewedAt.Compare(a.ReviewedAt)
+	})
+	if len(reviewed) > limit {
+		reviewed = reviewed[:limit]
+	}
+	return reviewed, nil
+}
+
+// Submit validates and stores a request and notifies the webhook
+func (s *AccessRequestService) Submit(submission AccessRequestSubmission) (AccessRequest, error) {
+	name := strings.TrimSpace(submission.PlayerName)
+	if err := validatePlayerName(name); err != nil {
+		return AccessRequest{}, err
+	}
+	message := strings.TrimSpace(submission.Message)
+	if utf8.RuneCountInString(message) > maxAccessRequestMessage {
+		return AccessRequest{}, fmt.Errorf("the message is longer than %d characters", maxAccessRequestMessage)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return AccessRequest{}, err
+	}
+
+	now := s.now()
+	s.pruneSubmissionsLocked(now)
+	recent := s.submissions[submission.Source]
+	if len(recent) >= maxAccessRequestsPerSource {
+		return AccessRequest{}, ErrAccessRequestRateLimited
+	}
+	pending := 0
+	for _, request := range s.state.Requests {
+		if request.Status != AccessRequestPending {
+			continue
+		}
+		if strings.EqualFold(request.PlayerName, name) {
+			return AccessRequest{}, fmt.Errorf("a request for %s is already waiting for review", request.PlayerName)
+		}
+		if request.DiscordID == submission.DiscordID {
+			pending++
+		}
+	}
+	limit := maxPendingAccessRequests
+	if submission.DiscordID != "" {
+		limit = maxAccessRequestsPerSource
+	}
+	if pending >= limit {
+		return AccessRequest{}, ErrAccessRequestRateLimited
+	}
+
+	request := AccessRequest{
+		ID:          newAccessRequestID(),
+		PlayerName:  name,
+		Message:     message,
+		DiscordID:   submission.DiscordID,
+		DiscordName: submission.DiscordName,
+		Status:      AccessRequestPending,
+		CreatedAt:   now,
+	}
+	s.state.Requests = append(s.state.Requests, request)
+	if err := s.saveLocked(); err != nil {
+		s.state.Requests = s.state.Requests[:len(s.state.Requests)-1]
+		return AccessRequest{}, err
+	}
+	s.submissions[submission.Source] = append(recent, now)
+
+	if s.webhook != nil {
+		go s.notify(request)
+	}
+	return request, nil
+}
+
+// notify posts a Discord compatible message, other receivers can read the request field
+func (s *AccessRequestService) notify(request AccessRequest) {
+	content := fmt.Sprintf("**%s** requested whitelist access", request.PlayerName)
+	if request.DiscordName != "" {
+		content += fmt.Sprintf(" (Discord: %s)", request.DiscordName)
+	}
+	if request.Message != "" {
+		content += "\n> " + strings.ReplaceAll(request.Message, "\n", "\n> ")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := s.webhook.Post(ctx, map[string]any{
+		"content":          content,
+		"allowed_mentions": map[string]any{"parse": []string{}},
+		"request":          request,
+	})
+	if err != nil {
+		log.Printf("Failed to notify about access request %s: %v", request.ID, err)
+	}
+}
+
+// Approve whitelists the player of a pending request. The request stays pending when adding fails.
+func (s *AccessRequestService) Approve(id string, reviewer string) (AccessRequest, error) {
+	request, err := s.findPending(id)
+	if err != nil {
+		return AccessRequest{}, err
+	}
+	// The Mojang lookup can be slow, so the whitelist is changed without holding the lock. A player
+	// who was whitelisted in the meantime got what they asked for.
+	if err := s.whitelist.AddNameToWhitelist(request.PlayerName); err != nil && !errors.Is(err, ErrAlreadyWhitelisted) {
+		return request, err
+	}
+	return s.review(id, AccessRequestApproved, reviewer, "")
+}
+
+// Deny rejects a pending request, reason is shown to admins reviewing the history
+func (s *AccessRequestService) Deny(id string, reviewer string, reason string) (AccessRequest, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return AccessRequest{}, fmt.Errorf("a reason is required to deny a request")
+	}
+	if _, err := s.findPending(id); err != nil {
+		return AccessRequest{}, err
+	}
+	return s.review(id, AccessRequestDenied, reviewer, reason)
+}
+
+func (s *AccessRequestService) findPending(id string) (AccessRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return AccessRequest{}, err
+	}
+	index := s.indexLocked(id)
+	if index < 0 {
+		return AccessRequest{}, fmt.Errorf("access request %q does not exist", id)
+	}
+	if request := s.state.Requests[index]; request.Status != AccessRequestPending {
+		return AccessRequest{}, fmt.Errorf("the request for %s was already %s", request.PlayerName, request.Status)
+	}
+	return s.state.Requests[index], nil
+}
+
+func (s *AccessRequestService) review(id string, status string, reviewer string, reason string) (AccessRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.indexLocked(id)
+	if index < 0 || s.state.Requests[index].Status != AccessRequestPending {
+		return AccessRequest{}, fmt.Errorf("access request %q is no longer pending", id)
+	}
+	request := &s.state.Requests[index]
+	request.Status = status
+	request.Reason = reason
+	request.ReviewedBy = reviewer
+	request.ReviewedAt = s.now()
+	reviewed := *request
+	s.pruneLocked()
+	return reviewed, s.saveLocked()
+}
+
+func (s *AccessRequestService) indexLocked(id string) int {
+	return slices.IndexFunc(s.state.Requests, func(request AccessRequest) bool {
+		return request.ID == id
+	})
+}
+
+// pruneSubmissionsLocked forgets submissions older than accessRequestWindow and sources without
+// recent ones
+func (s *AccessRequestService) pruneSubmissionsLocked(now time.Time) {
+	for source, times := range s.submissions {
+		times = slices.DeleteFunc(times, func(t time.Time) bool {
+			return now.Sub(t) >= accessRequestWindow
+		})
+		if len(times) == 0 {
+			delete(s.submissions, source)
+		} else {
+			s.submissions[source] = times
+		}
+	}
+}
+
+// pruneLocked drops the oldest reviewed requests beyond maxReviewedAccessRequests
+func (s *AccessRequestService) pruneLocked() {
+	reviewed := 0
+	for _, request := range s.state.Requests {
+		if request.Status != AccessRequestPending {
+			reviewed++
+		}
+	}
+	s.state.Requests = slices.DeleteFunc(s.state.Requests, func(request AccessRequest) bool {
+		if request.Status == AccessRequestPending || reviewed <= maxReviewedAccessRequests {
+			return false
+		}
+		reviewed--
+		return true
+	})
+}
+
+func newAccessRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
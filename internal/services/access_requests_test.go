@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeWhitelister struct {
+	added []string
+	err   error
+}
+
+func (f *fakeWhitelister) AddNameToWhitelist(name string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.added = append(f.added, name)
+	return nil
+}
+
+type fakeWebhook struct {
+	payloads chan map[string]any
+}
+
+func (f *fakeWebhook) Post(ctx context.Context, payload any) error {
+	f.payloads <- payload.(map[string]any)
+	return nil
+}
+
+func TestAccessRequestService_SubmitAndReview(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "access_requests.json")
+	whitelist := &fakeWhitelister{}
+	webhook := &fakeWebhook{payloads: make(chan map[string]any, 1)}
+	svc := NewAccessRequestService(whitelist, webhook, statePath)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	steve, err := svc.Submit(AccessRequestSubmission{PlayerName: " Steve ", Message: "Hi!\nI'm in class 7b", DiscordName: "steve#1", Source: "discord:1"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if steve.PlayerName != "Steve" || steve.Status != AccessRequestPending || len(steve.ID) != 16 {
+		t.Fatalf("request = %+v", steve)
+	}
+	select {
+	case payload := <-webhook.payloads:
+		if content := payload["content"].(string); !strings.Contains(content, "**Steve** requested") || !strings.Contains(content, "(Discord: steve#1)\n> Hi!\n> I'm in class 7b") {
+			t.Fatalf("content = %q", content)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("webhook was not notified")
+	}
+	if _, err := svc.Submit(AccessRequestSubmission{PlayerName: "steve", Source: "ip:10.0.0.2"}); err == nil || !strings.Contains(err.Error(), "already waiting") {
+		t.Fatalf("duplicate request: err = %v", err)
+	}
+	alex, _ := svc.Submit(AccessRequestSubmission{PlayerName: "Alex", Source: "ip:10.0.0.2"})
+	<-webhook.payloads
+
+	// Requests survive a restart
+	svc = NewAccessRequestService(whitelist, nil, statePath)
+	svc.now = func() time.Time { return now.Add(time.Minute) }
+	if pending, _ := svc.Pending(); len(pending) != 2 || pending[0].ID != steve.ID || pending[1].Message != "" {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	whitelist.err = errors.New("name 'Steve' does not exist")
+	if _, err := svc.Approve(steve.ID, "admin"); err == nil {
+		t.Fatalf("failed approval: err = %v", err)
+	}
+	if pending, _ := svc.Pending(); len(pending) != 2 {
+		t.Fatalf("failed approval: pending %d", len(pending))
+	}
+	whitelist.err = nil
+	approved, err := svc.Approve(steve.ID, "admin")
+	if err != nil || approved.Status != AccessRequestApproved || approved.ReviewedBy != "admin" || whitelist.added[0] != "Steve" {
+		t.Fatalf("Approve = %+v, %v", approved, err)
+	}
+	if _, err := svc.Approve(steve.ID, "admin"); err == nil || !strings.Contains(err.Error(), "already approved") {
+		t.Fatalf("approving twice: err = %v", err)
+	}
+
+	if _, err := svc.Deny(alex.ID, "admin", "  "); err == nil {
+		t.Fatalf("denying without a reason succeeded")
+	}
+	svc.now = func() time.Time { return now.Add(time.Hour) }
+	denied, err := svc.Deny(alex.ID, "admin", "Not a student")
+	if err != nil || denied.Status != AccessRequestDenied || denied.Reason != "Not a student" {
+		t.Fatalf("Deny = %+v, %v", denied, err)
+	}
+	if _, err := svc.Deny("missing", "admin", "x"); err == nil {
+		t.Fatalf("denying an unknown request succeeded")
+	}
+	pending, _ := svc.Pending()
+	if reviewed, _ := svc.Reviewed(10); len(reviewed) != 2 || reviewed[0].ID != alex.ID || len(pending) != 0 {
+		t.Fatalf("reviewed = %+v", reviewed)
+	}
+}
+
+func TestAccessRequestService_ApproveAlreadyWhitelisted(t *testing.T) {
+	whitelist := &fakeWhitelister{}
+	svc := NewAccessRequestService(whitelist, nil, filepath.Join(t.TempDir(), "access_requests.json"))
+	steve, err := svc.Submit(AccessRequestSubmission{PlayerName: "Steve", Source: "ip:10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an admin whitelisted Steve by hand before reviewing the request
+	whitelist.err = fmt.Errorf("name 'Steve' is %w", ErrAlreadyWhitelisted)
+	approved, err := svc.Approve(steve.ID, "admin")
+	if err != nil || approved.Status != AccessRequestApproved {
+		t.Fatalf("Approve = %+v, %v", approved, err)
+	}
+	if pending, _ := svc.Pending(); len(pending) != 0 {
+		t.Fatalf("pending = %+v", pending)
+	}
+}
+
+func TestAccessRequestService_Validation(t *testing.T) {
+	svc := NewAccessRequestService(&fakeWhitelister{}, nil, filepath.Join(t.TempDir(), "access_requests.json"))
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	for _, submission := range []AccessRequestSubmission{
+		{PlayerName: "not a name"},
+		{PlayerName: ""},
+		{PlayerName: "Steve", Message: strings.Repeat("a", maxAccessRequestMessage+1)},
+	} {
+		if _, err := svc.Submit(submission); err == nil {
+			t.Errorf("Submit(%.20q) succeeded", submission.PlayerName+submission.Message)
+		}
+	}
+
+	for _, name := range []string{"One", "Two", "Three"} {
+		if _, err := svc.Submit(AccessRequestSubmission{PlayerName: name, Source: "ip:10.0.0.1"}); err != nil {
+			t.Fatalf("Submit(%s): %v", name, err)
+		}
+	}
+	if _, err := svc.Submit(AccessRequestSubmission{PlayerName: "Four", Source: "ip:10.0.0.1"}); !errors.Is(err, ErrAccessRequestRateLimited) {
+		t.Fatalf("fourth request: err = %v", err)
+	}
+	if _, err := svc.Submit(AccessRequestSubmission{PlayerName: "Four", Source: "ip:10.0.0.9"}); err != nil {
+		t.Fatalf("request from another address: %v", err)
+	}
+	now = now.Add(accessRequestWindow)
+	if _, err := svc.Submit(AccessRequestSubmission{PlayerName: "Five", Source: "ip:10.0.0.1"}); err != nil {
+		t.Fatalf("request after the window: %v", err)
+	}
+}
+
+func TestAccessRequestService_PendingLimits(t *testing.T) {
+	svc := NewAccessRequestService(&fakeWhitelister{}, nil, filepath.Join(t.TempDir(), "access_requests.json"))
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	// Anonymous requests fill the queue, players signed in with Discord still get through
+	for i := range maxPendingAccessRequests {
+		source := fmt.Sprintf("ip:10.0.%d.%d", i/250, i%250)
+		if _, err := svc.Submit(AccessRequestSubmission{PlayerName: fmt.Sprintf("Guest%03d", i), Source: source}); err != nil {
+			t.Fatalf("anonymous request %d: %v", i, err)
+		}
+	}
+	if _, err := svc.Submit(AccessRequestSubmission{PlayerName: "Flood", Source: "ip:10.1.0.1"}); !errors.Is(err, ErrAccessRequestRateLimited) {
+		t.Fatalf("anonymous request beyond the cap: err = %v", err)
+	}
+	for _, name := range []string{"Steve", "Alex", "Notch"} {
+		if _, err := svc.Submit(AccessRequestSubmission{PlayerName: name, DiscordID: "1", Source: "discord:1"}); err != nil {
+			t.Fatalf("Discord request for %s: %v", name, err)
+		}
+	}
+
+	// Once the window passed old submissions are forgotten, but pending requests still count
+	now = now.Add(accessRequestWindow)
+	if _, err := svc.Submit(AccessRequestSubmission{PlayerName: "Herobrine", DiscordID: "1", Source: "discord:1"}); !errors.Is(err, ErrAccessRequestRateLimited) {
+		t.Fatalf("fourth pending request of one account: err = %v", err)
+	}
+	if len(svc.submissions) != 0 {
+		t.Fatalf("expired submissions were kept: %d sources", len(svc.submissions))
+	}
+}
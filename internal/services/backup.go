@@ -0,0 +1,736 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mc-admin/internal/clients/destinations"
+	"mc-admin/internal/clients/rcon"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackupFileSystemAccessor is the subset of the files client used by BackupService
+type BackupFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	ReadFile(path string) (string, error)
+}
+
+// BackupInfo describes a backup archive found in the backup directory
+type BackupInfo struct {
+	Name      string
+	Size      int64
+	ModTime   time.Time
+	Format    string
+	WorldName string
+	Uploads   []BackupUpload
+}
+
+// BackupEntry is a single file or directory inside a backup archive
+type BackupEntry struct {
+	Path  string
+	Size  int64
+	IsDir bool
+}
+
+const (
+	RestoreStepPending = "pending"
+	RestoreStepRunning = "running"
+	RestoreStepDone    = "done"
+	RestoreStepFailed  = "failed"
+)
+
+// RestoreStep is one stage of the guided restore flow
+type RestoreStep struct {
+	Label  string
+	Status string
+	Detail string
+}
+
+// RestoreStatus reports the progress of the current or last restore
+type RestoreStatus struct {
+	Backup     string
+	Running    bool
+	Steps      []RestoreStep
+	Progress   int
+	SafetyCopy string
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// RestoreOptions controls how players are warned before the server is stopped
+type RestoreOptions struct {
+	WarningSeconds int
+}
+
+const (
+	restoreStepWarn = iota
+	restoreStepKick
+	restoreStepStop
+	restoreStepMoveAside
+	restoreStepExtract
+)
+
+var restoreStepLabels = []string{
+	"Warn players",
+	"Kick players",
+	"Stop server",
+	"Move current world aside",
+	"Extract backup",
+}
+
+var errStopArchiveWalk = errors.New("stop archive walk")
+
+// BackupService lists backup archives and restores them into the world directory
+type BackupService struct {
+	rconClient      rcon.CommandExecutor
+	fileClient      BackupFileSystemAccessor
+	backupDir       string
+	destinations    []destinations.Destination
+	retention       int
+	shutdownTimeout time.Duration
+	uploadTimeout   func(size int64) time.Duration
+	sleep           func(time.Duration)
+	now             func() time.Time
+
+	mu         sync.Mutex
+	worldNames map[string]string
+	restore    *RestoreStatus
+	uploads    map[string]map[string]*BackupUpload
+}
+
+// NewBackupService creates a BackupService. backupDir is either absolute or relative to the Minecraft data directory.
+// Backups can be uploaded to uploadTargets, each of which keeps at most retention backups (0 keeps all).
+func NewBackupService(rconClient rcon.CommandExecutor, fileClient BackupFileSystemAccessor, backupDir string, uploadTargets []destinations.Destination, retention int) *BackupService {
+	return &BackupService{
+		rconClient:      rconClient,
+		fileClient:      fileClient,
+		backupDir:       backupDir,
+		destinations:    uploadTargets,
+		retention:       retention,
+		shutdownTimeout: 60 * time.Second,
+		uploadTimeout:   defaultUploadTimeout,
+		sleep:           time.Sleep,
+		now:             time.Now,
+		worldNames:      map[string]string{},
+	}
+}
+
+func (s *BackupService) resolveBackupDir() (string, error) {
+	if filepath.IsAbs(s.backupDir) {
+		return s.backupDir, nil
+	}
+	return s.fileClient.GetAbsolutePath(s.backupDir)
+}
+
+// backupFormat returns the archive format for a file name, or "" if unsupported
+func backupFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// isBackupName reports whether name is a backup archive this service lists and uploads
+func isBackupName(name string) bool {
+	return name != "" && name == filepath.Base(name) && !strings.HasPrefix(name, ".") && backupFormat(name) != ""
+}
+
+// GetBackupPath validates a backup name and returns the absolute path of the archive
+func (s *BackupService) GetBackupPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid backup name: %q", name)
+	}
+	if backupFormat(name) == "" {
+		return "", fmt.Errorf("unsupported backup format: %s", name)
+	}
+	dir, err := s.resolveBackupDir()
+	if err != nil {
+		return "", err
+	}
+	fullPath := filepath.Join(dir, name)
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("backup not found: %s", name)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("backup is a directory: %s", name)
+	}
+	return fullPath, nil
+}
+
+// ListBackups returns all supported archives in the backup directory, newest first
+func (s *BackupService) ListBackups() ([]BackupInfo, error) {
+	dir, err := s.resolveBackupDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	backups := []BackupInfo{}
+	for _, entry := range entries {
+		format := backupFormat(entry.Name())
+		if entry.IsDir() || format == "" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Name:      entry.Name(),
+			Size:      info.Size(),
+			ModTime:   info.ModTime(),
+			Format:    format,
+			WorldName: s.cachedWorldName(filepath.Join(dir, entry.Name()), info),
+			Uploads:   s.getUploads(entry.Name()),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime.After(backups[j].ModTime)
+	})
+	return backups, nil
+}
+
+// GetBackup returns metadata for a single backup
+func (s *BackupService) GetBackup(name string) (BackupInfo, error) {
+	fullPath, err := s.GetBackupPath(name)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	return BackupInfo{
+		Name:      name,
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		Format:    backupFormat(name),
+		WorldName: s.cachedWorldName(fullPath, info),
+		Uploads:   s.getUploads(name),
+	}, nil
+}
+
+// cachedWorldName scans an archive for its world directory once per name, size and mtime
+func (s *BackupService) cachedWorldName(fullPath string, info os.FileInfo) string {
+	key := fmt.Sprintf("%s|%d|%d", info.Name(), info.Size(), info.ModTime().UnixNano())
+	s.mu.Lock()
+	name, ok := s.worldNames[key]
+	s.mu.Unlock()
+	if ok {
+		return name
+	}
+
+	prefix, err := findArchiveWorldRoot(fullPath)
+	switch {
+	case err != nil:
+		name = ""
+	case prefix == "":
+		name = "(archive root)"
+	default:
+		name = path.Base(prefix)
+	}
+
+	s.mu.Lock()
+	s.worldNames[key] = name
+	s.mu.Unlock()
+	return name
+}
+
+// ListBackupContents returns every entry inside the given backup archive
+func (s *BackupService) ListBackupContents(name string) ([]BackupEntry, error) {
+	fullPath, err := s.GetBackupPath(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := []BackupEntry{}
+	err = walkBackupArchive(fullPath, func(entry archiveEntry, _ io.Reader) error {
+		entries = append(entries, BackupEntry{Path: entry.Path, Size: entry.Size, IsDir: entry.IsDir})
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	return entries, nil
+}
+
+// GetRestoreStatus returns a snapshot of the current or last restore, nil if none was started
+func (s *BackupService) GetRestoreStatus() *RestoreStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.restore == nil {
+		return nil
+	}
+	status := *s.restore
+	status.Steps = append([]RestoreStep(nil), s.restore.Steps...)
+	return &status
+}
+
+// StartRestore validates the backup and runs the restore flow in the background
+func (s *BackupService) StartRestore(name string, options RestoreOptions) error {
+	if _, err := s.GetBackupPath(name); err != nil {
+		return err
+	}
+	if err := s.beginRestore(name); err != nil {
+		return err
+	}
+	go s.runRestore(name, options)
+	return nil
+}
+
+func (s *BackupService) beginRestore(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.restore != nil && s.restore.Running {
+		return fmt.Errorf("a restore of '%s' is already running", s.restore.Backup)
+	}
+	steps := make([]RestoreStep, len(restoreStepLabels))
+	for i, label := range restoreStepLabels {
+		steps[i] = RestoreStep{Label: label, Status: RestoreStepPending}
+	}
+	s.restore = &RestoreStatus{
+		Backup:    name,
+		Running:   true,
+		Steps:     steps,
+		StartedAt: s.now(),
+	}
+	return nil
+}
+
+func (s *BackupService) setStep(step int, status string, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restore.Steps[step].Status = status
+	s.restore.Steps[step].Detail = detail
+}
+
+func (s *BackupService) setProgress(progress int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restore.Progress = progress
+}
+
+func (s *BackupService) finishRestore(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restore.Running = false
+	s.restore.FinishedAt = s.now()
+	if err != nil {
+		s.restore.Error = err.Error()
+	}
+}
+
+func (s *BackupService) runRestore(name string, options RestoreOptions) {
+	s.finishRestore(s.restoreBackup(name, options))
+}
+
+// restoreBackup performs the restore steps in order, stopping at the first failure
+func (s *BackupService) restoreBackup(name string, options RestoreOptions) error {
+	archivePath, err := s.GetBackupPath(name)
+	if err != nil {
+		return err
+	}
+	dataDir, err := s.fileClient.GetAbsolutePath(".")
+	if err != nil {
+		return err
+	}
+	levelName := getLevelName(s.fileClient)
+
+	prefix, err := findArchiveWorldRoot(archivePath)
+	if err != nil {
+		return err
+	}
+
+	s.setStep(restoreStepWarn, RestoreStepRunning, "")
+	if options.WarningSeconds > 0 {
+		warning := fmt.Sprintf("say Restoring a world backup in %d seconds. You will be disconnected.", options.WarningSeconds)
+		if _, err := s.rconClient.ExecuteCommand(warning); err != nil {
+			s.setStep(restoreStepWarn, RestoreStepDone, "Server not reachable, skipped")
+		} else {
+			s.sleep(time.Duration(options.WarningSeconds) * time.Second)
+			s.setStep(restoreStepWarn, RestoreStepDone, fmt.Sprintf("Warned %d seconds ahead", options.WarningSeconds))
+		}
+	} else {
+		s.setStep(restoreStepWarn, RestoreStepDone, "No warning requested")
+	}
+
+	s.setStep(restoreStepKick, RestoreStepRunning, "")
+	serverService := NewServerServiceFromRconClient(s.rconClient)
+	if info, err := serverService.GetServerPlayerInfo(); err != nil {
+		s.setStep(restoreStepKick, RestoreStepDone, "Server not reachable, skipped")
+	} else {
+		kicked := 0
+		for _, player := range info.PlayerNames {
+			if err := serverService.KickPlayerByName(player, "Server is restoring a backup"); err == nil {
+				kicked++
+			}
+		}
+		s.setStep(restoreStepKick, RestoreStepDone, fmt.Sprintf("Kicked %d player(s)", kicked))
+	}
+
+	s.setStep(restoreStepStop, RestoreStepRunning, "")
+	if err := s.stopServer(); err != nil {
+		s.setStep(restoreStepStop, RestoreStepFailed, err.Error())
+		return err
+	}
+	s.setStep(restoreStepStop, RestoreStepDone, "Server is offline")
+
+	s.setStep(restoreStepMoveAside, RestoreStepRunning, "")
+	suffix := preRestoreSuffix(s.now())
+	safetyCopy, err := moveWorldAside(dataDir, levelName, suffix)
+	if err != nil {
+		s.setStep(restoreStepMoveAside, RestoreStepFailed, err.Error())
+		return err
+	}
+	s.mu.Lock()
+	s.restore.SafetyCopy = safetyCopy
+	s.mu.Unlock()
+	if safetyCopy == "" {
+		s.setStep(restoreStepMoveAside, RestoreStepDone, "No existing world found")
+	} else {
+		s.setStep(restoreStepMoveAside, RestoreStepDone, "Moved to "+safetyCopy)
+	}
+
+	s.setStep(restoreStepExtract, RestoreStepRunning, "")
+	files, err := extractWorld(archivePath, prefix, dataDir, levelName, func(done, total int64) {
+		if total > 0 {
+			s.setProgress(int(done * 100 / total))
+		}
+	})
+	if err != nil {
+		detail := err.Error()
+		if safetyErr := putWorldBack(dataDir, levelName, suffix); safetyErr != nil {
+			detail += "; failed to put the previous world back: " + safetyErr.Error()
+		} else {
+			detail += "; the previous world was put back"
+		}
+		s.setStep(restoreStepExtract, RestoreStepFailed, detail)
+		return err
+	}
+	s.setProgress(100)
+	s.setStep(restoreStepExtract, RestoreStepDone, fmt.Sprintf("Extracted %d file(s) into %s", files, levelName))
+	return nil
+}
+
+// stopServer sends "stop" and waits until the server no longer answers RCON commands
+func (s *BackupService) stopServer() error {
+	if _, err := s.rconClient.ExecuteCommand("list"); err != nil {
+		return nil
+	}
+	// the connection often drops before the reply to "stop" arrives while the server is still
+	// saving, so only an unanswered "list" shows that it is gone
+	s.rconClient.ExecuteCommand("stop")
+	deadline := s.now().Add(s.shutdownTimeout)
+	for s.now().Before(deadline) {
+		s.sleep(time.Second)
+		if _, err := s.rconClient.ExecuteCommand("list"); err != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("server did not stop within %s", s.shutdownTimeout)
+}
+
+// worldDirs returns the world folder and its dimension folders
+func worldDirs(levelName string) []string {
+	return []string{levelName, levelName + "_nether", levelName + "_the_end"}
+}
+
+// preRestoreSuffix is appended to the world folders moved aside by a restore
+func preRestoreSuffix(now time.Time) string {
+	return ".pre-restore-" + now.Format("20060102-150405")
+}
+
+// moveWorldAside renames the world and its dimension folders to a safety copy ending in suffix
+func moveWorldAside(dataDir string, levelName string, suffix string) (string, error) {
+	safetyCopy := ""
+	for _, dir := range worldDirs(levelName) {
+		current := filepath.Join(dataDir, dir)
+		if _, err := os.Stat(current); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(current, current+suffix); err != nil {
+			return "", fmt.Errorf("failed to move %s aside: %w", dir, err)
+		}
+		if dir == levelName {
+			safetyCopy = dir + suffix
+		}
+	}
+	return safetyCopy, nil
+}
+
+// putWorldBack removes a partially extracted world and renames the safety copy made by moveWorldAside back
+func putWorldBack(dataDir string, levelName string, suffix string) error {
+	for _, dir := range worldDirs(levelName) {
+		current := filepath.Join(dataDir, dir)
+		if err := os.RemoveAll(current); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", dir, err)
+		}
+		if _, err := os.Stat(current + suffix); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(current+suffix, current); err != nil {
+			return fmt.Errorf("failed to move %s back: %w", dir+suffix, err)
+		}
+	}
+	return nil
+}
+
+// normalizeArchivePath strips leading "./" and "/" so archive paths compare consistently
+func normalizeArchivePath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	for strings.HasPrefix(p, "./") || strings.HasPrefix(p, "/") {
+		p = strings.TrimPrefix(strings.TrimPrefix(p, "./"), "/")
+	}
+	return strings.TrimSuffix(p, "/")
+}
+
+// findArchiveWorldRoot returns the directory inside the archive holding level.dat ("" for the archive root)
+func findArchiveWorldRoot(archivePath string) (string, error) {
+	found := false
+	root := ""
+	err := walkBackupArchive(archivePath, func(entry archiveEntry, _ io.Reader) error {
+		if entry.IsDir || path.Base(entry.Path) != "level.dat" {
+			return nil
+		}
+		dir := path.Dir(entry.Path)
+		if dir == "." {
+			dir = ""
+		}
+		if !found || len(dir) < len(root) {
+			root = dir
+			found = true
+		}
+		if dir == "" {
+			return errStopArchiveWalk
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %w", err)
+	}
+	if !found {
+		return "", fmt.Errorf("backup does not contain a world (level.dat not found)")
+	}
+	return root, nil
+}
+
+// hasParentSegment reports whether a normalized archive path contains a ".." element
+func hasParentSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// worldTargetPath maps an archive path to its destination below the data directory, ok=false if it is not part of the world
+func worldTargetPath(entryPath string, prefix string, levelName string) (string, bool) {
+	if hasParentSegment(entryPath) {
+		return "", false
+	}
+	if prefix == "" {
+		return path.Join(levelName, entryPath), true
+	}
+	for _, suffix := range []string{"", "_nether", "_the_end"} {
+		dir := prefix + suffix
+		if entryPath == dir {
+			return levelName + suffix, true
+		}
+		if rest, ok := strings.CutPrefix(entryPath, dir+"/"); ok {
+			return path.Join(levelName+suffix, rest), true
+		}
+	}
+	return "", false
+}
+
+// extractWorld writes the world found under prefix in the archive to <dataDir>/<levelName>
+func extractWorld(archivePath string, prefix string, dataDir string, levelName string, progress func(done, total int64)) (int, error) {
+	absDataDir, err := filepath.Abs(dataDir)
+	if err != nil {
+		return 0, err
+	}
+	var worldRoots []string
+	for _, dir := range worldDirs(levelName) {
+		worldRoots = append(worldRoots, filepath.Join(absDataDir, dir))
+	}
+	insideWorld := func(fullPath string) bool {
+		for _, root := range worldRoots {
+			if fullPath == root || strings.HasPrefix(fullPath, root+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	files := 0
+	err = walkBackupArchive(archivePath, func(entry archiveEntry, r io.Reader) error {
+		if hasParentSegment(entry.Path) {
+			return fmt.Errorf("archive entry escapes the world: %s", entry.Path)
+		}
+		target, ok := worldTargetPath(entry.Path, prefix, levelName)
+		if !ok {
+			return nil
+		}
+		fullPath := filepath.Join(absDataDir, filepath.FromSlash(target))
+		if !insideWorld(fullPath) {
+			return fmt.Errorf("archive entry escapes the world: %s", entry.Path)
+		}
+		if entry.IsDir {
+			return os.MkdirAll(fullPath, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(fullPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, r); err != nil {
+			out.Close()
+			return err
+		}
+		files++
+		return out.Close()
+	}, progress)
+	return files, err
+}
+
+type archiveEntry struct {
+	Path  string
+	Size  int64
+	IsDir bool
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// walkBackupArchive calls fn for every regular file and directory in a zip, tar or tar.gz archive.
+// Returning errStopArchiveWalk from fn ends the walk without an error.
+func walkBackupArchive(archivePath string, fn func(entry archiveEntry, r io.Reader) error, progress func(done, total int64)) error {
+	var err error
+	if backupFormat(archivePath) == "zip" {
+		err = walkZipArchive(archivePath, fn, progress)
+	} else {
+		err = walkTarArchive(archivePath, fn, progress)
+	}
+	if errors.Is(err, errStopArchiveWalk) {
+		return nil
+	}
+	return err
+}
+
+func walkZipArchive(archivePath string, fn func(entry archiveEntry, r io.Reader) error, progress func(done, total int64)) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var total, done int64
+	for _, f := range reader.File {
+		total += int64(f.UncompressedSize64)
+	}
+	for _, f := range reader.File {
+		entry := archiveEntry{
+			Path:  normalizeArchivePath(f.Name),
+			Size:  int64(f.UncompressedSize64),
+			IsDir: f.FileInfo().IsDir(),
+		}
+		if entry.Path == "" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = fn(entry, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		done += entry.Size
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+	return nil
+}
+
+func walkTarArchive(archivePath string, fn func(entry archiveEntry, r io.Reader) error, progress func(done, total int64)) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	counter := &countingReader{r: f}
+	var r io.Reader = counter
+	if backupFormat(archivePath) == "tar.gz" {
+		gz, err := gzip.NewReader(counter)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeDir {
+			continue
+		}
+		entry := archiveEntry{
+			Path:  normalizeArchivePath(header.Name),
+			Size:  header.Size,
+			IsDir: header.Typeflag == tar.TypeDir,
+		}
+		if entry.Path == "" {
+			continue
+		}
+		if err := fn(entry, tr); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(counter.n, info.Size())
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"mc-admin/internal/clients/destinations"
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stoppingRconClient behaves like fakeRconClient until "stop" is executed, after which every command fails.
+// With dropOnStop the reply to "stop" is lost and the server keeps answering for stopDelay more commands.
+type stoppingRconClient struct {
+	fakeRconClient
+	stopped    bool
+	dropOnStop bool
+	stopDelay  int
+}
+
+func (f *stoppingRconClient) ExecuteCommand(cmd string) (string, error) {
+	if f.stopped {
+		if f.stopDelay > 0 {
+			f.stopDelay--
+			return f.fakeRconClient.ExecuteCommand(cmd)
+		}
+		f.received = append(f.received, cmd)
+		return "", fmt.Errorf("connection refused")
+	}
+	if cmd == "stop" {
+		f.stopped = true
+		if f.dropOnStop {
+			f.received = append(f.received, cmd)
+			return "", fmt.Errorf("connection reset by peer")
+		}
+	}
+	return f.fakeRconClient.ExecuteCommand(cmd)
+}
+
+func writeTarGz(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create archive: %v", err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("write entry: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+}
+
+func writeZip(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create archive: %v", err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+}
+
+func newTestBackupService(t *testing.T, rconClient *stoppingRconClient) (*BackupService, string) {
+	t.Helper()
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dataDir, "backups"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	svc := NewBackupService(rconClient, &fileClient, "backups", nil, 0)
+	svc.sleep = func(time.Duration) {}
+	return svc, dataDir
+}
+
+func TestBackupService_ListBackups(t *testing.T) {
+	svc, dataDir := newTestBackupService(t, &stoppingRconClient{})
+	backupDir := filepath.Join(dataDir, "backups")
+
+	writeTarGz(t, filepath.Join(backupDir, "old.tar.gz"), map[string]string{"./world/level.dat": "old"})
+	writeZip(t, filepath.Join(backupDir, "new.zip"), map[string]string{"survival/level.dat": "new", "survival/region/r.0.0.mca": "r"})
+	writeZip(t, filepath.Join(backupDir, "broken.zip"), map[string]string{"readme.txt": "no world"})
+	os.WriteFile(filepath.Join(backupDir, "notes.txt"), []byte("ignored"), 0644)
+
+	now := time.Now()
+	os.Chtimes(filepath.Join(backupDir, "old.tar.gz"), now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	os.Chtimes(filepath.Join(backupDir, "broken.zip"), now.Add(-time.Hour), now.Add(-time.Hour))
+
+	backups, err := svc.ListBackups()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names, worlds, formats []string
+	for _, b := range backups {
+		names = append(names, b.Name)
+		worlds = append(worlds, b.WorldName)
+		formats = append(formats, b.Format)
+	}
+	if want := []string{"new.zip", "broken.zip", "old.tar.gz"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+	if want := []string{"survival", "", "world"}; !reflect.DeepEqual(worlds, want) {
+		t.Fatalf("worlds = %v, want %v", worlds, want)
+	}
+	if want := []string{"zip", "zip", "tar.gz"}; !reflect.DeepEqual(formats, want) {
+		t.Fatalf("formats = %v, want %v", formats, want)
+	}
+}
+
+func TestBackupService_GetBackupPath(t *testing.T) {
+	svc, dataDir := newTestBackupService(t, &stoppingRconClient{})
+	writeZip(t, filepath.Join(dataDir, "backups", "ok.zip"), map[string]string{"world/level.dat": "x"})
+	os.WriteFile(filepath.Join(dataDir, "secret.zip"), []byte("x"), 0644)
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "existing backup", input: "ok.zip"},
+		{name: "missing backup", input: "missing.zip", wantErr: true},
+		{name: "path traversal", input: "../secret.zip", wantErr: true},
+		{name: "unsupported format", input: "ok.rar", wantErr: true},
+		{name: "empty name", input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.GetBackupPath(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got path %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != filepath.Join(dataDir, "backups", tt.input) {
+				t.Fatalf("path = %q", got)
+			}
+		})
+	}
+}
+
+func TestBackupService_ListBackupContents(t *testing.T) {
+	svc, dataDir := newTestBackupService(t, &stoppingRconClient{})
+	writeTarGz(t, filepath.Join(dataDir, "backups", "b.tgz"), map[string]string{"world/level.dat": "abc"})
+
+	entries, err := svc.ListBackupContents("b.tgz")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []BackupEntry{{Path: "world/level.dat", Size: 3}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("entries = %#v, want %#v", entries, want)
+	}
+}
+
+func TestBackupService_Restore(t *testing.T) {
+	rconClient := &stoppingRconClient{fakeRconClient: fakeRconClient{
+		responses: map[string]struct {
+			out string
+			err error
+		}{
+			"say Restoring a world backup in 10 seconds. You will be disconnected.": {out: ""},
+			"list": {out: "There are 1 of a max of 20 players online: Steve"},
+			"kick Steve Server is restoring a backup": {out: "Kicked Steve"},
+			"stop": {out: "Stopping the server"},
+		},
+	}}
+	svc, dataDir := newTestBackupService(t, rconClient)
+	svc.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	os.WriteFile(filepath.Join(dataDir, "server.properties"), []byte("level-name=survival\n"), 0644)
+	os.MkdirAll(filepath.Join(dataDir, "survival"), 0755)
+	os.WriteFile(filepath.Join(dataDir, "survival", "level.dat"), []byte("broken"), 0644)
+	writeTarGz(t, filepath.Join(dataDir, "backups", "b.tar.gz"), map[string]string{
+		"./world/level.dat":          "restored",
+		"./world/region/r.0.0.mca":   "region",
+		"./world_nether/DIM-1/a.mca": "nether",
+		"./server.properties":        "level-name=other",
+	})
+
+	if err := svc.beginRestore("b.tar.gz"); err != nil {
+		t.Fatalf("beginRestore: %v", err)
+	}
+	svc.runRestore("b.tar.gz", RestoreOptions{WarningSeconds: 10})
+
+	status := svc.GetRestoreStatus()
+	if status.Running || status.Error != "" {
+		t.Fatalf("restore did not finish cleanly: %+v", status)
+	}
+	for _, step := range status.Steps {
+		if step.Status != RestoreStepDone {
+			t.Fatalf("step %q has status %q (%s)", step.Label, step.Status, step.Detail)
+		}
+	}
+	if status.Progress != 100 {
+		t.Fatalf("progress = %d, want 100", status.Progress)
+	}
+	if status.SafetyCopy != "survival.pre-restore-20240501-120000" {
+		t.Fatalf("safety copy = %q", status.SafetyCopy)
+	}
+
+	assertFile := func(rel, want string) {
+		t.Helper()
+		got, err := os.ReadFile(filepath.Join(dataDir, rel))
+		if err != nil {
+			t.Fatalf("read %s: %v", rel, err)
+		}
+		if string(got) != want {
+			t.Fatalf("%s = %q, want %q", rel, got, want)
+		}
+	}
+	assertFile("survival/level.dat", "restored")
+	assertFile("survival/region/r.0.0.mca", "region")
+	assertFile("survival_nether/DIM-1/a.mca", "nether")
+	assertFile("survival.pre-restore-20240501-120000/level.dat", "broken")
+	assertFile("server.properties", "level-name=survival\n")
+
+	if !strings.Contains(strings.Join(rconClient.received, "\n"), "kick Steve") {
+		t.Fatalf("expected Steve to be kicked, commands: %v", rconClient.received)
+	}
+}
+
+func TestBackupService_StopServerWaitsAfterDroppedConnection(t *testing.T) {
+	rconClient := &stoppingRconClient{
+		fakeRconClient: fakeRconClient{responses: map[string]struct {
+			out string
+			err error
+		}{
+			"list": {out: "There are 0 of a max of 20 players online: "},
+		}},
+		dropOnStop: true,
+		stopDelay:  2,
+	}
+	svc, _ := newTestBackupService(t, rconClient)
+
+	if err := svc.stopServer(); err != nil {
+		t.Fatalf("stopServer: %v", err)
+	}
+	if rconClient.stopDelay != 0 {
+		t.Fatalf("stopServer returned while the server still answered")
+	}
+}
+
+func TestBackupService_RestoreRejectsEscapingEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[string]string
+	}{
+		{
+			name:    "world at the archive root",
+			entries: map[string]string{"level.dat": "restored", "../server.properties": "level-name=evil"},
+		},
+		{
+			name:    "world in a folder",
+			entries: map[string]string{"world/level.dat": "restored", "world/../ops.json": "[]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rconClient := &stoppingRconClient{}
+			svc, dataDir := newTestBackupService(t, rconClient)
+			os.WriteFile(filepath.Join(dataDir, "server.properties"), []byte("level-name=world\n"), 0644)
+			os.WriteFile(filepath.Join(dataDir, "ops.json"), []byte("[\"admin\"]"), 0644)
+			os.MkdirAll(filepath.Join(dataDir, "world"), 0755)
+			os.WriteFile(filepath.Join(dataDir, "world", "level.dat"), []byte("current"), 0644)
+			writeZip(t, filepath.Join(dataDir, "backups", "evil.zip"), tt.entries)
+
+			if err := svc.beginRestore("evil.zip"); err != nil {
+				t.Fatalf("beginRestore: %v", err)
+			}
+			svc.runRestore("evil.zip", RestoreOptions{})
+
+			status := svc.GetRestoreStatus()
+			if !strings.Contains(status.Error, "escapes the world") {
+				t.Fatalf("restore error = %q", status.Error)
+			}
+			for rel, want := range map[string]string{
+				"server.properties": "level-name=world\n",
+				"ops.json":          "[\"admin\"]",
+				"world/level.dat":   "current",
+			} {
+				got, err := os.ReadFile(filepath.Join(dataDir, rel))
+				if err != nil || string(got) != want {
+					t.Fatalf("%s = %q, %v; want %q", rel, got, err, want)
+				}
+			}
+			if matches, _ := filepath.Glob(filepath.Join(dataDir, "world.pre-restore-*")); len(matches) != 0 {
+				t.Fatalf("safety copy was not moved back: %v", matches)
+			}
+		})
+	}
+}
+
+func TestBackupService_RestoreRejectsConcurrentRuns(t *testing.T) {
+	svc, _ := newTestBackupService(t, &stoppingRconClient{})
+	if err := svc.beginRestore("a.zip"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.beginRestore("b.zip"); err == nil {
+		t.Fatalf("expected error for concurrent restore")
+	}
+}
+
+func TestWorldTargetPath(t *testing.T) {
+	tests := []struct {
+		entry, prefix, want string
+		ok                  bool
+	}{
+		{entry: "level.dat", prefix: "", want: "world/level.dat", ok: true},
+		{entry: "backup/world/region/r.0.0.mca", prefix: "backup/world", want: "world/region/r.0.0.mca", ok: true},
+		{entry: "backup/world_the_end/DIM1", prefix: "backup/world", want: "world_the_end/DIM1", ok: true},
+		{entry: "backup/plugins/x.jar", prefix: "backup/world", ok: false},
+		{entry: "backup/worldedit/x", prefix: "backup/world", ok: false},
+		{entry: "../server.properties", prefix: "", ok: false},
+		{entry: "backup/world/../ops.json", prefix: "backup/world", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := worldTargetPath(tt.entry, tt.prefix, "world")
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("worldTargetPath(%q, %q) = %q, %v; want %q, %v", tt.entry, tt.prefix, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// failingDestination rejects every upload
+type failingDestination struct{}
+
+func (failingDestination) Name() string { return "broken" }
+func (failingDestination) Upload(ctx context.Context, key string, src io.ReaderAt, size int64, progress func(int64)) error {
+	return destinations.ErrChecksumMismatch
+}
+func (failingDestination) List(ctx context.Context) ([]destinations.RemoteObject, error) {
+	return nil, nil
+}
+func (failingDestination) Delete(ctx context.Context, key string) error { return nil }
+
+func TestBackupService_Upload(t *testing.T) {
+	svc, dataDir := newTestBackupService(t, &stoppingRconClient{})
+	mirrorDir := filepath.Join(t.TempDir(), "mirror")
+	mirror := destinations.NewLocalDestination(mirrorDir)
+	svc.destinations = []destinations.Destination{mirror, failingDestination{}}
+	svc.retention = 1
+	backupDir := filepath.Join(dataDir, "backups")
+
+	for i, name := range []string{"a.zip", "b.zip"} {
+		writeZip(t, filepath.Join(backupDir, name), map[string]string{"world/level.dat": name})
+		fullPath, err := svc.beginUpload(name)
+		if err != nil {
+			t.Fatalf("beginUpload(%s): %v", name, err)
+		}
+		svc.runUpload(name, fullPath)
+		// make the first upload the older remote copy
+		modTime := time.Now().Add(time.Duration(i-2) * time.Hour)
+		os.Chtimes(filepath.Join(mirrorDir, name), modTime, modTime)
+	}
+
+	backup, err := svc.GetBackup("b.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.Uploads) != 2 || backup.UploadInProgress() {
+		t.Fatalf("uploads = %+v", backup.Uploads)
+	}
+	if backup.Uploads[0].Status != UploadStatusDone || backup.Uploads[1].Status != UploadStatusFailed {
+		t.Fatalf("uploads = %+v, want done at the mirror and failed at the broken destination", backup.Uploads)
+	}
+	if _, err := os.Stat(filepath.Join(mirrorDir, "b.zip")); err != nil {
+		t.Fatalf("b.zip not mirrored: %v", err)
+	}
+
+	// retention of one removed the older copy from the mirror
+	first, _ := svc.GetBackup("a.zip")
+	if first.Uploads[0].Status != UploadStatusPruned {
+		t.Fatalf("a.zip mirror status = %s, want pruned", first.Uploads[0].Status)
+	}
+	if _, err := os.Stat(filepath.Join(mirrorDir, "a.zip")); !os.IsNotExist(err) {
+		t.Fatalf("a.zip should have been pruned from the mirror")
+	}
+
+	// state is persisted next to the backups
+	reloaded, _ := newTestBackupService(t, &stoppingRconClient{})
+	reloaded.backupDir = backupDir
+	reloaded.destinations = svc.destinations
+	if uploads := reloaded.getUploads("b.zip"); len(uploads) != 2 || uploads[0].Status != UploadStatusDone {
+		t.Fatalf("reloaded uploads = %+v", uploads)
+	}
+}
+
+// stalledDestination accepts a connection and never finishes the upload
+type stalledDestination struct{ failingDestination }
+
+func (stalledDestination) Name() string { return "stalled" }
+func (stalledDestination) Upload(ctx context.Context, key string, src io.ReaderAt, size int64, progress func(int64)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestBackupService_UploadTimesOut(t *testing.T) {
+	svc, dataDir := newTestBackupService(t, &stoppingRconClient{})
+	svc.destinations = []destinations.Destination{stalledDestination{}}
+	svc.uploadTimeout = func(int64) time.Duration { return 10 * time.Millisecond }
+	writeZip(t, filepath.Join(dataDir, "backups", "a.zip"), map[string]string{"world/level.dat": "x"})
+
+	fullPath, err := svc.beginUpload("a.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.runUpload("a.zip", fullPath)
+	backup, _ := svc.GetBackup("a.zip")
+	if len(backup.Uploads) != 1 || backup.Uploads[0].Status != UploadStatusFailed || !strings.Contains(backup.Uploads[0].Error, "did not finish") {
+		t.Fatalf("uploads = %+v", backup.Uploads)
+	}
+	// a timed out upload does not block the next attempt
+	if _, err := svc.beginUpload("a.zip"); err != nil {
+		t.Fatalf("beginUpload after a timeout: %v", err)
+	}
+}
+
+func TestBackupService_CorruptUploadState(t *testing.T) {
+	svc, dataDir := newTestBackupService(t, &stoppingRconClient{})
+	svc.destinations = []destinations.Destination{failingDestination{}}
+	writeZip(t, filepath.Join(dataDir, "backups", "a.zip"), map[string]string{"world/level.dat": "x"})
+	statePath := filepath.Join(dataDir, "backups", ".uploads.json")
+	os.WriteFile(statePath, []byte("{broken"), 0644)
+
+	if err := svc.UploadStateError(); err == nil || !strings.Contains(err.Error(), "fix or remove it") {
+		t.Fatalf("UploadStateError = %v", err)
+	}
+	if _, err := svc.beginUpload("a.zip"); err == nil {
+		t.Fatalf("beginUpload succeeded with unreadable upload state")
+	}
+	if data, _ := os.ReadFile(statePath); string(data) != "{broken" {
+		t.Fatalf("unreadable upload state was overwritten: %q", data)
+	}
+}
+
+func TestBackupService_StartUploadWithoutDestinations(t *testing.T) {
+	svc, dataDir := newTestBackupService(t, &stoppingRconClient{})
+	writeZip(t, filepath.Join(dataDir, "backups", "a.zip"), map[string]string{"world/level.dat": "x"})
+	if err := svc.StartUpload("a.zip"); err == nil {
+		t.Fatalf("expected error without destinations")
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mc-admin/internal/clients/destinations"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	UploadStatusPending   = "pending"
+	UploadStatusUploading = "uploading"
+	UploadStatusDone      = "done"
+	UploadStatusFailed    = "failed"
+	UploadStatusPruned    = "pruned"
+)
+
+// uploadStateFile records upload results next to the backups so they survive restarts
+const uploadStateFile = ".uploads.json"
+
+const (
+	// an upload may take uploadBaseTimeout plus the time the file needs at minUploadRate,
+	// after that it is cancelled so a stalled connection does not block the backup forever
+	uploadBaseTimeout = 5 * time.Minute
+	minUploadRate     = 256 << 10 // bytes per second
+	pruneTimeout      = 5 * time.Minute
+)
+
+// defaultUploadTimeout returns how long the upload of a file of the given size may take
+func defaultUploadTimeout(size int64) time.Duration {
+	return uploadBaseTimeout + time.Duration(size/minUploadRate)*time.Second
+}
+
+// BackupUpload is the state of one backup at one destination
+type BackupUpload struct {
+	Destination string
+	Status      string
+	Progress    int
+	Error       string
+	UpdatedAt   time.Time
+}
+
+// UploadInProgress reports whether any destination is still pending or uploading
+func (b BackupInfo) UploadInProgress() bool {
+	for _, upload := range b.Uploads {
+		if upload.Status == UploadStatusPending || upload.Status == UploadStatusUploading {
+			return true
+		}
+	}
+	return false
+}
+
+// HasDestinations reports whether any remote destination is configured
+func (s *BackupService) HasDestinations() bool {
+	return len(s.destinations) > 0
+}
+
+// getUploads returns the upload state of a backup in destination order. Unreadable state shows
+// no uploads, UploadStateError reports it.
+func (s *BackupService) getUploads(name string) []BackupUpload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uploads := []BackupUpload{}
+	if err := s.loadUploadsLocked(); err != nil {
+		return uploads
+	}
+	byDestination := s.uploads[name]
+	for _, d := range s.destinations {
+		if upload, ok := byDestination[d.Name()]; ok {
+			uploads = append(uploads, *upload)
+		}
+	}
+	return uploads
+}
+
+// UploadStateError returns why the upload state cannot be read, or nil
+func (s *BackupService) UploadStateError() error {
+	if !s.HasDestinations() {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadUploadsLocked()
+}
+
+// StartUpload copies a backup to every configured destination in the background
+func (s *BackupService) StartUpload(name string) error {
+	fullPath, err := s.beginUpload(name)
+	if err != nil {
+		return err
+	}
+	go s.runUpload(name, fullPath)
+	return nil
+}
+
+// beginUpload marks every destination as pending unless an upload of the backup is already running
+func (s *BackupService) beginUpload(name string) (string, error) {
+	if !s.HasDestinations() {
+		return "", fmt.Errorf("no backup destinations are configured")
+	}
+	fullPath, err := s.GetBackupPath(name)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	if err := s.loadUploadsLocked(); err != nil {
+		s.mu.Unlock()
+		return "", err
+	}
+	for _, upload := range s.uploads[name] {
+		if upload.Status == UploadStatusPending || upload.Status == UploadStatusUploading {
+			s.mu.Unlock()
+			return "", fmt.Errorf("an upload of '%s' is already running", name)
+		}
+	}
+	byDestination := map[string]*BackupUpload{}
+	for _, d := range s.destinations {
+		byDestination[d.Name()] = &BackupUpload{Destination: d.Name(), Status: UploadStatusPending, UpdatedAt: s.now()}
+	}
+	s.uploads[name] = byDestination
+	s.mu.Unlock()
+	return fullPath, nil
+}
+
+func (s *BackupService) runUpload(name string, fullPath string) {
+	for _, d := range s.destinations {
+		s.setUpload(name, d.Name(), UploadStatusUploading, 0, "")
+		if err := s.uploadTo(d, name, fullPath); err != nil {
+			s.setUpload(name, d.Name(), UploadStatusFailed, 0, err.Error())
+			continue
+		}
+		s.setUpload(name, d.Name(), UploadStatusDone, 100, "")
+
+		ctx, cancel := context.WithTimeout(context.Background(), pruneTimeout)
+		pruned, err := destinations.PruneRetention(ctx, d, s.retention, isBackupName)
+		cancel()
+		if err != nil {
+			log.Printf("Retention pruning failed for %s: %v", d.Name(), err)
+		}
+		for _, key := range pruned {
+			s.setUpload(key, d.Name(), UploadStatusPruned, 0, "")
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.saveUploadsLocked(); err != nil {
+		log.Printf("Failed to save upload state: %v", err)
+	}
+}
+
+func (s *BackupService) uploadTo(d destinations.Destination, name string, fullPath string) error {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	timeout := s.uploadTimeout(size)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = d.Upload(ctx, name, f, size, func(uploaded int64) {
+		if size > 0 {
+			s.setUpload(name, d.Name(), UploadStatusUploading, int(uploaded*100/size), "")
+		}
+	})
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("upload did not finish within %s", timeout)
+	}
+	return err
+}
+
+// setUpload updates the state of a backup at a destination. Remote copies that are
+// pruned but were never uploaded from this server are not tracked.
+func (s *BackupService) setUpload(name string, destination string, status string, progress int, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byDestination, ok := s.uploads[name]
+	if !ok {
+		if status == UploadStatusPruned {
+			return
+		}
+		byDestination = map[string]*BackupUpload{}
+		s.uploads[name] = byDestination
+	}
+	upload, ok := byDestination[destination]
+	if !ok {
+		if status == UploadStatusPruned {
+			return
+		}
+		upload = &BackupUpload{Destination: destination}
+		byDestination[destination] = upload
+	}
+	upload.Status = status
+	upload.Progress = progress
+	upload.Error = errMsg
+	upload.UpdatedAt = s.now()
+}
+
+func (s *BackupService) uploadState() (jsonStateFile, error) {
+	dir, err := s.resolveBackupDir()
+	if err != nil {
+		return jsonStateFile{}, err
+	}
+	return jsonStateFile{path: filepath.Join(dir, uploadStateFile)}, nil
+}
+
+// loadUploadsLocked reads the persisted upload state once. Uploads that were running
+// when the process stopped are reported as failed.
+func (s *BackupService) loadUploadsLocked() error {
+	if s.uploads != nil {
+		return nil
+	}
+	file, err := s.uploadState()
+	if err != nil {
+		return err
+	}
+	uploads := map[string]map[string]*BackupUpload{}
+	if err := file.load(&uploads); err != nil {
+		return err
+	}
+	for _, byDestination := range uploads {
+		for _, upload := range byDestination {
+			if upload.Status == UploadStatusPending || upload.Status == UploadStatusUploading {
+				upload.Status = UploadStatusFailed
+				upload.Error = "interrupted by restart"
+			}
+		}
+	}
+	s.uploads = uploads
+	return nil
+}
+
+func (s *BackupService) saveUploadsLocked() error {
+	file, err := s.uploadState()
+	if err != nil {
+		return err
+	}
+	return file.save(s.uploads)
+}
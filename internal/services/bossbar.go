@@ -0,0 +1,591 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"mc-admin/internal/clients/rcon"
+	"mc-admin/internal/regions"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// BossbarRemainingPlaceholder in a bossbar name is replaced with the time left of its countdown
+	BossbarRemainingPlaceholder = "{remaining}"
+
+	CountdownFinishKeep   = "keep"
+	CountdownFinishHide   = "hide"
+	CountdownFinishRemove = "remove"
+)
+
+var (
+	// resourceLocationPattern matches ids like event:countdown or diamond_sword (namespace optional)
+	resourceLocationPattern = regexp.MustCompile(`^([a-z0-9_.-]+:)?[a-z0-9_./-]+$`)
+	// bossbarNumberPattern matches "Custom bossbar [Event] has a value of 12"
+	bossbarNumberPattern = regexp.MustCompile(`has a (?:value|maximum) of (-?\d+)$`)
+)
+
+// BossbarColors are the colors accepted by "bossbar set <id> color"
+var BossbarColors = []string{"blue", "green", "pink", "purple", "red", "white", "yellow"}
+
+// BossbarStyles are the styles accepted by "bossbar set <id> style"
+var BossbarStyles = []string{"progress", "notched_6", "notched_10", "notched_12", "notched_20"}
+
+// CountdownFinishActions are what happens to a bossbar when its countdown reaches zero
+var CountdownFinishActions = []string{CountdownFinishKeep, CountdownFinishHide, CountdownFinishRemove}
+
+// BossbarFileSystemAccessor is the subset of the files client used by BossbarService
+type BossbarFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	ReadFile(path string) (string, error)
+}
+
+// BossbarCountdown counts a bossbar down to Target, one value per second
+type BossbarCountdown struct {
+	Target   time.Time `json:"target"`
+	OnFinish string    `json:"on_finish"`
+}
+
+// Remaining returns the whole seconds left at now, never negative
+func (c BossbarCountdown) Remaining(now time.Time) int {
+	return max(0, int(math.Ceil(c.Target.Sub(now).Seconds())))
+}
+
+// BossbarSettings are the editable properties of a bossbar. Name is plain text or a JSON text
+// component, Players a target selector or player names ("" shows the bar to nobody).
+type BossbarSettings struct {
+	Name    string `json:"name"`
+	Color   string `json:"color"`
+	Style   string `json:"style"`
+	Value   int    `json:"-"`
+	Max     int    `json:"-"`
+	Visible bool   `json:"-"`
+	Players string `json:"players"`
+}
+
+// managedBossbar is a bossbar created or edited here, remembered so raw names, player targets
+// and countdowns survive restarts
+type managedBossbar struct {
+	BossbarSettings
+	Countdown *BossbarCountdown `json:"countdown,omitempty"`
+}
+
+// Bossbar is a custom bossbar with its live value where the server is reachable
+type Bossbar struct {
+	ID string
+	BossbarSettings
+	// DisplayName is the plain text of the name as last saved
+	DisplayName string
+	// OnlinePlayers are the players currently seeing the bar
+	OnlinePlayers []string
+	Countdown     *BossbarCountdown
+	Managed       bool
+	Live          bool
+}
+
+// BossbarService manages custom bossbars over RCON and runs their countdowns in the background.
+// Bossbar ids are only listed in level.dat, so bars created elsewhere appear after a world save.
+type BossbarService struct {
+	rconClient rcon.CommandExecutor
+	fileClient BossbarFileSystemAccessor
+	state      jsonStateFile
+	now        func() time.Time
+
+	mu      sync.Mutex
+	managed map[string]*managedBossbar
+	// paused is set while countdowns wait for a readable state file, so that is logged once
+	paused bool
+}
+
+// NewBossbarService creates a BossbarService persisting managed bossbars to statePath
+func NewBossbarService(rconClient rcon.CommandExecutor, fileClient BossbarFileSystemAccessor, statePath string) *BossbarService {
+	return &BossbarService{
+		rconClient: rconClient,
+		fileClient: fileClient,
+		state:      jsonStateFile{path: statePath},
+		now:        time.Now,
+	}
+}
+
+// loadLocked reads the managed bossbars once
+func (s *BossbarService) loadLocked() error {
+	if s.managed != nil {
+		return nil
+	}
+	managed := map[string]*managedBossbar{}
+	if err := s.state.load(&managed); err != nil {
+		return err
+	}
+	s.managed = managed
+	return nil
+}
+
+func (s *BossbarService) saveLocked() error {
+	return s.state.save(s.managed)
+}
+
+// readSavedBossbars returns the custom bossbars stored in level.dat by id
+func (s *BossbarService) readSavedBossbars() (map[string]Bossbar, error) {
+	worldDir, err := s.fileClient.GetAbsolutePath(getLevelName(s.fileClient))
+	if err != nil {
+		return nil, err
+	}
+	data, err := readLevelData(worldDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]Bossbar{}, nil
+		}
+		return nil, err
+	}
+	bars := map[string]Bossbar{}
+	for id, value := range data.Compound("CustomBossEvents") {
+		raw, ok := value.(regions.Compound)
+		if !ok {
+			continue
+		}
+		bar := Bossbar{ID: id}
+		bar.DisplayName = componentText(raw["Name"], id)
+		bar.Color = raw.String("Color")
+		bar.Style = raw.String("Overlay")
+		if v, ok := raw.Int("Value"); ok {
+			bar.Value = int(v)
+		}
+		if v, ok := raw.Int("Max"); ok {
+			bar.Max = int(v)
+		}
+		if v, ok := raw.Int("Visible"); ok {
+			bar.Visible = v != 0
+		}
+		bars[id] = bar
+	}
+	return bars, nil
+}
+
+// GetBossbars lists saved and managed bossbars sorted by id. Managed bars that the server no
+// longer knows are forgotten.
+func (s *BossbarService) GetBossbars() ([]Bossbar, error) {
+	saved, err := s.readSavedBossbars()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read level.dat: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(saved)+len(s.managed))
+	for id := range saved {
+		ids = append(ids, id)
+	}
+	for id := range s.managed {
+		if _, ok := saved[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	bars := []Bossbar{}
+	forgotten := false
+	for _, id := range ids {
+		bar, ok := saved[id]
+		if !ok {
+			bar = Bossbar{ID: id, DisplayName: id}
+		}
+		if entry, ok := s.managed[id]; ok {
+			bar.Managed = true
+			bar.Name = entry.Name
+			bar.Color = entry.Color
+			bar.Style = entry.Style
+			bar.Players = entry.Players
+			bar.Countdown = entry.Countdown
+			if _, ok := saved[id]; !ok {
+				bar.DisplayName = componentText(entry.Name, id)
+			}
+		}
+		if bar.Name == "" {
+			bar.Name = bar.DisplayName
+		}
+		if err := s.readLive(&bar); err != nil {
+			if strings.HasPrefix(err.Error(), "No bossbar exists") {
+				if bar.Managed {
+					delete(s.managed, id)
+					forgotten = true
+				}
+				continue
+			}
+		}
+		bars = append(bars, bar)
+	}
+	if forgotten {
+		if err := s.saveLocked(); err != nil {
+			log.Printf("Failed to forget removed bossbars: %v", err)
+		}
+	}
+	return bars, nil
+}
+
+// readLive replaces the saved value, maximum, visibility and viewers with the current ones
+func (s *BossbarService) readLive(bar *Bossbar) error {
+	value, err := s.getNumber(bar.ID, "value")
+	if err != nil {
+		return err
+	}
+	maximum, err := s.getNumber(bar.ID, "max")
+	if err != nil {
+		return err
+	}
+	visible, err := s.rconClient.ExecuteCommand("bossbar get " + bar.ID + " visible")
+	if err != nil {
+		return err
+	}
+	players, err := s.rconClient.ExecuteCommand("bossbar get " + bar.ID + " players")
+	if err != nil {
+		return err
+	}
+	bar.Value = value
+	bar.Max = maximum
+	bar.Visible = strings.HasSuffix(strings.TrimSpace(visible), "is currently shown")
+	bar.OnlinePlayers = []string{}
+	if _, list, ok := strings.Cut(players, "currently online: "); ok {
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				bar.OnlinePlayers = append(bar.OnlinePlayers, name)
+			}
+		}
+	}
+	bar.Live = true
+	return nil
+}
+
+func (s *BossbarService) getNumber(id, property string) (int, error) {
+	response, err := s.rconClient.ExecuteCommand("bossbar get " + id + " " + property)
+	if err != nil {
+		return 0, err
+	}
+	response = strings.TrimSpace(response)
+	m := bossbarNumberPattern.FindStringSubmatch(response)
+	if m == nil {
+		return 0, fmt.Errorf("%s", response)
+	}
+	return strconv.Atoi(m[1])
+}
+
+func validateBossbarID(id string) error {
+	if !resourceLocationPattern.MatchString(id) {
+		return fmt.Errorf("bossbar id must be a lowercase resource location like event:countdown")
+	}
+	return nil
+}
+
+func (settings BossbarSettings) validate() error {
+	if strings.TrimSpace(settings.Name) == "" {
+		return fmt.Errorf("bossbar name is required")
+	}
+	if _, err := bossbarComponent(settings.Name); err != nil {
+		return err
+	}
+	if !slices.Contains(BossbarColors, settings.Color) {
+		return fmt.Errorf("unknown bossbar color %q", settings.Color)
+	}
+	if !slices.Contains(BossbarStyles, settings.Style) {
+		return fmt.Errorf("unknown bossbar style %q", settings.Style)
+	}
+	if settings.Max < 1 {
+		return fmt.Errorf("bossbar maximum must be at least 1")
+	}
+	if settings.Value < 0 {
+		return fmt.Errorf("bossbar value cannot be negative")
+	}
+	if strings.ContainsAny(settings.Players, "\r\n") {
+		return fmt.Errorf("players must be on a single line")
+	}
+	return nil
+}
+
+// bossbarComponent turns a name into a text component argument. Names starting with { or [ are
+// used as JSON components, anything else becomes a plain string.
+func bossbarComponent(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "{") || strings.HasPrefix(name, "[") {
+		if !json.Valid([]byte(name)) {
+			return "", fmt.Errorf("bossbar name is not a valid JSON text component")
+		}
+		return name, nil
+	}
+	quoted, err := json.Marshal(name)
+	if err != nil {
+		return "", err
+	}
+	return string(quoted), nil
+}
+
+// formatRemaining formats seconds as m:ss or h:mm:ss
+func formatRemaining(seconds int) string {
+	hours, minutes, secs := seconds/3600, seconds%3600/60, seconds%60
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, secs)
+	}
+	return fmt.Sprintf("%d:%02d", minutes, secs)
+}
+
+// renderName substitutes the countdown placeholder and builds the component argument
+func renderName(name string, countdown *BossbarCountdown, now time.Time) (string, error) {
+	if countdown != nil {
+		name = strings.ReplaceAll(name, BossbarRemainingPlaceholder, formatRemaining(countdown.Remaining(now)))
+	}
+	return bossbarComponent(name)
+}
+
+// set runs "bossbar set <id> <property> <value>". Setting a property to its current value is
+// reported by the server as "Nothing changed", which counts as success.
+func (s *BossbarService) set(id, property, value string) error {
+	command := strings.TrimSpace(fmt.Sprintf("bossbar set %s %s %s", id, property, value))
+	return executeExpecting(s.rconClient, command, "Custom bossbar", "Nothing changed")
+}
+
+// CreateBossbar adds a bossbar and applies its settings
+func (s *BossbarService) CreateBossbar(id string, settings BossbarSettings) error {
+	if err := validateBossbarID(id); err != nil {
+		return err
+	}
+	if err := settings.validate(); err != nil {
+		return err
+	}
+	name, err := bossbarComponent(settings.Name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	if err := executeExpecting(s.rconClient, fmt.Sprintf("bossbar add %s %s", id, name), "Created custom bossbar"); err != nil {
+		return err
+	}
+	entry := &managedBossbar{BossbarSettings: settings}
+	s.managed[id] = entry
+	if err := s.saveLocked(); err != nil {
+		return err
+	}
+	return s.applyLocked(id, entry, settings)
+}
+
+// UpdateBossbar changes the settings of a bossbar. While a countdown runs its value and
+// maximum are left to the countdown.
+func (s *BossbarService) UpdateBossbar(id string, settings BossbarSettings) error {
+	if err := validateBossbarID(id); err != nil {
+		return err
+	}
+	if err := settings.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	entry, ok := s.managed[id]
+	if !ok {
+		entry = &managedBossbar{}
+		s.managed[id] = entry
+	}
+	entry.BossbarSettings = settings
+	if err := s.saveLocked(); err != nil {
+		return err
+	}
+	return s.applyLocked(id, entry, settings)
+}
+
+// applyLocked sends every setting, maximum before value so the value is not clamped
+func (s *BossbarService) applyLocked(id string, entry *managedBossbar, settings BossbarSettings) error {
+	name, err := renderName(settings.Name, entry.Countdown, s.now())
+	if err != nil {
+		return err
+	}
+	if err := s.set(id, "name", name); err != nil {
+		return err
+	}
+	if err := s.set(id, "color", settings.Color); err != nil {
+		return err
+	}
+	if err := s.set(id, "style", settings.Style); err != nil {
+		return err
+	}
+	if entry.Countdown == nil {
+		if err := s.set(id, "max", strconv.Itoa(settings.Max)); err != nil {
+			return err
+		}
+		if err := s.set(id, "value", strconv.Itoa(min(settings.Value, settings.Max))); err != nil {
+			return err
+		}
+	}
+	if err := s.set(id, "visible", strconv.FormatBool(settings.Visible)); err != nil {
+		return err
+	}
+	return s.set(id, "players", strings.TrimSpace(settings.Players))
+}
+
+// RemoveBossbar removes a bossbar and stops its countdown
+func (s *BossbarService) RemoveBossbar(id string) error {
+	if err := validateBossbarID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	if err := executeExpecting(s.rconClient, "bossbar remove "+id, "Removed custom bossbar"); err != nil {
+		return err
+	}
+	if _, ok := s.managed[id]; ok {
+		delete(s.managed, id)
+		return s.saveLocked()
+	}
+	return nil
+}
+
+// StartCountdown makes a bossbar count down to target. The maximum becomes the number of
+// seconds until then and the bar is shown.
+func (s *BossbarService) StartCountdown(id string, target time.Time, onFinish string) error {
+	if err := validateBossbarID(id); err != nil {
+		return err
+	}
+	if !slices.Contains(CountdownFinishActions, onFinish) {
+		return fmt.Errorf("unknown countdown finish action %q", onFinish)
+	}
+	countdown := &BossbarCountdown{Target: target, OnFinish: onFinish}
+	now := s.now()
+	total := countdown.Remaining(now)
+	if total < 1 {
+		return fmt.Errorf("countdown target must be in the future")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	if err := s.set(id, "max", strconv.Itoa(total)); err != nil {
+		return err
+	}
+	if err := s.set(id, "value", strconv.Itoa(total)); err != nil {
+		return err
+	}
+	if err := s.set(id, "visible", "true"); err != nil {
+		return err
+	}
+	entry, ok := s.managed[id]
+	if !ok {
+		entry = &managedBossbar{}
+		s.managed[id] = entry
+	}
+	entry.Countdown = countdown
+	if err := s.saveLocked(); err != nil {
+		return err
+	}
+	if strings.Contains(entry.Name, BossbarRemainingPlaceholder) {
+		if name, err := renderName(entry.Name, countdown, now); err == nil {
+			s.set(id, "name", name)
+		}
+	}
+	return nil
+}
+
+// StopCountdown stops a countdown and leaves the bossbar as it is
+func (s *BossbarService) StopCountdown(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	entry, ok := s.managed[id]
+	if !ok || entry.Countdown == nil {
+		return fmt.Errorf("bossbar %s has no countdown", id)
+	}
+	entry.Countdown = nil
+	return s.saveLocked()
+}
+
+// StartCountdowns updates running countdowns every interval until the process exits
+func (s *BossbarService) StartCountdowns(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.tick()
+		}
+	}()
+}
+
+// tick sets the value (and name if it shows the remaining time) of every running countdown and
+// applies the finish action of countdowns that reached zero
+func (s *BossbarService) tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		if !s.paused {
+			log.Printf("Bossbar countdowns paused: %v", err)
+			s.paused = true
+		}
+		return
+	}
+	s.paused = false
+	now := s.now()
+	changed := false
+	for id, entry := range s.managed {
+		countdown := entry.Countdown
+		if countdown == nil {
+			continue
+		}
+		remaining := countdown.Remaining(now)
+		if err := s.set(id, "value", strconv.Itoa(remaining)); err != nil {
+			if strings.HasPrefix(err.Error(), "No bossbar exists") {
+				delete(s.managed, id)
+				changed = true
+			}
+			// otherwise the server is unreachable, try again next tick
+			continue
+		}
+		if strings.Contains(entry.Name, BossbarRemainingPlaceholder) {
+			if name, err := renderName(entry.Name, countdown, now); err == nil {
+				s.set(id, "name", name)
+			}
+		}
+		if remaining > 0 {
+			continue
+		}
+
+		entry.Countdown = nil
+		changed = true
+		switch countdown.OnFinish {
+		case CountdownFinishHide:
+			if err := s.set(id, "visible", "false"); err != nil {
+				log.Printf("Failed to hide bossbar %s: %v", id, err)
+			}
+		case CountdownFinishRemove:
+			if err := executeExpecting(s.rconClient, "bossbar remove "+id, "Removed custom bossbar"); err != nil {
+				log.Printf("Failed to remove bossbar %s: %v", id, err)
+			}
+			delete(s.managed, id)
+		}
+	}
+	if changed {
+		if err := s.saveLocked(); err != nil {
+			log.Printf("Failed to save bossbar countdowns: %v", err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeBossbarLevelDat(t *testing.T, dataDir string) {
+	t.Helper()
+	var b bytes.Buffer
+	writeNBTTag(&b, "", map[string]any{"Data": map[string]any{
+		"CustomBossEvents": map[string]any{
+			"event:contest": map[string]any{
+				"Name": `{"text":"Build Contest"}`, "Color": "purple", "Overlay": "notched_10",
+				"Value": int32(40), "Max": int32(100), "Visible": int8(1),
+			},
+		},
+	}})
+
+	path := filepath.Join(dataDir, "world", "level.dat")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	gz.Write(b.Bytes())
+	gz.Close()
+}
+
+func TestBossbarService_GetBossbars(t *testing.T) {
+	dataDir := t.TempDir()
+	writeBossbarLevelDat(t, dataDir)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	statePath := filepath.Join(t.TempDir(), "bossbars.json")
+	os.WriteFile(statePath, []byte(`{"event:gone": {"name": "Gone", "color": "red", "style": "progress", "players": "@a"}}`), 0644)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"bossbar get event:contest value":   {out: "Custom bossbar [Build Contest] has a value of 55"},
+		"bossbar get event:contest max":     {out: "Custom bossbar [Build Contest] has a maximum of 100"},
+		"bossbar get event:contest visible": {out: "Custom bossbar [Build Contest] is currently hidden"},
+		"bossbar get event:contest players": {out: "Custom bossbar [Build Contest] has 2 player(s) currently online: Steve, Alex"},
+		"bossbar get event:gone value":      {out: "No bossbar exists with the ID 'event:gone'"},
+	}}
+	svc := NewBossbarService(rconClient, &fileClient, statePath)
+
+	bars, err := svc.GetBossbars()
+	if err != nil {
+		t.Fatalf("GetBossbars: %v", err)
+	}
+	want := []Bossbar{{
+		ID: "event:contest",
+		BossbarSettings: BossbarSettings{
+			Name: "Build Contest", Color: "purple", Style: "notched_10", Value: 55, Max: 100,
+		},
+		DisplayName:   "Build Contest",
+		OnlinePlayers: []string{"Steve", "Alex"},
+		Live:          true,
+	}}
+	if !reflect.DeepEqual(bars, want) {
+		t.Fatalf("bossbars = %+v, want %+v", bars, want)
+	}
+	// managed bossbars removed in game are forgotten
+	if data, _ := os.ReadFile(statePath); string(data) != "{}" {
+		t.Fatalf("state = %s, want {}", data)
+	}
+
+	// with the server offline the values come from the last save
+	bars, err = NewBossbarService(&fakeRconClient{}, &fileClient, statePath).GetBossbars()
+	if err != nil {
+		t.Fatalf("GetBossbars offline: %v", err)
+	}
+	if len(bars) != 1 || bars[0].Live || bars[0].Value != 40 || !bars[0].Visible {
+		t.Fatalf("offline bossbars = %+v", bars)
+	}
+}
+
+func TestBossbarService_CreateBossbar(t *testing.T) {
+	fileClient := files.NewMinecraftFilesClient(t.TempDir(), 0)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		`bossbar add event:start "Starting soon"`:           {out: "Created custom bossbar [Starting soon]"},
+		`bossbar add event:taken "Taken"`:                   {out: "A bossbar already exists with the ID 'event:taken'"},
+		`bossbar set event:start name "Starting soon"`:      {out: "Nothing changed. That's already the name of this bossbar"},
+		"bossbar set event:start color green":               {out: "Custom bossbar [Starting soon] has changed color"},
+		"bossbar set event:start style notched_6":           {out: "Custom bossbar [Starting soon] has changed style"},
+		"bossbar set event:start max 6":                     {out: "Custom bossbar [Starting soon] has changed maximum to 6"},
+		"bossbar set event:start value 2":                   {out: "Custom bossbar [Starting soon] has changed value to 2"},
+		"bossbar set event:start visible true":              {out: "Custom bossbar [Starting soon] is now visible"},
+		"bossbar set event:start players @a[team=builders]": {out: "Custom bossbar [Starting soon] now has 1 player(s): Steve"},
+	}}
+	svc := NewBossbarService(rconClient, &fileClient, filepath.Join(t.TempDir(), "bossbars.json"))
+
+	settings := BossbarSettings{Name: "Starting soon", Color: "green", Style: "notched_6", Value: 2, Max: 6, Visible: true, Players: "@a[team=builders]"}
+	if err := svc.CreateBossbar("event:start", settings); err != nil {
+		t.Fatalf("CreateBossbar: %v", err)
+	}
+	settings.Name = "Taken"
+	if err := svc.CreateBossbar("event:taken", settings); err == nil || err.Error() != "A bossbar already exists with the ID 'event:taken'" {
+		t.Fatalf("CreateBossbar duplicate = %v, want server message", err)
+	}
+	invalid := []struct {
+		id       string
+		settings BossbarSettings
+	}{
+		{"Event:Start", settings},
+		{"event:start", BossbarSettings{Name: "x", Color: "orange", Style: "progress", Max: 1}},
+		{"event:start", BossbarSettings{Name: "x", Color: "red", Style: "progress", Max: 0}},
+		{"event:start", BossbarSettings{Name: `{"text":`, Color: "red", Style: "progress", Max: 1}},
+	}
+	for _, tt := range invalid {
+		if err := svc.CreateBossbar(tt.id, tt.settings); err == nil {
+			t.Fatalf("CreateBossbar(%q, %+v) succeeded, want error", tt.id, tt.settings)
+		}
+	}
+}
+
+func TestBossbarService_Countdown(t *testing.T) {
+	fileClient := files.NewMinecraftFilesClient(t.TempDir(), 0)
+	statePath := filepath.Join(t.TempDir(), "bossbars.json")
+	os.WriteFile(statePath, []byte(`{"event:contest": {"name": "Ends in {remaining}", "color": "red", "style": "progress"}}`), 0644)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"bossbar set event:contest max 3725":               {out: "Custom bossbar [x] has changed maximum to 3725"},
+		"bossbar set event:contest value 3725":             {out: "Custom bossbar [x] has changed value to 3725"},
+		"bossbar set event:contest visible true":           {out: "Custom bossbar [x] is now visible"},
+		`bossbar set event:contest name "Ends in 1:02:05"`: {out: "Custom bossbar [x] has been renamed"},
+		"bossbar set event:contest value 65":               {out: "Custom bossbar [x] has changed value to 65"},
+		`bossbar set event:contest name "Ends in 1:05"`:    {out: "Custom bossbar [x] has been renamed"},
+		"bossbar set event:contest value 0":                {out: "Custom bossbar [x] has changed value to 0"},
+		`bossbar set event:contest name "Ends in 0:00"`:    {out: "Custom bossbar [x] has been renamed"},
+		"bossbar set event:contest visible false":          {out: "Custom bossbar [x] is now hidden"},
+	}}
+	start := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+	now := start
+	svc := NewBossbarService(rconClient, &fileClient, statePath)
+	svc.now = func() time.Time { return now }
+
+	target := start.Add(time.Hour + 2*time.Minute + 5*time.Second)
+	if err := svc.StartCountdown("event:contest", target, CountdownFinishHide); err != nil {
+		t.Fatalf("StartCountdown: %v", err)
+	}
+	if err := svc.StartCountdown("event:contest", start.Add(-time.Second), CountdownFinishHide); err == nil {
+		t.Fatalf("expected error for a target in the past")
+	}
+
+	rconClient.received = nil
+	now = target.Add(-65 * time.Second)
+	svc.tick()
+	want := []string{"bossbar set event:contest value 65", `bossbar set event:contest name "Ends in 1:05"`}
+	if !reflect.DeepEqual(rconClient.received, want) {
+		t.Fatalf("tick sent %q, want %q", rconClient.received, want)
+	}
+
+	rconClient.received = nil
+	now = target.Add(time.Second)
+	svc.tick()
+	want = []string{"bossbar set event:contest value 0", `bossbar set event:contest name "Ends in 0:00"`, "bossbar set event:contest visible false"}
+	if !reflect.DeepEqual(rconClient.received, want) {
+		t.Fatalf("final tick sent %q, want %q", rconClient.received, want)
+	}
+
+	// a finished countdown is not resumed after a restart
+	restarted := NewBossbarService(rconClient, &fileClient, statePath)
+	rconClient.received = nil
+	restarted.tick()
+	if len(rconClient.received) != 0 {
+		t.Fatalf("restarted service sent %q", rconClient.received)
+	}
+}
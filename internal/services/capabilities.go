@@ -0,0 +1,372 @@
+package services
+
+import (
+	"fmt"
+	"mc-admin/internal/clients/rcon"
+	"mc-admin/internal/clients/slp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server software detected by CapabilityService
+const (
+	SoftwareUnknown  = "Unknown"
+	SoftwareVanilla  = "Vanilla"
+	SoftwareSpigot   = "Spigot"
+	SoftwarePaper    = "Paper"
+	SoftwarePurpur   = "Purpur"
+	SoftwareFabric   = "Fabric"
+	SoftwareForge    = "Forge"
+	SoftwareNeoForge = "NeoForge"
+)
+
+// Capabilities are commands probed over RCON
+const (
+	CapabilityTick     = "tick"
+	CapabilityTPS      = "tps"
+	CapabilityMSPT     = "mspt"
+	CapabilityDatapack = "datapack"
+)
+
+const (
+	// capabilityCacheTTL is how long a detection is reused
+	capabilityCacheTTL = 10 * time.Minute
+	// capabilityRetryTTL is how long a detection without RCON is reused
+	capabilityRetryTTL = 30 * time.Second
+)
+
+// softwareRank orders software by how specific the evidence is: Purpur runs Paper, which runs
+// Spigot, and NeoForge started as a Forge fork, so the most specific match wins
+var softwareRank = map[string]int{
+	SoftwareVanilla:  1,
+	SoftwareSpigot:   2,
+	SoftwarePaper:    3,
+	SoftwarePurpur:   4,
+	SoftwareFabric:   2,
+	SoftwareForge:    2,
+	SoftwareNeoForge: 3,
+}
+
+var (
+	// versionCommandPattern matches "This server is running Paper version 1.21.4-232-main@4f3b (MC: 1.21.4)"
+	versionCommandPattern = regexp.MustCompile(`running (\S+) version (\S+)`)
+	// minecraftVersionPattern matches release versions like 1.21 or 1.20.4
+	minecraftVersionPattern = regexp.MustCompile(`\b1\.\d+(?:\.\d+)?\b`)
+	// vanillaVersionPattern matches the name line of the vanilla version command (1.21.6+),
+	// whose lines RCON may join without a separator
+	vanillaVersionPattern = regexp.MustCompile(`(?s)Server version info:.*?name = (.+?)\s*(?:data = |\n|$)`)
+	// mcVersionPattern matches the "(MC: 1.21.4)" suffix of Bukkit version strings
+	mcVersionPattern = regexp.MustCompile(`\(MC: ([\d.]+)\)`)
+)
+
+// softwareFiles are files in the data directory left by a server software or its installer
+var softwareFiles = []struct {
+	path     string
+	software string
+}{
+	{"purpur.yml", SoftwarePurpur},
+	{"config/paper-global.yml", SoftwarePaper},
+	{"paper.yml", SoftwarePaper},
+	{"spigot.yml", SoftwareSpigot},
+	{"bukkit.yml", SoftwareSpigot},
+	{".fabric", SoftwareFabric},
+	{"fabric-server-launcher.properties", SoftwareFabric},
+	{"libraries/net/neoforged", SoftwareNeoForge},
+	{"libraries/net/minecraftforge", SoftwareForge},
+}
+
+// softwareJarPrefixes map server jar names in the data directory to software
+var softwareJarPrefixes = []struct {
+	prefix   string
+	software string
+}{
+	{"purpur", SoftwarePurpur},
+	{"paper", SoftwarePaper},
+	{"spigot", SoftwareSpigot},
+	{"craftbukkit", SoftwareSpigot},
+	{"fabric-server", SoftwareFabric},
+	{"neoforge", SoftwareNeoForge},
+	{"forge", SoftwareForge},
+	{"minecraft_server", SoftwareVanilla},
+}
+
+// CapabilityFileSystemAccessor is the subset of the files client used to inspect the data directory
+type CapabilityFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	ReadFile(path string) (string, error)
+}
+
+// ServerCapabilities describes the server software and the commands it supports
+type ServerCapabilities struct {
+	Software string
+	// Version is the Minecraft version, empty when unknown
+	Version string
+	// Reachable is false when RCON did not answer, leaving the capabilities unknown
+	Reachable    bool
+	Capabilities map[string]bool
+	// Evidence lists what the detection is based on
+	Evidence   []string
+	DetectedAt time.Time
+}
+
+// Supports reports whether the server has a capability. Without RCON nothing is known and
+// every capability is assumed, so controls are not hidden because of an outage.
+func (c ServerCapabilities) Supports(capability string) bool {
+	return !c.Reachable || c.Capabilities[capability]
+}
+
+// Description returns the software and version, e.g. "Paper 1.21.4"
+func (c ServerCapabilities) Description() string {
+	return strings.TrimSpace(c.Software + " " + c.Version)
+}
+
+// CapabilityService detects the server software, Minecraft version and supported commands
+// from RCON, the Server List Ping and the data directory
+type CapabilityService struct {
+	rconClient rcon.CommandExecutor
+	fileClient CapabilityFileSystemAccessor
+	pinger     slp.StatusPinger
+	now        func() time.Time
+
+	mu     sync.Mutex
+	cached *ServerCapabilities
+	// detecting is true while Cached refreshes the detection in the background
+	detecting bool
+}
+
+// NewCapabilityService creates a CapabilityService. fileClient and pinger may be nil to skip
+// those sources.
+func NewCapabilityService(rconClient rcon.CommandExecutor, fileClient CapabilityFileSystemAccessor, pinger slp.StatusPinger) *CapabilityService {
+	return &CapabilityService{
+		rconClient: rconClient,
+		fileClient: fileClient,
+		pinger:     pinger,
+		now:        time.Now,
+	}
+}
+
+// GetCapabilities returns the last detection while it is fresh and detects again otherwise
+func (s *CapabilityService) GetCapabilities() ServerCapabilities {
+	s.mu.Lock()
+	if s.freshLocked() {
+		defer s.mu.Unlock()
+		return *s.cached
+	}
+	s.mu.Unlock()
+	return s.Detect()
+}
+
+// Cached returns the last detection without waiting for RCON, nothing being known before the
+// first one. A stale detection is repeated in the background, so pages and the performance
+// sampler are not slowed down while the server is unreachable.
+func (s *CapabilityService) Cached() ServerCapabilities {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.freshLocked() && !s.detecting {
+		s.detecting = true
+		go func() {
+			s.Detect()
+			s.mu.Lock()
+			s.detecting = false
+			s.mu.Unlock()
+		}()
+	}
+	if s.cached == nil {
+		return ServerCapabilities{}
+	}
+	return *s.cached
+}
+
+// Detect runs the detection now, e.g. after the server was updated
+func (s *CapabilityService) Detect() ServerCapabilities {
+	capabilities := s.detect()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a slower detection that started earlier does not replace a newer one
+	if s.cached == nil || !capabilities.DetectedAt.Before(s.cached.DetectedAt) {
+		s.cached = &capabilities
+	}
+	return capabilities
+}
+
+// freshLocked reports whether the last detection can be reused
+func (s *CapabilityService) freshLocked() bool {
+	if s.cached == nil {
+		return false
+	}
+	ttl := capabilityCacheTTL
+	if !s.cached.Reachable {
+		ttl = capabilityRetryTTL
+	}
+	return s.now().Sub(s.cached.DetectedAt) < ttl
+}
+
+// detection collects the software candidates and versions of the individual sources
+type detection struct {
+	ServerCapabilities
+	software string
+}
+
+// vote keeps the most specific software seen so far
+func (d *detection) vote(software, evidence string) {
+	d.Evidence = append(d.Evidence, evidence)
+	if softwareRank[software] > softwareRank[d.software] {
+		d.software = software
+	}
+}
+
+// setVersion keeps the first version found, sources being consulted from the most reliable
+func (d *detection) setVersion(version string) {
+	if d.Version == "" {
+		d.Version = version
+	}
+}
+
+// detect runs the probes without holding the lock, RCON may take long to answer or fail
+func (s *CapabilityService) detect() ServerCapabilities {
+	d := &detection{ServerCapabilities: ServerCapabilities{Capabilities: map[string]bool{}}}
+	s.probeCommands(d)
+	if s.pinger != nil {
+		s.ping(d)
+	}
+	if s.fileClient != nil {
+		s.inspectDataDir(d)
+	}
+
+	d.Software = d.software
+	if d.Software == "" {
+		d.Software = SoftwareUnknown
+		// a server answering RCON without a version command or loader files is vanilla
+		if d.Reachable {
+			d.Software = SoftwareVanilla
+		}
+	}
+	d.DetectedAt = s.now()
+	return d.ServerCapabilities
+}
+
+// probeCommands runs harmless queries to find out which commands exist
+func (s *CapabilityService) probeCommands(d *detection) {
+	response, err := s.rconClient.ExecuteCommand("version")
+	if err != nil {
+		d.Evidence = append(d.Evidence, "RCON unreachable: "+err.Error())
+		return
+	}
+	d.Reachable = true
+	response = formattingCodePattern.ReplaceAllString(response, "")
+	if m := vanillaVersionPattern.FindStringSubmatch(response); m != nil {
+		d.vote(SoftwareVanilla, "vanilla version command")
+		d.setVersion(m[1])
+	} else if m := versionCommandPattern.FindStringSubmatch(response); m != nil {
+		software := bukkitSoftware(m[1], m[2])
+		d.vote(software, fmt.Sprintf("version command reports %s", m[1]))
+		if v := mcVersionPattern.FindStringSubmatch(response); v != nil {
+			d.setVersion(v[1])
+		} else if v := minecraftVersionPattern.FindString(m[2]); v != "" {
+			d.setVersion(v)
+		}
+	}
+
+	if response, err := s.rconClient.ExecuteCommand("tick query"); err == nil {
+		if _, err := parseTickQuery(response); err == nil {
+			d.Capabilities[CapabilityTick] = true
+		}
+	}
+	if response, err := s.rconClient.ExecuteCommand("tps"); err == nil {
+		if paperTPSPattern.MatchString(formattingCodePattern.ReplaceAllString(response, "")) {
+			d.Capabilities[CapabilityTPS] = true
+		}
+	}
+	if response, err := s.rconClient.ExecuteCommand("mspt"); err == nil {
+		if paperMSPTPattern.MatchString(formattingCodePattern.ReplaceAllString(response, "")) {
+			d.Capabilities[CapabilityMSPT] = true
+		}
+	}
+	if response, err := s.rconClient.ExecuteCommand("datapack list enabled"); err == nil {
+		if strings.HasPrefix(response, "There are") {
+			d.Capabilities[CapabilityDatapack] = true
+		}
+	}
+}
+
+// bukkitSoftware maps the name in the version command to software. Forks report their own
+// name; CraftBukkit builds from BuildTools carry "Spigot" in the version.
+func bukkitSoftware(name, version string) string {
+	switch strings.ToLower(name) {
+	case "purpur":
+		return SoftwarePurpur
+	case "paper", "folia":
+		return SoftwarePaper
+	case "craftbukkit", "spigot":
+		return SoftwareSpigot
+	}
+	if strings.Contains(version, "Paper") {
+		return SoftwarePaper
+	}
+	return SoftwareSpigot
+}
+
+// ping reads the version name of the server list, e.g. "Paper 1.21.4" or "1.21.4"
+func (s *CapabilityService) ping(d *detection) {
+	status, err := s.pinger.Ping()
+	if err != nil {
+		d.Evidence = append(d.Evidence, "server list ping failed: "+err.Error())
+		return
+	}
+	name := status.VersionName
+	if prefix, _, ok := strings.Cut(name, " "); ok {
+		for _, software := range []string{SoftwarePurpur, SoftwarePaper, SoftwareSpigot} {
+			if strings.EqualFold(prefix, software) || (software == SoftwareSpigot && strings.EqualFold(prefix, "CraftBukkit")) {
+				d.vote(software, "server list reports "+name)
+			}
+		}
+	}
+	if status.Modded {
+		d.vote(SoftwareForge, "server list carries Forge mod data")
+	}
+	if v := minecraftVersionPattern.FindString(name); v != "" {
+		d.setVersion(v)
+	}
+}
+
+// inspectDataDir looks for server jars and configuration files, and reads the version of the
+// world from level.dat
+func (s *CapabilityService) inspectDataDir(d *detection) {
+	root, err := s.fileClient.GetAbsolutePath(".")
+	if err != nil {
+		return
+	}
+	for _, file := range softwareFiles {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(file.path))); err == nil {
+			d.vote(file.software, "found "+file.path)
+		}
+	}
+	if entries, err := os.ReadDir(root); err == nil {
+		for _, entry := range entries {
+			name := strings.ToLower(entry.Name())
+			if entry.IsDir() || !strings.HasSuffix(name, ".jar") {
+				continue
+			}
+			for _, jar := range softwareJarPrefixes {
+				if strings.HasPrefix(name, jar.prefix) {
+					d.vote(jar.software, "found "+entry.Name())
+					break
+				}
+			}
+		}
+	}
+
+	worldDir, err := s.fileClient.GetAbsolutePath(getLevelName(s.fileClient))
+	if err != nil {
+		return
+	}
+	if data, err := readLevelData(worldDir); err == nil {
+		if name := data.Compound("Version").String("Name"); name != "" {
+			d.setVersion(name)
+		}
+	}
+}
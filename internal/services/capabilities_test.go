@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"mc-admin/internal/clients/files"
+	"mc-admin/internal/clients/slp"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakePinger struct {
+	status slp.Status
+	err    error
+}
+
+func (f fakePinger) Ping() (slp.Status, error) {
+	return f.status, f.err
+}
+
+func TestCapabilityService_Detect(t *testing.T) {
+	type responses = map[string]struct {
+		out string
+		err error
+	}
+	tests := []struct {
+		name             string
+		responses        responses
+		pinger           fakePinger
+		files            []string
+		wantSoftware     string
+		wantVersion      string
+		wantCapabilities []string
+	}{
+		{
+			name: "paper",
+			responses: responses{
+				"version":               {out: "§fThis server is running Paper version 1.21.4-232-main@4f3b2a1 (2025-03-01T10:00:00Z) (Implementing API version 1.21.4-R0.1-SNAPSHOT)"},
+				"tick query":            {out: "The game is running normallyTarget tick rate: 20.0 per second.\nAverage time per tick: 3.0ms (Target: 50.0ms)"},
+				"tps":                   {out: "§6TPS from last 1m, 5m, 15m: §a20.0, §a20.0, §a20.0"},
+				"mspt":                  {out: "§6Server tick times:\n§6◴ §a3.1§7/§a1.2§7/§a9.8"},
+				"datapack list enabled": {out: "There are 2 data pack(s) enabled: [vanilla (built-in)], [paper (built-in)]"},
+			},
+			pinger:           fakePinger{status: slp.Status{VersionName: "Paper 1.21.4"}},
+			files:            []string{"paper-1.21.4-232.jar", "spigot.yml", "config/paper-global.yml"},
+			wantSoftware:     SoftwarePaper,
+			wantVersion:      "1.21.4",
+			wantCapabilities: []string{CapabilityTick, CapabilityTPS, CapabilityMSPT, CapabilityDatapack},
+		},
+		{
+			name: "purpur found by files",
+			responses: responses{
+				"version":               {out: "Checking version, please wait..."},
+				"tick query":            {out: unknownCommand},
+				"tps":                   {out: "TPS from last 1m, 5m, 15m: 19.9, 20.0, 20.0"},
+				"mspt":                  {out: unknownCommand},
+				"datapack list enabled": {out: "There are 1 data pack(s) enabled: [vanilla (built-in)]"},
+			},
+			pinger:           fakePinger{status: slp.Status{VersionName: "Paper 1.20.1"}},
+			files:            []string{"purpur.yml", "spigot.yml"},
+			wantSoftware:     SoftwarePurpur,
+			wantVersion:      "1.20.1",
+			wantCapabilities: []string{CapabilityTPS, CapabilityDatapack},
+		},
+		{
+			name: "vanilla",
+			responses: responses{
+				"version":               {out: unknownCommand},
+				"tick query":            {out: "The game is running normallyTarget tick rate: 20.0 per second.\nAverage time per tick: 3.0ms (Target: 50.0ms)"},
+				"tps":                   {out: unknownCommand},
+				"mspt":                  {out: unknownCommand},
+				"datapack list enabled": {out: "There are 1 data pack(s) enabled: [vanilla (built-in)]"},
+			},
+			pinger:           fakePinger{status: slp.Status{VersionName: "1.21.1"}},
+			wantSoftware:     SoftwareVanilla,
+			wantVersion:      "1.21.1",
+			wantCapabilities: []string{CapabilityTick, CapabilityDatapack},
+		},
+		{
+			name: "vanilla version command",
+			responses: responses{
+				"version":               {out: "Server version info:id = 1.21.6name = 1.21.6data = 4435"},
+				"tick query":            {out: unknownCommand},
+				"tps":                   {out: unknownCommand},
+				"mspt":                  {out: unknownCommand},
+				"datapack list enabled": {out: unknownCommand},
+			},
+			pinger:       fakePinger{err: errors.New("connection refused")},
+			wantSoftware: SoftwareVanilla,
+			wantVersion:  "1.21.6",
+		},
+		{
+			name: "neoforge",
+			responses: responses{
+				"version":               {out: unknownCommand},
+				"tick query":            {out: unknownCommand},
+				"tps":                   {out: unknownCommand},
+				"mspt":                  {out: unknownCommand},
+				"datapack list enabled": {out: "There are 1 data pack(s) enabled: [vanilla (built-in)]"},
+			},
+			pinger:           fakePinger{status: slp.Status{VersionName: "1.20.1", Modded: true}},
+			files:            []string{"libraries/net/neoforged/neoforge/47.1.0/x.jar"},
+			wantSoftware:     SoftwareNeoForge,
+			wantVersion:      "1.20.1",
+			wantCapabilities: []string{CapabilityDatapack},
+		},
+		{
+			name:         "offline",
+			responses:    responses{"version": {err: errors.New("connection refused")}},
+			pinger:       fakePinger{err: errors.New("connection refused")},
+			files:        []string{"fabric-server-launch.jar"},
+			wantSoftware: SoftwareFabric,
+			wantVersion:  "1.21.4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			for _, file := range tt.files {
+				path := filepath.Join(dataDir, filepath.FromSlash(file))
+				os.MkdirAll(filepath.Dir(path), 0755)
+				os.WriteFile(path, nil, 0644)
+			}
+			writeVersionLevelDat(t, dataDir, "1.21.4")
+			fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+			svc := NewCapabilityService(&fakeRconClient{responses: tt.responses}, &fileClient, tt.pinger)
+
+			got := svc.Detect()
+			if got.Software != tt.wantSoftware || got.Version != tt.wantVersion {
+				t.Fatalf("detected %s %s, want %s %s (evidence %v)", got.Software, got.Version, tt.wantSoftware, tt.wantVersion, got.Evidence)
+			}
+			if len(got.Capabilities) != len(tt.wantCapabilities) {
+				t.Fatalf("capabilities = %v, want %v", got.Capabilities, tt.wantCapabilities)
+			}
+			for _, capability := range tt.wantCapabilities {
+				if !got.Supports(capability) {
+					t.Fatalf("capabilities = %v, want %v", got.Capabilities, tt.wantCapabilities)
+				}
+			}
+		})
+	}
+}
+
+func TestCapabilityService_GetCapabilitiesCaches(t *testing.T) {
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"version": {err: errors.New("connection refused")},
+	}}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewCapabilityService(rconClient, nil, nil)
+	svc.now = func() time.Time { return now }
+
+	offline := svc.GetCapabilities()
+	if offline.Reachable || !offline.Supports(CapabilityTick) {
+		t.Fatalf("offline detection = %+v, want every capability assumed", offline)
+	}
+	svc.GetCapabilities()
+	if len(rconClient.received) != 1 {
+		t.Fatalf("commands = %v, want the detection reused", rconClient.received)
+	}
+
+	// a failed detection is retried sooner than a successful one
+	now = now.Add(capabilityRetryTTL)
+	svc.GetCapabilities()
+	if len(rconClient.received) != 2 {
+		t.Fatalf("commands = %v, want a second detection", rconClient.received)
+	}
+}
+
+// blockingRconClient answers like an unreachable server once release is closed
+type blockingRconClient struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (c *blockingRconClient) ExecuteCommand(cmd string) (string, error) {
+	c.calls.Add(1)
+	<-c.release
+	return "", errors.New("connection refused")
+}
+
+func TestCapabilityService_CachedDoesNotWait(t *testing.T) {
+	rconClient := &blockingRconClient{release: make(chan struct{})}
+	svc := NewCapabilityService(rconClient, nil, nil)
+
+	// nothing is known yet, the detection runs in the background
+	if got := svc.Cached(); got.Reachable || !got.DetectedAt.IsZero() || !got.Supports(CapabilityTick) {
+		t.Fatalf("Cached before a detection = %+v", got)
+	}
+	svc.Cached()
+	close(rconClient.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for svc.Cached().DetectedAt.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatalf("the background detection did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if calls := rconClient.calls.Load(); calls != 1 {
+		t.Fatalf("version was sent %d times, want one detection at a time", calls)
+	}
+}
+
+func writeVersionLevelDat(t *testing.T, dataDir, version string) {
+	t.Helper()
+	var b bytes.Buffer
+	writeNBTTag(&b, "", map[string]any{"Data": map[string]any{
+		"Version": map[string]any{"Name": version, "Id": int32(4189)},
+	}})
+	path := filepath.Join(dataDir, "world", "level.dat")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	gz.Write(b.Bytes())
+	gz.Close()
+}
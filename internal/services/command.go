@@ -29,3 +29,18 @@ func (s *CommandService) ExecuteRawCommand(command string) (string, error) {
 
 	return response, nil
 }
+
+// executeExpecting runs a command whose failures come back as plain text. Responses that do
+// not start with one of the expected confirmations are returned as errors.
+func executeExpecting(rconClient rcon.CommandExecutor, command string, successPrefixes ...string) error {
+	response, err := rconClient.ExecuteCommand(command)
+	if err != nil {
+		return fmt.Errorf("failed to execute %q: %w", command, err)
+	}
+	for _, prefix := range successPrefixes {
+		if strings.HasPrefix(response, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s", strings.TrimSpace(response))
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mc-admin/internal/clients/rcon"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrDatapackIncompatible is returned when a pack's format range does not include the server's format
+var ErrDatapackIncompatible = errors.New("data pack is not compatible with this server version")
+
+// datapackEntryPattern matches one "[id (source)]" entry of "datapack list"
+var datapackEntryPattern = regexp.MustCompile(`\[([^\[\]]+?)(?: \(([^()]+)\))?\]`)
+
+// datapackFormats maps the first data version of a release to the data pack format it introduced.
+// Snapshots are treated like the release before them.
+var datapackFormats = []struct {
+	dataVersion int
+	format      int
+}{
+	{1519, 4},  // 1.13
+	{2225, 5},  // 1.15
+	{2578, 6},  // 1.16.2
+	{2724, 7},  // 1.17
+	{2860, 8},  // 1.18
+	{2975, 9},  // 1.18.2
+	{3105, 10}, // 1.19
+	{3337, 12}, // 1.19.4
+	{3463, 15}, // 1.20
+	{3578, 18}, // 1.20.2
+	{3698, 26}, // 1.20.3
+	{3837, 41}, // 1.20.5
+	{3953, 48}, // 1.21
+	{4080, 57}, // 1.21.2
+	{4189, 61}, // 1.21.4
+	{4325, 71}, // 1.21.5
+	{4435, 80}, // 1.21.6
+	{4438, 81}, // 1.21.7
+	{4554, 88}, // 1.21.9
+}
+
+// lastKnownDataVersion is the newest release covered by datapackFormats (1.21.10)
+const lastKnownDataVersion = 4556
+
+// DatapackPositions are the placements accepted by "datapack enable". Packs later in the
+// load order override earlier ones.
+var DatapackPositions = []string{"last", "first", "before", "after"}
+
+// DatapackFileSystemAccessor is the subset of the files client used by DatapackService
+type DatapackFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	ReadFile(path string) (string, error)
+}
+
+// Datapack is a data pack known to the server
+type Datapack struct {
+	ID     string
+	Source string
+}
+
+// DatapackList holds the enabled packs in load order and the packs that can be enabled
+type DatapackList struct {
+	Enabled   []Datapack
+	Available []Datapack
+}
+
+// DatapackFormat is the data pack format of the running world
+type DatapackFormat struct {
+	Format int
+	// Known is false when level.dat is missing or newer than the formats this tool knows
+	Known bool
+}
+
+// DatapackInstallResult describes an uploaded pack
+type DatapackInstallResult struct {
+	FileName    string
+	Description string
+	MinFormat   int
+	MaxFormat   int
+	Warning     string
+	Reloaded    bool
+}
+
+// DatapackService lists, orders and installs data packs
+type DatapackService struct {
+	rconClient rcon.CommandExecutor
+	fileClient DatapackFileSystemAccessor
+}
+
+// NewDatapackService creates a DatapackService
+func NewDatapackService(rconClient rcon.CommandExecutor, fileClient DatapackFileSystemAccessor) *DatapackService {
+	return &DatapackService{
+		rconClient: rconClient,
+		fileClient: fileClient,
+	}
+}
+
+// GetDatapacks returns the enabled and available packs reported by the server
+func (s *DatapackService) GetDatapacks() (DatapackList, error) {
+	enabled, err := s.rconClient.ExecuteCommand("datapack list enabled")
+	if err != nil {
+		return DatapackList{}, fmt.Errorf("failed to list enabled data packs: %w", err)
+	}
+	available, err := s.rconClient.ExecuteCommand("datapack list available")
+	if err != nil {
+		return DatapackList{}, fmt.Errorf("failed to list available data packs: %w", err)
+	}
+	return DatapackList{
+		Enabled:   parseDatapackList(enabled),
+		Available: parseDatapackList(available),
+	}, nil
+}
+
+// parseDatapackList parses "There are 2 data pack(s) enabled: [vanilla (built-in)], [file/a.zip (world)]"
+func parseDatapackList(response string) []Datapack {
+	packs := []Datapack{}
+	_, list, ok := strings.Cut(response, ":")
+	if !ok {
+		return packs
+	}
+	for _, m := range datapackEntryPattern.FindAllStringSubmatch(list, -1) {
+		packs = append(packs, Datapack{ID: m[1], Source: m[2]})
+	}
+	return packs
+}
+
+// quoteArgument quotes a pack id for use as a Brigadier string argument
+func quoteArgument(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func datapackEnableCommand(id, position, reference string) (string, error) {
+	command := "datapack enable " + quoteArgument(id)
+	switch position {
+	case "", "last":
+		return command, nil
+	case "first":
+		return command + " first", nil
+	case "before", "after":
+		if reference == "" {
+			return "", fmt.Errorf("position %s needs a reference pack", position)
+		}
+		return command + " " + position + " " + quoteArgument(reference), nil
+	default:
+		return "", fmt.Errorf("unknown position: %s", position)
+	}
+}
+
+// EnableDatapack enables a pack at the given position (first, last, before or after reference)
+func (s *DatapackService) EnableDatapack(id, position, reference string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("pack id cannot be empty")
+	}
+	command, err := datapackEnableCommand(id, position, reference)
+	if err != nil {
+		return err
+	}
+	return executeExpecting(s.rconClient, command, "Enabling")
+}
+
+// DisableDatapack disables a pack
+func (s *DatapackService) DisableDatapack(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("pack id cannot be empty")
+	}
+	return executeExpecting(s.rconClient, "datapack disable "+quoteArgument(id), "Disabling")
+}
+
+// MoveDatapack changes the load order of an enabled pack. The server has no move command, so
+// the pack is disabled and enabled again at the new position.
+func (s *DatapackService) MoveDatapack(id, position, reference string) error {
+	command, err := datapackEnableCommand(id, position, reference)
+	if err != nil {
+		return err
+	}
+	if err := s.DisableDatapack(id); err != nil {
+		return err
+	}
+	if err := executeExpecting(s.rconClient, command, "Enabling"); err != nil {
+		// put the pack back rather than leaving it disabled
+		if restoreErr := executeExpecting(s.rconClient, "datapack enable "+quoteArgument(id), "Enabling"); restoreErr != nil {
+			return fmt.Errorf("%w (the pack could not be enabled again: %v)", err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetServerFormat derives the data pack format from the DataVersion in level.dat
+func (s *DatapackService) GetServerFormat() (DatapackFormat, error) {
+	worldDir, err := s.fileClient.GetAbsolutePath(getLevelName(s.fileClient))
+	if err != nil {
+		return DatapackFormat{}, err
+	}
+	data, err := readLevelData(worldDir)
+	if err != nil {
+		return DatapackFormat{}, fmt.Errorf("failed to read level.dat: %w", err)
+	}
+	dataVersion, ok := data.Int("DataVersion")
+	if !ok {
+		return DatapackFormat{}, fmt.Errorf("level.dat has no DataVersion")
+	}
+	return datapackFormatForDataVersion(int(dataVersion)), nil
+}
+
+func datapackFormatForDataVersion(dataVersion int) DatapackFormat {
+	format := DatapackFormat{}
+	for _, f := range datapackFormats {
+		if dataVersion >= f.dataVersion {
+			format = DatapackFormat{Format: f.format, Known: true}
+		}
+	}
+	if dataVersion > lastKnownDataVersion {
+		format.Known = false
+	}
+	return format
+}
+
+// packMeta is the part of pack.mcmeta that describes compatibility
+type packMeta struct {
+	Pack struct {
+		PackFormat       *int            `json:"pack_format"`
+		SupportedFormats json.RawMessage `json:"supported_formats"`
+		MinFormat        json.RawMessage `json:"min_format"`
+		MaxFormat        json.RawMessage `json:"max_format"`
+		Description      json.RawMessage `json:"description"`
+	} `json:"pack"`
+}
+
+// formatRange returns the pack formats a pack supports. Since 1.21.9 packs declare
+// min_format/max_format (as major or [major, minor]); before that pack_format with an
+// optional supported_formats range.
+func (m packMeta) formatRange() (int, int, error) {
+	if len(m.Pack.MinFormat) > 0 && len(m.Pack.MaxFormat) > 0 {
+		minFormat, errMin := parseMajorFormat(m.Pack.MinFormat)
+		maxFormat, errMax := parseMajorFormat(m.Pack.MaxFormat)
+		if errMin != nil || errMax != nil {
+			return 0, 0, fmt.Errorf("invalid min_format or max_format in pack.mcmeta")
+		}
+		return minFormat, maxFormat, nil
+	}
+	if m.Pack.PackFormat == nil {
+		return 0, 0, fmt.Errorf("pack.mcmeta has no pack_format")
+	}
+	minFormat, maxFormat := *m.Pack.PackFormat, *m.Pack.PackFormat
+	if len(m.Pack.SupportedFormats) > 0 {
+		low, high, err := parseSupportedFormats(m.Pack.SupportedFormats)
+		if err != nil {
+			return 0, 0, err
+		}
+		minFormat, maxFormat = min(minFormat, low), max(maxFormat, high)
+	}
+	return minFormat, maxFormat, nil
+}
+
+// parseSupportedFormats accepts 48, [41, 48] and {"min_inclusive": 41, "max_inclusive": 48}
+func parseSupportedFormats(raw json.RawMessage) (int, int, error) {
+	var single int
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single, single, nil
+	}
+	var pair []int
+	if err := json.Unmarshal(raw, &pair); err == nil && len(pair) == 2 {
+		return pair[0], pair[1], nil
+	}
+	var bounds struct {
+		MinInclusive *int `json:"min_inclusive"`
+		MaxInclusive *int `json:"max_inclusive"`
+	}
+	if err := json.Unmarshal(raw, &bounds); err == nil && bounds.MinInclusive != nil && bounds.MaxInclusive != nil {
+		return *bounds.MinInclusive, *bounds.MaxInclusive, nil
+	}
+	return 0, 0, fmt.Errorf("invalid supported_formats in pack.mcmeta")
+}
+
+// parseMajorFormat accepts 88, [88] and [88, 0]; the minor version is ignored
+func parseMajorFormat(raw json.RawMessage) (int, error) {
+	var single int
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single, nil
+	}
+	var parts []int
+	if err := json.Unmarshal(raw, &parts); err == nil && len(parts) >= 1 && len(parts) <= 2 {
+		return parts[0], nil
+	}
+	return 0, fmt.Errorf("invalid format %s", raw)
+}
+
+// plainText flattens a JSON text component to plain text
+func plainText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var component struct {
+		Text  string            `json:"text"`
+		Extra []json.RawMessage `json:"extra"`
+	}
+	if err := json.Unmarshal(raw, &component); err == nil {
+		for _, extra := range component.Extra {
+			component.Text += plainText(extra)
+		}
+		return component.Text
+	}
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err == nil {
+		for _, part := range parts {
+			text += plainText(part)
+		}
+	}
+	return text
+}
+
+// readPackMeta finds and parses pack.mcmeta at the root of a data pack zip
+func readPackMeta(src io.ReaderAt, size int64) (packMeta, error) {
+	archive, err := zip.NewReader(src, size)
+	if err != nil {
+		return packMeta{}, fmt.Errorf("not a valid zip file: %w", err)
+	}
+	for _, f := range archive.File {
+		if f.Name != "pack.mcmeta" {
+			if path.Base(f.Name) == "pack.mcmeta" {
+				return packMeta{}, fmt.Errorf("pack.mcmeta must be at the root of the zip, found %s (zip the contents of the pack folder, not the folder)", f.Name)
+			}
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return packMeta{}, err
+		}
+		defer r.Close()
+		var meta packMeta
+		if err := json.NewDecoder(r).Decode(&meta); err != nil {
+			return packMeta{}, fmt.Errorf("invalid pack.mcmeta: %w", err)
+		}
+		return meta, nil
+	}
+	return packMeta{}, fmt.Errorf("the zip has no pack.mcmeta")
+}
+
+// InstallDatapack validates an uploaded zip, stores it in <world>/datapacks and reloads the
+// server. Packs made for another format are rejected unless force is set.
+func (s *DatapackService) InstallDatapack(fileName string, src io.ReaderAt, size int64, force bool) (DatapackInstallResult, error) {
+	fileName = filepath.Base(fileName)
+	if !strings.HasSuffix(strings.ToLower(fileName), ".zip") || strings.HasPrefix(fileName, ".") {
+		return DatapackInstallResult{}, fmt.Errorf("data packs must be uploaded as .zip files")
+	}
+
+	meta, err := readPackMeta(src, size)
+	if err != nil {
+		return DatapackInstallResult{}, err
+	}
+	minFormat, maxFormat, err := meta.formatRange()
+	if err != nil {
+		return DatapackInstallResult{}, err
+	}
+	result := DatapackInstallResult{
+		FileName:    fileName,
+		Description: plainText(meta.Pack.Description),
+		MinFormat:   minFormat,
+		MaxFormat:   maxFormat,
+	}
+
+	serverFormat, err := s.GetServerFormat()
+	switch {
+	case err != nil:
+		result.Warning = "Compatibility was not checked: " + err.Error()
+	case !serverFormat.Known:
+		result.Warning = "The server is newer than the known data pack formats, compatibility was not checked"
+	case serverFormat.Format < minFormat || serverFormat.Format > maxFormat:
+		if !force {
+			return result, fmt.Errorf("%w: the pack supports format %s, the server uses %d", ErrDatapackIncompatible, result.Formats(), serverFormat.Format)
+		}
+		result.Warning = fmt.Sprintf("Installed although the pack supports format %s and the server uses %d", result.Formats(), serverFormat.Format)
+	}
+
+	worldDir, err := s.fileClient.GetAbsolutePath(getLevelName(s.fileClient))
+	if err != nil {
+		return result, err
+	}
+	if err := writeDatapack(filepath.Join(worldDir, "datapacks"), fileName, io.NewSectionReader(src, 0, size)); err != nil {
+		return result, err
+	}
+
+	// reload picks up new packs and enables them; an offline server loads them on start
+	if _, err := s.rconClient.ExecuteCommand("reload"); err == nil {
+		result.Reloaded = true
+	}
+	return result, nil
+}
+
+// Formats returns the supported format range for display
+func (r DatapackInstallResult) Formats() string {
+	if r.MinFormat == r.MaxFormat {
+		return fmt.Sprintf("%d", r.MinFormat)
+	}
+	return fmt.Sprintf("%d-%d", r.MinFormat, r.MaxFormat)
+}
+
+// writeDatapack stores a pack atomically so the server never loads a partial zip
+func writeDatapack(dir, fileName string, r io.Reader) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create datapacks directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write data pack: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, fileName))
+}
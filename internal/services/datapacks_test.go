@@ -0,0 +1,179 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDatapackList(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []Datapack
+	}{
+		{
+			name:     "enabled packs",
+			response: "There are 3 data pack(s) enabled: [vanilla (built-in)], [file/Terralith v2.5.zip (world)], [bundle (feature)]",
+			want: []Datapack{
+				{ID: "vanilla", Source: "built-in"},
+				{ID: "file/Terralith v2.5.zip", Source: "world"},
+				{ID: "bundle", Source: "feature"},
+			},
+		},
+		{
+			name:     "none available",
+			response: "There are no more data packs available",
+			want:     []Datapack{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDatapackList(tt.response); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseDatapackList() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatapackService_EnableAndMove(t *testing.T) {
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		`datapack enable "file/a.zip" before "file/b \"1\".zip"`: {out: "Enabling data pack [file/a.zip (world)]"},
+		`datapack disable "file/a.zip"`:                          {out: "Disabling data pack [file/a.zip (world)]"},
+		`datapack enable "file/a.zip" first`:                     {out: "Enabling data pack [file/a.zip (world)]"},
+		`datapack enable "file/missing.zip"`:                     {out: "Unknown data pack 'file/missing.zip'"},
+	}}
+	fileClient := files.NewMinecraftFilesClient(t.TempDir(), 0)
+	svc := NewDatapackService(rconClient, &fileClient)
+
+	if err := svc.EnableDatapack("file/a.zip", "before", `file/b "1".zip`); err != nil {
+		t.Fatalf("EnableDatapack: %v", err)
+	}
+	if err := svc.EnableDatapack("file/missing.zip", "last", ""); err == nil || err.Error() != "Unknown data pack 'file/missing.zip'" {
+		t.Fatalf("EnableDatapack unknown pack = %v, want server message", err)
+	}
+	if err := svc.EnableDatapack("file/a.zip", "after", ""); err == nil {
+		t.Fatalf("expected error for missing reference")
+	}
+
+	rconClient.received = nil
+	if err := svc.MoveDatapack("file/a.zip", "first", ""); err != nil {
+		t.Fatalf("MoveDatapack: %v", err)
+	}
+	want := []string{`datapack disable "file/a.zip"`, `datapack enable "file/a.zip" first`}
+	if !reflect.DeepEqual(rconClient.received, want) {
+		t.Fatalf("commands = %q, want %q", rconClient.received, want)
+	}
+}
+
+func TestPackMeta_FormatRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		mcmeta   string
+		min, max int
+		wantErr  bool
+	}{
+		{name: "pack_format only", mcmeta: `{"pack":{"pack_format":48,"description":"x"}}`, min: 48, max: 48},
+		{name: "supported range", mcmeta: `{"pack":{"pack_format":48,"supported_formats":[41,57]}}`, min: 41, max: 57},
+		{name: "supported object", mcmeta: `{"pack":{"pack_format":15,"supported_formats":{"min_inclusive":15,"max_inclusive":18}}}`, min: 15, max: 18},
+		{name: "min and max format", mcmeta: `{"pack":{"min_format":[81,0],"max_format":88}}`, min: 81, max: 88},
+		{name: "no format", mcmeta: `{"pack":{"description":"x"}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := readPackMeta(datapackZip(t, "pack.mcmeta", tt.mcmeta))
+			if err != nil {
+				t.Fatalf("readPackMeta: %v", err)
+			}
+			low, high, err := meta.formatRange()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil || low != tt.min || high != tt.max {
+				t.Fatalf("formatRange() = %d, %d, %v, want %d, %d", low, high, err, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestDatapackFormatForDataVersion(t *testing.T) {
+	tests := map[int]DatapackFormat{
+		3955: {Format: 48, Known: true},
+		4440: {Format: 81, Known: true},
+		4556: {Format: 88, Known: true},
+		4671: {Format: 88, Known: false},
+		1343: {},
+	}
+	for dataVersion, want := range tests {
+		if got := datapackFormatForDataVersion(dataVersion); got != want {
+			t.Errorf("datapackFormatForDataVersion(%d) = %+v, want %+v", dataVersion, got, want)
+		}
+	}
+}
+
+// datapackZip builds a zip with a single file and returns it as reader arguments
+func datapackZip(t *testing.T, name, content string) (*bytes.Reader, int64) {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	zw.Close()
+	return bytes.NewReader(b.Bytes()), int64(b.Len())
+}
+
+func TestDatapackService_InstallDatapack(t *testing.T) {
+	dataDir := t.TempDir()
+	os.MkdirAll(filepath.Join(dataDir, "world"), 0755)
+	writeLevelDat(t, filepath.Join(dataDir, "world", "level.dat"), 0, 0, 3955)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"reload": {out: "Reloading!"},
+	}}
+	svc := NewDatapackService(rconClient, &fileClient)
+	installed := filepath.Join(dataDir, "world", "datapacks", "tools.zip")
+
+	src, size := datapackZip(t, "pack.mcmeta", `{"pack":{"pack_format":41,"description":{"text":"Old ","extra":["tools"]}}}`)
+	if _, err := svc.InstallDatapack("tools.zip", src, size, false); !errors.Is(err, ErrDatapackIncompatible) {
+		t.Fatalf("InstallDatapack incompatible = %v, want ErrDatapackIncompatible", err)
+	}
+	if _, err := os.Stat(installed); !os.IsNotExist(err) {
+		t.Fatalf("incompatible pack was written")
+	}
+
+	result, err := svc.InstallDatapack("tools.zip", src, size, true)
+	if err != nil {
+		t.Fatalf("InstallDatapack forced: %v", err)
+	}
+	if !result.Reloaded || result.Warning == "" || result.Description != "Old tools" {
+		t.Fatalf("result = %+v, want reloaded with warning and description", result)
+	}
+	if _, err := os.Stat(installed); err != nil {
+		t.Fatalf("pack not installed: %v", err)
+	}
+
+	nested, nestedSize := datapackZip(t, "tools/pack.mcmeta", `{"pack":{"pack_format":48}}`)
+	if _, err := svc.InstallDatapack("nested.zip", nested, nestedSize, false); err == nil {
+		t.Fatalf("expected error for pack.mcmeta inside a folder")
+	}
+	if _, err := svc.InstallDatapack("pack.jar", src, size, false); err == nil {
+		t.Fatalf("expected error for non-zip file name")
+	}
+}
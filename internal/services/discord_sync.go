@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mc-admin/internal/clients/discord"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscordMemberLookup reads guild memberships
+type DiscordMemberLookup interface {
+	GuildMember(ctx context.Context, guildID, userID string) (discord.Member, bool, error)
+}
+
+// DiscordSyncWhitelister adds and removes the players of linked accounts
+type DiscordSyncWhitelister interface {
+	AddNameToWhitelist(name string) error
+	RemoveNameFromWhitelist(name string) error
+}
+
+// DiscordLink connects a Discord account to a Minecraft name
+type DiscordLink struct {
+	DiscordID   string    `json:"discord_id"`
+	DiscordName string    `json:"discord_name,omitempty"`
+	PlayerName  string    `json:"player_name"`
+	LinkedAt    time.Time `json:"linked_at"`
+	// Pending is set until an admin approves the name. Nothing proves the account owns the
+	// Minecraft name, so pending links are never whitelisted.
+	Pending bool `json:"pending,omitempty"`
+	// Whitelisted is set when the sync added the player. Only those players are removed again,
+	// players an admin whitelisted by hand stay when they lose the role.
+	Whitelisted bool      `json:"whitelisted"`
+	HasRole     bool      `json:"has_role"`
+	CheckedAt   time.Time `json:"checked_at,omitzero"`
+	// Error is the last failure syncing this link
+	Error string `json:"error,omitempty"`
+}
+
+// DiscordSyncResult summarizes a reconcile
+type DiscordSyncResult struct {
+	Added      []string  `json:"added,omitempty"`
+	Removed    []string  `json:"removed,omitempty"`
+	Failed     int       `json:"failed,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+// discordSyncState is the persisted state of DiscordSyncService
+type discordSyncState struct {
+	Links    []DiscordLink     `json:"links"`
+	LastSync DiscordSyncResult `json:"last_sync,omitzero"`
+}
+
+// DiscordSyncService links Discord accounts to Minecraft names and keeps the whitelist in sync
+// with the members of a guild role
+type DiscordSyncService struct {
+	members   DiscordMemberLookup
+	whitelist DiscordSyncWhitelister
+	guildID   string
+	roleID    string
+	stateFile jsonStateFile
+	now       func() time.Time
+
+	// syncMu serializes changes to the whitelist, mu guards the state
+	syncMu sync.Mutex
+	mu     sync.Mutex
+	state  *discordSyncState
+}
+
+// NewDiscordSyncService creates a DiscordSyncService whitelisting members of roleID in guildID
+func NewDiscordSyncService(members DiscordMemberLookup, whitelist DiscordSyncWhitelister, guildID, roleID, statePath string) *DiscordSyncService {
+	return &DiscordSyncService{
+		members:   members,
+		whitelist: whitelist,
+		guildID:   guildID,
+		roleID:    roleID,
+		stateFile: jsonStateFile{path: statePath},
+		now:       time.Now,
+	}
+}
+
+// loadLocked reads the state once. An unreadable file is left alone and reported on every call.
+func (s *DiscordSyncService) loadLocked() error {
+	if s.state != nil {
+		return nil
+	}
+	state := &discordSyncState{}
+	if err := s.stateFile.load(state); err != nil {
+		return err
+	}
+	s.state = state
+	return nil
+}
+
+func (s *DiscordSyncService) saveLocked() error {
+	return s.stateFile.save(s.state)
+}
+
+// Links returns all links ordered by player name
+func (s *DiscordSyncService) Links() ([]DiscordLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	links := slices.Clone(s.state.Links)
+	slices.SortFunc(links, func(a, b DiscordLink) int {
+		return strings.Compare(strings.ToLower(a.PlayerName), strings.ToLower(b.PlayerName))
+	})
+	return links, nil
+}
+
+// LastSync returns the result of the last reconcile
+func (s *DiscordSyncService) LastSync() (DiscordSyncResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return DiscordSyncResult{}, err
+	}
+	return s.state.LastSync, nil
+}
+
+// GetLink returns the link of a Discord account
+func (s *DiscordSyncService) GetLink(discordID string) (DiscordLink, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return DiscordLink{}, false, err
+	}
+	if index := s.indexLocked(discordID); index >= 0 {
+		return s.state.Links[index], true, nil
+	}
+	return DiscordLink{}, false, nil
+}
+
+// Link connects a Discord account to a Minecraft name. A new name waits for an admin to approve
+// it, relinking to another name removes the old name first if the sync whitelisted it.
+func (s *DiscordSyncService) Link(ctx context.Context, discordID, discordName, playerName string) (DiscordLink, error) {
+	name := strings.TrimSpace(playerName)
+	if err := validatePlayerName(name); err != nil {
+		return DiscordLink{}, err
+	}
+	if discordID == "" {
+		return DiscordLink{}, fmt.Errorf("sign in with Discord to link a player")
+	}
+
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	s.mu.Lock()
+	if err := s.loadLocked(); err != nil {
+		s.mu.Unlock()
+		return DiscordLink{}, err
+	}
+	for _, link := range s.state.Links {
+		if link.DiscordID != discordID && strings.EqualFold(link.PlayerName, name) {
+			s.mu.Unlock()
+			return DiscordLink{}, fmt.Errorf("%s is already linked to another Discord account", link.PlayerName)
+		}
+	}
+	var previous DiscordLink
+	relinked := false
+	if index := s.indexLocked(discordID); index >= 0 {
+		previous = s.state.Links[index]
+		relinked = true
+	}
+	s.mu.Unlock()
+
+	link := DiscordLink{
+		DiscordID:   discordID,
+		DiscordName: discordName,
+		PlayerName:  name,
+		LinkedAt:    s.now(),
+		Pending:     true,
+	}
+	if relinked && strings.EqualFold(previous.PlayerName, name) {
+		link.PlayerName = previous.PlayerName
+		link.LinkedAt = previous.LinkedAt
+		link.Pending = previous.Pending
+		link.Whitelisted = previous.Whitelisted
+	} else if previous.Whitelisted {
+		// the link keeps the old name until it is off the whitelist, so it is not lost track of
+		err := s.whitelist.RemoveNameFromWhitelist(previous.PlayerName)
+		if err != nil && !errors.Is(err, ErrNotWhitelisted) {
+			return DiscordLink{}, fmt.Errorf("failed to remove %s from the whitelist: %w", previous.PlayerName, err)
+		}
+	}
+	var result DiscordSyncResult
+	s.syncLink(ctx, &link, &result)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index := s.indexLocked(discordID); index >= 0 {
+		s.state.Links[index] = link
+	} else {
+		s.state.Links = append(s.state.Links, link)
+	}
+	return link, s.saveLocked()
+}
+
+// Approve confirms that a linked account owns its Minecraft name and syncs it right away
+func (s *DiscordSyncService) Approve(ctx context.Context, discordID string) (DiscordLink, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	link, ok, err := s.GetLink(discordID)
+	if err != nil {
+		return DiscordLink{}, err
+	}
+	if !ok {
+		return DiscordLink{}, fmt.Errorf("no player is linked to this Discord account")
+	}
+	link.Pending = false
+	var result DiscordSyncResult
+	s.syncLink(ctx, &link, &result)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index := s.indexLocked(discordID); index >= 0 {
+		s.state.Links[index] = link
+	}
+	return link, s.saveLocked()
+}
+
+// Unlink removes the link of a Discord account and the player if the sync whitelisted them
+func (s *DiscordSyncService) Unlink(discordID string) (DiscordLink, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	link, ok, err := s.GetLink(discordID)
+	if err != nil {
+		return DiscordLink{}, err
+	}
+	if !ok {
+		return DiscordLink{}, fmt.Errorf("no player is linked to this Discord account")
+	}
+	if link.Whitelisted {
+		if err := s.whitelist.RemoveNameFromWhitelist(link.PlayerName); err != nil {
+			return link, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index := s.indexLocked(discordID); index >= 0 {
+		s.state.Links = slices.Delete(s.state.Links, index, index+1)
+	}
+	return link, s.saveLocked()
+}
+
+// Reconcile checks every linked account, whitelisting members who gained the role and removing
+// players whose account lost it or left the guild
+func (s *DiscordSyncService) Reconcile(ctx context.Context) (DiscordSyncResult, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	links, err := s.Links()
+	if err != nil {
+		return DiscordSyncResult{}, err
+	}
+	var result DiscordSyncResult
+	for _, link := range links {
+		if ctx.Err() != nil {
+			break
+		}
+		s.syncLink(ctx, &link, &result)
+
+		s.mu.Lock()
+		if index := s.indexLocked(link.DiscordID); index >= 0 {
+			s.state.Links[index] = link
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result.FinishedAt = s.now()
+	s.state.LastSync = result
+	return result, s.saveLocked()
+}
+
+// syncLink updates the whitelist for one link. Lookup failures leave the whitelist as it is.
+func (s *DiscordSyncService) syncLink(ctx context.Context, link *DiscordLink, result *DiscordSyncResult) {
+	link.CheckedAt = s.now()
+	member, found, err := s.members.GuildMember(ctx, s.guildID, link.DiscordID)
+	if err != nil {
+		link.Error = err.Error()
+		result.Failed++
+		return
+	}
+	link.Error = ""
+	link.HasRole = found && member.HasRole(s.roleID)
+	if link.Pending {
+		return
+	}
+
+	switch {
+	case link.HasRole && !link.Whitelisted:
+		err := s.whitelist.AddNameToWhitelist(link.PlayerName)
+		if errors.Is(err, ErrAlreadyWhitelisted) {
+			return
+		}
+		if err != nil {
+			link.Error = err.Error()
+			result.Failed++
+			return
+		}
+		link.Whitelisted = true
+		result.Added = append(result.Added, link.PlayerName)
+	case !link.HasRole && link.Whitelisted:
+		if err := s.whitelist.RemoveNameFromWhitelist(link.PlayerName); err != nil {
+			link.Error = err.Error()
+			result.Failed++
+			return
+		}
+		link.Whitelisted = false
+		result.Removed = append(result.Removed, link.PlayerName)
+	}
+}
+
+// StartReconcile reconciles every interval in the background
+func (s *DiscordSyncService) StartReconcile(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			result, err := s.Reconcile(ctx)
+			cancel()
+			if err != nil {
+				log.Printf("Discord role sync failed: %v", err)
+			}
+			if len(result.Added) > 0 || len(result.Removed) > 0 || result.Failed > 0 {
+				log.Printf("Discord role sync added %v, removed %v, %d failed", result.Added, result.Removed, result.Failed)
+			}
+		}
+	}()
+}
+
+func (s *DiscordSyncService) indexLocked(discordID string) int {
+	return slices.IndexFunc(s.state.Links, func(link DiscordLink) bool {
+		return link.DiscordID == discordID
+	})
+}
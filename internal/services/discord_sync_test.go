@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mc-admin/internal/clients/discord"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type fakeDiscordMembers struct {
+	// roles maps user IDs of guild members to their roles
+	roles map[string][]string
+	err   error
+}
+
+func (f *fakeDiscordMembers) GuildMember(ctx context.Context, guildID, userID string) (discord.Member, bool, error) {
+	if f.err != nil {
+		return discord.Member{}, false, f.err
+	}
+	roles, ok := f.roles[userID]
+	if !ok {
+		return discord.Member{}, false, nil
+	}
+	return discord.Member{User: discord.User{ID: userID}, Roles: roles}, true, nil
+}
+
+type fakeSyncWhitelist struct {
+	names     []string
+	removeErr error
+}
+
+func (f *fakeSyncWhitelist) AddNameToWhitelist(name string) error {
+	if slices.Contains(f.names, name) {
+		return fmt.Errorf("name '%s' is %w", name, ErrAlreadyWhitelisted)
+	}
+	f.names = append(f.names, name)
+	return nil
+}
+
+func (f *fakeSyncWhitelist) RemoveNameFromWhitelist(name string) error {
+	if f.removeErr != nil {
+		return f.removeErr
+	}
+	f.names = slices.DeleteFunc(f.names, func(n string) bool { return n == name })
+	return nil
+}
+
+func TestDiscordSyncService_LinkAndReconcile(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "discord_links.json")
+	members := &fakeDiscordMembers{roles: map[string][]string{"1": {"player"}, "2": {}, "3": {"player"}}}
+	whitelist := &fakeSyncWhitelist{names: []string{"Admin"}}
+	svc := NewDiscordSyncService(members, whitelist, "guild", "player", statePath)
+	ctx := context.Background()
+
+	steve, err := svc.Link(ctx, "1", "steve", " Steve ")
+	if err != nil || steve.PlayerName != "Steve" || !steve.Pending || steve.Whitelisted || !steve.HasRole {
+		t.Fatalf("link = %+v, err = %v", steve, err)
+	}
+	// Nothing proves the account owns the name until an admin approves it
+	if result, _ := svc.Reconcile(ctx); len(result.Added) != 0 || !slices.Equal(whitelist.names, []string{"Admin"}) {
+		t.Fatalf("pending link was whitelisted: %+v, whitelist = %v", result, whitelist.names)
+	}
+	if steve, err := svc.Approve(ctx, "1"); err != nil || steve.Pending || !steve.Whitelisted {
+		t.Fatalf("approved link = %+v, err = %v", steve, err)
+	}
+	svc.Link(ctx, "2", "alex", "Alex")
+	if alex, _ := svc.Approve(ctx, "2"); alex.Whitelisted || alex.HasRole {
+		t.Fatalf("member without the role was whitelisted: %+v", alex)
+	}
+	// An admin whitelisted this player by hand, the sync must not take over
+	svc.Link(ctx, "3", "admin", "Admin")
+	if admin, _ := svc.Approve(ctx, "3"); admin.Whitelisted {
+		t.Fatalf("manually whitelisted player is managed: %+v", admin)
+	}
+	if _, err := svc.Link(ctx, "2", "alex", "steve"); err == nil || !strings.Contains(err.Error(), "another Discord account") {
+		t.Fatalf("taking another account's name: err = %v", err)
+	}
+	if _, err := svc.Approve(ctx, "4"); err == nil {
+		t.Fatal("approving an unknown account succeeded")
+	}
+	if !slices.Equal(whitelist.names, []string{"Admin", "Steve"}) {
+		t.Fatalf("whitelist = %v", whitelist.names)
+	}
+
+	// Alex gains the role, Steve leaves the guild and the admin loses the role
+	members.roles = map[string][]string{"2": {"player"}, "3": {}}
+	result, err := svc.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if !slices.Equal(result.Added, []string{"Alex"}) || !slices.Equal(result.Removed, []string{"Steve"}) || result.Failed != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	if !slices.Equal(whitelist.names, []string{"Admin", "Alex"}) {
+		t.Fatalf("whitelist = %v", whitelist.names)
+	}
+
+	// Discord being unreachable must not remove anyone
+	members.err = errors.New("discord: rate limited")
+	if result, _ := svc.Reconcile(ctx); result.Failed != 3 || len(result.Removed) != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	members.err = nil
+
+	// Links survive a restart
+	reloaded := NewDiscordSyncService(members, whitelist, "guild", "player", statePath)
+	alex, ok, _ := reloaded.GetLink("2")
+	if !ok || !alex.Whitelisted || alex.Error != "discord: rate limited" {
+		t.Fatalf("reloaded link = %+v", alex)
+	}
+	if last, _ := reloaded.LastSync(); last.Failed != 3 || last.FinishedAt.IsZero() {
+		t.Fatalf("last sync = %+v", last)
+	}
+
+	// Relinking to another name fails while the old one cannot be removed
+	whitelist.removeErr = errors.New("rcon down")
+	if _, err := reloaded.Link(ctx, "2", "alex", "Alex2"); err == nil || !strings.Contains(err.Error(), "rcon down") {
+		t.Fatalf("relink with a failing removal: err = %v", err)
+	}
+	if alex, _, _ := reloaded.GetLink("2"); alex.PlayerName != "Alex" || !alex.Whitelisted {
+		t.Fatalf("link after a failed relink = %+v", alex)
+	}
+	whitelist.removeErr = nil
+
+	// Relinking to another name removes the old one and needs a new approval
+	if alex2, err := reloaded.Link(ctx, "2", "alex", "Alex2"); err != nil || !alex2.Pending {
+		t.Fatalf("relink = %+v, err = %v", alex2, err)
+	}
+	if !slices.Equal(whitelist.names, []string{"Admin"}) {
+		t.Fatalf("whitelist after relink = %v", whitelist.names)
+	}
+	reloaded.Approve(ctx, "2")
+	if !slices.Equal(whitelist.names, []string{"Admin", "Alex2"}) {
+		t.Fatalf("whitelist after approval = %v", whitelist.names)
+	}
+	// Linking the same name again keeps the approval
+	if alex2, _ := reloaded.Link(ctx, "2", "alex", "alex2"); alex2.Pending || !alex2.Whitelisted {
+		t.Fatalf("same name relink = %+v", alex2)
+	}
+	if _, err := reloaded.Unlink("2"); err != nil {
+		t.Fatalf("Unlink: %v", err)
+	}
+	if links, _ := reloaded.Links(); !slices.Equal(whitelist.names, []string{"Admin"}) || len(links) != 2 {
+		t.Fatalf("whitelist = %v, links = %+v", whitelist.names, links)
+	}
+}
+
+func TestDiscordSyncService_LinkValidation(t *testing.T) {
+	svc := NewDiscordSyncService(&fakeDiscordMembers{}, &fakeSyncWhitelist{}, "guild", "player", filepath.Join(t.TempDir(), "links.json"))
+	if _, err := svc.Link(context.Background(), "1", "steve", "not a name"); err == nil {
+		t.Fatal("expected an error for an invalid name")
+	}
+	if _, err := svc.Link(context.Background(), "", "", "Steve"); err == nil {
+		t.Fatal("expected an error without a Discord account")
+	}
+	if _, err := svc.Unlink("1"); err == nil {
+		t.Fatal("expected an error unlinking an unknown account")
+	}
+}
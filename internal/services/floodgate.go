@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultFloodgatePrefix is the prefix Floodgate puts in front of Bedrock names unless configured
+const DefaultFloodgatePrefix = "."
+
+// floodgateUUIDPrefix starts every UUID Floodgate derives from an XUID, which fills the lower half
+const floodgateUUIDPrefix = "00000000-0000-0000-"
+
+// floodgateConfigs are the Floodgate configuration files of the plugin and of the mod. Only the
+// plugin registers the fwhitelist command.
+var floodgateConfigs = []struct {
+	path             string
+	whitelistCommand bool
+}{
+	{"plugins/floodgate/config.yml", true},
+	{"config/floodgate/config.yml", false},
+}
+
+var (
+	// bedrockPrefixPattern matches the prefixes accepted for Bedrock names. Floodgate recommends a
+	// character that is not allowed in Java names, which must also be safe in commands.
+	bedrockPrefixPattern = regexp.MustCompile(`^(?:[.*!~+-]{1,3}|[A-Za-z0-9_]{1,3})?$`)
+	// javaNamePrefixPattern matches prefixes a Java name can start with as well. With prefix "B",
+	// "Bob" may be a Java player or the Bedrock player "ob", so such a prefix identifies nobody.
+	javaNamePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	// floodgatePrefixSetting matches the username-prefix line of the Floodgate config.yml
+	floodgatePrefixSetting = regexp.MustCompile(`(?m)^username-prefix:[ \t]*["']?([^"'\r\n#]*)["']?`)
+)
+
+// XUIDResolver looks up the Xbox user ID of a Bedrock gamertag
+type XUIDResolver interface {
+	XUID(ctx context.Context, gamertag string) (uint64, bool, error)
+}
+
+// Floodgate describes how Geyser/Floodgate presents Bedrock players to the Java server. The zero
+// value is a server without Floodgate.
+type Floodgate struct {
+	Enabled bool
+	// Prefix starts the names of Bedrock players, an empty prefix leaves them indistinguishable
+	Prefix string
+	// WhitelistCommand is true when the Floodgate plugin provides fwhitelist
+	WhitelistCommand bool
+}
+
+// NewFloodgate validates the prefix and creates an enabled Floodgate
+func NewFloodgate(prefix string, whitelistCommand bool) (Floodgate, error) {
+	if !bedrockPrefixPattern.MatchString(prefix) {
+		return Floodgate{}, fmt.Errorf("unsupported Floodgate prefix %q, use up to 3 of .*!~+- or of letters, digits and _", prefix)
+	}
+	return Floodgate{Enabled: true, Prefix: prefix, WhitelistCommand: whitelistCommand}, nil
+}
+
+// DetectFloodgate looks for the configuration of the Floodgate plugin or mod and reads the
+// username prefix from it, ok=false if Floodgate is not installed
+func DetectFloodgate(fileClient CapabilityFileSystemAccessor) (floodgate Floodgate, ok bool, err error) {
+	for _, config := range floodgateConfigs {
+		content, err := fileClient.ReadFile(config.path)
+		if err != nil {
+			continue
+		}
+		prefix := DefaultFloodgatePrefix
+		if m := floodgatePrefixSetting.FindStringSubmatch(content); m != nil {
+			prefix = strings.TrimSpace(m[1])
+		}
+		floodgate, err := NewFloodgate(prefix, config.whitelistCommand)
+		if err != nil {
+			return Floodgate{}, false, fmt.Errorf("%s: %w", config.path, err)
+		}
+		return floodgate, true, nil
+	}
+	return Floodgate{}, false, nil
+}
+
+// NamePrefix returns the prefix that tells Bedrock names apart from Java names, or "" when there is
+// none. Bedrock players behind a letter or digit prefix are only recognized by their Floodgate UUID.
+func (f Floodgate) NamePrefix() string {
+	if !f.Enabled || javaNamePrefixPattern.MatchString(f.Prefix) {
+		return ""
+	}
+	return f.Prefix
+}
+
+// IsBedrockName reports whether a name carries the Bedrock prefix
+func (f Floodgate) IsBedrockName(name string) bool {
+	prefix := f.NamePrefix()
+	return prefix != "" && len(name) > len(prefix) && strings.HasPrefix(name, prefix)
+}
+
+// Gamertag strips the prefix from a Bedrock name. Floodgate replaces spaces in gamertags with
+// underscores, so the result may differ from the Xbox gamertag.
+func (f Floodgate) Gamertag(name string) string {
+	return strings.TrimPrefix(name, f.Prefix)
+}
+
+// FloodgateUUID returns the UUID Floodgate gives the Bedrock player with the XUID
+func FloodgateUUID(xuid uint64) string {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], xuid)
+	h := hex.EncodeToString(b[:])
+	return floodgateUUIDPrefix + h[0:4] + "-" + h[4:16]
+}
+
+// FloodgateXUID returns the XUID of a Floodgate UUID, ok=false for Java UUIDs
+func FloodgateXUID(uuid string) (uint64, bool) {
+	normalized, ok := normalizeUUID(uuid)
+	if !ok || !strings.HasPrefix(normalized, floodgateUUIDPrefix) {
+		return 0, false
+	}
+	b, _ := hex.DecodeString(strings.ReplaceAll(strings.TrimPrefix(normalized, floodgateUUIDPrefix), "-", ""))
+	xuid := binary.BigEndian.Uint64(b)
+	return xuid, xuid != 0
+}
+
+// IsFloodgateUUID reports whether a UUID belongs to a Bedrock player
+func IsFloodgateUUID(uuid string) bool {
+	_, ok := FloodgateXUID(uuid)
+	return ok
+}
+
+// lookupXUID resolves a gamertag from a Bedrock name, trying spaces where Floodgate put underscores
+func lookupXUID(ctx context.Context, xuids XUIDResolver, gamertag string) (uint64, bool, error) {
+	xuid, found, err := xuids.XUID(ctx, gamertag)
+	if err != nil || found || !strings.Contains(gamertag, "_") {
+		return xuid, found, err
+	}
+	return xuids.XUID(ctx, strings.ReplaceAll(gamertag, "_", " "))
+}
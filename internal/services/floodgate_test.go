@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeXUIDs map[string]uint64
+
+func (f fakeXUIDs) XUID(ctx context.Context, gamertag string) (uint64, bool, error) {
+	xuid, ok := f[gamertag]
+	return xuid, ok, nil
+}
+
+func TestFloodgateUUID(t *testing.T) {
+	uuid := FloodgateUUID(2535428650000001)
+	if uuid != "00000000-0000-0000-0009-01f57c095e81" {
+		t.Fatalf("FloodgateUUID = %s", uuid)
+	}
+	if xuid, ok := FloodgateXUID(strings.ToUpper(uuid)); !ok || xuid != 2535428650000001 {
+		t.Fatalf("FloodgateXUID = %d, %v", xuid, ok)
+	}
+	if IsFloodgateUUID("069a79f4-44e9-4726-a5be-fca90e38aaf5") || IsFloodgateUUID("00000000-0000-0000-0000-000000000000") {
+		t.Fatal("Java and nil UUIDs are not Floodgate UUIDs")
+	}
+}
+
+func TestDetectFloodgate(t *testing.T) {
+	fileClient := &fakeFileClient{files: map[string]string{}}
+	if _, ok, err := DetectFloodgate(fileClient); ok || err != nil {
+		t.Fatalf("without Floodgate: ok = %v, err = %v", ok, err)
+	}
+
+	fileClient.files["config/floodgate/config.yml"] = "key-file-name: key.pem\n"
+	if floodgate, ok, err := DetectFloodgate(fileClient); !ok || err != nil || floodgate != (Floodgate{Enabled: true, Prefix: "."}) {
+		t.Fatalf("mod = %+v, %v, %v", floodgate, ok, err)
+	}
+
+	fileClient.files["plugins/floodgate/config.yml"] = "# Floodgate\nusername-prefix: \"*\" # shown in front of Bedrock names\nreplace-spaces: true\n"
+	floodgate, ok, err := DetectFloodgate(fileClient)
+	if !ok || err != nil || floodgate != (Floodgate{Enabled: true, Prefix: "*", WhitelistCommand: true}) {
+		t.Fatalf("plugin = %+v, %v, %v", floodgate, ok, err)
+	}
+	if !floodgate.IsBedrockName("*Steve") || floodgate.IsBedrockName("Steve") || floodgate.IsBedrockName("*") {
+		t.Fatal("IsBedrockName does not follow the prefix")
+	}
+	if err := validatePlayerName("*Steve_Bedrock"); err != nil {
+		t.Fatalf("validatePlayerName: %v", err)
+	}
+
+	fileClient.files["plugins/floodgate/config.yml"] = "username-prefix: \"§ \"\n"
+	if _, _, err := DetectFloodgate(fileClient); err == nil {
+		t.Fatal("expected an error for a prefix that is unsafe in commands")
+	}
+}
+
+func TestWhitelistService_AddBedrockPlayer(t *testing.T) {
+	const existing = `[{"uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "name": "Notch"}]`
+	floodgate, _ := NewFloodgate(".", false)
+
+	t.Run("XUID lookup", func(t *testing.T) {
+		dataDir := writeWhitelistServer(t, "online-mode=true\n", existing)
+		fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+		rconClient := &fakeRconClient{responses: map[string]struct {
+			out string
+			err error
+		}{"whitelist reload": {}}}
+		// The Mojang check would reject the name, it must not be asked
+		svc := NewWhitelistService(rconClient, &fakeMojangChecker{}, &fileClient)
+		svc.UseFloodgate(floodgate, fakeXUIDs{"Steve Bedrock": 2535428650000001})
+
+		if err := svc.AddNameToWhitelist(".Steve_Bedrock"); err != nil {
+			t.Fatalf("AddNameToWhitelist: %v", err)
+		}
+		entries := readWhitelistJSON(t, dataDir)
+		if len(entries) != 2 || entries[1] != (whitelistFileEntry{UUID: "00000000-0000-0000-0009-01f57c095e81", Name: ".Steve_Bedrock"}) {
+			t.Fatalf("whitelist.json = %+v", entries)
+		}
+		if err := svc.AddNameToWhitelist(".Nobody"); err == nil || !strings.Contains(err.Error(), "unknown") {
+			t.Fatalf("unknown gamertag: err = %v", err)
+		}
+
+		info, err := svc.GetWhitelistInfo()
+		if err != nil || info.BedrockPrefix != "." || info.Entries[0].Bedrock || !info.Entries[1].Bedrock {
+			t.Fatalf("info = %+v, err = %v", info, err)
+		}
+
+		if err := svc.RemoveNameFromWhitelist(".Steve_Bedrock"); err != nil {
+			t.Fatalf("RemoveNameFromWhitelist: %v", err)
+		}
+		if entries := readWhitelistJSON(t, dataDir); len(entries) != 1 {
+			t.Fatalf("whitelist.json = %+v", entries)
+		}
+		if err := svc.RemoveNameFromWhitelist(".Steve_Bedrock"); !errors.Is(err, ErrNotWhitelisted) {
+			t.Fatalf("removing a player who is not whitelisted: err = %v", err)
+		}
+	})
+
+	t.Run("fwhitelist", func(t *testing.T) {
+		dataDir := writeWhitelistServer(t, "online-mode=true\n", existing)
+		fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+		rconClient := &fakeRconClient{responses: map[string]struct {
+			out string
+			err error
+		}{
+			"fwhitelist add Steve":  {},
+			"fwhitelist add Alex":   {out: "§cAlex is already whitelisted!"},
+			"fwhitelist remove Bob": {out: "§cBob is not whitelisted!"},
+		}}
+		svc := NewWhitelistService(rconClient, nil, &fileClient)
+		svc.UseFloodgate(Floodgate{Enabled: true, Prefix: ".", WhitelistCommand: true}, nil)
+
+		if err := svc.AddNameToWhitelist(".Steve"); err != nil {
+			t.Fatalf("AddNameToWhitelist: %v", err)
+		}
+		if err := svc.AddNameToWhitelist(".Alex"); err == nil || !strings.Contains(err.Error(), "already whitelisted") {
+			t.Fatalf("err = %v", err)
+		}
+		if err := svc.RemoveNameFromWhitelist(".Bob"); !errors.Is(err, ErrNotWhitelisted) {
+			t.Fatalf("removing a player who is not whitelisted: err = %v", err)
+		}
+		if !reflect.DeepEqual(rconClient.received, []string{"fwhitelist add Steve", "fwhitelist add Alex", "fwhitelist remove Bob"}) {
+			t.Fatalf("commands = %v", rconClient.received)
+		}
+	})
+}
+
+func TestWhitelistService_JavaNameSharingThePrefix(t *testing.T) {
+	dataDir := writeWhitelistServer(t, "online-mode=false\n", `[{"uuid": "00000000-0000-0000-0009-01f57c095e81", "name": "BSteve"}]`)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{"whitelist reload": {}}}
+	svc := NewWhitelistService(rconClient, nil, &fileClient)
+	floodgate, err := NewFloodgate("B", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.UseFloodgate(floodgate, fakeXUIDs{})
+
+	// Bob is a Java player, not the Bedrock player "ob"
+	if floodgate.IsBedrockName("Bob") {
+		t.Fatal("a Java name starting with a letter prefix is taken for a Bedrock name")
+	}
+	if err := svc.AddNameToWhitelist("Bob"); err != nil {
+		t.Fatalf("AddNameToWhitelist: %v", err)
+	}
+	entries := readWhitelistJSON(t, dataDir)
+	if len(entries) != 2 || entries[1] != (whitelistFileEntry{UUID: OfflinePlayerUUID("Bob"), Name: "Bob"}) {
+		t.Fatalf("whitelist.json = %+v", entries)
+	}
+	if !reflect.DeepEqual(rconClient.received, []string{"whitelist reload"}) {
+		t.Fatalf("commands = %v", rconClient.received)
+	}
+
+	// Bedrock players are still recognized by their Floodgate UUID
+	info, err := svc.GetWhitelistInfo()
+	if err != nil || info.BedrockPrefix != "" || !info.Entries[0].Bedrock || info.Entries[1].Bedrock {
+		t.Fatalf("info = %+v, err = %v", info, err)
+	}
+}
+
+func TestUserStatsService_BedrockPlayers(t *testing.T) {
+	dataDir := t.TempDir()
+	os.WriteFile(filepath.Join(dataDir, "usercache.json"), []byte(`[{"name": "Notch", "uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5"}]`), 0644)
+	statsDir := filepath.Join(dataDir, "world", "stats")
+	os.MkdirAll(statsDir, 0755)
+	for _, uuid := range []string{"069a79f4-44e9-4726-a5be-fca90e38aaf5", "00000000-0000-0000-0009-01f57c095e81"} {
+		os.WriteFile(filepath.Join(statsDir, uuid+".json"), []byte(`{"stats": {}, "DataVersion": 3953}`), 0644)
+	}
+
+	svc, err := NewUserStatsService(dataDir)
+	if err != nil {
+		t.Fatalf("NewUserStatsService: %v", err)
+	}
+	stats, _ := svc.GetAllPlayerStats()
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	for _, ps := range stats {
+		bedrock := ps.UUID == "00000000-0000-0000-0009-01f57c095e81"
+		if ps.Bedrock != bedrock || (bedrock && ps.XUID != "2535428650000001") {
+			t.Fatalf("player = %+v", ps)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+// CommonItems are item ids suggested by the give form. Any other id is accepted as well.
+var CommonItems = []string{
+	"minecraft:acacia_log", "minecraft:amethyst_shard", "minecraft:ancient_debris", "minecraft:andesite",
+	"minecraft:anvil", "minecraft:apple", "minecraft:armor_stand", "minecraft:arrow",
+	"minecraft:baked_potato", "minecraft:barrel", "minecraft:beacon",
+	"minecraft:beef", "minecraft:bell", "minecraft:birch_log", "minecraft:black_wool",
+	"minecraft:blast_furnace", "minecraft:blaze_rod", "minecraft:blue_ice", "minecraft:bone",
+	"minecraft:bone_meal", "minecraft:book", "minecraft:bookshelf", "minecraft:bow",
+	"minecraft:bread", "minecraft:brewing_stand", "minecraft:bricks", "minecraft:bucket",
+	"minecraft:cake", "minecraft:campfire", "minecraft:carrot", "minecraft:cartography_table",
+	"minecraft:cauldron", "minecraft:charcoal", "minecraft:cherry_log",
+	"minecraft:chest", "minecraft:chiseled_bookshelf", "minecraft:clay_ball", "minecraft:clock",
+	"minecraft:coal", "minecraft:coal_block", "minecraft:cobbled_deepslate", "minecraft:cobblestone",
+	"minecraft:comparator", "minecraft:compass", "minecraft:composter", "minecraft:cooked_beef",
+	"minecraft:cooked_chicken", "minecraft:cooked_cod", "minecraft:cooked_mutton", "minecraft:cooked_porkchop",
+	"minecraft:cooked_salmon", "minecraft:copper_block", "minecraft:copper_ingot", "minecraft:crafting_table",
+	"minecraft:crossbow", "minecraft:dark_oak_log", "minecraft:deepslate", "minecraft:diamond",
+	"minecraft:diamond_axe", "minecraft:diamond_block", "minecraft:diamond_boots", "minecraft:diamond_chestplate",
+	"minecraft:diamond_helmet", "minecraft:diamond_hoe", "minecraft:diamond_leggings", "minecraft:diamond_pickaxe",
+	"minecraft:diamond_shovel", "minecraft:diamond_sword", "minecraft:diorite", "minecraft:dirt",
+	"minecraft:dispenser", "minecraft:dropper", "minecraft:egg", "minecraft:elytra",
+	"minecraft:emerald", "minecraft:emerald_block", "minecraft:enchanted_golden_apple", "minecraft:enchanting_table",
+	"minecraft:end_crystal", "minecraft:end_stone", "minecraft:ender_chest", "minecraft:ender_eye",
+	"minecraft:ender_pearl", "minecraft:experience_bottle", "minecraft:feather", "minecraft:fire_charge",
+	"minecraft:firework_rocket", "minecraft:fishing_rod", "minecraft:flint", "minecraft:flint_and_steel",
+	"minecraft:furnace", "minecraft:glass", "minecraft:glass_pane", "minecraft:glowstone",
+	"minecraft:glowstone_dust", "minecraft:gold_block", "minecraft:gold_ingot", "minecraft:gold_nugget",
+	"minecraft:golden_apple", "minecraft:golden_carrot", "minecraft:granite", "minecraft:grass_block",
+	"minecraft:gravel", "minecraft:grindstone", "minecraft:gunpowder", "minecraft:hay_block",
+	"minecraft:honey_bottle", "minecraft:hopper", "minecraft:ice", "minecraft:iron_axe",
+	"minecraft:iron_bars", "minecraft:iron_block", "minecraft:iron_boots", "minecraft:iron_chestplate",
+	"minecraft:iron_door", "minecraft:iron_helmet", "minecraft:iron_ingot", "minecraft:iron_leggings",
+	"minecraft:iron_nugget", "minecraft:iron_pickaxe", "minecraft:iron_shovel", "minecraft:iron_sword",
+	"minecraft:item_frame", "minecraft:jukebox", "minecraft:jungle_log", "minecraft:ladder",
+	"minecraft:lantern", "minecraft:lapis_lazuli", "minecraft:lava_bucket", "minecraft:lead",
+	"minecraft:leather", "minecraft:lectern", "minecraft:lever", "minecraft:lodestone",
+	"minecraft:loom", "minecraft:mangrove_log", "minecraft:map", "minecraft:melon_slice",
+	"minecraft:milk_bucket", "minecraft:minecart", "minecraft:mud_bricks", "minecraft:name_tag",
+	"minecraft:nether_star", "minecraft:netherite_axe", "minecraft:netherite_boots", "minecraft:netherite_chestplate",
+	"minecraft:netherite_helmet", "minecraft:netherite_ingot", "minecraft:netherite_leggings", "minecraft:netherite_pickaxe",
+	"minecraft:netherite_scrap", "minecraft:netherite_shovel", "minecraft:netherite_sword", "minecraft:netherrack",
+	"minecraft:note_block", "minecraft:oak_log", "minecraft:oak_planks", "minecraft:oak_sapling",
+	"minecraft:observer", "minecraft:obsidian", "minecraft:painting", "minecraft:paper",
+	"minecraft:piston", "minecraft:poisonous_potato", "minecraft:potato", "minecraft:powered_rail",
+	"minecraft:pumpkin", "minecraft:pumpkin_pie", "minecraft:quartz", "minecraft:quartz_block",
+	"minecraft:rail", "minecraft:raw_copper", "minecraft:raw_gold", "minecraft:raw_iron",
+	"minecraft:red_bed", "minecraft:red_sand", "minecraft:redstone", "minecraft:redstone_block",
+	"minecraft:redstone_lamp", "minecraft:redstone_torch", "minecraft:repeater", "minecraft:respawn_anchor",
+	"minecraft:saddle", "minecraft:sand", "minecraft:sandstone", "minecraft:scaffolding",
+	"minecraft:sea_lantern", "minecraft:shears", "minecraft:shield", "minecraft:shulker_box",
+	"minecraft:slime_ball", "minecraft:smithing_table", "minecraft:smoker", "minecraft:smooth_stone",
+	"minecraft:snowball", "minecraft:spruce_log", "minecraft:spyglass", "minecraft:stick",
+	"minecraft:sticky_piston", "minecraft:stone", "minecraft:stone_bricks", "minecraft:stonecutter",
+	"minecraft:string", "minecraft:sugar", "minecraft:sugar_cane", "minecraft:sweet_berries",
+	"minecraft:target", "minecraft:terracotta", "minecraft:tnt", "minecraft:torch",
+	"minecraft:totem_of_undying", "minecraft:trident", "minecraft:tripwire_hook", "minecraft:turtle_helmet",
+	"minecraft:water_bucket", "minecraft:wheat", "minecraft:wheat_seeds", "minecraft:white_bed",
+	"minecraft:white_wool", "minecraft:writable_book",
+}
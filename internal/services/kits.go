@@ -0,0 +1,348 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"mc-admin/internal/clients/rcon"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxKitDeliveries is how many deliveries the log keeps, oldest dropped first
+const maxKitDeliveries = 500
+
+var (
+	// kitNamePattern matches kit names like starter or event-prize_2
+	kitNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+	// kitItemPattern matches an item id followed by optional components (1.20.5+) or NBT
+	kitItemPattern = regexp.MustCompile(`^((?:[a-z0-9_.-]+:)?[a-z0-9_./-]+)([\[{].*)?$`)
+)
+
+// KitItem is one give of a kit. Item is an id with optional components or NBT exactly as the
+// give command takes it, e.g. diamond_sword[enchantments={sharpness:5}].
+type KitItem struct {
+	Item  string `json:"item"`
+	Count int    `json:"count"`
+}
+
+// String formats the item as one line of the kit editor
+func (i KitItem) String() string {
+	return fmt.Sprintf("%s %d", i.Item, i.Count)
+}
+
+// Kit is a named list of items handed out together
+type Kit struct {
+	Name  string    `json:"name"`
+	Items []KitItem `json:"items"`
+	// Cooldown is how long a player waits before receiving the kit again, 0 for no wait
+	Cooldown time.Duration `json:"cooldown"`
+}
+
+// KitDelivery is one entry of the delivery log
+type KitDelivery struct {
+	Time   time.Time `json:"time"`
+	Kit    string    `json:"kit"`
+	Player string    `json:"player"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// KitDeliveryResult sums up handing a kit to several players
+type KitDeliveryResult struct {
+	Delivered []string
+	// OnCooldown are players skipped because they received the kit too recently
+	OnCooldown []string
+	Failed     map[string]string
+}
+
+// kitState is the persisted state of KitService
+type kitState struct {
+	Kits map[string]*Kit `json:"kits"`
+	// LastDelivered maps kit name to player name to the time of the last delivery
+	LastDelivered map[string]map[string]time.Time `json:"last_delivered"`
+	Deliveries    []KitDelivery                   `json:"deliveries"`
+}
+
+// KitService stores item kits and hands them to online players with the give command
+type KitService struct {
+	rconClient    rcon.CommandExecutor
+	serverService *ServerService
+	stateFile     jsonStateFile
+	now           func() time.Time
+
+	mu    sync.Mutex
+	state *kitState
+}
+
+// NewKitService creates a KitService persisting kits, cooldowns and deliveries to statePath
+func NewKitService(rconClient rcon.CommandExecutor, serverService *ServerService, statePath string) *KitService {
+	return &KitService{
+		rconClient:    rconClient,
+		serverService: serverService,
+		stateFile:     jsonStateFile{path: statePath},
+		now:           time.Now,
+	}
+}
+
+// loadLocked reads the state once
+func (s *KitService) loadLocked() error {
+	if s.state != nil {
+		return nil
+	}
+	state := &kitState{}
+	if err := s.stateFile.load(state); err != nil {
+		return err
+	}
+	if state.Kits == nil {
+		state.Kits = map[string]*Kit{}
+	}
+	if state.LastDelivered == nil {
+		state.LastDelivered = map[string]map[string]time.Time{}
+	}
+	s.state = state
+	return nil
+}
+
+func (s *KitService) saveLocked() error {
+	return s.stateFile.save(s.state)
+}
+
+// ParseKitItems reads one item per line as "<item> [count]", the way they follow the target
+// of a give command. Blank lines and lines starting with # are skipped.
+func ParseKitItems(text string) ([]KitItem, error) {
+	var items []KitItem
+	for number, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		item := KitItem{Item: line, Count: 1}
+		if i := strings.LastIndexAny(line, " \t"); i >= 0 {
+			if count, err := strconv.Atoi(line[i+1:]); err == nil {
+				item = KitItem{Item: strings.TrimSpace(line[:i]), Count: count}
+			}
+		}
+		if err := item.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", number+1, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (i KitItem) validate() error {
+	m := kitItemPattern.FindStringSubmatch(i.Item)
+	if m == nil || strings.ContainsAny(i.Item, "\r\n") {
+		return fmt.Errorf("%q is not an item id like minecraft:diamond", i.Item)
+	}
+	if data := m[2]; data != "" {
+		if closing := map[byte]byte{'[': ']', '{': '}'}[data[0]]; data[len(data)-1] != closing {
+			return fmt.Errorf("components of %s must end with %q", m[1], closing)
+		}
+	}
+	if i.Count < 1 || i.Count > maxGiveCount {
+		return fmt.Errorf("count of %s must be between 1 and %d", m[1], maxGiveCount)
+	}
+	return nil
+}
+
+func validateKitName(name string) error {
+	if !kitNamePattern.MatchString(name) {
+		return fmt.Errorf("kit name must be 1 to 32 letters, digits, - or _")
+	}
+	return nil
+}
+
+// GetKits returns the kits sorted by name
+func (s *KitService) GetKits() ([]Kit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	kits := make([]Kit, 0, len(s.state.Kits))
+	for _, kit := range s.state.Kits {
+		kits = append(kits, *kit)
+	}
+	sort.Slice(kits, func(i, j int) bool { return kits[i].Name < kits[j].Name })
+	return kits, nil
+}
+
+// SaveKit creates a kit or replaces the kit with the same name
+func (s *KitService) SaveKit(kit Kit) error {
+	if err := validateKitName(kit.Name); err != nil {
+		return err
+	}
+	if len(kit.Items) == 0 {
+		return fmt.Errorf("kit %s has no items", kit.Name)
+	}
+	for _, item := range kit.Items {
+		if err := item.validate(); err != nil {
+			return err
+		}
+	}
+	if kit.Cooldown < 0 {
+		return fmt.Errorf("cooldown cannot be negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	s.state.Kits[kit.Name] = &kit
+	return s.saveLocked()
+}
+
+// DeleteKit removes a kit and its cooldowns. Its deliveries stay in the log.
+func (s *KitService) DeleteKit(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	if _, ok := s.state.Kits[name]; !ok {
+		return fmt.Errorf("kit %s does not exist", name)
+	}
+	delete(s.state.Kits, name)
+	delete(s.state.LastDelivered, name)
+	return s.saveLocked()
+}
+
+// ResetCooldowns lets every player receive a kit again right away
+func (s *KitService) ResetCooldowns(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	if _, ok := s.state.Kits[name]; !ok {
+		return fmt.Errorf("kit %s does not exist", name)
+	}
+	delete(s.state.LastDelivered, name)
+	return s.saveLocked()
+}
+
+// CooldownRemaining returns how long player still waits for a kit, 0 if it can be given now
+func (s *KitService) CooldownRemaining(name, player string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return 0, err
+	}
+	kit, ok := s.state.Kits[name]
+	if !ok {
+		return 0, nil
+	}
+	return s.cooldownRemainingLocked(kit, player), nil
+}
+
+func (s *KitService) cooldownRemainingLocked(kit *Kit, player string) time.Duration {
+	last, ok := s.state.LastDelivered[kit.Name][player]
+	if !ok || kit.Cooldown == 0 {
+		return 0
+	}
+	return max(0, last.Add(kit.Cooldown).Sub(s.now()))
+}
+
+// GetDeliveries returns the latest deliveries first, at most limit of them
+func (s *KitService) GetDeliveries(limit int) ([]KitDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	deliveries := make([]KitDelivery, 0, min(limit, len(s.state.Deliveries)))
+	for i := len(s.state.Deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		deliveries = append(deliveries, s.state.Deliveries[i])
+	}
+	return deliveries, nil
+}
+
+// GiveKit hands a kit to one online player unless the player is on cooldown
+func (s *KitService) GiveKit(name, player string) error {
+	if err := validatePlayerName(player); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	kit, ok := s.state.Kits[name]
+	if !ok {
+		return fmt.Errorf("kit %s does not exist", name)
+	}
+	if wait := s.cooldownRemainingLocked(kit, player); wait > 0 {
+		return fmt.Errorf("%s can receive kit %s again in %s", player, name, wait.Round(time.Second))
+	}
+	err := s.deliverLocked(kit, player)
+	// a failed delivery is logged too, without starting the cooldown
+	if saveErr := s.saveLocked(); saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	return err
+}
+
+// GiveKitToAll hands a kit to every online player who is not on cooldown
+func (s *KitService) GiveKitToAll(name string) (KitDeliveryResult, error) {
+	result := KitDeliveryResult{Failed: map[string]string{}}
+	info, err := s.serverService.GetServerPlayerInfo()
+	if err != nil {
+		return result, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return result, err
+	}
+	kit, ok := s.state.Kits[name]
+	if !ok {
+		return result, fmt.Errorf("kit %s does not exist", name)
+	}
+	for _, player := range info.PlayerNames {
+		if s.cooldownRemainingLocked(kit, player) > 0 {
+			result.OnCooldown = append(result.OnCooldown, player)
+			continue
+		}
+		if err := s.deliverLocked(kit, player); err != nil {
+			result.Failed[player] = err.Error()
+			continue
+		}
+		result.Delivered = append(result.Delivered, player)
+	}
+	return result, s.saveLocked()
+}
+
+// deliverLocked gives every item of a kit and logs the delivery. The cooldown only starts when
+// all items were given, so an admin can retry a failed delivery; items given before the failure
+// are not taken back and are given again by the retry.
+func (s *KitService) deliverLocked(kit *Kit, player string) error {
+	var err error
+	for _, item := range kit.Items {
+		command := fmt.Sprintf("give %s %s %d", player, item.Item, item.Count)
+		if err = executeExpecting(s.rconClient, command, "Gave "); err != nil {
+			err = fmt.Errorf("failed to give %s: %w", item.Item, err)
+			break
+		}
+	}
+	now := s.now()
+	delivery := KitDelivery{Time: now, Kit: kit.Name, Player: player}
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		if s.state.LastDelivered[kit.Name] == nil {
+			s.state.LastDelivered[kit.Name] = map[string]time.Time{}
+		}
+		s.state.LastDelivered[kit.Name][player] = now
+	}
+	s.state.Deliveries = append(s.state.Deliveries, delivery)
+	if extra := len(s.state.Deliveries) - maxKitDeliveries; extra > 0 {
+		s.state.Deliveries = append([]KitDelivery(nil), s.state.Deliveries[extra:]...)
+	}
+	return err
+}
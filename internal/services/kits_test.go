@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseKitItems(t *testing.T) {
+	items, err := ParseKitItems("# starter kit\nminecraft:stone_sword\n\nbread 16\ndiamond_sword[enchantments={levels:{sharpness:5}}] 1\n")
+	if err != nil {
+		t.Fatalf("ParseKitItems: %v", err)
+	}
+	want := []KitItem{
+		{Item: "minecraft:stone_sword", Count: 1},
+		{Item: "bread", Count: 16},
+		{Item: "diamond_sword[enchantments={levels:{sharpness:5}}]", Count: 1},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("items = %+v, want %+v", items, want)
+	}
+
+	for _, text := range []string{"Diamond 1", "diamond_sword[damage=5 1", "dirt 0", "dirt 6401", "@a dirt"} {
+		if _, err := ParseKitItems(text); err == nil {
+			t.Errorf("ParseKitItems(%q) succeeded, want error", text)
+		}
+	}
+}
+
+func TestKitService_GiveKit(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "kits.json")
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"give Steve minecraft:bread 16":      {out: "Gave 16 [Bread] to Steve"},
+		"give Steve minecraft:stone_sword 1": {out: "Gave 1 [Stone Sword] to Steve"},
+		"give Notch minecraft:bread 16":      {out: "No player was found"},
+		"give Alex minecraft:bread 16":       {out: "Gave 16 [Bread] to Alex"},
+		"give Alex minecraft:stone_sword 1":  {out: "Gave 1 [Stone Sword] to Alex"},
+		"list":                               {out: "There are 2 of a max of 20 players online: Steve, Alex"},
+	}}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewKitService(rconClient, NewServerServiceFromRconClient(rconClient), statePath)
+	svc.now = func() time.Time { return now }
+
+	starter := Kit{Name: "starter", Cooldown: time.Hour, Items: []KitItem{
+		{Item: "minecraft:bread", Count: 16}, {Item: "minecraft:stone_sword", Count: 1},
+	}}
+	if err := svc.SaveKit(starter); err != nil {
+		t.Fatalf("SaveKit: %v", err)
+	}
+	if err := svc.GiveKit("starter", "Steve"); err != nil {
+		t.Fatalf("GiveKit: %v", err)
+	}
+	if err := svc.GiveKit("starter", "Steve"); err == nil {
+		t.Fatalf("GiveKit during cooldown succeeded")
+	}
+	if err := svc.GiveKit("starter", "Notch"); err == nil {
+		t.Fatalf("GiveKit to offline player succeeded")
+	}
+
+	// Steve is still on cooldown, Alex is not
+	result, err := svc.GiveKitToAll("starter")
+	if err != nil {
+		t.Fatalf("GiveKitToAll: %v", err)
+	}
+	if !reflect.DeepEqual(result.Delivered, []string{"Alex"}) || !reflect.DeepEqual(result.OnCooldown, []string{"Steve"}) {
+		t.Fatalf("result = %+v", result)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if wait, _ := svc.CooldownRemaining("starter", "Steve"); wait != 30*time.Minute {
+		t.Fatalf("CooldownRemaining = %s, want 30m", wait)
+	}
+	now = now.Add(30 * time.Minute)
+	if err := svc.GiveKit("starter", "Steve"); err != nil {
+		t.Fatalf("GiveKit after cooldown: %v", err)
+	}
+
+	// cooldowns and the log survive a restart
+	reloaded := NewKitService(rconClient, NewServerServiceFromRconClient(rconClient), statePath)
+	reloaded.now = svc.now
+	if wait, _ := reloaded.CooldownRemaining("starter", "Steve"); wait != time.Hour {
+		t.Fatalf("CooldownRemaining after reload = %s, want 1h", wait)
+	}
+	deliveries, _ := reloaded.GetDeliveries(10)
+	if len(deliveries) != 4 {
+		t.Fatalf("deliveries = %+v, want 4", deliveries)
+	}
+	if deliveries[0].Player != "Steve" || deliveries[0].Error != "" {
+		t.Fatalf("latest delivery = %+v", deliveries[0])
+	}
+	if deliveries[2].Player != "Notch" || deliveries[2].Error == "" {
+		t.Fatalf("failed delivery = %+v", deliveries[2])
+	}
+
+	if err := reloaded.ResetCooldowns("starter"); err != nil {
+		t.Fatalf("ResetCooldowns: %v", err)
+	}
+	if wait, _ := reloaded.CooldownRemaining("starter", "Steve"); wait != 0 {
+		t.Fatalf("CooldownRemaining after reset = %s", wait)
+	}
+	if err := reloaded.DeleteKit("starter"); err != nil {
+		t.Fatalf("DeleteKit: %v", err)
+	}
+	if kits, _ := reloaded.GetKits(); len(kits) != 0 {
+		t.Fatalf("kits = %+v, want none", kits)
+	}
+}
+
+func TestKitService_PartialDeliveryStartsNoCooldown(t *testing.T) {
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"give Steve minecraft:bread 16":      {out: "Gave 16 [Bread] to Steve"},
+		"give Steve minecraft:stone_sword 1": {err: errors.New("connection reset")},
+	}}
+	svc := NewKitService(rconClient, NewServerServiceFromRconClient(rconClient), filepath.Join(t.TempDir(), "kits.json"))
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	starter := Kit{Name: "starter", Cooldown: time.Hour, Items: []KitItem{
+		{Item: "minecraft:bread", Count: 16}, {Item: "minecraft:stone_sword", Count: 1},
+	}}
+	if err := svc.SaveKit(starter); err != nil {
+		t.Fatalf("SaveKit: %v", err)
+	}
+
+	if err := svc.GiveKit("starter", "Steve"); err == nil {
+		t.Fatalf("GiveKit with a failing item succeeded")
+	}
+	if deliveries, _ := svc.GetDeliveries(10); len(deliveries) != 1 || deliveries[0].Error == "" {
+		t.Fatalf("deliveries = %+v, want one failed delivery", deliveries)
+	}
+	if wait, _ := svc.CooldownRemaining("starter", "Steve"); wait != 0 {
+		t.Fatalf("CooldownRemaining after a partial delivery = %s, want none", wait)
+	}
+
+	// the retry gives every item again and starts the cooldown
+	rconClient.responses["give Steve minecraft:stone_sword 1"] = struct {
+		out string
+		err error
+	}{out: "Gave 1 [Stone Sword] to Steve"}
+	rconClient.received = nil
+	if err := svc.GiveKit("starter", "Steve"); err != nil {
+		t.Fatalf("GiveKit retry: %v", err)
+	}
+	if len(rconClient.received) != 2 {
+		t.Fatalf("retry sent %v", rconClient.received)
+	}
+	if wait, _ := svc.CooldownRemaining("starter", "Steve"); wait != time.Hour {
+		t.Fatalf("CooldownRemaining after the retry = %s, want 1h", wait)
+	}
+}
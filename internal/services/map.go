@@ -0,0 +1,223 @@
+package services
+
+import (
+	"compress/gzip"
+	"fmt"
+	"mc-admin/internal/clients/rcon"
+	"mc-admin/internal/maprender"
+	"mc-admin/internal/regions"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dimensionIDs maps dimension names used by the regions package to Minecraft's identifiers
+var dimensionIDs = map[string]string{
+	"overworld": "minecraft:overworld",
+	"nether":    "minecraft:the_nether",
+	"end":       "minecraft:the_end",
+}
+
+// positionPattern matches the reply of "data get entity <player> Pos"
+var positionPattern = regexp.MustCompile(`\[(-?[\d.]+(?:E-?\d+)?)d, (-?[\d.]+(?:E-?\d+)?)d, (-?[\d.]+(?:E-?\d+)?)d\]`)
+
+// MapFileSystemAccessor is the subset of the files client used by MapService
+type MapFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	ReadFile(path string) (string, error)
+}
+
+// PlayerMarker is the live position of an online player
+type PlayerMarker struct {
+	Name string  `json:"name"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Z    float64 `json:"z"`
+}
+
+// MapPoint is a block position on the map
+type MapPoint struct {
+	X int `json:"x"`
+	Z int `json:"z"`
+}
+
+// MapMarkers are the markers shown on top of a dimension's tiles
+type MapMarkers struct {
+	Players []PlayerMarker `json:"players"`
+	Spawn   *MapPoint      `json:"spawn"`
+}
+
+// MapService serves rendered map tiles and marker positions
+type MapService struct {
+	rconClient    rcon.CommandExecutor
+	fileClient    MapFileSystemAccessor
+	serverService *ServerService
+	tiles         *maprender.TileStore
+}
+
+// NewMapService creates a MapService that caches tiles below cacheDir
+func NewMapService(rconClient rcon.CommandExecutor, fileClient MapFileSystemAccessor, cacheDir string) *MapService {
+	return &MapService{
+		rconClient:    rconClient,
+		fileClient:    fileClient,
+		serverService: NewServerServiceFromRconClient(rconClient),
+		tiles:         maprender.NewTileStore(cacheDir),
+	}
+}
+
+func (s *MapService) worldDir() (string, error) {
+	return s.fileClient.GetAbsolutePath(getLevelName(s.fileClient))
+}
+
+// Dimensions returns the dimensions that have region files
+func (s *MapService) Dimensions() ([]regions.Dimension, error) {
+	worldDir, err := s.worldDir()
+	if err != nil {
+		return nil, err
+	}
+	return regions.WorldDimensions(worldDir), nil
+}
+
+func (s *MapService) dimension(name string) (regions.Dimension, error) {
+	dimensions, err := s.Dimensions()
+	if err != nil {
+		return regions.Dimension{}, err
+	}
+	for _, d := range dimensions {
+		if d.Name == name {
+			return d, nil
+		}
+	}
+	return regions.Dimension{}, fmt.Errorf("unknown dimension: %s", name)
+}
+
+// GetTilePath returns a cached PNG for the tile, rendering it first if its regions changed
+func (s *MapService) GetTilePath(dimension string, zoom, x, y int) (string, error) {
+	d, err := s.dimension(dimension)
+	if err != nil {
+		return "", err
+	}
+	return s.tiles.Tile(d, zoom, x, y)
+}
+
+// GetMarkers returns the players in the dimension and the world spawn if it lies in the dimension
+func (s *MapService) GetMarkers(dimension string) (MapMarkers, error) {
+	markers := MapMarkers{Players: []PlayerMarker{}}
+	dimensionID, ok := dimensionIDs[dimension]
+	if !ok {
+		return markers, fmt.Errorf("unknown dimension: %s", dimension)
+	}
+
+	if spawn, spawnDimension, err := s.readSpawn(); err == nil && spawnDimension == dimensionID {
+		markers.Spawn = &spawn
+	}
+
+	info, err := s.serverService.GetServerPlayerInfo()
+	if err != nil {
+		// the map stays usable while the server is offline, just without players
+		return markers, nil
+	}
+	for _, name := range info.PlayerNames {
+		playerDimension, err := s.rconClient.ExecuteCommand(fmt.Sprintf("data get entity %s Dimension", name))
+		if err != nil || !strings.Contains(playerDimension, `"`+dimensionID+`"`) {
+			continue
+		}
+		pos, err := s.rconClient.ExecuteCommand(fmt.Sprintf("data get entity %s Pos", name))
+		if err != nil {
+			continue
+		}
+		marker, ok := parsePlayerPosition(name, pos)
+		if ok {
+			markers.Players = append(markers.Players, marker)
+		}
+	}
+	return markers, nil
+}
+
+// parsePlayerPosition parses "Steve has the following entity data: [12.5d, 64.0d, -3.2d]"
+func parsePlayerPosition(name string, response string) (PlayerMarker, bool) {
+	m := positionPattern.FindStringSubmatch(response)
+	if m == nil {
+		return PlayerMarker{}, false
+	}
+	x, errX := strconv.ParseFloat(m[1], 64)
+	y, errY := strconv.ParseFloat(m[2], 64)
+	z, errZ := strconv.ParseFloat(m[3], 64)
+	if errX != nil || errY != nil || errZ != nil {
+		return PlayerMarker{}, false
+	}
+	return PlayerMarker{Name: name, X: x, Y: y, Z: z}, true
+}
+
+// readNBTFile decodes a gzip compressed NBT file such as level.dat
+func readNBTFile(path string) (regions.Compound, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return regions.DecodeNBT(gz)
+}
+
+// readLevelData reads the Data compound of the world's level.dat
+func readLevelData(worldDir string) (regions.Compound, error) {
+	level, err := readNBTFile(filepath.Join(worldDir, "level.dat"))
+	if err != nil {
+		return nil, err
+	}
+	data := level.Compound("Data")
+	if data == nil {
+		return nil, fmt.Errorf("level.dat has no Data compound")
+	}
+	return data, nil
+}
+
+// readSpawn returns the world spawn column for the spawn marker
+func (s *MapService) readSpawn() (MapPoint, string, error) {
+	worldDir, err := s.worldDir()
+	if err != nil {
+		return MapPoint{}, "", err
+	}
+	spawn, err := readWorldSpawn(worldDir)
+	if err != nil {
+		return MapPoint{}, "", err
+	}
+	return MapPoint{X: spawn.X, Z: spawn.Z}, spawn.Dimension, nil
+}
+
+// worldSpawn is the world spawn block and its dimension
+type worldSpawn struct {
+	X, Y, Z   int
+	Dimension string
+}
+
+// readWorldSpawn reads the world spawn from level.dat. Since 1.21.9 it is stored in a spawn
+// compound with its dimension, before that as SpawnX/SpawnY/SpawnZ in the overworld.
+func readWorldSpawn(worldDir string) (worldSpawn, error) {
+	data, err := readLevelData(worldDir)
+	if err != nil {
+		return worldSpawn{}, err
+	}
+	if spawn := data.Compound("spawn"); spawn != nil {
+		if pos, ok := spawn["pos"].([]int32); ok && len(pos) == 3 {
+			dimension := spawn.String("dimension")
+			if dimension == "" {
+				dimension = dimensionIDs["overworld"]
+			}
+			return worldSpawn{X: int(pos[0]), Y: int(pos[1]), Z: int(pos[2]), Dimension: dimension}, nil
+		}
+	}
+	x, okX := data.Int("SpawnX")
+	z, okZ := data.Int("SpawnZ")
+	if !okX || !okZ {
+		return worldSpawn{}, fmt.Errorf("level.dat has no spawn position")
+	}
+	y, _ := data.Int("SpawnY")
+	return worldSpawn{X: int(x), Y: int(y), Z: int(z), Dimension: dimensionIDs["overworld"]}, nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeLevelDat writes a gzip compressed level.dat with Data.SpawnX, Data.SpawnZ and Data.DataVersion
+func writeLevelDat(t *testing.T, path string, spawnX, spawnZ, dataVersion int32) {
+	t.Helper()
+	var b bytes.Buffer
+	tag := func(tagType byte, name string) {
+		b.WriteByte(tagType)
+		binary.Write(&b, binary.BigEndian, uint16(len(name)))
+		b.WriteString(name)
+	}
+	tag(10, "")
+	tag(10, "Data")
+	tag(3, "SpawnX")
+	binary.Write(&b, binary.BigEndian, spawnX)
+	tag(3, "SpawnZ")
+	binary.Write(&b, binary.BigEndian, spawnZ)
+	tag(3, "DataVersion")
+	binary.Write(&b, binary.BigEndian, dataVersion)
+	b.WriteByte(0)
+	b.WriteByte(0)
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	gz.Write(b.Bytes())
+	gz.Close()
+}
+
+func TestParsePlayerPosition(t *testing.T) {
+	marker, ok := parsePlayerPosition("Steve", "Steve has the following entity data: [12.5d, 64.0d, -3.25d]")
+	if !ok || marker != (PlayerMarker{Name: "Steve", X: 12.5, Y: 64, Z: -3.25}) {
+		t.Fatalf("marker = %+v, ok = %v", marker, ok)
+	}
+	if _, ok := parsePlayerPosition("Steve", "No entity was found"); ok {
+		t.Fatalf("expected parse failure")
+	}
+}
+
+func TestMapService_GetMarkers(t *testing.T) {
+	dataDir := t.TempDir()
+	os.MkdirAll(filepath.Join(dataDir, "world", "region"), 0755)
+	writeLevelDat(t, filepath.Join(dataDir, "world", "level.dat"), 100, -200, 3955)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"list":                            {out: "There are 2 of a max of 20 players online: Steve, Alex"},
+		"data get entity Steve Dimension": {out: `Steve has the following entity data: "minecraft:overworld"`},
+		"data get entity Steve Pos":       {out: "Steve has the following entity data: [1.5d, 70.0d, 2.5d]"},
+		"data get entity Alex Dimension":  {out: `Alex has the following entity data: "minecraft:the_nether"`},
+	}}
+	svc := NewMapService(rconClient, &fileClient, t.TempDir())
+
+	markers, err := svc.GetMarkers("overworld")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := MapMarkers{
+		Players: []PlayerMarker{{Name: "Steve", X: 1.5, Y: 70, Z: 2.5}},
+		Spawn:   &MapPoint{X: 100, Z: -200},
+	}
+	if !reflect.DeepEqual(markers, want) {
+		t.Fatalf("markers = %+v, want %+v", markers, want)
+	}
+
+	nether, err := svc.GetMarkers("nether")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nether.Spawn != nil || len(nether.Players) != 0 {
+		t.Fatalf("nether markers = %+v, Alex has no position and spawn is in the overworld", nether)
+	}
+
+	if _, err := svc.GetMarkers("moon"); err == nil {
+		t.Fatalf("expected error for unknown dimension")
+	}
+}
+
+func TestMapService_GetTilePathUnknownDimension(t *testing.T) {
+	dataDir := t.TempDir()
+	os.MkdirAll(filepath.Join(dataDir, "world", "region"), 0755)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	svc := NewMapService(&fakeRconClient{}, &fileClient, t.TempDir())
+	if _, err := svc.GetTilePath("nether", 0, 0, 0); err == nil {
+		t.Fatalf("expected error for a dimension without regions")
+	}
+}
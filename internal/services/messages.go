@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"mc-admin/internal/clients/rcon"
+	"mc-admin/internal/textcomponent"
+	"slices"
+	"strings"
+)
+
+const (
+	MessageChat      = "tellraw"
+	MessageTitle     = "title"
+	MessageSubtitle  = "subtitle"
+	MessageActionbar = "actionbar"
+)
+
+// MessageKinds are the ways a composed message can be shown
+var MessageKinds = []string{MessageChat, MessageTitle, MessageSubtitle, MessageActionbar}
+
+// Default title timings of the game, in ticks
+const (
+	DefaultTitleFadeIn  = 10
+	DefaultTitleStay    = 70
+	DefaultTitleFadeOut = 20
+)
+
+// MessageFileSystemAccessor is the subset of the files client used by MessageService
+type MessageFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	ReadFile(path string) (string, error)
+}
+
+// Broadcast is a composed message and who sees it. The fade timings (in ticks) apply to
+// titles, subtitles and action bars.
+type Broadcast struct {
+	Kind    string
+	Targets string
+	Message textcomponent.Component
+	FadeIn  int
+	Stay    int
+	FadeOut int
+}
+
+// MessageService sends text component messages with tellraw and title
+type MessageService struct {
+	rconClient rcon.CommandExecutor
+	fileClient MessageFileSystemAccessor
+}
+
+// NewMessageService creates a MessageService
+func NewMessageService(rconClient rcon.CommandExecutor, fileClient MessageFileSystemAccessor) *MessageService {
+	return &MessageService{
+		rconClient: rconClient,
+		fileClient: fileClient,
+	}
+}
+
+// Format returns the component format of the server, read from the DataVersion in level.dat.
+// Without a readable level.dat the current format is assumed.
+func (s *MessageService) Format() textcomponent.Format {
+	worldDir, err := s.fileClient.GetAbsolutePath(getLevelName(s.fileClient))
+	if err != nil {
+		return textcomponent.Modern
+	}
+	data, err := readLevelData(worldDir)
+	if err != nil {
+		return textcomponent.Modern
+	}
+	dataVersion, _ := data.Int("DataVersion")
+	return textcomponent.FormatForDataVersion(int(dataVersion))
+}
+
+// Commands validates a broadcast and returns the commands that show it
+func (s *MessageService) Commands(b Broadcast) ([]string, error) {
+	if !slices.Contains(MessageKinds, b.Kind) {
+		return nil, fmt.Errorf("unknown message kind %q", b.Kind)
+	}
+	targets := strings.TrimSpace(b.Targets)
+	if targets == "" {
+		targets = "@a"
+	}
+	if strings.ContainsAny(targets, "\r\n") {
+		return nil, fmt.Errorf("targets must be on a single line")
+	}
+	if b.Message.PlainText() == "" {
+		return nil, fmt.Errorf("message is empty")
+	}
+	if err := b.Message.Validate(); err != nil {
+		return nil, err
+	}
+	component := b.Message.Encode(s.Format())
+
+	if b.Kind == MessageChat {
+		return []string{fmt.Sprintf("tellraw %s %s", targets, component)}, nil
+	}
+	if b.FadeIn < 0 || b.Stay < 0 || b.FadeOut < 0 {
+		return nil, fmt.Errorf("title timings cannot be negative")
+	}
+	return []string{
+		fmt.Sprintf("title %s times %d %d %d", targets, b.FadeIn, b.Stay, b.FadeOut),
+		fmt.Sprintf("title %s %s %s", targets, b.Kind, component),
+	}, nil
+}
+
+// Send shows a broadcast. tellraw gives no feedback on success, so any response is an error.
+func (s *MessageService) Send(b Broadcast) error {
+	commands, err := s.Commands(b)
+	if err != nil {
+		return err
+	}
+	for _, command := range commands {
+		if strings.HasPrefix(command, "tellraw ") {
+			response, err := s.rconClient.ExecuteCommand(command)
+			if err != nil {
+				return fmt.Errorf("failed to execute tellraw: %w", err)
+			}
+			if response = strings.TrimSpace(response); response != "" {
+				return fmt.Errorf("%s", response)
+			}
+			continue
+		}
+		if err := executeExpecting(s.rconClient, command, "Changing title times", "Showing new"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
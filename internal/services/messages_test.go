@@ -0,0 +1,81 @@
+package services
+
+import (
+	"mc-admin/internal/clients/files"
+	"mc-admin/internal/textcomponent"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMessageService_Send(t *testing.T) {
+	fileClient := files.NewMinecraftFilesClient(t.TempDir(), 0)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		`tellraw @a {"extra":[{"color":"gold","text":"Contest "},{"selector":"@p"}],"text":""}`: {out: ""},
+		`tellraw Notch {"text":"hi"}`:                     {out: "No player was found"},
+		"title Steve times 5 40 10":                       {out: "Changing title times for Steve"},
+		`title Steve actionbar {"bold":true,"text":"Go"}`: {out: "Showing new actionbar title for Steve"},
+	}}
+	svc := NewMessageService(rconClient, &fileClient)
+
+	err := svc.Send(Broadcast{
+		Kind:    MessageChat,
+		Message: textcomponent.Join(textcomponent.Component{Text: "Contest ", Color: "gold"}, textcomponent.Component{Selector: "@p"}),
+	})
+	if err != nil {
+		t.Fatalf("Send tellraw: %v", err)
+	}
+	err = svc.Send(Broadcast{Kind: MessageChat, Targets: "Notch", Message: textcomponent.Text("hi")})
+	if err == nil || err.Error() != "No player was found" {
+		t.Fatalf("Send to offline player = %v, want server message", err)
+	}
+	err = svc.Send(Broadcast{
+		Kind: MessageActionbar, Targets: "Steve", FadeIn: 5, Stay: 40, FadeOut: 10,
+		Message: textcomponent.Component{Text: "Go", Bold: true},
+	})
+	if err != nil {
+		t.Fatalf("Send actionbar: %v", err)
+	}
+
+	invalid := []Broadcast{
+		{Kind: "bossbar", Message: textcomponent.Text("x")},
+		{Kind: MessageChat, Message: textcomponent.Text("")},
+		{Kind: MessageTitle, Message: textcomponent.Text("x"), FadeIn: -1},
+		{Kind: MessageChat, Targets: "@a\nstop", Message: textcomponent.Text("x")},
+	}
+	for _, b := range invalid {
+		if err := svc.Send(b); err == nil {
+			t.Fatalf("Send(%+v) succeeded, want error", b)
+		}
+	}
+}
+
+func TestMessageService_CommandsForOlderServers(t *testing.T) {
+	dataDir := t.TempDir()
+	os.MkdirAll(filepath.Join(dataDir, "world"), 0755)
+	writeLevelDat(t, filepath.Join(dataDir, "world", "level.dat"), 0, 0, 3955)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	svc := NewMessageService(&fakeRconClient{}, &fileClient)
+
+	commands, err := svc.Commands(Broadcast{
+		Kind: MessageTitle, FadeIn: DefaultTitleFadeIn, Stay: DefaultTitleStay, FadeOut: DefaultTitleFadeOut,
+		Message: textcomponent.Component{
+			Text:  "Vote",
+			Click: &textcomponent.ClickEvent{Action: textcomponent.ClickRunCommand, Value: "/trigger vote"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Commands: %v", err)
+	}
+	want := []string{
+		"title @a times 10 70 20",
+		`title @a title {"clickEvent":{"action":"run_command","value":"/trigger vote"},"text":"Vote"}`,
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Fatalf("commands = %q, want %q", commands, want)
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mc-admin/internal/clients/rcon"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PerformanceSourceTickQuery samples with the vanilla "tick query" command (1.20.3+)
+	PerformanceSourceTickQuery = "tick query"
+	// PerformanceSourcePaper samples with the "tps" and "mspt" commands of Paper and Spigot
+	PerformanceSourcePaper = "paper"
+	// PerformanceSourceLog counts "Can't keep up" warnings in logs/latest.log
+	PerformanceSourceLog = "log"
+
+	// targetTPS is the tick rate of an unmodified server
+	targetTPS = 20.0
+	// latestLogPath is the server log relative to the data directory
+	latestLogPath = "logs/latest.log"
+)
+
+// errSourceUnavailable is returned by a sampler the server does not support
+var errSourceUnavailable = errors.New("not supported by the server")
+
+// decimalPattern captures a number printed with either decimal separator
+const decimalPattern = `(\d+(?:[.,]\d+)?)`
+
+var (
+	tickRatePattern        = regexp.MustCompile(`Target tick rate: ` + decimalPattern + ` per second`)
+	tickAveragePattern     = regexp.MustCompile(`Average time per tick: ` + decimalPattern + ` ?ms`)
+	tickPercentilesPattern = regexp.MustCompile(`P50: ` + decimalPattern + ` ?ms,? P95: ` + decimalPattern + ` ?ms`)
+	// paperTPSPattern matches "TPS from last 1m, 5m, 15m: 19.98, 20.0, *20.0"
+	paperTPSPattern = regexp.MustCompile(`TPS from last 1m, 5m, 15m: \*?` + decimalPattern)
+	// paperMSPTPattern matches the avg/min/max of the last 5 seconds in the mspt output
+	paperMSPTPattern = regexp.MustCompile(`◴ ` + decimalPattern + `/` + decimalPattern + `/` + decimalPattern)
+	// formattingCodePattern matches legacy formatting codes like §a
+	formattingCodePattern = regexp.MustCompile(`§.`)
+	// cantKeepUpPattern matches "Can't keep up! Is the server overloaded? Running 2345ms or 46 ticks behind"
+	cantKeepUpPattern = regexp.MustCompile(`Can't keep up! .*?Running (\d+)ms or (\d+) ticks behind`)
+)
+
+// PerformanceFileSystemAccessor is the subset of the files client used to read the server log
+type PerformanceFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+}
+
+// PerformanceSample is one measurement of tick health. TPS and MSPT are zero when the source
+// does not report them.
+type PerformanceSample struct {
+	Time   time.Time
+	Source string
+	TPS    float64
+	// MSPT is the average milliseconds per tick
+	MSPT float64
+	// P95 is the 95th percentile milliseconds per tick where known
+	P95 float64
+	// Status is the state reported by tick query, e.g. "running normally" or "frozen"
+	Status string
+	// Warnings are the "Can't keep up" warnings logged since the previous sample
+	Warnings int
+	// BehindMS is the largest delay reported by those warnings
+	BehindMS int
+}
+
+// Lagging reports whether the server falls behind its tick rate
+func (s PerformanceSample) Lagging() bool {
+	if s.Warnings > 0 {
+		return true
+	}
+	return s.MSPT > 1000/targetTPS
+}
+
+// PerformanceService samples tick health with the best source the server offers and keeps a
+// rolling history in memory
+type PerformanceService struct {
+	rconClient   rcon.CommandExecutor
+	fileClient   PerformanceFileSystemAccessor
+	capabilities *CapabilityService
+	size         int
+	now          func() time.Time
+
+	mu      sync.Mutex
+	history []PerformanceSample
+	// logOffset is how far latest.log was read, -1 before the first read
+	logOffset int64
+}
+
+// NewPerformanceService creates a PerformanceService keeping the last size samples. fileClient
+// may be nil, in which case the log fallback is not available. capabilities may be nil, in which
+// case every command is tried in turn.
+func NewPerformanceService(rconClient rcon.CommandExecutor, fileClient PerformanceFileSystemAccessor, capabilities *CapabilityService, size int) *PerformanceService {
+	return &PerformanceService{
+		rconClient:   rconClient,
+		fileClient:   fileClient,
+		capabilities: capabilities,
+		size:         size,
+		now:          time.Now,
+		logOffset:    -1,
+	}
+}
+
+// parseDecimal parses numbers printed with either decimal separator
+func parseDecimal(value string) float64 {
+	f, _ := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	return f
+}
+
+// Sample measures tick health once and appends the result to the history
+func (s *PerformanceService) Sample() (PerformanceSample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// commands the detection did not find are skipped; before a detection every one is tried
+	var capabilities ServerCapabilities
+	if s.capabilities != nil {
+		capabilities = s.capabilities.Cached()
+	}
+	// fall back to the next source only when a command is unknown, not when RCON is down
+	var sample PerformanceSample
+	err := fmt.Errorf("performance commands are %w", errSourceUnavailable)
+	if capabilities.Supports(CapabilityTick) {
+		sample, err = s.sampleTickQuery()
+	}
+	if errors.Is(err, errSourceUnavailable) && capabilities.Supports(CapabilityTPS) {
+		sample, err = s.samplePaper()
+	}
+	if errors.Is(err, errSourceUnavailable) && s.fileClient != nil {
+		sample, err = s.sampleLogLocked()
+	}
+	if err != nil {
+		return PerformanceSample{}, err
+	}
+	sample.Time = s.now()
+	s.history = append(s.history, sample)
+	if extra := len(s.history) - s.size; extra > 0 {
+		s.history = append([]PerformanceSample(nil), s.history[extra:]...)
+	}
+	return sample, nil
+}
+
+// tickQuery is the parsed answer of "tick query"
+type tickQuery struct {
+	// Rate is the target tick rate
+	Rate float64
+	MSPT float64
+	P95  float64
+	// Status is "running normally", "lagging", "frozen" or "sprinting"
+	Status string
+}
+
+// parseTickQuery reads the answer of "tick query". RCON joins the lines of the answer, so every
+// value is matched on its own.
+func parseTickQuery(response string) (tickQuery, error) {
+	average := tickAveragePattern.FindStringSubmatch(response)
+	if average == nil {
+		return tickQuery{}, fmt.Errorf("tick query %w: %s", errSourceUnavailable, strings.TrimSpace(response))
+	}
+	query := tickQuery{Rate: targetTPS, MSPT: parseDecimal(average[1])}
+	if m := tickRatePattern.FindStringSubmatch(response); m != nil {
+		query.Rate = parseDecimal(m[1])
+	}
+	if m := tickPercentilesPattern.FindStringSubmatch(response); m != nil {
+		query.P95 = parseDecimal(m[2])
+	}
+	switch {
+	case strings.Contains(response, "is frozen"):
+		query.Status = "frozen"
+	case strings.Contains(response, "sprinting"):
+		query.Status = "sprinting"
+	case strings.Contains(response, "can't keep up"):
+		query.Status = "lagging"
+	default:
+		query.Status = "running normally"
+	}
+	return query, nil
+}
+
+func (s *PerformanceService) sampleTickQuery() (PerformanceSample, error) {
+	response, err := s.rconClient.ExecuteCommand("tick query")
+	if err != nil {
+		return PerformanceSample{}, err
+	}
+	query, err := parseTickQuery(response)
+	if err != nil {
+		return PerformanceSample{}, err
+	}
+	sample := PerformanceSample{
+		Source: PerformanceSourceTickQuery,
+		TPS:    query.Rate,
+		MSPT:   query.MSPT,
+		P95:    query.P95,
+		Status: query.Status,
+	}
+	if sample.MSPT > 0 {
+		sample.TPS = min(query.Rate, 1000/sample.MSPT)
+	}
+	return sample, nil
+}
+
+// samplePaper uses the 1 minute TPS of "tps" and the 5 second average of "mspt"
+func (s *PerformanceService) samplePaper() (PerformanceSample, error) {
+	response, err := s.rconClient.ExecuteCommand("tps")
+	if err != nil {
+		return PerformanceSample{}, err
+	}
+	response = formattingCodePattern.ReplaceAllString(response, "")
+	m := paperTPSPattern.FindStringSubmatch(response)
+	if m == nil {
+		return PerformanceSample{}, fmt.Errorf("tps %w: %s", errSourceUnavailable, strings.TrimSpace(response))
+	}
+	sample := PerformanceSample{Source: PerformanceSourcePaper, TPS: parseDecimal(m[1])}
+	// mspt only exists on Paper, Spigot answers tps alone
+	if response, err := s.rconClient.ExecuteCommand("mspt"); err == nil {
+		response = formattingCodePattern.ReplaceAllString(response, "")
+		if m := paperMSPTPattern.FindStringSubmatch(response); m != nil {
+			sample.MSPT = parseDecimal(m[1])
+		}
+	}
+	return sample, nil
+}
+
+// sampleLogLocked counts the "Can't keep up" warnings written to latest.log since the previous
+// read. The first read starts at the end of the log, a rotated log is read from the start.
+func (s *PerformanceService) sampleLogLocked() (PerformanceSample, error) {
+	path, err := s.fileClient.GetAbsolutePath(latestLogPath)
+	if err != nil {
+		return PerformanceSample{}, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return PerformanceSample{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return PerformanceSample{}, err
+	}
+
+	sample := PerformanceSample{Source: PerformanceSourceLog}
+	offset := s.logOffset
+	if offset < 0 {
+		offset = info.Size()
+	} else if offset > info.Size() {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return PerformanceSample{}, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return PerformanceSample{}, err
+	}
+	// leave a partly written last line for the next read
+	if i := strings.LastIndexByte(string(data), '\n'); i >= 0 {
+		data = data[:i+1]
+	} else {
+		data = nil
+	}
+	s.logOffset = offset + int64(len(data))
+
+	for _, m := range cantKeepUpPattern.FindAllStringSubmatch(string(data), -1) {
+		sample.Warnings++
+		if behind, err := strconv.Atoi(m[1]); err == nil {
+			sample.BehindMS = max(sample.BehindMS, behind)
+		}
+	}
+	return sample, nil
+}
+
+// History returns the samples oldest first
+func (s *PerformanceService) History() []PerformanceSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PerformanceSample(nil), s.history...)
+}
+
+// Latest returns the most recent sample, false before the first one
+func (s *PerformanceService) Latest() (PerformanceSample, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.history) == 0 {
+		return PerformanceSample{}, false
+	}
+	return s.history[len(s.history)-1], true
+}
+
+// StartSampling samples every interval until the process exits. Failed samples leave a gap in
+// the history; only the first failure in a row is logged.
+func (s *PerformanceService) StartSampling(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		failing := false
+		for ; ; <-ticker.C {
+			_, err := s.Sample()
+			if err != nil && !failing {
+				log.Printf("Performance sampling failed: %v", err)
+			}
+			failing = err != nil
+		}
+	}()
+}
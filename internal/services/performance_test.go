@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const unknownCommand = "Unknown or incomplete command, see below for error"
+
+func TestPerformanceService_Sample(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses map[string]struct {
+			out string
+			err error
+		}
+		wantSource string
+		wantTPS    float64
+		wantMSPT   float64
+		wantP95    float64
+		wantStatus string
+		wantErr    bool
+	}{
+		{
+			name: "tick query",
+			responses: map[string]struct {
+				out string
+				err error
+			}{
+				"tick query": {out: "The game is running normallyTarget tick rate: 20.0 per second.\nAverage time per tick: 12.5ms (Target: 50.0ms)Percentiles: P50: 11.2ms P95: 20.1ms P99: 31.0ms, sample: 100"},
+			},
+			wantSource: PerformanceSourceTickQuery,
+			wantTPS:    20,
+			wantMSPT:   12.5,
+			wantP95:    20.1,
+			wantStatus: "running normally",
+		},
+		{
+			name: "tick query lagging",
+			responses: map[string]struct {
+				out string
+				err error
+			}{
+				"tick query": {out: "The game is running, but can't keep up with the target tick rateTarget tick rate: 20.0 per second.\nAverage time per tick: 80.0ms (Target: 50.0ms)"},
+			},
+			wantSource: PerformanceSourceTickQuery,
+			wantTPS:    12.5,
+			wantMSPT:   80,
+			wantStatus: "lagging",
+		},
+		{
+			name: "paper",
+			responses: map[string]struct {
+				out string
+				err error
+			}{
+				"tick query": {out: unknownCommand},
+				"tps":        {out: "§6TPS from last 1m, 5m, 15m: §a*20.0, §a19.97, §a19.99"},
+				"mspt":       {out: "§6Server tick times §e(§7avg§e/§7min§e/§7max§e)§6 from last 5s§7,§6 10s§7,§6 1m§e:\n§6◴ §a3.1§7/§a1.2§7/§a9.8§7, §a3.0§7/§a1.1§7/§a9.8§7, §a2.9§7/§a1.0§7/§a12.4"},
+			},
+			wantSource: PerformanceSourcePaper,
+			wantTPS:    20,
+			wantMSPT:   3.1,
+		},
+		{
+			name: "spigot without mspt",
+			responses: map[string]struct {
+				out string
+				err error
+			}{
+				"tick query": {out: unknownCommand},
+				"tps":        {out: "TPS from last 1m, 5m, 15m: 18.5, 19.2, 19.8"},
+				"mspt":       {out: unknownCommand},
+			},
+			wantSource: PerformanceSourcePaper,
+			wantTPS:    18.5,
+		},
+		{
+			name: "server unreachable",
+			responses: map[string]struct {
+				out string
+				err error
+			}{
+				"tick query": {err: errors.New("connection refused")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "no source",
+			responses: map[string]struct {
+				out string
+				err error
+			}{
+				"tick query": {out: unknownCommand},
+				"tps":        {out: unknownCommand},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewPerformanceService(&fakeRconClient{responses: tt.responses}, nil, nil, 10)
+			sample, err := svc.Sample()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(svc.History()) != 0 {
+					t.Fatalf("failed sample added to history")
+				}
+				return
+			}
+			if sample.Source != tt.wantSource || sample.TPS != tt.wantTPS || sample.MSPT != tt.wantMSPT ||
+				sample.P95 != tt.wantP95 || sample.Status != tt.wantStatus {
+				t.Fatalf("sample = %+v", sample)
+			}
+		})
+	}
+}
+
+func TestPerformanceService_SampleLog(t *testing.T) {
+	dataDir := t.TempDir()
+	logPath := filepath.Join(dataDir, "logs", "latest.log")
+	os.MkdirAll(filepath.Dir(logPath), 0755)
+	os.WriteFile(logPath, []byte("[12:00:00] [Server thread/WARN]: Can't keep up! Is the server overloaded? Running 5000ms or 100 ticks behind\n"), 0644)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"tick query": {out: unknownCommand},
+		"tps":        {out: unknownCommand},
+	}}
+	svc := NewPerformanceService(rconClient, &fileClient, nil, 2)
+
+	// warnings written before the first sample are not counted
+	if sample, err := svc.Sample(); err != nil || sample.Warnings != 0 || sample.Source != PerformanceSourceLog {
+		t.Fatalf("first sample = %+v, %v", sample, err)
+	}
+
+	f, _ := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("[12:01:00] [Server thread/WARN]: Can't keep up! Is the server overloaded? Running 2345ms or 46 ticks behind\n")
+	f.WriteString("[12:01:05] [Server thread/INFO]: Steve joined the game\n")
+	f.WriteString("[12:01:10] [Server thread/WARN]: Can't keep up! Is the server overloaded? Running 3100ms or 62 ticks behind\n")
+	f.WriteString("[12:01:12] [Server thread/WARN]: Can't keep up! Is the server overloaded? Running 9999ms")
+	f.Close()
+	sample, err := svc.Sample()
+	if err != nil || sample.Warnings != 2 || sample.BehindMS != 3100 || !sample.Lagging() {
+		t.Fatalf("second sample = %+v, %v", sample, err)
+	}
+
+	// a rotated log is read from the start
+	os.WriteFile(logPath, []byte("[12:05:00] [Server thread/WARN]: Can't keep up! Is the server overloaded? Running 2100ms or 42 ticks behind\n"), 0644)
+	svc.now = func() time.Time { return time.Date(2025, 1, 1, 12, 5, 0, 0, time.UTC) }
+	if sample, err := svc.Sample(); err != nil || sample.Warnings != 1 {
+		t.Fatalf("rotated sample = %+v, %v", sample, err)
+	}
+
+	history := svc.History()
+	if len(history) != 2 || history[0].Warnings != 2 || !history[1].Time.Equal(svc.now()) {
+		t.Fatalf("history = %+v", history)
+	}
+}
+
+func TestPerformanceService_SampleUsesDetectedCommands(t *testing.T) {
+	paper := map[string]struct {
+		out string
+		err error
+	}{
+		"version": {out: "This server is running Paper version 1.21.4-232-main@4f3b2a1 (MC: 1.21.4)"},
+		"tps":     {out: "TPS from last 1m, 5m, 15m: 18.5, 19.2, 19.8"},
+		"mspt":    {out: "§6◴ §a3.1§7/§a1.2§7/§a9.8"},
+	}
+	capabilities := NewCapabilityService(&fakeRconClient{responses: paper}, nil, nil)
+	capabilities.Detect()
+
+	rconClient := &fakeRconClient{responses: paper}
+	svc := NewPerformanceService(rconClient, nil, capabilities, 10)
+	for i := 0; i < 2; i++ {
+		if sample, err := svc.Sample(); err != nil || sample.Source != PerformanceSourcePaper {
+			t.Fatalf("sample = %+v, %v", sample, err)
+		}
+	}
+	// tick query was not detected and is not probed on every sample
+	if want := []string{"tps", "mspt", "tps", "mspt"}; !slices.Equal(rconClient.received, want) {
+		t.Fatalf("commands = %v, want %v", rconClient.received, want)
+	}
+}
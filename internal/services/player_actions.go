@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// playerNamePattern matches Java player names and Bedrock names behind one of the Floodgate
+// prefixes accepted by bedrockPrefixPattern
+var playerNamePattern = regexp.MustCompile(`^[.*!~+-]{0,3}[A-Za-z0-9_]{1,16}$`)
+
+// GameModes are the modes accepted by "gamemode"
+var GameModes = []string{"survival", "creative", "adventure", "spectator"}
+
+// TeleportDimensions are the dimensions a player can be teleported to by coordinates
+var TeleportDimensions = []string{"overworld", "nether", "end"}
+
+// maxGiveCount is the most the give command hands out of stackable items (100 stacks of 64)
+const maxGiveCount = 6400
+
+func validatePlayerName(name string) error {
+	if !playerNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid player name %q", name)
+	}
+	return nil
+}
+
+// formatCoordinate prints a coordinate without trailing zeros
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// SetGameMode changes the game mode of a player. The server stays silent when the player
+// already is in that mode.
+func (s *ServerService) SetGameMode(player, mode string) error {
+	if err := validatePlayerName(player); err != nil {
+		return err
+	}
+	if !slices.Contains(GameModes, mode) {
+		return fmt.Errorf("unknown game mode %q", mode)
+	}
+	command := fmt.Sprintf("gamemode %s %s", mode, player)
+	response, err := s.rconClient.ExecuteCommand(command)
+	if err != nil {
+		return fmt.Errorf("failed to execute %q: %w", command, err)
+	}
+	if response = strings.TrimSpace(response); response != "" && !strings.HasPrefix(response, "Set ") {
+		return fmt.Errorf("%s", response)
+	}
+	return nil
+}
+
+// TeleportToCoordinates moves a player to a position in a dimension
+func (s *ServerService) TeleportToCoordinates(player, dimension string, x, y, z float64) error {
+	if err := validatePlayerName(player); err != nil {
+		return err
+	}
+	dimensionID, ok := dimensionIDs[dimension]
+	if !ok {
+		return fmt.Errorf("unknown dimension %q", dimension)
+	}
+	command := fmt.Sprintf("execute in %s run tp %s %s %s %s", dimensionID, player,
+		formatCoordinate(x), formatCoordinate(y), formatCoordinate(z))
+	return executeExpecting(s.rconClient, command, "Teleported ")
+}
+
+// TeleportToPlayer moves a player to another player
+func (s *ServerService) TeleportToPlayer(player, target string) error {
+	if err := validatePlayerName(player); err != nil {
+		return err
+	}
+	if err := validatePlayerName(target); err != nil {
+		return err
+	}
+	if player == target {
+		return fmt.Errorf("cannot teleport a player to themselves")
+	}
+	return executeExpecting(s.rconClient, fmt.Sprintf("tp %s %s", player, target), "Teleported ")
+}
+
+// TeleportToSpawn moves a player to the centre of the world spawn block read from level.dat
+func (s *ServerService) TeleportToSpawn(player string) error {
+	if err := validatePlayerName(player); err != nil {
+		return err
+	}
+	if s.fileClient == nil {
+		return fmt.Errorf("the world spawn is only known when MINECRAFT_DATA_DIR is set")
+	}
+	worldDir, err := s.fileClient.GetAbsolutePath(getLevelName(s.fileClient))
+	if err != nil {
+		return err
+	}
+	spawn, err := readWorldSpawn(worldDir)
+	if err != nil {
+		return fmt.Errorf("failed to read the world spawn: %w", err)
+	}
+	command := fmt.Sprintf("execute in %s run tp %s %s %d %s", spawn.Dimension, player,
+		formatCoordinate(float64(spawn.X)+0.5), spawn.Y, formatCoordinate(float64(spawn.Z)+0.5))
+	return executeExpecting(s.rconClient, command, "Teleported ")
+}
+
+// Heal restores a player's health with a strong instant health effect
+func (s *ServerService) Heal(player string) error {
+	if err := validatePlayerName(player); err != nil {
+		return err
+	}
+	return executeExpecting(s.rconClient, fmt.Sprintf("effect give %s minecraft:instant_health 1 4 true", player), "Applied effect")
+}
+
+// Feed fills a player's hunger and saturation with a short saturation effect
+func (s *ServerService) Feed(player string) error {
+	if err := validatePlayerName(player); err != nil {
+		return err
+	}
+	return executeExpecting(s.rconClient, fmt.Sprintf("effect give %s minecraft:saturation 1 9 true", player), "Applied effect")
+}
+
+// ClearEffects removes every effect from a player
+func (s *ServerService) ClearEffects(player string) error {
+	if err := validatePlayerName(player); err != nil {
+		return err
+	}
+	return executeExpecting(s.rconClient, "effect clear "+player, "Removed every effect")
+}
+
+// GiveItem gives a player count items of the given id, e.g. diamond or minecraft:oak_log
+func (s *ServerService) GiveItem(player, item string, count int) error {
+	if err := validatePlayerName(player); err != nil {
+		return err
+	}
+	if !resourceLocationPattern.MatchString(item) {
+		return fmt.Errorf("item must be an id like minecraft:diamond")
+	}
+	if count < 1 || count > maxGiveCount {
+		return fmt.Errorf("count must be between 1 and %d", maxGiveCount)
+	}
+	return executeExpecting(s.rconClient, fmt.Sprintf("give %s %s %d", player, item, count), "Gave ")
+}
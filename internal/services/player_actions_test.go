@@ -0,0 +1,147 @@
+package services
+
+import (
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerService_PlayerActions(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      func(svc *ServerService) error
+		wantCommand string
+		response    string
+		wantErr     bool
+	}{
+		{
+			name:        "game mode",
+			action:      func(svc *ServerService) error { return svc.SetGameMode("Steve", "creative") },
+			wantCommand: "gamemode creative Steve",
+			response:    "Set Steve's game mode to Creative Mode",
+		},
+		{
+			name:        "game mode unchanged",
+			action:      func(svc *ServerService) error { return svc.SetGameMode("Steve", "survival") },
+			wantCommand: "gamemode survival Steve",
+		},
+		{
+			name:        "game mode offline player",
+			action:      func(svc *ServerService) error { return svc.SetGameMode("Notch", "survival") },
+			wantCommand: "gamemode survival Notch",
+			response:    "No player was found",
+			wantErr:     true,
+		},
+		{
+			name:    "unknown game mode",
+			action:  func(svc *ServerService) error { return svc.SetGameMode("Steve", "hardcore") },
+			wantErr: true,
+		},
+		{
+			name:        "teleport to coordinates",
+			action:      func(svc *ServerService) error { return svc.TeleportToCoordinates("Steve", "nether", 10.5, 64, -3) },
+			wantCommand: "execute in minecraft:the_nether run tp Steve 10.5 64 -3",
+			response:    "Teleported Steve to 10.500000, 64.000000, -3.000000",
+		},
+		{
+			name:        "teleport to player",
+			action:      func(svc *ServerService) error { return svc.TeleportToPlayer("Steve", "Alex") },
+			wantCommand: "tp Steve Alex",
+			response:    "Teleported Steve to Alex",
+		},
+		{
+			name:    "teleport with selector",
+			action:  func(svc *ServerService) error { return svc.TeleportToPlayer("@a", "Alex") },
+			wantErr: true,
+		},
+		{
+			name:        "heal",
+			action:      func(svc *ServerService) error { return svc.Heal("Steve") },
+			wantCommand: "effect give Steve minecraft:instant_health 1 4 true",
+			response:    "Applied effect Instant Health to Steve",
+		},
+		{
+			name:        "feed",
+			action:      func(svc *ServerService) error { return svc.Feed("Steve") },
+			wantCommand: "effect give Steve minecraft:saturation 1 9 true",
+			response:    "Applied effect Saturation to Steve",
+		},
+		{
+			name:        "clear effects without effects",
+			action:      func(svc *ServerService) error { return svc.ClearEffects("Steve") },
+			wantCommand: "effect clear Steve",
+			response:    "Target has no effects to remove",
+			wantErr:     true,
+		},
+		{
+			name:        "give",
+			action:      func(svc *ServerService) error { return svc.GiveItem("Steve", "minecraft:diamond", 3) },
+			wantCommand: "give Steve minecraft:diamond 3",
+			response:    "Gave 3 [Diamond] to Steve",
+		},
+		{
+			name:        "give unknown item",
+			action:      func(svc *ServerService) error { return svc.GiveItem("Steve", "minecraft:dimond", 1) },
+			wantCommand: "give Steve minecraft:dimond 1",
+			response:    "Unknown item 'minecraft:dimond'",
+			wantErr:     true,
+		},
+		{
+			name:    "give with components",
+			action:  func(svc *ServerService) error { return svc.GiveItem("Steve", "diamond_sword[damage=5]", 1) },
+			wantErr: true,
+		},
+		{
+			name:    "give too many",
+			action:  func(svc *ServerService) error { return svc.GiveItem("Steve", "dirt", 6401) },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRconClient{responses: map[string]struct {
+				out string
+				err error
+			}{
+				tt.wantCommand: {out: tt.response},
+			}}
+			svc := NewServerServiceFromRconClient(fake)
+
+			err := tt.action(svc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantCommand == "" {
+				if len(fake.received) != 0 {
+					t.Fatalf("invalid input sent %v", fake.received)
+				}
+				return
+			}
+			if len(fake.received) != 1 || fake.received[0] != tt.wantCommand {
+				t.Fatalf("ExecuteCommand called with %v, want %q", fake.received, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestServerService_TeleportToSpawn(t *testing.T) {
+	dataDir := t.TempDir()
+	os.MkdirAll(filepath.Join(dataDir, "world"), 0755)
+	writeLevelDat(t, filepath.Join(dataDir, "world", "level.dat"), 100, -20, 3955)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	fake := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"execute in minecraft:overworld run tp Steve 100.5 0 -19.5": {out: "Teleported Steve to 100.500000, 0.000000, -19.500000"},
+	}}
+
+	if err := NewServerService(fake, &fileClient).TeleportToSpawn("Steve"); err != nil {
+		t.Fatalf("TeleportToSpawn: %v", err)
+	}
+	if err := NewServerServiceFromRconClient(fake).TeleportToSpawn("Steve"); err == nil {
+		t.Fatalf("expected error without world files")
+	}
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The metadata files inside plugin and mod jars are YAML (plugin.yml), JSON (fabric.mod.json)
+// and TOML (mods.toml). The parsers below cover the subset those files use in practice: block
+// mappings and lists, flow lists, quoted scalars and TOML tables with simple values.
+
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseSimpleYAML parses a YAML document into nested map[string]any, []any and string values
+func parseSimpleYAML(text string) map[string]any {
+	p := &yamlParser{}
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		unindented := strings.TrimLeft(raw, " ")
+		p.lines = append(p.lines, yamlLine{indent: len(raw) - len(unindented), text: strings.TrimRight(unindented, " \t")})
+	}
+	if len(p.lines) == 0 {
+		return map[string]any{}
+	}
+	if m, ok := p.parseBlock().(map[string]any); ok {
+		return m
+	}
+	return map[string]any{}
+}
+
+// parseBlock parses the list or mapping starting at the current line
+func (p *yamlParser) parseBlock() any {
+	line := p.lines[p.pos]
+	if isYAMLListItem(line.text) {
+		return p.parseList(line.indent)
+	}
+	return p.parseMapping(line.indent)
+}
+
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseList(indent int) []any {
+	items := []any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLListItem(p.lines[p.pos].text) {
+		after := strings.TrimPrefix(p.lines[p.pos].text, "-")
+		rest := strings.TrimLeft(after, " ")
+		if rest == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				items = append(items, p.parseBlock())
+			} else {
+				items = append(items, "")
+			}
+			continue
+		}
+		if _, _, ok := splitYAMLKey(rest); ok {
+			// "- name: Vault" starts a mapping whose keys line up with name
+			p.lines[p.pos] = yamlLine{indent: indent + 1 + len(after) - len(rest), text: rest}
+			items = append(items, p.parseMapping(p.lines[p.pos].indent))
+			continue
+		}
+		items = append(items, yamlScalar(rest))
+		p.pos++
+	}
+	return items
+}
+
+func (p *yamlParser) parseMapping(indent int) map[string]any {
+	m := map[string]any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent >= indent {
+		line := p.lines[p.pos]
+		p.pos++
+		key, value, ok := splitYAMLKey(line.text)
+		// lines indented deeper than their mapping or without a key are skipped
+		if line.indent > indent || !ok || isYAMLListItem(line.text) {
+			continue
+		}
+		switch {
+		case value == "":
+			if p.pos < len(p.lines) && (p.lines[p.pos].indent > indent ||
+				(p.lines[p.pos].indent == indent && isYAMLListItem(p.lines[p.pos].text))) {
+				m[key] = p.parseBlock()
+			} else {
+				m[key] = ""
+			}
+		case value[0] == '|' || value[0] == '>':
+			var parts []string
+			for p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				parts = append(parts, p.lines[p.pos].text)
+				p.pos++
+			}
+			separator := "\n"
+			if value[0] == '>' {
+				separator = " "
+			}
+			m[key] = strings.Join(parts, separator)
+		case value[0] == '[':
+			m[key] = parseFlowList(value, yamlScalar)
+		case value[0] == '{':
+			m[key] = map[string]any{}
+		default:
+			m[key] = yamlScalar(value)
+		}
+	}
+	return m
+}
+
+// splitYAMLKey splits "key: value" and "key:", the key may be quoted
+func splitYAMLKey(text string) (string, string, bool) {
+	start := 0
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		start = end + 2
+	}
+	idx := strings.Index(text[start:], ": ")
+	if idx < 0 {
+		if !strings.HasSuffix(text, ":") || len(text) <= start {
+			return "", "", false
+		}
+		idx = len(text) - 1 - start
+	}
+	idx += start
+	if idx == 0 {
+		return "", "", false
+	}
+	return yamlScalar(text[:idx]), strings.TrimSpace(text[idx+1:]), true
+}
+
+// yamlScalar unquotes a scalar and drops a trailing comment
+func yamlScalar(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	switch value[0] {
+	case '"':
+		if end := closingQuote(value); end > 0 {
+			if unquoted, err := strconv.Unquote(value[:end+1]); err == nil {
+				return unquoted
+			}
+			return value[1:end]
+		}
+	case '\'':
+		if end := strings.LastIndexByte(value, '\''); end > 0 {
+			return strings.ReplaceAll(value[1:end], "''", "'")
+		}
+	}
+	if idx := strings.Index(value, " #"); idx >= 0 {
+		value = strings.TrimSpace(value[:idx])
+	}
+	return value
+}
+
+// closingQuote returns the index of the quote ending a double-quoted string, or -1
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseFlowList parses "[a, 'b', c]" with the given scalar parser
+func parseFlowList(value string, scalar func(string) string) []any {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, "[")
+	if end := strings.LastIndexByte(value, ']'); end >= 0 {
+		value = value[:end]
+	}
+	items := []any{}
+	for _, item := range strings.Split(value, ",") {
+		if item = scalar(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// yamlString returns a scalar value, or "" for lists, mappings and missing keys
+func yamlString(value any) string {
+	s, _ := value.(string)
+	return s
+}
+
+// yamlStrings returns the scalars of a list, or a single scalar as a one element list
+func yamlStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// tomlTable is a [table] or [[array of tables]] entry; the root table has an empty name
+type tomlTable struct {
+	name   string
+	values map[string]any
+}
+
+// parseTOMLTables returns the tables of a TOML document in order, values are string or []any
+func parseTOMLTables(text string) []tomlTable {
+	tables := []tomlTable{{values: map[string]any{}}}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			if end := strings.LastIndexByte(line, ']'); end > 0 {
+				line = line[:end]
+			}
+			tables = append(tables, tomlTable{name: strings.TrimSpace(strings.Trim(line, "[]")), values: map[string]any{}})
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = yamlScalar(key)
+		value = strings.TrimSpace(value)
+		// multi-line strings and arrays continue on the following lines
+		for _, delimiter := range []string{`"""`, `'''`} {
+			if strings.HasPrefix(value, delimiter) {
+				for !strings.Contains(value[3:], delimiter) && i+1 < len(lines) {
+					i++
+					value += "\n" + lines[i]
+				}
+			}
+		}
+		if strings.HasPrefix(value, "[") {
+			for !strings.Contains(value, "]") && i+1 < len(lines) {
+				i++
+				value += " " + strings.TrimSpace(lines[i])
+			}
+		}
+		tables[len(tables)-1].values[key] = tomlValue(value)
+	}
+	return tables
+}
+
+func tomlValue(value string) any {
+	for _, delimiter := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(value, delimiter) {
+			value = strings.TrimPrefix(value, delimiter)
+			if end := strings.Index(value, delimiter); end >= 0 {
+				value = value[:end]
+			}
+			return strings.TrimSpace(value)
+		}
+	}
+	if strings.HasPrefix(value, "[") {
+		return parseFlowList(value, yamlScalar)
+	}
+	// TOML comments start with #, not " #" like in YAML
+	if value != "" && value[0] != '"' && value[0] != '\'' {
+		if idx := strings.IndexByte(value, '#'); idx >= 0 {
+			value = value[:idx]
+		}
+	}
+	return yamlScalar(value)
+}
@@ -0,0 +1,409 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mc-admin/internal/clients/modrinth"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownGameVersion is returned when updates are checked before the server version is known
+	ErrUnknownGameVersion = errors.New("the Minecraft version of the server is unknown, updates cannot be checked for compatibility")
+	errChecksumMismatch   = errors.New("checksum mismatch")
+)
+
+// ModrinthClient is the subset of the Modrinth client used by PluginUpdateService
+type ModrinthClient interface {
+	VersionsFromHashes(ctx context.Context, hashes []string, algorithm string) (map[string]modrinth.Version, error)
+	LatestVersionsFromHashes(ctx context.Context, hashes []string, algorithm string, loaders, gameVersions []string) (map[string]modrinth.Version, error)
+	Download(ctx context.Context, file modrinth.File, w io.Writer) error
+}
+
+// PluginUpdateFileSystemAccessor is the subset of the files client used by PluginUpdateService
+type PluginUpdateFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	Exists(path string) (bool, error)
+	Rename(oldPath, newPath string) error
+	Delete(path string) error
+}
+
+// PluginUpdate is the Modrinth state of an installed jar
+type PluginUpdate struct {
+	Plugin Plugin
+	SHA1   string
+	SHA512 string
+	// Current is the version the jar belongs to, nil when Modrinth does not know the file
+	Current *modrinth.Version
+	// Latest is the newest version for the server's loader and Minecraft version
+	Latest *modrinth.Version
+}
+
+// Available reports whether a newer compatible version exists
+func (u PluginUpdate) Available() bool {
+	return u.Current != nil && u.Latest != nil && u.Latest.ID != u.Current.ID
+}
+
+// ProjectURL links the Modrinth project page
+func (u PluginUpdate) ProjectURL() string {
+	if u.Current == nil {
+		return ""
+	}
+	return "https://modrinth.com/project/" + u.Current.ProjectID
+}
+
+// PluginUpdateReport is the result of an update check
+type PluginUpdateReport struct {
+	Updates     []PluginUpdate
+	GameVersion string
+	Software    string
+	CheckedAt   time.Time
+}
+
+// AvailableCount returns the number of jars with an update
+func (r PluginUpdateReport) AvailableCount() int {
+	count := 0
+	for _, update := range r.Updates {
+		if update.Available() {
+			count++
+		}
+	}
+	return count
+}
+
+// StagedUpdate is a downloaded update; the jar it replaces is kept disabled for a rollback
+type StagedUpdate struct {
+	Directory     string    `json:"directory"`
+	FileName      string    `json:"file_name"`
+	Replaced      string    `json:"replaced"`
+	VersionNumber string    `json:"version_number"`
+	StagedAt      time.Time `json:"staged_at"`
+}
+
+// pluginUpdateState is the persisted state of PluginUpdateService
+type pluginUpdateState struct {
+	Staged []StagedUpdate `json:"staged"`
+}
+
+// PluginUpdateService checks the installed jars for updates on Modrinth and stages them. The
+// last report is kept in memory, staged updates are persisted so they can be rolled back after
+// a restart.
+type PluginUpdateService struct {
+	pluginService     *PluginService
+	fileClient        PluginUpdateFileSystemAccessor
+	modrinthClient    ModrinthClient
+	capabilityService *CapabilityService
+	stateFile         jsonStateFile
+	now               func() time.Time
+
+	mu     sync.Mutex
+	report *PluginUpdateReport
+	state  *pluginUpdateState
+}
+
+// NewPluginUpdateService creates a PluginUpdateService persisting staged updates to statePath
+func NewPluginUpdateService(pluginService *PluginService, fileClient PluginUpdateFileSystemAccessor, modrinthClient ModrinthClient, capabilityService *CapabilityService, statePath string) *PluginUpdateService {
+	return &PluginUpdateService{
+		pluginService:     pluginService,
+		fileClient:        fileClient,
+		modrinthClient:    modrinthClient,
+		capabilityService: capabilityService,
+		stateFile:         jsonStateFile{path: statePath},
+		now:               time.Now,
+	}
+}
+
+// loadLocked reads the staged updates once
+func (s *PluginUpdateService) loadLocked() error {
+	if s.state != nil {
+		return nil
+	}
+	state := &pluginUpdateState{}
+	if err := s.stateFile.load(state); err != nil {
+		return err
+	}
+	s.state = state
+	return nil
+}
+
+func (s *PluginUpdateService) saveLocked() error {
+	return s.stateFile.save(s.state)
+}
+
+// LastReport returns the result of the last update check
+func (s *PluginUpdateService) LastReport() (PluginUpdateReport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.report == nil {
+		return PluginUpdateReport{}, false
+	}
+	return *s.report, true
+}
+
+// Staged returns the staged updates that can be rolled back
+func (s *PluginUpdateService) Staged() ([]StagedUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.state.Staged), nil
+}
+
+// updateLoaders returns the Modrinth loaders a jar may be replaced with. Plugin servers run
+// the plugins of the platforms they are built on.
+func updateLoaders(software string, plugin Plugin) []string {
+	if plugin.Directory == "plugins" {
+		switch software {
+		case SoftwarePurpur:
+			return []string{"purpur", "paper", "spigot", "bukkit"}
+		case SoftwareSpigot:
+			return []string{"spigot", "bukkit"}
+		}
+		return []string{"paper", "spigot", "bukkit"}
+	}
+	switch software {
+	case SoftwareFabric, SoftwareForge, SoftwareNeoForge:
+		return []string{strings.ToLower(software)}
+	}
+	if plugin.Kind != "" {
+		return []string{strings.ToLower(plugin.Kind)}
+	}
+	return nil
+}
+
+// hashJar returns the SHA-1 and SHA-512 of a file, the hashes Modrinth indexes files by
+func hashJar(fullPath string) (string, string, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	sum1, sum512 := sha1.New(), sha512.New()
+	if _, err := io.Copy(io.MultiWriter(sum1, sum512), f); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(sum1.Sum(nil)), hex.EncodeToString(sum512.Sum(nil)), nil
+}
+
+// CheckUpdates hashes the enabled jars and looks up their versions and the newest versions
+// compatible with the server
+func (s *PluginUpdateService) CheckUpdates(ctx context.Context) (PluginUpdateReport, error) {
+	capabilities := s.capabilityService.GetCapabilities()
+	if capabilities.Version == "" {
+		return PluginUpdateReport{}, ErrUnknownGameVersion
+	}
+	inventory, err := s.pluginService.GetInventory()
+	if err != nil {
+		return PluginUpdateReport{}, err
+	}
+
+	report := PluginUpdateReport{GameVersion: capabilities.Version, Software: capabilities.Software}
+	var hashes []string
+	for _, plugin := range inventory.Plugins {
+		if plugin.Disabled {
+			continue
+		}
+		fullPath, err := s.fileClient.GetAbsolutePath(path.Join(plugin.Directory, plugin.FileName))
+		if err != nil {
+			return PluginUpdateReport{}, err
+		}
+		sum1, sum512, err := hashJar(fullPath)
+		if err != nil {
+			return PluginUpdateReport{}, fmt.Errorf("failed to hash %s: %w", plugin.FileName, err)
+		}
+		report.Updates = append(report.Updates, PluginUpdate{Plugin: plugin, SHA1: sum1, SHA512: sum512})
+		hashes = append(hashes, sum512)
+	}
+	if len(hashes) == 0 {
+		return s.storeReport(report), nil
+	}
+
+	current, err := s.modrinthClient.VersionsFromHashes(ctx, hashes, modrinth.AlgorithmSHA512)
+	if err != nil {
+		return PluginUpdateReport{}, err
+	}
+	// the update endpoint filters by one loader list per request
+	groups := map[string][]string{}
+	groupLoaders := map[string][]string{}
+	for i := range report.Updates {
+		update := &report.Updates[i]
+		version, ok := current[update.SHA512]
+		if !ok {
+			continue
+		}
+		update.Current = &version
+		loaders := updateLoaders(capabilities.Software, update.Plugin)
+		if len(loaders) == 0 {
+			continue
+		}
+		key := strings.Join(loaders, ",")
+		groups[key] = append(groups[key], update.SHA512)
+		groupLoaders[key] = loaders
+	}
+	latest := map[string]modrinth.Version{}
+	for key, groupHashes := range groups {
+		versions, err := s.modrinthClient.LatestVersionsFromHashes(ctx, groupHashes, modrinth.AlgorithmSHA512, groupLoaders[key], []string{capabilities.Version})
+		if err != nil {
+			return PluginUpdateReport{}, err
+		}
+		for hash, version := range versions {
+			latest[hash] = version
+		}
+	}
+	for i := range report.Updates {
+		if version, ok := latest[report.Updates[i].SHA512]; ok && report.Updates[i].Current != nil {
+			report.Updates[i].Latest = &version
+		}
+	}
+	return s.storeReport(report), nil
+}
+
+func (s *PluginUpdateService) storeReport(report PluginUpdateReport) PluginUpdateReport {
+	report.CheckedAt = s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = &report
+	return report
+}
+
+// StageUpdate downloads the newest version of a jar from the last report next to it and
+// disables the old jar, so the update is loaded on the next server start
+func (s *PluginUpdateService) StageUpdate(ctx context.Context, directory, fileName string) (StagedUpdate, error) {
+	// the lock is not held during the download, so pages keep rendering
+	s.mu.Lock()
+	if err := s.loadLocked(); err != nil {
+		s.mu.Unlock()
+		return StagedUpdate{}, err
+	}
+	var update *PluginUpdate
+	if s.report != nil {
+		for _, candidate := range s.report.Updates {
+			if candidate.Plugin.Directory == directory && candidate.Plugin.FileName == fileName && candidate.Available() {
+				update = &candidate
+			}
+		}
+	}
+	s.mu.Unlock()
+	if update == nil {
+		return StagedUpdate{}, fmt.Errorf("no update known for %s, check for updates first", fileName)
+	}
+	file, ok := update.Latest.PrimaryFile()
+	if !ok {
+		return StagedUpdate{}, fmt.Errorf("version %s has no files", update.Latest.VersionNumber)
+	}
+	if file.Hashes[modrinth.AlgorithmSHA512] == "" && file.Hashes[modrinth.AlgorithmSHA1] == "" {
+		return StagedUpdate{}, fmt.Errorf("version %s lists no checksum for %s, it cannot be verified", update.Latest.VersionNumber, file.Filename)
+	}
+	newName := filepath.Base(file.Filename)
+	if !strings.HasSuffix(strings.ToLower(newName), ".jar") || strings.HasPrefix(newName, ".") {
+		return StagedUpdate{}, fmt.Errorf("unexpected update file name %q", file.Filename)
+	}
+	oldPath, disabledPath, newPath := path.Join(directory, fileName), path.Join(directory, fileName+disabledJarSuffix), path.Join(directory, newName)
+	for _, target := range []string{disabledPath, newPath} {
+		if target == oldPath {
+			continue
+		}
+		if exists, err := s.fileClient.Exists(target); err != nil {
+			return StagedUpdate{}, err
+		} else if exists {
+			return StagedUpdate{}, fmt.Errorf("%s already exists", target)
+		}
+	}
+
+	dir, err := s.fileClient.GetAbsolutePath(directory)
+	if err != nil {
+		return StagedUpdate{}, err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return StagedUpdate{}, err
+	}
+	defer os.Remove(tmp.Name())
+	sum1, sum512 := sha1.New(), sha512.New()
+	err = s.modrinthClient.Download(ctx, file, io.MultiWriter(tmp, sum1, sum512))
+	if err == nil {
+		// CreateTemp makes the file private, the server may run as another user
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return StagedUpdate{}, fmt.Errorf("failed to download %s: %w", newName, err)
+	}
+	if want := file.Hashes[modrinth.AlgorithmSHA512]; want != "" && want != hex.EncodeToString(sum512.Sum(nil)) {
+		return StagedUpdate{}, fmt.Errorf("download of %s: %w", newName, errChecksumMismatch)
+	}
+	if want := file.Hashes[modrinth.AlgorithmSHA1]; want != "" && want != hex.EncodeToString(sum1.Sum(nil)) {
+		return StagedUpdate{}, fmt.Errorf("download of %s: %w", newName, errChecksumMismatch)
+	}
+
+	if err := s.fileClient.Rename(oldPath, disabledPath); err != nil {
+		return StagedUpdate{}, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, newName)); err != nil {
+		s.fileClient.Rename(disabledPath, oldPath)
+		return StagedUpdate{}, fmt.Errorf("failed to store %s: %w", newName, err)
+	}
+
+	staged := StagedUpdate{
+		Directory:     directory,
+		FileName:      newName,
+		Replaced:      fileName,
+		VersionNumber: update.Latest.VersionNumber,
+		StagedAt:      s.now(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Staged = append(s.state.Staged, staged)
+	// the old jar is disabled now, its update is no longer pending
+	var updates []PluginUpdate
+	for _, u := range s.report.Updates {
+		if u.Plugin.Directory != directory || u.Plugin.FileName != fileName {
+			updates = append(updates, u)
+		}
+	}
+	s.report.Updates = updates
+	return staged, s.saveLocked()
+}
+
+// Rollback deletes a staged update and enables the jar it replaced
+func (s *PluginUpdateService) Rollback(directory, fileName string) (StagedUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return StagedUpdate{}, err
+	}
+	for i, staged := range s.state.Staged {
+		if staged.Directory != directory || staged.FileName != fileName {
+			continue
+		}
+		disabledPath := path.Join(directory, staged.Replaced+disabledJarSuffix)
+		if exists, err := s.fileClient.Exists(disabledPath); err != nil {
+			return StagedUpdate{}, err
+		} else if !exists {
+			return StagedUpdate{}, fmt.Errorf("%s no longer exists", disabledPath)
+		}
+		if err := s.fileClient.Delete(path.Join(directory, staged.FileName)); err != nil {
+			return StagedUpdate{}, err
+		}
+		if err := s.fileClient.Rename(disabledPath, path.Join(directory, staged.Replaced)); err != nil {
+			return StagedUpdate{}, err
+		}
+		s.state.Staged = slices.Delete(s.state.Staged, i, i+1)
+		return staged, s.saveLocked()
+	}
+	return StagedUpdate{}, fmt.Errorf("%s is not a staged update", fileName)
+}
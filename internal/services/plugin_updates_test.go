@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"mc-admin/internal/clients/files"
+	"mc-admin/internal/clients/modrinth"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeModrinth is a local stand-in for the Modrinth API that knows one version per hash
+type fakeModrinth struct {
+	current  map[string]modrinth.Version
+	latest   map[string]modrinth.Version
+	files    map[string][]byte
+	requests []map[string]any
+}
+
+func (f *fakeModrinth) start(t *testing.T) (*httptest.Server, *modrinth.Client) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content, ok := f.files[r.URL.Path]; ok {
+			w.Write(content)
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		f.requests = append(f.requests, body)
+		versions := f.current
+		if r.URL.Path == "/version_files/update" {
+			versions = f.latest
+		}
+		result := map[string]modrinth.Version{}
+		for _, hash := range body["hashes"].([]any) {
+			if version, ok := versions[hash.(string)]; ok {
+				result[hash.(string)] = version
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(srv.Close)
+	client, err := modrinth.NewClient(modrinth.Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, client
+}
+
+func sha512Hex(data []byte) string {
+	sum := sha512.Sum512(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// paperCapabilities returns a capability service detecting Paper 1.21.4
+func paperCapabilities() *CapabilityService {
+	return NewCapabilityService(&fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"version": {out: "This server is running Paper version 1.21.4-232-main@4f3b2a1 (MC: 1.21.4)"},
+	}}, nil, nil)
+}
+
+func TestPluginUpdateService_CheckAndStage(t *testing.T) {
+	dataDir := t.TempDir()
+	writeJar(t, filepath.Join(dataDir, "plugins", "Shop-1.0.jar"), map[string]string{"plugin.yml": "name: Shop\nversion: 1.0\n"})
+	writeJar(t, filepath.Join(dataDir, "plugins", "Private.jar"), map[string]string{"plugin.yml": "name: Private\n"})
+	writeJar(t, filepath.Join(dataDir, "plugins", "Old.jar.disabled"), map[string]string{"plugin.yml": "name: Old\n"})
+	shopJar, _ := os.ReadFile(filepath.Join(dataDir, "plugins", "Shop-1.0.jar"))
+	shopHash := sha512Hex(shopJar)
+	update := jarBytes(t, map[string]string{"plugin.yml": "name: Shop\nversion: 2.0\n"})
+
+	fake := &fakeModrinth{files: map[string][]byte{"/cdn/Shop-2.0.jar": update}}
+	srv, client := fake.start(t)
+	fake.current = map[string]modrinth.Version{shopHash: {ID: "v1", ProjectID: "shop", VersionNumber: "1.0"}}
+	fake.latest = map[string]modrinth.Version{shopHash: {ID: "v2", ProjectID: "shop", VersionNumber: "2.0", Files: []modrinth.File{{
+		Filename: "Shop-2.0.jar",
+		URL:      srv.URL + "/cdn/Shop-2.0.jar",
+		Primary:  true,
+		Hashes:   map[string]string{"sha512": sha512Hex(update)},
+	}}}}
+
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	statePath := filepath.Join(t.TempDir(), "plugin_updates.json")
+	svc := NewPluginUpdateService(NewPluginService(&fileClient), &fileClient, client, paperCapabilities(), statePath)
+
+	report, err := svc.CheckUpdates(context.Background())
+	if err != nil {
+		t.Fatalf("CheckUpdates: %v", err)
+	}
+	if report.GameVersion != "1.21.4" || len(report.Updates) != 2 || report.AvailableCount() != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if latest := fake.requests[1]; !slices.Equal(latest["loaders"].([]any), []any{"paper", "spigot", "bukkit"}) ||
+		!slices.Equal(latest["game_versions"].([]any), []any{"1.21.4"}) || len(latest["hashes"].([]any)) != 1 {
+		t.Fatalf("update request = %v", latest)
+	}
+	for _, u := range report.Updates {
+		if u.Plugin.FileName == "Private.jar" && (u.Current != nil || u.Available()) {
+			t.Fatalf("unknown jar = %+v", u)
+		}
+		if u.Plugin.FileName == "Shop-1.0.jar" && (!u.Available() || u.ProjectURL() != "https://modrinth.com/project/shop") {
+			t.Fatalf("shop update = %+v", u)
+		}
+	}
+
+	staged, err := svc.StageUpdate(context.Background(), "plugins", "Shop-1.0.jar")
+	if err != nil {
+		t.Fatalf("StageUpdate: %v", err)
+	}
+	if staged.FileName != "Shop-2.0.jar" || staged.Replaced != "Shop-1.0.jar" || staged.VersionNumber != "2.0" {
+		t.Fatalf("staged = %+v", staged)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dataDir, "plugins", "Shop-2.0.jar")); string(got) != string(update) {
+		t.Fatalf("staged jar has wrong contents")
+	}
+	// the server may run as another user and must be able to load the jar
+	if info, err := os.Stat(filepath.Join(dataDir, "plugins", "Shop-2.0.jar")); err != nil || info.Mode().Perm() != 0644 {
+		t.Fatalf("staged jar mode = %v, %v", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "plugins", "Shop-1.0.jar.disabled")); err != nil {
+		t.Fatalf("old jar not kept: %v", err)
+	}
+	if report, _ := svc.LastReport(); report.AvailableCount() != 0 {
+		t.Fatalf("report after staging = %+v", report)
+	}
+	if _, err := svc.StageUpdate(context.Background(), "plugins", "Shop-1.0.jar"); err == nil {
+		t.Fatalf("staging twice succeeded")
+	}
+
+	// Staged updates can still be rolled back after a restart
+	svc = NewPluginUpdateService(NewPluginService(&fileClient), &fileClient, client, paperCapabilities(), statePath)
+	if staged, err := svc.Staged(); err != nil || len(staged) != 1 || staged[0].FileName != "Shop-2.0.jar" {
+		t.Fatalf("staged after restart = %+v, %v", staged, err)
+	}
+	if _, err := svc.Rollback("plugins", "Shop-2.0.jar"); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "plugins", "Shop-2.0.jar")); !os.IsNotExist(err) {
+		t.Fatalf("staged jar not removed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dataDir, "plugins", "Shop-1.0.jar")); string(got) != string(shopJar) {
+		t.Fatalf("old jar not restored")
+	}
+	if _, err := svc.Rollback("plugins", "Shop-2.0.jar"); err == nil {
+		t.Fatalf("rolling back twice succeeded")
+	}
+	if staged, _ := svc.Staged(); len(staged) != 0 {
+		t.Fatalf("staged after rollback = %+v", staged)
+	}
+}
+
+func TestPluginUpdateService_StageRejectsChecksumMismatch(t *testing.T) {
+	dataDir := t.TempDir()
+	writeJar(t, filepath.Join(dataDir, "mods", "sodium.jar"), map[string]string{"fabric.mod.json": `{"id": "sodium"}`})
+	jar, _ := os.ReadFile(filepath.Join(dataDir, "mods", "sodium.jar"))
+
+	fake := &fakeModrinth{files: map[string][]byte{"/cdn/sodium-2.jar": []byte("tampered")}}
+	srv, client := fake.start(t)
+	fake.current = map[string]modrinth.Version{sha512Hex(jar): {ID: "v1"}}
+	fake.latest = map[string]modrinth.Version{sha512Hex(jar): {ID: "v2", Files: []modrinth.File{{
+		Filename: "sodium-2.jar",
+		URL:      srv.URL + "/cdn/sodium-2.jar",
+		Hashes:   map[string]string{"sha1": "0000"},
+	}}}}
+
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	svc := NewPluginUpdateService(NewPluginService(&fileClient), &fileClient, client, paperCapabilities(), filepath.Join(t.TempDir(), "plugin_updates.json"))
+	if _, err := svc.CheckUpdates(context.Background()); err != nil {
+		t.Fatalf("CheckUpdates: %v", err)
+	}
+	// Paper has no mod loader, the jar's own loader is used
+	if loaders := fake.requests[1]["loaders"].([]any); !slices.Equal(loaders, []any{"fabric"}) {
+		t.Fatalf("loaders = %v", loaders)
+	}
+	if _, err := svc.StageUpdate(context.Background(), "mods", "sodium.jar"); !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("StageUpdate err = %v, want a checksum mismatch", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dataDir, "mods"))
+	if len(entries) != 1 || entries[0].Name() != "sodium.jar" {
+		t.Fatalf("mods after a failed download = %v", entries)
+	}
+
+	// a file without any checksum cannot be verified and is not downloaded
+	fake.latest[sha512Hex(jar)].Files[0].Hashes = nil
+	if _, err := svc.CheckUpdates(context.Background()); err != nil {
+		t.Fatalf("CheckUpdates: %v", err)
+	}
+	if _, err := svc.StageUpdate(context.Background(), "mods", "sodium.jar"); err == nil || !strings.Contains(err.Error(), "cannot be verified") {
+		t.Fatalf("StageUpdate without checksums err = %v", err)
+	}
+}
+
+func TestPluginUpdateService_CheckNeedsGameVersion(t *testing.T) {
+	capabilities := NewCapabilityService(&fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{"version": {err: errors.New("connection refused")}}}, nil, nil)
+	fileClient := files.NewMinecraftFilesClient(t.TempDir(), 0)
+	svc := NewPluginUpdateService(NewPluginService(&fileClient), &fileClient, nil, capabilities, filepath.Join(t.TempDir(), "plugin_updates.json"))
+	if _, err := svc.CheckUpdates(context.Background()); !errors.Is(err, ErrUnknownGameVersion) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := svc.StageUpdate(context.Background(), "plugins", "x.jar"); err == nil || !strings.Contains(err.Error(), "check for updates first") {
+		t.Fatalf("StageUpdate err = %v", err)
+	}
+}
@@ -0,0 +1,556 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Loaders a jar in plugins/ or mods/ is written for
+const (
+	PluginKindBukkit   = "Bukkit"
+	PluginKindPaper    = "Paper"
+	PluginKindFabric   = "Fabric"
+	PluginKindForge    = "Forge"
+	PluginKindNeoForge = "NeoForge"
+)
+
+const (
+	// disabledJarSuffix keeps the server from loading a jar, the convention mod launchers use
+	disabledJarSuffix = ".disabled"
+	// maxNestedJarSize bounds the jar-in-jar libraries read into memory
+	maxNestedJarSize = 64 << 20
+)
+
+// PluginDirectories are the data directory folders scanned for jars
+var PluginDirectories = []string{"plugins", "mods"}
+
+// loaderDependencies are provided by the server or loader and never installed as a jar
+var loaderDependencies = map[string]bool{
+	"minecraft":    true,
+	"java":         true,
+	"fabricloader": true,
+	"forge":        true,
+	"neoforge":     true,
+}
+
+// metadataFiles are read in order, so a jar built for several loaders is listed under the
+// loader of its folder
+var metadataFiles = map[string][]string{
+	"plugins": {"paper-plugin.yml", "plugin.yml", "fabric.mod.json", "META-INF/neoforge.mods.toml", "META-INF/mods.toml"},
+	"mods":    {"fabric.mod.json", "META-INF/neoforge.mods.toml", "META-INF/mods.toml", "paper-plugin.yml", "plugin.yml"},
+}
+
+// PluginFileSystemAccessor is the subset of the files client used by PluginService
+type PluginFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	Exists(path string) (bool, error)
+	Rename(oldPath, newPath string) error
+}
+
+// PluginDependency is a plugin or mod another one depends on
+type PluginDependency struct {
+	ID string
+	// Version is the accepted version range, empty when any version works
+	Version  string
+	Required bool
+	// Missing is set for required dependencies no enabled jar provides
+	Missing bool
+}
+
+// Plugin is a jar in plugins/ or mods/ with the metadata read from it
+type Plugin struct {
+	Directory string
+	FileName  string
+	Size      int64
+	Disabled  bool
+	Kind      string
+	ID        string
+	Name      string
+	Version   string
+	Authors   []string
+	// MinecraftVersions is the supported version range as declared by the jar
+	MinecraftVersions string
+	Dependencies      []PluginDependency
+	// Provides lists further IDs the jar satisfies dependencies for, e.g. bundled libraries
+	Provides []string
+	// Problems lists missing dependencies, duplicates and unreadable metadata
+	Problems []string
+}
+
+// PluginInventory lists the jars of all plugin directories
+type PluginInventory struct {
+	Plugins []Plugin
+	// Directories are the plugin directories that exist in the data directory
+	Directories []string
+}
+
+// ProblemCount returns the number of enabled jars with problems
+func (i PluginInventory) ProblemCount() int {
+	count := 0
+	for _, plugin := range i.Plugins {
+		if !plugin.Disabled && len(plugin.Problems) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// DisabledCount returns the number of disabled jars
+func (i PluginInventory) DisabledCount() int {
+	count := 0
+	for _, plugin := range i.Plugins {
+		if plugin.Disabled {
+			count++
+		}
+	}
+	return count
+}
+
+// PluginService lists the plugins and mods in the data directory and disables them
+type PluginService struct {
+	fileClient PluginFileSystemAccessor
+}
+
+// NewPluginService creates a PluginService
+func NewPluginService(fileClient PluginFileSystemAccessor) *PluginService {
+	return &PluginService{fileClient: fileClient}
+}
+
+// GetInventory reads every jar in the plugin directories and checks their dependencies
+func (s *PluginService) GetInventory() (PluginInventory, error) {
+	inventory := PluginInventory{Plugins: []Plugin{}}
+	for _, directory := range PluginDirectories {
+		dir, err := s.fileClient.GetAbsolutePath(directory)
+		if err != nil {
+			return inventory, err
+		}
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return inventory, fmt.Errorf("failed to read %s: %w", directory, err)
+		}
+		inventory.Directories = append(inventory.Directories, directory)
+		for _, entry := range entries {
+			name := strings.ToLower(entry.Name())
+			if entry.IsDir() || !(strings.HasSuffix(name, ".jar") || strings.HasSuffix(name, ".jar"+disabledJarSuffix)) {
+				continue
+			}
+			inventory.Plugins = append(inventory.Plugins, readPlugin(directory, filepath.Join(dir, entry.Name())))
+		}
+	}
+	checkPluginDependencies(inventory.Plugins)
+	sort.SliceStable(inventory.Plugins, func(a, b int) bool {
+		pa, pb := inventory.Plugins[a], inventory.Plugins[b]
+		if pa.Directory != pb.Directory {
+			return pa.Directory < pb.Directory
+		}
+		return strings.ToLower(pa.displayName()) < strings.ToLower(pb.displayName())
+	})
+	return inventory, nil
+}
+
+func (p Plugin) displayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.FileName
+}
+
+// readPlugin reads the metadata of a jar; problems reading it are recorded on the plugin
+func readPlugin(directory, jarPath string) Plugin {
+	plugin := Plugin{
+		Directory: directory,
+		FileName:  filepath.Base(jarPath),
+		Disabled:  strings.HasSuffix(strings.ToLower(jarPath), disabledJarSuffix),
+	}
+	if info, err := os.Stat(jarPath); err == nil {
+		plugin.Size = info.Size()
+	}
+	archive, err := zip.OpenReader(jarPath)
+	if err != nil {
+		plugin.Problems = append(plugin.Problems, "not a readable jar: "+err.Error())
+		return plugin
+	}
+	defer archive.Close()
+
+	metadata, err := readJarMetadata(&archive.Reader, metadataFiles[directory])
+	if err != nil {
+		plugin.Problems = append(plugin.Problems, err.Error())
+		return plugin
+	}
+	metadata.Directory, metadata.FileName, metadata.Size, metadata.Disabled = plugin.Directory, plugin.FileName, plugin.Size, plugin.Disabled
+
+	// libraries bundled as jar-in-jar satisfy dependencies on their IDs
+	for _, f := range archive.File {
+		if !(strings.HasPrefix(f.Name, "META-INF/jars/") || strings.HasPrefix(f.Name, "META-INF/jarjar/")) ||
+			!strings.HasSuffix(f.Name, ".jar") || f.UncompressedSize64 > maxNestedJarSize {
+			continue
+		}
+		if nested, err := readNestedJar(f); err == nil {
+			metadata.Provides = append(metadata.Provides, nested.ID)
+			metadata.Provides = append(metadata.Provides, nested.Provides...)
+		}
+	}
+	return metadata
+}
+
+func readNestedJar(f *zip.File) (Plugin, error) {
+	r, err := f.Open()
+	if err != nil {
+		return Plugin{}, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Plugin{}, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Plugin{}, err
+	}
+	return readJarMetadata(archive, metadataFiles["mods"])
+}
+
+// readJarMetadata parses the first metadata file found in the jar
+func readJarMetadata(archive *zip.Reader, candidates []string) (Plugin, error) {
+	for _, name := range candidates {
+		text, err := readZipText(archive, name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Plugin{}, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		switch name {
+		case "plugin.yml", "paper-plugin.yml":
+			return parseBukkitPlugin(text, name == "paper-plugin.yml")
+		case "fabric.mod.json":
+			return parseFabricMod(text)
+		default:
+			kind := PluginKindForge
+			if name == "META-INF/neoforge.mods.toml" {
+				kind = PluginKindNeoForge
+			}
+			manifest, _ := readZipText(archive, "META-INF/MANIFEST.MF")
+			return parseModsTOML(text, kind, manifest)
+		}
+	}
+	return Plugin{}, errors.New("no plugin.yml, fabric.mod.json or mods.toml found")
+}
+
+func readZipText(archive *zip.Reader, name string) (string, error) {
+	f, err := archive.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseBukkitPlugin reads plugin.yml or paper-plugin.yml
+func parseBukkitPlugin(text string, paper bool) (Plugin, error) {
+	doc := parseSimpleYAML(text)
+	name := yamlString(doc["name"])
+	if name == "" {
+		return Plugin{}, errors.New("plugin.yml has no name")
+	}
+	plugin := Plugin{
+		Kind:     PluginKindBukkit,
+		ID:       name,
+		Name:     name,
+		Version:  yamlString(doc["version"]),
+		Authors:  append(yamlStrings(doc["author"]), yamlStrings(doc["authors"])...),
+		Provides: yamlStrings(doc["provides"]),
+	}
+	if apiVersion := yamlString(doc["api-version"]); apiVersion != "" {
+		plugin.MinecraftVersions = apiVersion + "+"
+	}
+	for _, id := range yamlStrings(doc["depend"]) {
+		plugin.Dependencies = append(plugin.Dependencies, PluginDependency{ID: id, Required: true})
+	}
+	for _, id := range yamlStrings(doc["softdepend"]) {
+		plugin.Dependencies = append(plugin.Dependencies, PluginDependency{ID: id})
+	}
+
+	if paper {
+		plugin.Kind = PluginKindPaper
+		// dependencies: {server: {Vault: {required: true}}}, required defaults to true
+		dependencies, _ := doc["dependencies"].(map[string]any)
+		server, _ := dependencies["server"].(map[string]any)
+		ids := make([]string, 0, len(server))
+		for id := range server {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			options, _ := server[id].(map[string]any)
+			plugin.Dependencies = append(plugin.Dependencies, PluginDependency{
+				ID:       id,
+				Required: yamlString(options["required"]) != "false",
+			})
+		}
+	}
+	return plugin, nil
+}
+
+// parseFabricMod reads fabric.mod.json
+func parseFabricMod(text string) (Plugin, error) {
+	var mod struct {
+		ID         string                     `json:"id"`
+		Version    string                     `json:"version"`
+		Name       string                     `json:"name"`
+		Authors    []json.RawMessage          `json:"authors"`
+		Depends    map[string]json.RawMessage `json:"depends"`
+		Recommends map[string]json.RawMessage `json:"recommends"`
+		Suggests   map[string]json.RawMessage `json:"suggests"`
+		Provides   []string                   `json:"provides"`
+	}
+	if err := json.Unmarshal([]byte(text), &mod); err != nil {
+		return Plugin{}, fmt.Errorf("invalid fabric.mod.json: %w", err)
+	}
+	if mod.ID == "" {
+		return Plugin{}, errors.New("fabric.mod.json has no id")
+	}
+	plugin := Plugin{
+		Kind:     PluginKindFabric,
+		ID:       mod.ID,
+		Name:     mod.Name,
+		Version:  mod.Version,
+		Provides: mod.Provides,
+	}
+	if plugin.Name == "" {
+		plugin.Name = mod.ID
+	}
+	// authors are names or {"name": ..., "contact": {...}} objects
+	for _, raw := range mod.Authors {
+		var name string
+		if json.Unmarshal(raw, &name) != nil {
+			var person struct {
+				Name string `json:"name"`
+			}
+			json.Unmarshal(raw, &person)
+			name = person.Name
+		}
+		if name != "" {
+			plugin.Authors = append(plugin.Authors, name)
+		}
+	}
+	plugin.MinecraftVersions = fabricVersionRange(mod.Depends["minecraft"])
+	plugin.Dependencies = append(plugin.Dependencies, fabricDependencies(mod.Depends, true)...)
+	plugin.Dependencies = append(plugin.Dependencies, fabricDependencies(mod.Recommends, false)...)
+	plugin.Dependencies = append(plugin.Dependencies, fabricDependencies(mod.Suggests, false)...)
+	return plugin, nil
+}
+
+func fabricDependencies(dependencies map[string]json.RawMessage, required bool) []PluginDependency {
+	ids := make([]string, 0, len(dependencies))
+	for id := range dependencies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	result := make([]PluginDependency, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, PluginDependency{ID: id, Version: fabricVersionRange(dependencies[id]), Required: required})
+	}
+	return result
+}
+
+// fabricVersionRange formats a version predicate, a string or a list of alternatives
+func fabricVersionRange(raw json.RawMessage) string {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		if single == "*" {
+			return ""
+		}
+		return single
+	}
+	var alternatives []string
+	json.Unmarshal(raw, &alternatives)
+	return strings.Join(alternatives, " || ")
+}
+
+// parseModsTOML reads META-INF/mods.toml or neoforge.mods.toml. The first [[mods]] entry
+// describes the jar, further entries are listed as provided IDs.
+func parseModsTOML(text, kind, manifest string) (Plugin, error) {
+	tables := parseTOMLTables(text)
+	var mods []tomlTable
+	for _, table := range tables {
+		if table.name == "mods" {
+			mods = append(mods, table)
+		}
+	}
+	if len(mods) == 0 {
+		return Plugin{}, errors.New("mods.toml has no [[mods]] entry")
+	}
+	mod := mods[0].values
+	plugin := Plugin{
+		Kind:    kind,
+		ID:      yamlString(mod["modId"]),
+		Name:    yamlString(mod["displayName"]),
+		Version: yamlString(mod["version"]),
+	}
+	if plugin.ID == "" {
+		return Plugin{}, errors.New("mods.toml has no modId")
+	}
+	if plugin.Name == "" {
+		plugin.Name = plugin.ID
+	}
+	// the version is usually filled in from the manifest when the jar is loaded
+	if plugin.Version == "${file.jarVersion}" {
+		plugin.Version = manifestValue(manifest, "Implementation-Version")
+	}
+	authors := mod["authors"]
+	if authors == nil {
+		authors = tables[0].values["authors"]
+	}
+	for _, author := range yamlStrings(authors) {
+		for name := range strings.SplitSeq(author, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				plugin.Authors = append(plugin.Authors, name)
+			}
+		}
+	}
+	for _, other := range mods[1:] {
+		if id := yamlString(other.values["modId"]); id != "" {
+			plugin.Provides = append(plugin.Provides, id)
+		}
+	}
+
+	for _, table := range tables {
+		if table.name != "dependencies."+plugin.ID {
+			continue
+		}
+		id := yamlString(table.values["modId"])
+		// NeoForge uses type = "required", Forge mandatory = true
+		dependencyType := strings.ToLower(yamlString(table.values["type"]))
+		if id == "" || dependencyType == "incompatible" || dependencyType == "discouraged" {
+			continue
+		}
+		dependency := PluginDependency{
+			ID:       id,
+			Version:  yamlString(table.values["versionRange"]),
+			Required: dependencyType == "required" || yamlString(table.values["mandatory"]) == "true",
+		}
+		if id == "minecraft" {
+			plugin.MinecraftVersions = dependency.Version
+		}
+		plugin.Dependencies = append(plugin.Dependencies, dependency)
+	}
+	return plugin, nil
+}
+
+// manifestValue reads a main attribute of META-INF/MANIFEST.MF
+func manifestValue(manifest, key string) string {
+	for line := range strings.SplitSeq(strings.ReplaceAll(manifest, "\r\n", "\n"), "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, key) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// checkPluginDependencies flags required dependencies no enabled jar provides and IDs that
+// more than one enabled jar declares
+func checkPluginDependencies(plugins []Plugin) {
+	// bundled libraries are not duplicates, the loader picks one of them
+	owners := map[string][]int{}
+	providers := map[string]bool{}
+	disabled := map[string]string{}
+	for i, plugin := range plugins {
+		if plugin.ID == "" {
+			continue
+		}
+		if !plugin.Disabled {
+			owners[strings.ToLower(plugin.ID)] = append(owners[strings.ToLower(plugin.ID)], i)
+		}
+		for _, id := range append([]string{plugin.ID}, plugin.Provides...) {
+			if plugin.Disabled {
+				disabled[strings.ToLower(id)] = plugin.FileName
+			} else {
+				providers[strings.ToLower(id)] = true
+			}
+		}
+	}
+
+	for i := range plugins {
+		plugin := &plugins[i]
+		if plugin.Disabled || plugin.ID == "" {
+			continue
+		}
+		for _, j := range owners[strings.ToLower(plugin.ID)] {
+			if j != i {
+				plugin.Problems = append(plugin.Problems, fmt.Sprintf("duplicate of %s in %s", plugins[j].FileName, plugins[j].Directory))
+			}
+		}
+		for d := range plugin.Dependencies {
+			dependency := &plugin.Dependencies[d]
+			key := strings.ToLower(dependency.ID)
+			if !dependency.Required || loaderDependencies[key] || providers[key] {
+				continue
+			}
+			dependency.Missing = true
+			if fileName, ok := disabled[key]; ok {
+				plugin.Problems = append(plugin.Problems, fmt.Sprintf("requires %s, which is disabled (%s)", dependency.ID, fileName))
+			} else {
+				plugin.Problems = append(plugin.Problems, "requires missing "+dependency.ID)
+			}
+		}
+	}
+}
+
+// DisableJar renames a jar to <name>.jar.disabled so the server skips it on the next start
+func (s *PluginService) DisableJar(directory, fileName string) (string, error) {
+	if !strings.HasSuffix(strings.ToLower(fileName), ".jar") {
+		return "", fmt.Errorf("%s is not an enabled jar", fileName)
+	}
+	return s.renameJar(directory, fileName, fileName+disabledJarSuffix)
+}
+
+// EnableJar removes the .disabled suffix of a jar
+func (s *PluginService) EnableJar(directory, fileName string) (string, error) {
+	if !strings.HasSuffix(strings.ToLower(fileName), ".jar"+disabledJarSuffix) {
+		return "", fmt.Errorf("%s is not a disabled jar", fileName)
+	}
+	return s.renameJar(directory, fileName, fileName[:len(fileName)-len(disabledJarSuffix)])
+}
+
+func (s *PluginService) renameJar(directory, fileName, newName string) (string, error) {
+	if !slices.Contains(PluginDirectories, directory) {
+		return "", fmt.Errorf("unknown plugin directory %q", directory)
+	}
+	if fileName != path.Base(fileName) || strings.ContainsRune(fileName, '\\') || strings.HasPrefix(fileName, ".") {
+		return "", fmt.Errorf("invalid file name %q", fileName)
+	}
+	oldPath, newPath := path.Join(directory, fileName), path.Join(directory, newName)
+	if exists, err := s.fileClient.Exists(oldPath); err != nil {
+		return "", err
+	} else if !exists {
+		return "", fmt.Errorf("%s does not exist", oldPath)
+	}
+	// os.Rename replaces an existing target, which would lose the other jar
+	if exists, err := s.fileClient.Exists(newPath); err != nil {
+		return "", err
+	} else if exists {
+		return "", fmt.Errorf("%s already exists", newPath)
+	}
+	if err := s.fileClient.Rename(oldPath, newPath); err != nil {
+		return "", err
+	}
+	return newName, nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// jarBytes builds a zip with the given files
+func jarBytes(t *testing.T, contents map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for name, content := range contents {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func writeJar(t *testing.T, path string, contents map[string]string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, jarBytes(t, contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func findPlugin(t *testing.T, inventory PluginInventory, fileName string) Plugin {
+	t.Helper()
+	for _, plugin := range inventory.Plugins {
+		if plugin.FileName == fileName {
+			return plugin
+		}
+	}
+	t.Fatalf("%s not in inventory", fileName)
+	return Plugin{}
+}
+
+func TestPluginService_GetInventory(t *testing.T) {
+	dataDir := t.TempDir()
+	plugins := filepath.Join(dataDir, "plugins")
+	mods := filepath.Join(dataDir, "mods")
+
+	writeJar(t, filepath.Join(plugins, "Shop-2.1.jar"), map[string]string{"plugin.yml": `
+name: Shop
+version: '2.1'
+main: com.example.shop.Shop
+api-version: "1.20"
+author: Alex
+authors: [Sam, 'Kim']
+depend: [Vault]
+softdepend:
+  - Essentials
+commands:
+  shop:
+    description: |
+      Opens the shop.
+      Second line.
+    usage: /shop # comment
+`})
+	writeJar(t, filepath.Join(plugins, "Vault.jar.disabled"), map[string]string{"plugin.yml": "name: Vault\nversion: 1.7.3\n"})
+	writeJar(t, filepath.Join(plugins, "Claims.jar"), map[string]string{"paper-plugin.yml": `
+name: Claims
+version: 1.0.0
+api-version: '1.21'
+dependencies:
+  server:
+    WorldGuard:
+      load: BEFORE
+      required: false
+    Shop:
+      load: BEFORE
+`, "plugin.yml": "name: ClaimsLegacy\n"})
+	writeJar(t, filepath.Join(plugins, "Claims-old.jar"), map[string]string{"paper-plugin.yml": "name: Claims\nversion: 0.9.0\n"})
+	os.WriteFile(filepath.Join(plugins, "broken.jar"), []byte("not a zip"), 0644)
+	os.WriteFile(filepath.Join(plugins, "config.yml"), []byte("a: b"), 0644)
+
+	library := jarBytes(t, map[string]string{"fabric.mod.json": `{"id": "cloth-config", "version": "15.0.0"}`})
+	writeJar(t, filepath.Join(mods, "sodium.jar"), map[string]string{
+		"fabric.mod.json": `{
+  "schemaVersion": 1,
+  "id": "sodium",
+  "version": "0.6.0",
+  "name": "Sodium",
+  "authors": ["JellySquid", {"name": "IMS", "contact": {}}],
+  "depends": {"minecraft": ["1.21", "1.21.1"], "fabricloader": ">=0.16", "cloth-config": "*", "fabric-api": ">=0.100"},
+  "recommends": {"modmenu": "*"}
+}`,
+		"META-INF/jars/cloth-config.jar": string(library),
+	})
+	writeJar(t, filepath.Join(mods, "create.jar"), map[string]string{
+		"META-INF/neoforge.mods.toml": `
+modLoader="javafml" # the loader
+loaderVersion="[4,)"
+license='MIT'
+[[mods]]
+modId="create"
+version="${file.jarVersion}"
+displayName="Create"
+authors="simibubi, Others"
+description='''
+Building tools
+and contraptions.
+'''
+[[mods]]
+modId="flywheel"
+[[dependencies.create]]
+    modId="neoforge"
+    type="required"
+    versionRange="[21.1,)"
+[[dependencies.create]]
+    modId="minecraft"
+    type="required"
+    versionRange="[1.21.1,1.21.2)"
+[[dependencies.create]]
+    modId="ponder"
+    type="required"
+[[dependencies.create]]
+    modId="optifine"
+    type="incompatible"
+`,
+		"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\r\nImplementation-Version: 6.0.4\r\n",
+	})
+	writeJar(t, filepath.Join(mods, "jei.jar"), map[string]string{"META-INF/mods.toml": `
+[[mods]]
+modId="jei"
+version="19.0.0"
+[[dependencies.jei]]
+modId="create"
+mandatory=true
+`})
+
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	inventory, err := NewPluginService(&fileClient).GetInventory()
+	if err != nil {
+		t.Fatalf("GetInventory: %v", err)
+	}
+	if len(inventory.Plugins) != 8 || !slices.Equal(inventory.Directories, PluginDirectories) {
+		t.Fatalf("inventory = %+v", inventory)
+	}
+	if inventory.DisabledCount() != 1 || inventory.ProblemCount() != 6 {
+		t.Fatalf("disabled %d, problems %d", inventory.DisabledCount(), inventory.ProblemCount())
+	}
+
+	shop := findPlugin(t, inventory, "Shop-2.1.jar")
+	if shop.Kind != PluginKindBukkit || shop.Version != "2.1" || shop.MinecraftVersions != "1.20+" ||
+		!slices.Equal(shop.Authors, []string{"Alex", "Sam", "Kim"}) {
+		t.Fatalf("shop = %+v", shop)
+	}
+	if len(shop.Dependencies) != 2 || !shop.Dependencies[0].Missing || shop.Dependencies[1].Required ||
+		!slices.Equal(shop.Problems, []string{"requires Vault, which is disabled (Vault.jar.disabled)"}) {
+		t.Fatalf("shop dependencies = %+v, problems %v", shop.Dependencies, shop.Problems)
+	}
+	if vault := findPlugin(t, inventory, "Vault.jar.disabled"); !vault.Disabled || vault.Version != "1.7.3" || len(vault.Problems) != 0 {
+		t.Fatalf("vault = %+v", vault)
+	}
+
+	claims := findPlugin(t, inventory, "Claims.jar")
+	if claims.Kind != PluginKindPaper || len(claims.Dependencies) != 2 ||
+		claims.Dependencies[0] != (PluginDependency{ID: "Shop", Required: true}) ||
+		claims.Dependencies[1] != (PluginDependency{ID: "WorldGuard"}) ||
+		!slices.Equal(claims.Problems, []string{"duplicate of Claims-old.jar in plugins"}) {
+		t.Fatalf("claims = %+v", claims)
+	}
+	if broken := findPlugin(t, inventory, "broken.jar"); len(broken.Problems) != 1 || !strings.HasPrefix(broken.Problems[0], "not a readable jar") {
+		t.Fatalf("broken = %+v", broken)
+	}
+
+	sodium := findPlugin(t, inventory, "sodium.jar")
+	if sodium.Kind != PluginKindFabric || sodium.Name != "Sodium" || sodium.MinecraftVersions != "1.21 || 1.21.1" ||
+		!slices.Equal(sodium.Authors, []string{"JellySquid", "IMS"}) || !slices.Equal(sodium.Provides, []string{"cloth-config"}) {
+		t.Fatalf("sodium = %+v", sodium)
+	}
+	// cloth-config is bundled, fabric-api is not installed and modmenu is optional
+	if !slices.Equal(sodium.Problems, []string{"requires missing fabric-api"}) {
+		t.Fatalf("sodium problems = %v", sodium.Problems)
+	}
+
+	create := findPlugin(t, inventory, "create.jar")
+	if create.Kind != PluginKindNeoForge || create.Name != "Create" || create.Version != "6.0.4" ||
+		create.MinecraftVersions != "[1.21.1,1.21.2)" || !slices.Equal(create.Authors, []string{"simibubi", "Others"}) ||
+		!slices.Equal(create.Provides, []string{"flywheel"}) || len(create.Dependencies) != 3 {
+		t.Fatalf("create = %+v", create)
+	}
+	if !slices.Equal(create.Problems, []string{"requires missing ponder"}) {
+		t.Fatalf("create problems = %v", create.Problems)
+	}
+	if jei := findPlugin(t, inventory, "jei.jar"); jei.Kind != PluginKindForge || len(jei.Problems) != 0 || !jei.Dependencies[0].Required {
+		t.Fatalf("jei = %+v", jei)
+	}
+}
+
+func TestPluginService_GetInventoryWithoutDirectories(t *testing.T) {
+	fileClient := files.NewMinecraftFilesClient(t.TempDir(), 0)
+	inventory, err := NewPluginService(&fileClient).GetInventory()
+	if err != nil || len(inventory.Plugins) != 0 || len(inventory.Directories) != 0 {
+		t.Fatalf("inventory = %+v, %v", inventory, err)
+	}
+}
+
+func TestPluginService_DisableAndEnableJar(t *testing.T) {
+	dataDir := t.TempDir()
+	writeJar(t, filepath.Join(dataDir, "mods", "sodium.jar"), map[string]string{"fabric.mod.json": `{"id": "sodium"}`})
+	writeJar(t, filepath.Join(dataDir, "mods", "lithium.jar"), map[string]string{"fabric.mod.json": `{"id": "lithium"}`})
+	writeJar(t, filepath.Join(dataDir, "mods", "lithium.jar.disabled"), map[string]string{"fabric.mod.json": `{"id": "lithium"}`})
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	svc := NewPluginService(&fileClient)
+
+	name, err := svc.DisableJar("mods", "sodium.jar")
+	if err != nil || name != "sodium.jar.disabled" {
+		t.Fatalf("DisableJar = %q, %v", name, err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "mods", "sodium.jar.disabled")); err != nil {
+		t.Fatalf("disabled jar missing: %v", err)
+	}
+	if name, err := svc.EnableJar("mods", "sodium.jar.disabled"); err != nil || name != "sodium.jar" {
+		t.Fatalf("EnableJar = %q, %v", name, err)
+	}
+
+	for _, tt := range []struct {
+		directory, fileName string
+		enable              bool
+		wantErr             string
+	}{
+		{"mods", "lithium.jar", false, "already exists"},
+		{"mods", "missing.jar", false, "does not exist"},
+		{"mods", "sodium.jar", true, "not a disabled jar"},
+		{"mods", "../server.jar", false, "invalid file name"},
+		{"world", "sodium.jar", false, "unknown plugin directory"},
+	} {
+		var err error
+		if tt.enable {
+			_, err = svc.EnableJar(tt.directory, tt.fileName)
+		} else {
+			_, err = svc.DisableJar(tt.directory, tt.fileName)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s/%s: err = %v, want %q", tt.directory, tt.fileName, err, tt.wantErr)
+		}
+	}
+}
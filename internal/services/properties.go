@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultLevelName is the world directory used when server.properties does not set level-name
+const defaultLevelName = "world"
+
+type ServerPropertiesReader interface {
+	ReadFile(path string) (string, error)
+}
+
+// readServerProperty returns the value of key in server.properties, ok=false if the key is not set
+func readServerProperty(fileClient ServerPropertiesReader, key string) (string, bool, error) {
+	content, err := fileClient.ReadFile("server.properties")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read server.properties: %w", err)
+	}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return strings.TrimSpace(parts[1]), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// getLevelName returns the configured world directory name, falling back to "world"
+func getLevelName(fileClient ServerPropertiesReader) string {
+	name, ok, err := readServerProperty(fileClient, "level-name")
+	if err != nil || !ok || name == "" {
+		return defaultLevelName
+	}
+	return name
+}
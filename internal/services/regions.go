@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"mc-admin/internal/clients/rcon"
+	"mc-admin/internal/regions"
+	"sync"
+	"time"
+)
+
+// trimPlanMaxAge is how long a dry run stays valid for ApplyTrim
+const trimPlanMaxAge = 30 * time.Minute
+
+// RegionFileSystemAccessor is the subset of the files client used by RegionService
+type RegionFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	ReadFile(path string) (string, error)
+}
+
+// RegionService analyzes the region files of the world and trims unused chunks
+type RegionService struct {
+	rconClient rcon.CommandExecutor
+	fileClient RegionFileSystemAccessor
+	now        func() time.Time
+
+	mu   sync.Mutex
+	plan *regions.TrimPlan
+}
+
+// NewRegionService creates a RegionService
+func NewRegionService(rconClient rcon.CommandExecutor, fileClient RegionFileSystemAccessor) *RegionService {
+	return &RegionService{
+		rconClient: rconClient,
+		fileClient: fileClient,
+		now:        time.Now,
+	}
+}
+
+func (s *RegionService) worldDimensions() ([]regions.Dimension, error) {
+	worldDir, err := s.fileClient.GetAbsolutePath(getLevelName(s.fileClient))
+	if err != nil {
+		return nil, err
+	}
+	dimensions := regions.WorldDimensions(worldDir)
+	if len(dimensions) == 0 {
+		return nil, fmt.Errorf("no region files found in %s", worldDir)
+	}
+	return dimensions, nil
+}
+
+// AnalyzeWorld reports chunk counts, sizes and the InhabitedTime distribution per dimension
+func (s *RegionService) AnalyzeWorld() ([]regions.DimensionReport, error) {
+	dimensions, err := s.worldDimensions()
+	if err != nil {
+		return nil, err
+	}
+	reports := []regions.DimensionReport{}
+	for _, d := range dimensions {
+		report, err := regions.AnalyzeDimension(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze %s: %w", d.Name, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// PlanTrim runs a dry run and remembers the plan for ApplyTrim
+func (s *RegionService) PlanTrim(opts regions.TrimOptions) (*regions.TrimPlan, error) {
+	dimensions, err := s.worldDimensions()
+	if err != nil {
+		return nil, err
+	}
+	plan, err := regions.PlanTrim(dimensions, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan.CreatedAt = s.now()
+	s.mu.Lock()
+	s.plan = plan
+	s.mu.Unlock()
+	return plan, nil
+}
+
+// ApplyTrim deletes the chunks of the last dry run. The server has to be stopped.
+func (s *RegionService) ApplyTrim() (regions.TrimResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.plan == nil {
+		return regions.TrimResult{}, fmt.Errorf("run a dry run before trimming")
+	}
+	if s.now().Sub(s.plan.CreatedAt) > trimPlanMaxAge {
+		s.plan = nil
+		return regions.TrimResult{}, fmt.Errorf("the dry run has expired, run it again")
+	}
+	// a reachable RCON means the server would overwrite the trimmed files from memory
+	if _, err := s.rconClient.ExecuteCommand("list"); err == nil {
+		return regions.TrimResult{}, fmt.Errorf("the server is running, stop it before trimming")
+	}
+
+	result, err := regions.ApplyTrim(s.plan)
+	s.plan = nil
+	return result, err
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"mc-admin/internal/clients/files"
+	"mc-admin/internal/regions"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRegionService(t *testing.T, rconClient *fakeRconClient) *RegionService {
+	t.Helper()
+	dataDir := t.TempDir()
+	os.WriteFile(filepath.Join(dataDir, "server.properties"), []byte("level-name=survival\n"), 0644)
+	if err := os.MkdirAll(filepath.Join(dataDir, "survival", "region"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	return NewRegionService(rconClient, &fileClient)
+}
+
+func TestRegionService_AnalyzeWorld(t *testing.T) {
+	svc := newTestRegionService(t, &fakeRconClient{})
+	reports, err := svc.AnalyzeWorld()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Name != "overworld" || !strings.HasSuffix(reports[0].Dir, "survival") {
+		t.Fatalf("reports = %+v", reports)
+	}
+}
+
+func TestRegionService_ApplyTrimRequiresDryRun(t *testing.T) {
+	svc := newTestRegionService(t, &fakeRconClient{})
+	if _, err := svc.ApplyTrim(); err == nil || !strings.Contains(err.Error(), "dry run") {
+		t.Fatalf("expected dry run error, got %v", err)
+	}
+}
+
+func TestRegionService_ApplyTrimRequiresStoppedServer(t *testing.T) {
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"list": {out: "There are 0 of a max of 20 players online:"},
+	}}
+	svc := newTestRegionService(t, rconClient)
+	if _, err := svc.PlanTrim(regions.TrimOptions{MinInhabited: time.Minute}); err != nil {
+		t.Fatalf("PlanTrim: %v", err)
+	}
+	if _, err := svc.ApplyTrim(); err == nil || !strings.Contains(err.Error(), "server is running") {
+		t.Fatalf("expected running server error, got %v", err)
+	}
+
+	// the plan survives the rejected attempt and applies once RCON is unreachable
+	delete(rconClient.responses, "list")
+	if _, err := svc.ApplyTrim(); err != nil {
+		t.Fatalf("ApplyTrim with stopped server: %v", err)
+	}
+	if _, err := svc.ApplyTrim(); err == nil {
+		t.Fatalf("a plan must only be applied once")
+	}
+}
+
+func TestRegionService_ApplyTrimExpiredPlan(t *testing.T) {
+	svc := newTestRegionService(t, &fakeRconClient{})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	if _, err := svc.PlanTrim(regions.TrimOptions{Radius: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(trimPlanMaxAge + time.Minute)
+	if _, err := svc.ApplyTrim(); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("expected expired error, got %v", err)
+	}
+}
@@ -0,0 +1,681 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"mc-admin/internal/clients/rcon"
+	"mc-admin/internal/regions"
+	"mc-admin/internal/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// scoreEntryPattern matches the "[Display Name]: 12" entries of "scoreboard players list <holder>"
+	scoreEntryPattern = regexp.MustCompile(`\[(.*?)\]: (-?\d+)`)
+	// scoreGetPattern matches "Steve has 12 [Kills]"
+	scoreGetPattern = regexp.MustCompile(`^\S+ has (-?\d+) \[`)
+	// scoreboardNamePattern is the character set of unquoted objective and team names
+	scoreboardNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+)
+
+// TeamColors are the colors accepted by "team modify <team> color"
+var TeamColors = []string{
+	"reset", "black", "dark_blue", "dark_green", "dark_aqua", "dark_red", "dark_purple", "gold", "gray",
+	"dark_gray", "blue", "green", "aqua", "red", "light_purple", "yellow", "white",
+}
+
+// NameTagVisibilities are the values accepted by "team modify <team> nametagVisibility"
+var NameTagVisibilities = []string{"always", "never", "hideForOtherTeams", "hideForOwnTeam"}
+
+// DisplaySlots are the common targets of "scoreboard objectives setdisplay"
+var DisplaySlots = []string{"sidebar", "list", "below_name"}
+
+// legacyDisplaySlots names the numbered slots scoreboard.dat used before 1.20.2
+var legacyDisplaySlots = append([]string{"list", "sidebar", "below_name"}, teamSidebarSlots()...)
+
+func teamSidebarSlots() []string {
+	var slots []string
+	for _, color := range TeamColors[1:] {
+		slots = append(slots, "sidebar.team."+color)
+	}
+	return slots
+}
+
+// ScoreboardFileSystemAccessor is the subset of the files client used by ScoreboardService
+type ScoreboardFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	ReadFile(path string) (string, error)
+}
+
+// ScoreboardObjective is an objective and the display slots showing it
+type ScoreboardObjective struct {
+	Name         string
+	Criteria     string
+	DisplayName  string
+	DisplaySlots []string
+}
+
+// ScoreboardTeam is a team with the options managed by this page
+type ScoreboardTeam struct {
+	Name              string
+	DisplayName       string
+	Color             string
+	Prefix            string
+	FriendlyFire      bool
+	NameTagVisibility string
+	Members           []string
+}
+
+// ScoreRow is one score holder with a value per objective, nil where no score is set
+type ScoreRow struct {
+	Holder string
+	Values []*int
+}
+
+// Scoreboard holds objectives and teams as of the last save plus the changes made on this page
+// since, and the scores of all holders
+type Scoreboard struct {
+	Objectives []ScoreboardObjective
+	Teams      []ScoreboardTeam
+	Scores     map[string]map[string]int
+	// LiveScores is false when the server was unreachable and scores come from scoreboard.dat
+	LiveScores bool
+	SavedAt    time.Time
+}
+
+// Rows returns the score table sorted by an objective (or by holder name if sortBy is empty).
+// Holders without a score in the sort objective come last.
+func (b Scoreboard) Rows(sortBy string, descending bool) []ScoreRow {
+	holders := make([]string, 0, len(b.Scores))
+	for holder := range b.Scores {
+		holders = append(holders, holder)
+	}
+	sort.Slice(holders, func(i, j int) bool {
+		if sortBy != "" {
+			a, okA := b.Scores[holders[i]][sortBy]
+			c, okC := b.Scores[holders[j]][sortBy]
+			if okA != okC {
+				return okA
+			}
+			if okA && a != c {
+				return (a < c) != descending
+			}
+		}
+		return strings.ToLower(holders[i]) < strings.ToLower(holders[j])
+	})
+
+	rows := make([]ScoreRow, len(holders))
+	for i, holder := range holders {
+		rows[i].Holder = holder
+		rows[i].Values = make([]*int, len(b.Objectives))
+		for j, objective := range b.Objectives {
+			if value, ok := b.Scores[holder][objective.Name]; ok {
+				rows[i].Values[j] = &value
+			}
+		}
+	}
+	return rows
+}
+
+// ScoreboardService manages objectives, scores and teams over RCON. Criteria, display slots
+// and team options cannot be queried with commands, so they are read from scoreboard.dat.
+// Saving is left to the server's autosave, changes made here are remembered until a save
+// contains them.
+type ScoreboardService struct {
+	rconClient rcon.CommandExecutor
+	fileClient ScoreboardFileSystemAccessor
+	now        func() time.Time
+
+	mu      sync.Mutex
+	pending []scoreboardChange
+}
+
+// scoreboardChange is a successful command whose effect scoreboard.dat may not show yet. Applying
+// it is idempotent, so applying it to a file that already contains it does no harm.
+type scoreboardChange struct {
+	madeAt time.Time
+	apply  func(board *Scoreboard)
+}
+
+// NewScoreboardService creates a ScoreboardService
+func NewScoreboardService(rconClient rcon.CommandExecutor, fileClient ScoreboardFileSystemAccessor) *ScoreboardService {
+	return &ScoreboardService{
+		rconClient: rconClient,
+		fileClient: fileClient,
+		now:        time.Now,
+	}
+}
+
+func (s *ScoreboardService) scoreboardPath() (string, error) {
+	worldDir, err := s.fileClient.GetAbsolutePath(getLevelName(s.fileClient))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(worldDir, "data", "scoreboard.dat"), nil
+}
+
+// GetScoreboard reads the saved scoreboard and replaces its scores and team members with live values
+func (s *ScoreboardService) GetScoreboard() (Scoreboard, error) {
+	path, err := s.scoreboardPath()
+	if err != nil {
+		return Scoreboard{}, err
+	}
+	board := Scoreboard{Scores: map[string]map[string]int{}}
+	var data regions.Compound
+	if info, err := os.Stat(path); err == nil {
+		board.SavedAt = info.ModTime()
+		root, err := readNBTFile(path)
+		if err != nil {
+			return Scoreboard{}, fmt.Errorf("failed to read scoreboard.dat: %w", err)
+		}
+		data = root.Compound("data")
+	}
+	board.Objectives = parseObjectives(data)
+	board.Teams = parseTeams(data)
+	s.applyPending(&board)
+
+	if err := s.readLiveScores(&board); err != nil {
+		board.Scores = parseSavedScores(data)
+		return board, nil
+	}
+	board.LiveScores = true
+	for i := range board.Teams {
+		if members, err := s.teamMembers(board.Teams[i].Name); err == nil {
+			board.Teams[i].Members = members
+		}
+	}
+	return board, nil
+}
+
+func parseObjectives(data regions.Compound) []ScoreboardObjective {
+	slotsByObjective := map[string][]string{}
+	for slot, value := range data.Compound("DisplaySlots") {
+		name, _ := value.(string)
+		if legacy, ok := strings.CutPrefix(slot, "slot_"); ok {
+			index, err := strconv.Atoi(legacy)
+			if err != nil || index < 0 || index >= len(legacyDisplaySlots) {
+				continue
+			}
+			slot = legacyDisplaySlots[index]
+		}
+		slotsByObjective[name] = append(slotsByObjective[name], slot)
+	}
+
+	objectives := []ScoreboardObjective{}
+	for _, item := range data.List("Objectives") {
+		raw, ok := item.(regions.Compound)
+		if !ok {
+			continue
+		}
+		name := raw.String("Name")
+		slots := slotsByObjective[name]
+		sort.Strings(slots)
+		objectives = append(objectives, ScoreboardObjective{
+			Name:         name,
+			Criteria:     raw.String("CriteriaName"),
+			DisplayName:  componentText(raw["DisplayName"], name),
+			DisplaySlots: slots,
+		})
+	}
+	sort.Slice(objectives, func(i, j int) bool { return objectives[i].Name < objectives[j].Name })
+	return objectives
+}
+
+func parseTeams(data regions.Compound) []ScoreboardTeam {
+	teams := []ScoreboardTeam{}
+	for _, item := range data.List("Teams") {
+		raw, ok := item.(regions.Compound)
+		if !ok {
+			continue
+		}
+		name := raw.String("Name")
+		team := ScoreboardTeam{
+			Name:              name,
+			DisplayName:       componentText(raw["DisplayName"], name),
+			Color:             raw.String("TeamColor"),
+			Prefix:            componentText(raw["MemberNamePrefix"], ""),
+			NameTagVisibility: raw.String("NameTagVisibility"),
+			Members:           []string{},
+		}
+		if friendlyFire, ok := raw.Int("AllowFriendlyFire"); ok {
+			team.FriendlyFire = friendlyFire != 0
+		}
+		if team.Color == "" {
+			team.Color = "reset"
+		}
+		if team.NameTagVisibility == "" {
+			team.NameTagVisibility = "always"
+		}
+		for _, member := range raw.List("Players") {
+			if name, ok := member.(string); ok {
+				team.Members = append(team.Members, name)
+			}
+		}
+		teams = append(teams, team)
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+	return teams
+}
+
+func parseSavedScores(data regions.Compound) map[string]map[string]int {
+	scores := map[string]map[string]int{}
+	for _, item := range data.List("PlayerScores") {
+		raw, ok := item.(regions.Compound)
+		if !ok {
+			continue
+		}
+		value, ok := raw.Int("Score")
+		if !ok {
+			continue
+		}
+		holder := raw.String("Name")
+		if scores[holder] == nil {
+			scores[holder] = map[string]int{}
+		}
+		scores[holder][raw.String("Objective")] = int(value)
+	}
+	return scores
+}
+
+// componentText returns the plain text of a saved text component. Before 1.21.5 components are
+// stored as JSON strings, since then as NBT strings or compounds.
+func componentText(value any, fallback string) string {
+	text := ""
+	switch v := value.(type) {
+	case string:
+		if json.Valid([]byte(v)) {
+			text = plainText(json.RawMessage(v))
+		} else {
+			text = v
+		}
+	case regions.Compound:
+		text = v.String("text")
+		for _, extra := range v.List("extra") {
+			text += componentText(extra, "")
+		}
+	}
+	if text == "" {
+		return fallback
+	}
+	return text
+}
+
+// readLiveScores lists all score holders and maps the display names in their score lists back
+// to objectives. Objectives sharing a display name are queried one by one.
+func (s *ScoreboardService) readLiveScores(board *Scoreboard) error {
+	response, err := s.rconClient.ExecuteCommand("scoreboard players list")
+	if err != nil {
+		return err
+	}
+	holders := []string{}
+	if _, list, ok := strings.Cut(response, ":"); ok {
+		holders = utils.SplitAndTrim(list, ",")
+	}
+
+	byDisplayName := map[string][]string{}
+	for _, objective := range board.Objectives {
+		byDisplayName[objective.DisplayName] = append(byDisplayName[objective.DisplayName], objective.Name)
+	}
+
+	for _, holder := range holders {
+		if holder == "" {
+			continue
+		}
+		response, err := s.rconClient.ExecuteCommand("scoreboard players list " + holder)
+		if err != nil {
+			return err
+		}
+		scores := map[string]int{}
+		for _, m := range scoreEntryPattern.FindAllStringSubmatch(response, -1) {
+			value, _ := strconv.Atoi(m[2])
+			names := byDisplayName[m[1]]
+			switch len(names) {
+			case 0:
+				// objective created outside this page and not saved yet
+			case 1:
+				scores[names[0]] = value
+			default:
+				for _, name := range names {
+					if value, ok := s.getScore(holder, name); ok {
+						scores[name] = value
+					}
+				}
+			}
+		}
+		board.Scores[holder] = scores
+	}
+	return nil
+}
+
+func (s *ScoreboardService) getScore(holder, objective string) (int, bool) {
+	response, err := s.rconClient.ExecuteCommand(fmt.Sprintf("scoreboard players get %s %s", holder, objective))
+	if err != nil {
+		return 0, false
+	}
+	m := scoreGetPattern.FindStringSubmatch(response)
+	if m == nil {
+		return 0, false
+	}
+	value, err := strconv.Atoi(m[1])
+	return value, err == nil
+}
+
+// teamMembers parses "Team [Red] has 2 member(s): Steve, Alex"
+func (s *ScoreboardService) teamMembers(team string) ([]string, error) {
+	response, err := s.rconClient.ExecuteCommand("team list " + team)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(response, "There are no members") {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(response, "Team ") {
+		return nil, fmt.Errorf("unexpected response format: %s", response)
+	}
+	_, list, _ := strings.Cut(response, ": ")
+	return utils.SplitAndTrim(list, ","), nil
+}
+
+// remember records a change made after a successful command
+func (s *ScoreboardService) remember(apply func(board *Scoreboard)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, scoreboardChange{madeAt: s.now(), apply: apply})
+}
+
+// applyPending applies the changes the last save does not contain and forgets the others
+func (s *ScoreboardService) applyPending(board *Scoreboard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = slices.DeleteFunc(s.pending, func(change scoreboardChange) bool {
+		return !board.SavedAt.IsZero() && board.SavedAt.After(change.madeAt)
+	})
+	for _, change := range s.pending {
+		change.apply(board)
+	}
+}
+
+// change runs a scoreboard or team command and remembers its effect on success. Score changes
+// pass no apply function, scores are read live.
+func (s *ScoreboardService) change(command string, apply func(board *Scoreboard), successPrefixes ...string) error {
+	if err := executeExpecting(s.rconClient, command, successPrefixes...); err != nil {
+		return err
+	}
+	if apply != nil {
+		s.remember(apply)
+	}
+	return nil
+}
+
+func (b *Scoreboard) objective(name string) *ScoreboardObjective {
+	for i := range b.Objectives {
+		if b.Objectives[i].Name == name {
+			return &b.Objectives[i]
+		}
+	}
+	return nil
+}
+
+func (b *Scoreboard) team(name string) *ScoreboardTeam {
+	for i := range b.Teams {
+		if b.Teams[i].Name == name {
+			return &b.Teams[i]
+		}
+	}
+	return nil
+}
+
+func validateScoreboardName(kind, name string) error {
+	if !scoreboardNamePattern.MatchString(name) {
+		return fmt.Errorf("%s name must only contain letters, digits and _ . + -", kind)
+	}
+	return nil
+}
+
+// validateHolder accepts player names, fake players like #global and selectors without spaces
+func validateHolder(holder string) error {
+	if holder == "" || strings.ContainsAny(holder, " \t\r\n") {
+		return fmt.Errorf("score holder must be a single name without spaces")
+	}
+	return nil
+}
+
+// AddObjective creates an objective with an optional display name
+func (s *ScoreboardService) AddObjective(name, criteria, displayName string) error {
+	if err := validateScoreboardName("objective", name); err != nil {
+		return err
+	}
+	if criteria == "" || strings.ContainsAny(criteria, " \t\r\n") {
+		return fmt.Errorf("criteria must be a single word, e.g. dummy or minecraft.killed:minecraft.zombie")
+	}
+	command := fmt.Sprintf("scoreboard objectives add %s %s", name, criteria)
+	if displayName != "" {
+		// a quoted string is a valid text component both as JSON and as SNBT
+		quoted, _ := json.Marshal(displayName)
+		command += " " + string(quoted)
+	}
+	return s.change(command, func(board *Scoreboard) {
+		if board.objective(name) != nil {
+			return
+		}
+		objective := ScoreboardObjective{Name: name, Criteria: criteria, DisplayName: displayName}
+		if objective.DisplayName == "" {
+			objective.DisplayName = name
+		}
+		board.Objectives = append(board.Objectives, objective)
+		sort.Slice(board.Objectives, func(i, j int) bool { return board.Objectives[i].Name < board.Objectives[j].Name })
+	}, "Created new objective")
+}
+
+// RemoveObjective deletes an objective and all its scores
+func (s *ScoreboardService) RemoveObjective(name string) error {
+	if err := validateScoreboardName("objective", name); err != nil {
+		return err
+	}
+	return s.change("scoreboard objectives remove "+name, func(board *Scoreboard) {
+		board.Objectives = slices.DeleteFunc(board.Objectives, func(o ScoreboardObjective) bool { return o.Name == name })
+	}, "Removed objective")
+}
+
+// SetDisplay shows an objective in a display slot, or clears the slot if objective is empty
+func (s *ScoreboardService) SetDisplay(slot, objective string) error {
+	if !slices.Contains(DisplaySlots, slot) && !slices.Contains(teamSidebarSlots(), slot) {
+		return fmt.Errorf("unknown display slot: %s", slot)
+	}
+	apply := func(board *Scoreboard) {
+		for i := range board.Objectives {
+			board.Objectives[i].DisplaySlots = slices.DeleteFunc(board.Objectives[i].DisplaySlots, func(s string) bool { return s == slot })
+		}
+		if o := board.objective(objective); o != nil {
+			o.DisplaySlots = append(o.DisplaySlots, slot)
+			sort.Strings(o.DisplaySlots)
+		}
+	}
+	if objective == "" {
+		return s.change("scoreboard objectives setdisplay "+slot, apply, "Cleared any objectives")
+	}
+	if err := validateScoreboardName("objective", objective); err != nil {
+		return err
+	}
+	return s.change(fmt.Sprintf("scoreboard objectives setdisplay %s %s", slot, objective), apply, "Set display slot")
+}
+
+// SetScore sets a holder's score
+func (s *ScoreboardService) SetScore(holder, objective string, value int) error {
+	if err := validateHolder(holder); err != nil {
+		return err
+	}
+	if err := validateScoreboardName("objective", objective); err != nil {
+		return err
+	}
+	return s.change(fmt.Sprintf("scoreboard players set %s %s %d", holder, objective, value), nil, "Set ")
+}
+
+// AddScore adds to a holder's score, negative amounts are subtracted
+func (s *ScoreboardService) AddScore(holder, objective string, amount int) error {
+	if err := validateHolder(holder); err != nil {
+		return err
+	}
+	if err := validateScoreboardName("objective", objective); err != nil {
+		return err
+	}
+	if amount < 0 {
+		return s.change(fmt.Sprintf("scoreboard players remove %s %s %d", holder, objective, -amount), nil, "Removed ")
+	}
+	return s.change(fmt.Sprintf("scoreboard players add %s %s %d", holder, objective, amount), nil, "Added ")
+}
+
+// ResetScore removes a holder's score in one objective, or in all objectives if objective is empty
+func (s *ScoreboardService) ResetScore(holder, objective string) error {
+	if err := validateHolder(holder); err != nil {
+		return err
+	}
+	command := "scoreboard players reset " + holder
+	if objective != "" {
+		if err := validateScoreboardName("objective", objective); err != nil {
+			return err
+		}
+		command += " " + objective
+	}
+	return s.change(command, nil, "Reset ")
+}
+
+// AddTeam creates a team
+func (s *ScoreboardService) AddTeam(name string) error {
+	if err := validateScoreboardName("team", name); err != nil {
+		return err
+	}
+	return s.change("team add "+name, func(board *Scoreboard) {
+		if board.team(name) != nil {
+			return
+		}
+		board.Teams = append(board.Teams, ScoreboardTeam{
+			Name: name, DisplayName: name, Color: "reset", FriendlyFire: true, NameTagVisibility: "always", Members: []string{},
+		})
+		sort.Slice(board.Teams, func(i, j int) bool { return board.Teams[i].Name < board.Teams[j].Name })
+	}, "Created team")
+}
+
+// RemoveTeam deletes a team
+func (s *ScoreboardService) RemoveTeam(name string) error {
+	if err := validateScoreboardName("team", name); err != nil {
+		return err
+	}
+	return s.change("team remove "+name, func(board *Scoreboard) {
+		board.Teams = slices.DeleteFunc(board.Teams, func(t ScoreboardTeam) bool { return t.Name == name })
+	}, "Removed team")
+}
+
+// TeamOptions are the team settings edited together on the scoreboard page
+type TeamOptions struct {
+	Color             string
+	Prefix            string
+	FriendlyFire      bool
+	NameTagVisibility string
+}
+
+// ConfigureTeam applies the options that differ from the team's current settings. The server
+// rejects modify commands that change nothing, so unchanged options are skipped.
+func (s *ScoreboardService) ConfigureTeam(name string, options TeamOptions) error {
+	if err := validateScoreboardName("team", name); err != nil {
+		return err
+	}
+	if !slices.Contains(TeamColors, options.Color) {
+		return fmt.Errorf("unknown team color: %s", options.Color)
+	}
+	if !slices.Contains(NameTagVisibilities, options.NameTagVisibility) {
+		return fmt.Errorf("unknown nametag visibility: %s", options.NameTagVisibility)
+	}
+	board, err := s.GetScoreboard()
+	if err != nil {
+		return err
+	}
+	current := board.team(name)
+	if current == nil {
+		// not saved yet: apply everything
+		current = &ScoreboardTeam{FriendlyFire: !options.FriendlyFire}
+	}
+
+	type modification struct {
+		command string
+		success string
+		apply   func(team *ScoreboardTeam)
+	}
+	var modifications []modification
+	if options.Color != current.Color {
+		modifications = append(modifications, modification{fmt.Sprintf("team modify %s color %s", name, options.Color), "Updated the color",
+			func(team *ScoreboardTeam) { team.Color = options.Color }})
+	}
+	if options.Prefix != current.Prefix {
+		quoted, _ := json.Marshal(options.Prefix)
+		modifications = append(modifications, modification{fmt.Sprintf("team modify %s prefix %s", name, quoted), "Team prefix set",
+			func(team *ScoreboardTeam) { team.Prefix = options.Prefix }})
+	}
+	if options.FriendlyFire != current.FriendlyFire {
+		success := "Disabled friendly fire"
+		if options.FriendlyFire {
+			success = "Enabled friendly fire"
+		}
+		modifications = append(modifications, modification{fmt.Sprintf("team modify %s friendlyFire %t", name, options.FriendlyFire), success,
+			func(team *ScoreboardTeam) { team.FriendlyFire = options.FriendlyFire }})
+	}
+	if options.NameTagVisibility != current.NameTagVisibility {
+		modifications = append(modifications, modification{fmt.Sprintf("team modify %s nametagVisibility %s", name, options.NameTagVisibility), "Nametag visibility is now",
+			func(team *ScoreboardTeam) { team.NameTagVisibility = options.NameTagVisibility }})
+	}
+
+	for _, m := range modifications {
+		err := s.change(m.command, func(board *Scoreboard) {
+			if team := board.team(name); team != nil {
+				m.apply(team)
+			}
+		}, m.success)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JoinTeam adds holders to a team, moving them out of their current team
+func (s *ScoreboardService) JoinTeam(team string, members []string) error {
+	if err := validateScoreboardName("team", team); err != nil {
+		return err
+	}
+	return s.changeMembers(members, func(member string) string {
+		return fmt.Sprintf("team join %s %s", team, member)
+	}, "Added ")
+}
+
+// LeaveTeam removes holders from their team
+func (s *ScoreboardService) LeaveTeam(members []string) error {
+	return s.changeMembers(members, func(member string) string {
+		return "team leave " + member
+	}, "Removed ")
+}
+
+// changeMembers runs one command per member, since a score holder argument takes a single name
+func (s *ScoreboardService) changeMembers(members []string, command func(member string) string, success string) error {
+	if len(members) == 0 {
+		return fmt.Errorf("no members given")
+	}
+	for _, member := range members {
+		if err := validateHolder(member); err != nil {
+			return err
+		}
+	}
+	for _, member := range members {
+		if err := executeExpecting(s.rconClient, command(member), success); err != nil {
+			return err
+		}
+	}
+	return nil
+}
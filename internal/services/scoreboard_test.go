@@ -0,0 +1,242 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeNBTTag encodes a named tag. Only the types needed by the tests are supported.
+func writeNBTTag(b *bytes.Buffer, name string, value any) {
+	header := func(tagType byte) {
+		b.WriteByte(tagType)
+		binary.Write(b, binary.BigEndian, uint16(len(name)))
+		b.WriteString(name)
+	}
+	writeString := func(s string) {
+		binary.Write(b, binary.BigEndian, uint16(len(s)))
+		b.WriteString(s)
+	}
+	switch v := value.(type) {
+	case int8:
+		header(1)
+		b.WriteByte(byte(v))
+	case int32:
+		header(3)
+		binary.Write(b, binary.BigEndian, v)
+	case string:
+		header(8)
+		writeString(v)
+	case []string:
+		header(9)
+		b.WriteByte(8)
+		binary.Write(b, binary.BigEndian, int32(len(v)))
+		for _, s := range v {
+			writeString(s)
+		}
+	case []map[string]any:
+		header(9)
+		b.WriteByte(10)
+		binary.Write(b, binary.BigEndian, int32(len(v)))
+		for _, elem := range v {
+			writeNBTCompound(b, elem)
+		}
+	case map[string]any:
+		header(10)
+		writeNBTCompound(b, v)
+	}
+}
+
+func writeNBTCompound(b *bytes.Buffer, c map[string]any) {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeNBTTag(b, k, c[k])
+	}
+	b.WriteByte(0)
+}
+
+func writeScoreboardDat(t *testing.T, dataDir string) {
+	t.Helper()
+	data := map[string]any{
+		"Objectives": []map[string]any{
+			{"Name": "kills", "CriteriaName": "playerKillCount", "DisplayName": `{"text":"Kills"}`, "RenderType": "integer"},
+			{"Name": "deaths", "CriteriaName": "deathCount", "DisplayName": "Deaths", "RenderType": "integer"},
+			{"Name": "points", "CriteriaName": "dummy", "DisplayName": map[string]any{"text": "Pts"}, "RenderType": "integer"},
+		},
+		"PlayerScores": []map[string]any{
+			{"Name": "Steve", "Objective": "kills", "Score": int32(3)},
+		},
+		"DisplaySlots": map[string]any{"slot_1": "kills", "list": "deaths"},
+		"Teams": []map[string]any{
+			{
+				"Name": "red", "DisplayName": `"Red Team"`, "TeamColor": "red", "MemberNamePrefix": `"[R] "`,
+				"AllowFriendlyFire": int8(0), "NameTagVisibility": "always", "Players": []string{"Steve"},
+			},
+		},
+	}
+	var b bytes.Buffer
+	writeNBTTag(&b, "", map[string]any{"DataVersion": int32(3955), "data": data})
+
+	path := filepath.Join(dataDir, "world", "data", "scoreboard.dat")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	gz.Write(b.Bytes())
+	gz.Close()
+}
+
+func TestScoreboardService_GetScoreboard(t *testing.T) {
+	dataDir := t.TempDir()
+	writeScoreboardDat(t, dataDir)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"scoreboard players list":       {out: "There are 2 tracked entity/entities: Steve, #global"},
+		"scoreboard players list Steve": {out: "Steve has 2 score(s):[Kills]: 7[Deaths]: 1"},
+		"scoreboard players list #global": {
+			out: "#global has 1 score(s):[Pts]: -4",
+		},
+		"team list red": {out: "Team [Red Team] has 2 member(s): Steve, Alex"},
+	}}
+	svc := NewScoreboardService(rconClient, &fileClient)
+
+	board, err := svc.GetScoreboard()
+	if err != nil {
+		t.Fatalf("GetScoreboard: %v", err)
+	}
+	wantObjectives := []ScoreboardObjective{
+		{Name: "deaths", Criteria: "deathCount", DisplayName: "Deaths", DisplaySlots: []string{"list"}},
+		{Name: "kills", Criteria: "playerKillCount", DisplayName: "Kills", DisplaySlots: []string{"sidebar"}},
+		{Name: "points", Criteria: "dummy", DisplayName: "Pts"},
+	}
+	if !reflect.DeepEqual(board.Objectives, wantObjectives) {
+		t.Fatalf("objectives = %+v, want %+v", board.Objectives, wantObjectives)
+	}
+	wantTeams := []ScoreboardTeam{
+		{Name: "red", DisplayName: "Red Team", Color: "red", Prefix: "[R] ", NameTagVisibility: "always", Members: []string{"Steve", "Alex"}},
+	}
+	if !reflect.DeepEqual(board.Teams, wantTeams) {
+		t.Fatalf("teams = %+v, want %+v", board.Teams, wantTeams)
+	}
+	if !board.LiveScores || board.Scores["Steve"]["kills"] != 7 || board.Scores["#global"]["points"] != -4 {
+		t.Fatalf("scores = %+v, live = %v", board.Scores, board.LiveScores)
+	}
+
+	rows := board.Rows("kills", true)
+	if rows[0].Holder != "Steve" || *rows[0].Values[1] != 7 || rows[0].Values[2] != nil || rows[1].Holder != "#global" {
+		t.Fatalf("rows sorted by kills = %+v", rows)
+	}
+
+	// with the server offline scores come from the last save
+	offline := NewScoreboardService(&fakeRconClient{}, &fileClient)
+	board, err = offline.GetScoreboard()
+	if err != nil {
+		t.Fatalf("GetScoreboard offline: %v", err)
+	}
+	if board.LiveScores || board.Scores["Steve"]["kills"] != 3 {
+		t.Fatalf("offline scores = %+v, live = %v", board.Scores, board.LiveScores)
+	}
+}
+
+func TestScoreboardService_Changes(t *testing.T) {
+	dataDir := t.TempDir()
+	writeScoreboardDat(t, dataDir)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		`scoreboard objectives add wins dummy "Event Wins"`: {out: "Created new objective [Event Wins]"},
+		"scoreboard objectives add kills dummy":             {out: "An objective already exists by that name"},
+		"scoreboard players remove Steve kills 2":           {out: "Removed 2 from [Kills] for Steve (now 1)"},
+		"team modify red friendlyFire true":                 {out: "Enabled friendly fire for team [Red Team]"},
+		"team modify red nametagVisibility never":           {out: `Nametag visibility is now "never" for team [Red Team]`},
+		"team join red Alex":                                {out: "Added Alex to team [Red Team]"},
+		"team join red Notch":                               {out: "Added Notch to team [Red Team]"},
+		"scoreboard players list":                           {out: "There are no tracked entities"},
+		"team list red":                                     {out: "Team [Red Team] has 1 member(s): Steve"},
+	}}
+	svc := NewScoreboardService(rconClient, &fileClient)
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	if err := svc.AddObjective("wins", "dummy", "Event Wins"); err != nil {
+		t.Fatalf("AddObjective: %v", err)
+	}
+	if err := svc.AddObjective("kills", "dummy", ""); err == nil || err.Error() != "An objective already exists by that name" {
+		t.Fatalf("AddObjective duplicate = %v, want server message", err)
+	}
+	if err := svc.AddObjective("bad name", "dummy", ""); err == nil {
+		t.Fatalf("expected error for objective name with space")
+	}
+	if err := svc.AddScore("Steve", "kills", -2); err != nil {
+		t.Fatalf("AddScore: %v", err)
+	}
+	if err := svc.JoinTeam("red", []string{"Alex", "Notch"}); err != nil {
+		t.Fatalf("JoinTeam: %v", err)
+	}
+
+	// only the options that differ from the saved team are sent
+	rconClient.received = nil
+	err := svc.ConfigureTeam("red", TeamOptions{Color: "red", Prefix: "[R] ", FriendlyFire: true, NameTagVisibility: "never"})
+	if err != nil {
+		t.Fatalf("ConfigureTeam: %v", err)
+	}
+	var modifications []string
+	for _, command := range rconClient.received {
+		if strings.HasPrefix(command, "team modify ") {
+			modifications = append(modifications, command)
+		}
+	}
+	want := []string{"team modify red friendlyFire true", "team modify red nametagVisibility never"}
+	if !reflect.DeepEqual(modifications, want) {
+		t.Fatalf("modifications = %q, want %q", modifications, want)
+	}
+	if err := svc.ConfigureTeam("red", TeamOptions{Color: "pink", NameTagVisibility: "always"}); err == nil {
+		t.Fatalf("expected error for unknown color")
+	}
+	for _, command := range rconClient.received {
+		if command == "save-all" {
+			t.Fatalf("changes must not save the world")
+		}
+	}
+
+	// changes show before the server saves them
+	board, err := svc.GetScoreboard()
+	if err != nil {
+		t.Fatalf("GetScoreboard: %v", err)
+	}
+	wins := board.objective("wins")
+	if wins == nil || wins.Criteria != "dummy" || wins.DisplayName != "Event Wins" {
+		t.Fatalf("objectives = %+v", board.Objectives)
+	}
+	if red := board.team("red"); red == nil || !red.FriendlyFire || red.NameTagVisibility != "never" {
+		t.Fatalf("teams = %+v", board.Teams)
+	}
+
+	// a later save is trusted over the remembered changes
+	writeScoreboardDat(t, dataDir)
+	path := filepath.Join(dataDir, "world", "data", "scoreboard.dat")
+	os.Chtimes(path, now.Add(time.Minute), now.Add(time.Minute))
+	if board, _ := svc.GetScoreboard(); board.objective("wins") != nil || len(svc.pending) != 0 {
+		t.Fatalf("objectives = %+v, pending = %d", board.Objectives, len(svc.pending))
+	}
+}
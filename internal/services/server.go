@@ -9,6 +9,13 @@ import (
 
 type ServerService struct {
 	rconClient rcon.CommandExecutor
+	fileClient ServerFileSystemAccessor
+}
+
+// ServerFileSystemAccessor is the subset of the files client used to read the world spawn
+type ServerFileSystemAccessor interface {
+	GetAbsolutePath(path string) (string, error)
+	ReadFile(path string) (string, error)
 }
 
 type ServerPlayerInfo struct {
@@ -18,7 +25,12 @@ type ServerPlayerInfo struct {
 }
 
 func NewServerServiceFromRconClient(rconClient rcon.CommandExecutor) *ServerService {
-	return &ServerService{rconClient}
+	return &ServerService{rconClient: rconClient}
+}
+
+// NewServerService creates a ServerService that can also teleport players to the world spawn
+func NewServerService(rconClient rcon.CommandExecutor, fileClient ServerFileSystemAccessor) *ServerService {
+	return &ServerService{rconClient: rconClient, fileClient: fileClient}
 }
 
 func (s *ServerService) GetServerPlayerInfo() (ServerPlayerInfo, error) {
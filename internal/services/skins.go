@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"mc-admin/internal/clients/geyser"
+	"mc-admin/internal/clients/profiles"
+	"mc-admin/internal/skinrender"
+	"mc-admin/internal/utils"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// skinRefreshInterval is how long a player's skin is trusted before asking again
+	skinRefreshInterval = 6 * time.Hour
+	// skinRetryInterval is shorter, a failed lookup should not leave a player on the default skin for long
+	skinRetryInterval = 10 * time.Minute
+	// skinLookupTimeout bounds the requests behind one image, the browser is waiting for it
+	skinLookupTimeout = 10 * time.Second
+	// defaultSkinID names the renders of the default skin in the cache
+	defaultSkinID = "default"
+)
+
+// SkinTextureLookup finds the skin of a Java account, implemented by profiles.MojangProvider
+type SkinTextureLookup interface {
+	Textures(ctx context.Context, uuid string) (profiles.Textures, bool, error)
+}
+
+// BedrockSkinLookup finds the skin of a Bedrock player, implemented by geyser.Client
+type BedrockSkinLookup interface {
+	Skin(ctx context.Context, xuid uint64) (geyser.Skin, bool, error)
+}
+
+// TextureDownloader fetches skin textures by ID, implemented by profiles.TextureClient
+type TextureDownloader interface {
+	Texture(ctx context.Context, id string) ([]byte, error)
+}
+
+// skinRecord is the cached skin of one player
+type skinRecord struct {
+	// TextureID is empty for players using the default skin
+	TextureID string    `json:"texture_id,omitempty"`
+	Slim      bool      `json:"slim,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Error is the reason the last lookup failed, the record is retried sooner then
+	Error string `json:"error,omitempty"`
+}
+
+// SkinService renders heads and body previews of players. Below cacheDir it keeps the skin of
+// each player by UUID, the textures by ID and the renders by texture ID, so players sharing a
+// skin share renders and a changed skin gets new ones. Players whose skin cannot be found get
+// the default skin, an image is always returned.
+type SkinService struct {
+	textures   SkinTextureLookup
+	bedrock    BedrockSkinLookup
+	downloader TextureDownloader
+	names      profiles.Resolver
+	cacheDir   string
+	now        func() time.Time
+
+	locks utils.KeyedMutex
+}
+
+// NewSkinService creates a SkinService caching below cacheDir. bedrock may be nil without
+// Floodgate, names may be nil when players are only requested by UUID.
+func NewSkinService(textures SkinTextureLookup, bedrock BedrockSkinLookup, downloader TextureDownloader, names profiles.Resolver, cacheDir string) *SkinService {
+	return &SkinService{
+		textures:   textures,
+		bedrock:    bedrock,
+		downloader: downloader,
+		names:      names,
+		cacheDir:   cacheDir,
+		now:        time.Now,
+	}
+}
+
+// lock serializes work on one cache entry while letting different entries proceed in parallel
+func (s *SkinService) lock(key string) func() {
+	return s.locks.Lock(key)
+}
+
+// HeadPath returns the path of a PNG of the player's head with the hat layer, size pixels wide.
+// player is a UUID or a name.
+func (s *SkinService) HeadPath(ctx context.Context, player string, size int) (string, error) {
+	if size < skinrender.MinHeadSize || size > skinrender.MaxHeadSize {
+		return "", fmt.Errorf("head size must be between %d and %d", skinrender.MinHeadSize, skinrender.MaxHeadSize)
+	}
+	return s.render(ctx, player, fmt.Sprintf("head-%d.png", size), func(skin image.Image, slim bool) image.Image {
+		return skinrender.Head(skin, size)
+	})
+}
+
+// BodyPath returns the path of a PNG of the player seen from the front, scale pixels per skin pixel
+func (s *SkinService) BodyPath(ctx context.Context, player string, scale int) (string, error) {
+	if scale < 1 || scale > skinrender.MaxBodyScale {
+		return "", fmt.Errorf("body scale must be between 1 and %d", skinrender.MaxBodyScale)
+	}
+	return s.render(ctx, player, fmt.Sprintf("body-%d.png", scale), func(skin image.Image, slim bool) image.Image {
+		return skinrender.Body(skin, slim, scale)
+	})
+}
+
+func (s *SkinService) render(ctx context.Context, player string, name string, draw func(skin image.Image, slim bool) image.Image) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, skinLookupTimeout)
+	defer cancel()
+
+	record := s.skinOf(ctx, player)
+	if record.TextureID != "" {
+		skin, err := s.texture(ctx, record.TextureID)
+		if err == nil {
+			return s.cachedRender(record.TextureID, record.Slim, name, skin, draw)
+		}
+		log.Printf("Using the default skin for %s: %v", player, err)
+	}
+	return s.cachedRender(defaultSkinID, false, name, skinrender.DefaultSkin(), draw)
+}
+
+// cachedRender draws a skin once per texture and render name. Textures are named by their hash,
+// so a cached render never goes stale.
+func (s *SkinService) cachedRender(textureID string, slim bool, name string, skin image.Image, draw func(skin image.Image, slim bool) image.Image) (string, error) {
+	if slim {
+		name = "slim-" + name
+	}
+	path := filepath.Join(s.cacheDir, "renders", textureID, name)
+	unlock := s.lock(path)
+	defer unlock()
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := writeFileAtomic(path, func(f *os.File) error { return png.Encode(f, draw(skin, slim)) }); err != nil {
+		return "", fmt.Errorf("failed to cache skin render: %w", err)
+	}
+	return path, nil
+}
+
+// texture returns a cached texture, downloading it the first time
+func (s *SkinService) texture(ctx context.Context, id string) (image.Image, error) {
+	path := filepath.Join(s.cacheDir, "textures", id+".png")
+	unlock := s.lock(path)
+	defer unlock()
+	if data, err := os.ReadFile(path); err == nil {
+		return skinrender.Decode(data)
+	}
+	if s.downloader == nil {
+		return nil, fmt.Errorf("no texture source is configured")
+	}
+	data, err := s.downloader.Texture(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	skin, err := skinrender.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, func(f *os.File) error { _, err := f.Write(data); return err }); err != nil {
+		log.Printf("Failed to cache skin texture %s: %v", id, err)
+	}
+	return skin, nil
+}
+
+// skinOf returns the skin of a player, looking it up again when the cached one is too old. A
+// player that cannot be identified uses the default skin.
+func (s *SkinService) skinOf(ctx context.Context, player string) skinRecord {
+	uuid, ok := normalizeUUID(player)
+	if !ok {
+		if s.names == nil {
+			return skinRecord{}
+		}
+		profile, found, err := s.names.Resolve(ctx, player)
+		if err != nil || !found {
+			return skinRecord{}
+		}
+		if uuid, ok = normalizeUUID(profile.UUID); !ok {
+			return skinRecord{}
+		}
+	}
+
+	path := filepath.Join(s.cacheDir, "players", uuid+".json")
+	unlock := s.lock(path)
+	defer unlock()
+
+	var cached skinRecord
+	if data, err := os.ReadFile(path); err == nil {
+		// records cached before texture IDs were validated are looked up again
+		if err := json.Unmarshal(data, &cached); err != nil || (cached.TextureID != "" && !profiles.ValidTextureID(cached.TextureID)) {
+			cached = skinRecord{}
+		}
+	}
+	maxAge := skinRefreshInterval
+	if cached.Error != "" {
+		maxAge = skinRetryInterval
+	}
+	if !cached.CheckedAt.IsZero() && s.now().Sub(cached.CheckedAt) < maxAge {
+		return cached
+	}
+
+	record, err := s.lookup(ctx, uuid)
+	if err != nil {
+		log.Printf("Failed to look up the skin of %s: %v", uuid, err)
+		// keep showing the last known skin until a lookup succeeds
+		record = skinRecord{TextureID: cached.TextureID, Slim: cached.Slim, Error: err.Error()}
+	}
+	record.CheckedAt = s.now()
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(path, func(f *os.File) error { _, err := f.Write(data); return err })
+	}
+	if err != nil {
+		log.Printf("Failed to cache the skin of %s: %v", uuid, err)
+	}
+	return record
+}
+
+// lookup asks the Global API for Bedrock players and the session server for Java accounts.
+// Offline mode UUIDs belong to no account and always use the default skin.
+func (s *SkinService) lookup(ctx context.Context, uuid string) (skinRecord, error) {
+	if xuid, ok := FloodgateXUID(uuid); ok {
+		if s.bedrock == nil {
+			return skinRecord{}, nil
+		}
+		skin, found, err := s.bedrock.Skin(ctx, xuid)
+		if err != nil || !found {
+			return skinRecord{}, err
+		}
+		return skinRecord{TextureID: skin.TextureID, Slim: skin.Slim}, nil
+	}
+	if s.textures == nil || isOfflineUUID(uuid) {
+		return skinRecord{}, nil
+	}
+	textures, found, err := s.textures.Textures(ctx, uuid)
+	if err != nil || !found {
+		return skinRecord{}, err
+	}
+	if textures.SkinURL == "" {
+		return skinRecord{}, nil
+	}
+	id := profiles.TextureID(textures.SkinURL)
+	if id == "" {
+		return skinRecord{}, fmt.Errorf("unexpected skin URL %q", textures.SkinURL)
+	}
+	return skinRecord{TextureID: id, Slim: textures.Slim}, nil
+}
+
+// isOfflineUUID reports whether a UUID is a name-based (version 3) UUID of an offline mode server
+func isOfflineUUID(uuid string) bool {
+	return len(uuid) == 36 && uuid[14] == '3'
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"mc-admin/internal/clients/geyser"
+	"mc-admin/internal/clients/profiles"
+	"mc-admin/internal/skinrender"
+	"os"
+	"testing"
+	"time"
+)
+
+const notchUUID = "069a79f4-44e9-4726-a5be-fca90e38aaf5"
+
+type fakeSkinSources struct {
+	javaSkins    map[string]profiles.Textures
+	bedrockSkins map[uint64]geyser.Skin
+	textures     map[string][]byte
+	javaErr      error
+	javaLookups  int
+	downloads    int
+}
+
+func (f *fakeSkinSources) Textures(ctx context.Context, uuid string) (profiles.Textures, bool, error) {
+	f.javaLookups++
+	if f.javaErr != nil {
+		return profiles.Textures{}, false, f.javaErr
+	}
+	textures, ok := f.javaSkins[uuid]
+	return textures, ok, nil
+}
+
+func (f *fakeSkinSources) Skin(ctx context.Context, xuid uint64) (geyser.Skin, bool, error) {
+	skin, ok := f.bedrockSkins[xuid]
+	return skin, ok, nil
+}
+
+func (f *fakeSkinSources) Texture(ctx context.Context, id string) ([]byte, error) {
+	f.downloads++
+	data, ok := f.textures[id]
+	if !ok {
+		return nil, profiles.ErrNoTexture
+	}
+	return data, nil
+}
+
+// solidSkin returns a skin texture whose face has one color
+func solidSkin(t *testing.T, c color.NRGBA) []byte {
+	skin := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 8; y < 16; y++ {
+		for x := 8; x < 16; x++ {
+			skin.SetNRGBA(x, y, c)
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, skin); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func readPNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return img
+}
+
+func TestSkinService_HeadPath(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	sources := &fakeSkinSources{
+		javaSkins: map[string]profiles.Textures{notchUUID: {SkinURL: "http://textures.minecraft.net/texture/aa11"}},
+		textures:  map[string][]byte{"aa11": solidSkin(t, red)},
+	}
+	names := &fakeMojangChecker{existsMap: map[string]bool{"Notch": true}, uuids: map[string]string{"Notch": notchUUID}}
+	s := NewSkinService(sources, nil, sources, names, t.TempDir())
+	ctx := context.Background()
+
+	path, err := s.HeadPath(ctx, "Notch", 16)
+	if err != nil {
+		t.Fatalf("HeadPath: %v", err)
+	}
+	head := readPNG(t, path)
+	if head.Bounds().Dx() != 16 || color.NRGBAModel.Convert(head.At(8, 8)) != red {
+		t.Fatalf("head %v with pixel %v", head.Bounds(), head.At(8, 8))
+	}
+
+	// a second size reuses the cached skin and texture
+	if _, err := s.HeadPath(ctx, notchUUID, 32); err != nil {
+		t.Fatalf("HeadPath: %v", err)
+	}
+	if sources.javaLookups != 1 || sources.downloads != 1 {
+		t.Fatalf("lookups = %d, downloads = %d", sources.javaLookups, sources.downloads)
+	}
+
+	if _, err := s.HeadPath(ctx, "Notch", 4096); err == nil {
+		t.Fatal("expected an error for an oversized head")
+	}
+}
+
+func TestSkinService_defaultSkin(t *testing.T) {
+	sources := &fakeSkinSources{}
+	names := &fakeMojangChecker{existsMap: map[string]bool{}, errMap: map[string]error{"Unknown": profiles.ErrNotCached}}
+	s := NewSkinService(sources, nil, sources, names, t.TempDir())
+	ctx := context.Background()
+
+	want := skinrender.Head(skinrender.DefaultSkin(), 8)
+	for _, player := range []string{"Unknown", OfflinePlayerUUID("Steve"), FloodgateUUID(2535428650000001)} {
+		path, err := s.HeadPath(ctx, player, 8)
+		if err != nil {
+			t.Fatalf("%s: %v", player, err)
+		}
+		if got := color.NRGBAModel.Convert(readPNG(t, path).At(4, 4)); got != want.At(4, 4) {
+			t.Fatalf("%s: pixel = %v, want the default skin", player, got)
+		}
+	}
+	// neither offline nor Bedrock players are looked up on the session server
+	if sources.javaLookups != 0 {
+		t.Fatalf("lookups = %d", sources.javaLookups)
+	}
+}
+
+func TestSkinService_bedrockSkin(t *testing.T) {
+	blue := color.NRGBA{B: 255, A: 255}
+	sources := &fakeSkinSources{
+		bedrockSkins: map[uint64]geyser.Skin{2535428650000001: {TextureID: "bb22", Slim: true}},
+		textures:     map[string][]byte{"bb22": solidSkin(t, blue)},
+	}
+	s := NewSkinService(sources, sources, sources, nil, t.TempDir())
+
+	path, err := s.BodyPath(context.Background(), FloodgateUUID(2535428650000001), 2)
+	if err != nil {
+		t.Fatalf("BodyPath: %v", err)
+	}
+	body := readPNG(t, path)
+	if body.Bounds().Dx() != 32 || color.NRGBAModel.Convert(body.At(16, 8)) != blue {
+		t.Fatalf("body %v with pixel %v", body.Bounds(), body.At(16, 8))
+	}
+}
+
+func TestSkinService_keepsSkinWhenLookupFails(t *testing.T) {
+	sources := &fakeSkinSources{
+		javaSkins: map[string]profiles.Textures{notchUUID: {SkinURL: "http://textures.minecraft.net/texture/aa11"}},
+		textures:  map[string][]byte{"aa11": solidSkin(t, color.NRGBA{R: 255, A: 255})},
+	}
+	s := NewSkinService(sources, nil, sources, nil, t.TempDir())
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	first, err := s.HeadPath(ctx, notchUUID, 8)
+	if err != nil {
+		t.Fatalf("HeadPath: %v", err)
+	}
+
+	now = now.Add(skinRefreshInterval)
+	sources.javaErr = &profiles.RateLimitError{Provider: "mojang", RetryAfter: time.Minute}
+	second, err := s.HeadPath(ctx, notchUUID, 8)
+	if err != nil || second != first {
+		t.Fatalf("HeadPath = %s, %v, want the last known skin %s", second, err, first)
+	}
+
+	// the failure is retried sooner than a successful lookup
+	now = now.Add(skinRetryInterval)
+	sources.javaErr = errors.New("offline")
+	s.HeadPath(ctx, notchUUID, 8)
+	if sources.javaLookups != 3 {
+		t.Fatalf("lookups = %d", sources.javaLookups)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// jsonStateFile is the JSON file a service keeps its state in. Saves replace it atomically, so a
+// crash leaves either the old or the new state. A file that does not parse is never overwritten:
+// loading fails until an admin fixes or removes it, rather than starting over empty.
+type jsonStateFile struct {
+	path string
+}
+
+// load decodes the file into state, leaving state as it is when the file does not exist yet
+func (f jsonStateFile) load(state any) error {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("%s is not valid JSON, fix or remove it: %w", f.path, err)
+	}
+	return nil
+}
+
+// save writes state to the file
+func (f jsonStateFile) save(state any) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(f.path, func(file *os.File) error {
+		_, err := file.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes a file next to path and renames it into place, so readers and crashes
+// never see a partial file
+func writeFileAtomic(path string, write func(f *os.File) error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	// CreateTemp makes the file private, state files are as readable as the rest of the server
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "kits.json")
+	file := jsonStateFile{path: path}
+
+	state := map[string]int{"kept": 1}
+	if err := file.load(&state); err != nil || state["kept"] != 1 {
+		t.Fatalf("loading a missing file: state = %v, err = %v", state, err)
+	}
+	if err := file.save(map[string]int{"saved": 2}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	var loaded map[string]int
+	if err := file.load(&loaded); err != nil || loaded["saved"] != 2 {
+		t.Fatalf("load = %v, %v", loaded, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+
+	// A broken file is reported and left for the admin
+	os.WriteFile(path, []byte("{broken"), 0644)
+	if err := file.load(&loaded); err == nil || !strings.Contains(err.Error(), "fix or remove it") {
+		t.Fatalf("loading a broken file: err = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{broken" {
+		t.Fatalf("broken file was changed: %q", data)
+	}
+}
+
+func TestKitService_KeepsBrokenState(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "kits.json")
+	os.WriteFile(statePath, []byte("not json"), 0644)
+	svc := NewKitService(&fakeRconClient{}, nil, statePath)
+
+	if _, err := svc.GetKits(); err == nil {
+		t.Fatalf("GetKits succeeded with a broken state file")
+	}
+	if err := svc.SaveKit(Kit{Name: "starter", Items: []KitItem{{Item: "minecraft:bread", Count: 1}}}); err == nil || !strings.Contains(err.Error(), "fix or remove it") {
+		t.Fatalf("SaveKit with a broken state file: err = %v", err)
+	}
+	if data, _ := os.ReadFile(statePath); string(data) != "not json" {
+		t.Fatalf("broken state file was overwritten: %q", data)
+	}
+}
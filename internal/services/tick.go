@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mc-admin/internal/clients/rcon"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MinTickRate and MaxTickRate are the bounds of "tick rate"
+	MinTickRate = 1.0
+	MaxTickRate = 10000.0
+	// maxTickSteps bounds "tick step" and "tick sprint" from this panel
+	maxTickSteps = 1000000
+)
+
+// ErrTickUnsupported is returned when the server predates the tick command (1.20.3)
+var ErrTickUnsupported = errors.New("the tick command needs Minecraft 1.20.3 or newer")
+
+// TickState is the tick status of the server
+type TickState struct {
+	// Supported is false on servers without the tick command
+	Supported bool
+	// Status is "running normally", "lagging", "frozen" or "sprinting"
+	Status string
+	// Rate is the target tick rate
+	Rate float64
+	MSPT float64
+	// AutoUnfreezeAt is when a freeze made here is lifted, zero without one
+	AutoUnfreezeAt time.Time
+}
+
+// Frozen reports whether the game is frozen
+func (s TickState) Frozen() bool {
+	return s.Status == "frozen"
+}
+
+// TickService controls the tick rate of 1.20.3+ servers. Freezes are lifted automatically after
+// a timeout so a forgotten freeze does not stop the server for good.
+type TickService struct {
+	rconClient rcon.CommandExecutor
+	now        func() time.Time
+
+	mu             sync.Mutex
+	autoUnfreeze   *time.Timer
+	autoUnfreezeAt time.Time
+	// autoUnfreezeGeneration changes whenever the timer is replaced or stopped, so a timer that
+	// already fired while waiting for the lock knows it is outdated
+	autoUnfreezeGeneration uint64
+}
+
+// NewTickService creates a TickService
+func NewTickService(rconClient rcon.CommandExecutor) *TickService {
+	return &TickService{rconClient: rconClient, now: time.Now}
+}
+
+// execute runs a tick subcommand, reporting ErrTickUnsupported for servers without it
+func (s *TickService) execute(command string, successPrefixes ...string) error {
+	err := executeExpecting(s.rconClient, command, successPrefixes...)
+	if err != nil && strings.HasPrefix(err.Error(), "Unknown or incomplete command") {
+		return ErrTickUnsupported
+	}
+	return err
+}
+
+// GetState queries the tick status. Servers without the tick command report Supported false
+// rather than an error.
+func (s *TickService) GetState() (TickState, error) {
+	response, err := s.rconClient.ExecuteCommand("tick query")
+	if err != nil {
+		return TickState{}, fmt.Errorf("failed to execute tick query: %w", err)
+	}
+	if strings.HasPrefix(response, "Unknown or incomplete command") {
+		return TickState{}, nil
+	}
+	query, err := parseTickQuery(response)
+	if err != nil {
+		return TickState{}, fmt.Errorf("unexpected tick query response: %q", strings.TrimSpace(response))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := TickState{Supported: true, Status: query.Status, Rate: query.Rate, MSPT: query.MSPT}
+	if query.Status == "frozen" {
+		state.AutoUnfreezeAt = s.autoUnfreezeAt
+	}
+	return state, nil
+}
+
+// Freeze freezes the game and unfreezes it again after timeout, or never for a timeout of 0
+func (s *TickService) Freeze(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("auto-unfreeze timeout cannot be negative")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.execute("tick freeze", "The game is frozen"); err != nil {
+		return err
+	}
+	s.stopAutoUnfreezeLocked()
+	if timeout > 0 {
+		generation := s.autoUnfreezeGeneration
+		s.autoUnfreezeAt = s.now().Add(timeout)
+		s.autoUnfreeze = time.AfterFunc(timeout, func() { s.autoUnfreezeExpired(generation) })
+	}
+	return nil
+}
+
+// autoUnfreezeExpired lifts a freeze whose timeout passed, unless the timer of generation was
+// stopped or replaced in the meantime
+func (s *TickService) autoUnfreezeExpired(generation uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.autoUnfreezeGeneration {
+		return
+	}
+	s.autoUnfreezeGeneration++
+	s.autoUnfreeze = nil
+	s.autoUnfreezeAt = time.Time{}
+	if err := s.execute("tick unfreeze", "The game is running normally"); err != nil {
+		log.Printf("Failed to unfreeze the game after the timeout: %v", err)
+		return
+	}
+	log.Printf("Unfroze the game after the auto-unfreeze timeout")
+}
+
+func (s *TickService) stopAutoUnfreezeLocked() {
+	s.autoUnfreezeGeneration++
+	if s.autoUnfreeze != nil {
+		s.autoUnfreeze.Stop()
+		s.autoUnfreeze = nil
+	}
+	s.autoUnfreezeAt = time.Time{}
+}
+
+// Unfreeze lets the game run again and cancels a pending auto-unfreeze
+func (s *TickService) Unfreeze() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.execute("tick unfreeze", "The game is running normally"); err != nil {
+		return err
+	}
+	s.stopAutoUnfreezeLocked()
+	return nil
+}
+
+// SetRate sets the target tick rate, 20 being normal speed
+func (s *TickService) SetRate(rate float64) error {
+	if rate < MinTickRate || rate > MaxTickRate {
+		return fmt.Errorf("tick rate must be between %g and %g", MinTickRate, MaxTickRate)
+	}
+	return s.execute("tick rate "+strconv.FormatFloat(rate, 'f', -1, 64), "Set the target tick rate")
+}
+
+// Step runs a number of ticks while the game is frozen
+func (s *TickService) Step(ticks int) error {
+	if ticks < 1 || ticks > maxTickSteps {
+		return fmt.Errorf("steps must be between 1 and %d", maxTickSteps)
+	}
+	return s.execute(fmt.Sprintf("tick step %d", ticks), "Stepping ")
+}
+
+// Sprint runs a number of ticks as fast as possible. The server stays silent when the sprint
+// starts and reports its speed once it completes.
+func (s *TickService) Sprint(ticks int) error {
+	if ticks < 1 || ticks > maxTickSteps {
+		return fmt.Errorf("sprint must be between 1 and %d ticks", maxTickSteps)
+	}
+	command := fmt.Sprintf("tick sprint %d", ticks)
+	response, err := s.rconClient.ExecuteCommand(command)
+	if err != nil {
+		return fmt.Errorf("failed to execute %q: %w", command, err)
+	}
+	response = strings.TrimSpace(response)
+	switch {
+	case response == "" || strings.HasPrefix(response, "Interrupted the current tick sprint"):
+		return nil
+	case strings.HasPrefix(response, "Unknown or incomplete command"):
+		return ErrTickUnsupported
+	}
+	return fmt.Errorf("%s", response)
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTickService_GetState(t *testing.T) {
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"tick query": {out: "The game is frozenTarget tick rate: 10.0 per second.\nAverage time per tick: 0.2ms (Target: 100.0ms)"},
+	}}
+	state, err := NewTickService(rconClient).GetState()
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if !state.Supported || !state.Frozen() || state.Rate != 10 || state.MSPT != 0.2 {
+		t.Fatalf("state = %+v", state)
+	}
+
+	rconClient.responses["tick query"] = struct {
+		out string
+		err error
+	}{out: "Unknown or incomplete command, see below for error"}
+	state, err = NewTickService(rconClient).GetState()
+	if err != nil || state.Supported {
+		t.Fatalf("state on an old server = %+v, %v", state, err)
+	}
+}
+
+func TestTickService_Commands(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      func(svc *TickService) error
+		wantCommand string
+		response    string
+		wantErr     error
+	}{
+		{
+			name:        "rate",
+			action:      func(svc *TickService) error { return svc.SetRate(2.5) },
+			wantCommand: "tick rate 2.5",
+			response:    "Set the target tick rate to 2.5 per second",
+		},
+		{
+			name:    "rate too low",
+			action:  func(svc *TickService) error { return svc.SetRate(0.5) },
+			wantErr: errors.New(""),
+		},
+		{
+			name:        "step",
+			action:      func(svc *TickService) error { return svc.Step(5) },
+			wantCommand: "tick step 5",
+			response:    "Stepping 5 tick(s)",
+		},
+		{
+			name:        "step while running",
+			action:      func(svc *TickService) error { return svc.Step(1) },
+			wantCommand: "tick step 1",
+			response:    "Unable to step the game - the game must be frozen first",
+			wantErr:     errors.New(""),
+		},
+		{
+			name:        "sprint",
+			action:      func(svc *TickService) error { return svc.Sprint(1200) },
+			wantCommand: "tick sprint 1200",
+		},
+		{
+			name:        "old server",
+			action:      func(svc *TickService) error { return svc.Unfreeze() },
+			wantCommand: "tick unfreeze",
+			response:    "Unknown or incomplete command, see below for error",
+			wantErr:     ErrTickUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRconClient{responses: map[string]struct {
+				out string
+				err error
+			}{
+				tt.wantCommand: {out: tt.response},
+			}}
+			err := tt.action(NewTickService(fake))
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == ErrTickUnsupported && !errors.Is(err, ErrTickUnsupported) {
+				t.Fatalf("err = %v, want ErrTickUnsupported", err)
+			}
+			if tt.wantCommand == "" {
+				if len(fake.received) != 0 {
+					t.Fatalf("invalid input sent %v", fake.received)
+				}
+				return
+			}
+			if len(fake.received) != 1 || fake.received[0] != tt.wantCommand {
+				t.Fatalf("ExecuteCommand called with %v, want %q", fake.received, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestTickService_AutoUnfreeze(t *testing.T) {
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"tick freeze":   {out: "The game is frozen"},
+		"tick unfreeze": {out: "The game is running normally"},
+	}}
+	svc := NewTickService(rconClient)
+
+	// unfreezing by hand cancels the timeout
+	if err := svc.Freeze(time.Hour); err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	if svc.autoUnfreezeAt.IsZero() {
+		t.Fatalf("auto-unfreeze not scheduled")
+	}
+	if err := svc.Unfreeze(); err != nil {
+		t.Fatalf("Unfreeze: %v", err)
+	}
+	if svc.autoUnfreeze != nil || !svc.autoUnfreezeAt.IsZero() {
+		t.Fatalf("auto-unfreeze still scheduled")
+	}
+
+	// a timer that fired while a new freeze took the lock must leave the new freeze alone
+	if err := svc.Freeze(time.Hour); err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	svc.autoUnfreezeExpired(svc.autoUnfreezeGeneration - 1)
+	if svc.autoUnfreezeAt.IsZero() || len(rconClient.received) != 3 {
+		t.Fatalf("outdated timer unfroze the game: commands = %v", rconClient.received)
+	}
+
+	if err := svc.Freeze(10 * time.Millisecond); err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	want := []string{"tick freeze", "tick unfreeze", "tick freeze", "tick freeze", "tick unfreeze"}
+	if len(rconClient.received) != len(want) || rconClient.received[4] != want[4] {
+		t.Fatalf("commands = %v, want %v", rconClient.received, want)
+	}
+}
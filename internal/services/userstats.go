@@ -2,10 +2,18 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // UserCacheEntry represents one entry from usercache.json
@@ -21,71 +29,190 @@ type PlayerStats struct {
 	Name        string
 	Stats       map[string]map[string]int64
 	DataVersion int
+	// Bedrock is true for players joining through Geyser/Floodgate, XUID is their Xbox user ID
+	Bedrock bool
+	XUID    string
 }
 
-// UserStatsService reads the usercache and per-player stats files
+// statsRefreshInterval is how long a scan of the stats files is reused. A page load asks several
+// times, and the server saves stats every few minutes at most.
+const statsRefreshInterval = 5 * time.Second
+
+// statsDirs are the stats directories below the data directory, in order of preference. Some
+// setups keep the world in the data directory itself.
+var statsDirs = []string{filepath.Join("world", "stats"), "stats"}
+
+// statsFile is a parsed stats file and the file state it was parsed from
+type statsFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	stats   *PlayerStats
+}
+
+// UserStatsService keeps the usercache and the per-player stats files in memory. It checks the
+// files when asked, at most once per statsRefreshInterval, and parses only those whose
+// modification time or size changed. It is safe for concurrent use.
 type UserStatsService struct {
 	minecraftDataDir string
+	now              func() time.Time
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	// usercacheModTime is the modification time of the parsed usercache.json, zero if it is missing
+	usercacheModTime time.Time
 	// cached mapping from name->uuid and uuid->name
 	nameToUUID map[string]string
 	uuidToName map[string]string
+	// files holds the stats files by UUID
+	files map[string]*statsFile
 }
 
-// NewUserStatsService constructs the service and attempts to load the usercache.json
+// NewUserStatsService constructs the service. The files are read on first use, so a data
+// directory without usercache.json or stats yet is fine.
 func NewUserStatsService(minecraftDataDir string) (*UserStatsService, error) {
 	if minecraftDataDir == "" {
 		return nil, fmt.Errorf("MINECRAFT_DATA_DIR is empty")
 	}
-	s := &UserStatsService{
+	return &UserStatsService{
 		minecraftDataDir: minecraftDataDir,
+		now:              time.Now,
 		nameToUUID:       map[string]string{},
 		uuidToName:       map[string]string{},
+		files:            map[string]*statsFile{},
+	}, nil
+}
+
+// refreshLocked reloads what changed on disk since the last check
+func (s *UserStatsService) refreshLocked() {
+	now := s.now()
+	if !s.checkedAt.IsZero() && now.Sub(s.checkedAt) < statsRefreshInterval {
+		return
 	}
-	if err := s.loadUsercache(); err != nil {
-		return nil, err
+	s.checkedAt = now
+	if err := s.loadUsercacheLocked(); err != nil {
+		log.Printf("Failed to read usercache.json: %v", err)
 	}
-	return s, nil
+	s.loadStatsFilesLocked()
 }
 
-func (s *UserStatsService) loadUsercache() error {
+func (s *UserStatsService) loadUsercacheLocked() error {
 	path := filepath.Join(s.minecraftDataDir, "usercache.json")
+	stat, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.usercacheModTime = time.Time{}
+		clear(s.nameToUUID)
+		clear(s.uuidToName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stat.ModTime().Equal(s.usercacheModTime) {
+		return nil
+	}
 	buf, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 	var entries []UserCacheEntry
 	if err := json.Unmarshal(buf, &entries); err != nil {
+		// the server may be writing it, the old names are kept until the next check
 		return err
 	}
+	nameToUUID := make(map[string]string, len(entries))
+	uuidToName := make(map[string]string, len(entries))
 	for _, e := range entries {
-		s.nameToUUID[e.Name] = e.UUID
-		s.uuidToName[e.UUID] = e.Name
+		nameToUUID[e.Name] = e.UUID
+		uuidToName[e.UUID] = e.Name
 	}
+	s.nameToUUID, s.uuidToName = nameToUUID, uuidToName
+	s.usercacheModTime = stat.ModTime()
 	return nil
 }
 
+// loadStatsFilesLocked parses new and changed stats files and forgets deleted ones. A file that
+// fails to parse keeps its previous stats, it is most likely being written.
+func (s *UserStatsService) loadStatsFilesLocked() {
+	seen := map[string]bool{}
+	for _, dir := range statsDirs {
+		entries, err := os.ReadDir(filepath.Join(s.minecraftDataDir, dir))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			uuid, ok := strings.CutSuffix(entry.Name(), ".json")
+			if !ok || entry.IsDir() || seen[uuid] {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			seen[uuid] = true
+			path := filepath.Join(s.minecraftDataDir, dir, entry.Name())
+			cached := s.files[uuid]
+			if cached != nil && cached.path == path && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+				continue
+			}
+			stats, err := parseStatsFile(path, uuid)
+			if err != nil {
+				if cached == nil {
+					log.Printf("Skipping unreadable stats file %s: %v", path, err)
+				}
+				continue
+			}
+			s.files[uuid] = &statsFile{path: path, modTime: info.ModTime(), size: info.Size(), stats: stats}
+		}
+	}
+	for uuid := range s.files {
+		if !seen[uuid] {
+			delete(s.files, uuid)
+		}
+	}
+}
+
 // GetUUIDForName returns the uuid for the given username, ok=false if not found
 func (s *UserStatsService) GetUUIDForName(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLocked()
 	u, ok := s.nameToUUID[name]
 	return u, ok
 }
 
 // GetNameForUUID returns the username for the given uuid, ok=false if not found
 func (s *UserStatsService) GetNameForUUID(uuid string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLocked()
 	n, ok := s.uuidToName[uuid]
 	return n, ok
 }
 
-// GetStatsForUUID reads and parses world/stats/<uuid>.json
+// GetStatsForUUID returns the stats of world/stats/<uuid>.json, or of stats/<uuid>.json if the
+// world has none
 func (s *UserStatsService) GetStatsForUUID(uuid string) (*PlayerStats, error) {
-	statsPath := filepath.Join(s.minecraftDataDir, "world", "stats", uuid+".json")
-	// also try without the world prefix for setups that store stats under <datadir>/stats
-	if _, err := os.Stat(statsPath); os.IsNotExist(err) {
-		alt := filepath.Join(s.minecraftDataDir, "stats", uuid+".json")
-		if _, err2 := os.Stat(alt); err2 == nil {
-			statsPath = alt
-		}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLocked()
+	return s.statsLocked(uuid)
+}
+
+// statsLocked returns a copy of the cached stats with the current name. The stats maps are
+// shared, they are replaced rather than changed when the file changes.
+func (s *UserStatsService) statsLocked(uuid string) (*PlayerStats, error) {
+	file, ok := s.files[uuid]
+	if !ok {
+		return nil, fmt.Errorf("no stats for %s: %w", uuid, fs.ErrNotExist)
 	}
+	ps := *file.stats
+	ps.Name = s.uuidToName[uuid]
+	return &ps, nil
+}
+
+// parseStatsFile reads the stats file of the player with the UUID
+func parseStatsFile(statsPath string, uuid string) (*PlayerStats, error) {
 	f, err := os.Open(statsPath)
 	if err != nil {
 		return nil, err
@@ -140,35 +267,49 @@ func (s *UserStatsService) GetStatsForUUID(uuid string) (*PlayerStats, error) {
 		}
 	}
 
-	name, _ := s.GetNameForUUID(uuid)
-
-	return &PlayerStats{
+	ps := &PlayerStats{
 		UUID:        uuid,
-		Name:        name,
 		Stats:       stats,
 		DataVersion: dataVersion,
-	}, nil
+	}
+	if xuid, ok := FloodgateXUID(uuid); ok {
+		ps.Bedrock = true
+		ps.XUID = strconv.FormatUint(xuid, 10)
+	}
+	return ps, nil
 }
 
-// GetStatsForName looks up the uuid and reads its stats
+// GetStatsForName looks up the uuid and returns its stats
 func (s *UserStatsService) GetStatsForName(name string) (*PlayerStats, error) {
-	uuid, ok := s.GetUUIDForName(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLocked()
+	uuid, ok := s.nameToUUID[name]
 	if !ok {
 		return nil, fmt.Errorf("username not found: %s", name)
 	}
-	return s.GetStatsForUUID(uuid)
+	return s.statsLocked(uuid)
 }
 
-// GetAllPlayerStats returns stats for all users present in usercache.json (skips missing files)
+// GetAllPlayerStats returns stats for all users present in usercache.json that have a stats file,
+// and for Bedrock players whose stats files carry a Floodgate UUID the cache does not know, by name
 func (s *UserStatsService) GetAllPlayerStats() ([]*PlayerStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLocked()
 	var out []*PlayerStats
-	for uuid := range s.uuidToName {
-		ps, err := s.GetStatsForUUID(uuid)
-		if err != nil {
-			// skip missing/unreadable files
+	for uuid := range s.files {
+		if _, cached := s.uuidToName[uuid]; !cached && !IsFloodgateUUID(uuid) {
 			continue
 		}
+		ps, _ := s.statsLocked(uuid)
 		out = append(out, ps)
 	}
+	slices.SortFunc(out, func(a, b *PlayerStats) int {
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.UUID, b.UUID)
+	})
 	return out, nil
 }
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const alexUUID = "853c80ef-3c37-49fd-aa49-938b674adae6"
+
+func writeStatsFile(t *testing.T, dir string, uuid string, jumps int) string {
+	t.Helper()
+	path := filepath.Join(dir, uuid+".json")
+	content := fmt.Sprintf(`{"stats": {"minecraft:custom": {"minecraft:jump": %d}}, "DataVersion": 3953}`, jumps)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func jumpsOf(t *testing.T, s *UserStatsService, uuid string) int64 {
+	t.Helper()
+	ps, err := s.GetStatsForUUID(uuid)
+	if err != nil {
+		t.Fatalf("GetStatsForUUID(%s): %v", uuid, err)
+	}
+	return ps.Stats["minecraft:custom"]["minecraft:jump"]
+}
+
+func TestUserStatsService_reloadsChangedFiles(t *testing.T) {
+	dataDir := t.TempDir()
+	statsDir := filepath.Join(dataDir, "world", "stats")
+	os.MkdirAll(statsDir, 0755)
+	os.WriteFile(filepath.Join(dataDir, "usercache.json"), []byte(`[{"name": "Notch", "uuid": "`+notchUUID+`"}]`), 0644)
+	notchPath := writeStatsFile(t, statsDir, notchUUID, 10)
+
+	svc, err := NewUserStatsService(dataDir)
+	if err != nil {
+		t.Fatalf("NewUserStatsService: %v", err)
+	}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	if got := jumpsOf(t, svc, notchUUID); got != 10 {
+		t.Fatalf("jumps = %d", got)
+	}
+
+	// an unchanged modification time and size means the file is not read again
+	stat, _ := os.Stat(notchPath)
+	os.WriteFile(notchPath, []byte(fmt.Sprintf("%-*s", stat.Size(), "{")), 0644)
+	os.Chtimes(notchPath, stat.ModTime(), stat.ModTime())
+	now = now.Add(statsRefreshInterval)
+	if got := jumpsOf(t, svc, notchUUID); got != 10 {
+		t.Fatalf("jumps = %d, the unchanged file must not be parsed again", got)
+	}
+
+	writeStatsFile(t, statsDir, notchUUID, 42)
+	os.Chtimes(notchPath, stat.ModTime().Add(time.Minute), stat.ModTime().Add(time.Minute))
+	writeStatsFile(t, statsDir, alexUUID, 1)
+	os.WriteFile(filepath.Join(dataDir, "usercache.json"), []byte(`[{"name": "Notch", "uuid": "`+notchUUID+`"}, {"name": "Alex", "uuid": "`+alexUUID+`"}]`), 0644)
+	os.Chtimes(filepath.Join(dataDir, "usercache.json"), stat.ModTime().Add(time.Minute), stat.ModTime().Add(time.Minute))
+
+	// changes are only picked up once the last check is old enough
+	if got := jumpsOf(t, svc, notchUUID); got != 10 {
+		t.Fatalf("jumps = %d before the refresh interval passed", got)
+	}
+	now = now.Add(statsRefreshInterval)
+	if got := jumpsOf(t, svc, notchUUID); got != 42 {
+		t.Fatalf("jumps = %d after the file changed", got)
+	}
+	stats, _ := svc.GetAllPlayerStats()
+	if len(stats) != 2 || stats[0].Name != "Alex" || stats[1].Name != "Notch" {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	os.Remove(notchPath)
+	now = now.Add(statsRefreshInterval)
+	if _, err := svc.GetStatsForUUID(notchUUID); err == nil {
+		t.Fatal("expected an error for a deleted stats file")
+	}
+}
+
+func TestUserStatsService_statsDirFallback(t *testing.T) {
+	dataDir := t.TempDir()
+	os.MkdirAll(filepath.Join(dataDir, "stats"), 0755)
+	writeStatsFile(t, filepath.Join(dataDir, "stats"), notchUUID, 7)
+
+	// without usercache.json the stats are still found by UUID
+	svc, _ := NewUserStatsService(dataDir)
+	if got := jumpsOf(t, svc, notchUUID); got != 7 {
+		t.Fatalf("jumps = %d", got)
+	}
+	if _, err := svc.GetStatsForName("Notch"); err == nil {
+		t.Fatal("expected an error for a name missing from usercache.json")
+	}
+}
+
+func TestUserStatsService_concurrentAccess(t *testing.T) {
+	dataDir := t.TempDir()
+	statsDir := filepath.Join(dataDir, "world", "stats")
+	os.MkdirAll(statsDir, 0755)
+	os.WriteFile(filepath.Join(dataDir, "usercache.json"), []byte(`[{"name": "Notch", "uuid": "`+notchUUID+`"}]`), 0644)
+	writeStatsFile(t, statsDir, notchUUID, 1)
+
+	svc, _ := NewUserStatsService(dataDir)
+	var mu sync.Mutex
+	now := time.Now()
+	svc.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(statsRefreshInterval)
+		return now
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if stats, _ := svc.GetAllPlayerStats(); len(stats) != 1 {
+					t.Errorf("stats = %+v", stats)
+					return
+				}
+				svc.GetStatsForName("Notch")
+			}
+		}()
+	}
+	wg.Wait()
+}
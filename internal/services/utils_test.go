@@ -1,6 +1,11 @@
 package services
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"mc-admin/internal/clients/profiles"
+)
 
 type fakeRconClient struct {
 	responses map[string]struct {
@@ -21,16 +26,26 @@ func (f *fakeRconClient) ExecuteCommand(cmd string) (string, error) {
 type fakeMojangChecker struct {
 	existsMap map[string]bool
 	errMap    map[string]error
+	uuids     map[string]string
 }
 
-func (f *fakeMojangChecker) CheckMojangUsernameExists(username string) (bool, error) {
+func (f *fakeMojangChecker) Resolve(ctx context.Context, username string) (profiles.Profile, bool, error) {
 	if err, ok := f.errMap[username]; ok {
-		return false, err
+		return profiles.Profile{}, false, err
 	}
-	if exists, ok := f.existsMap[username]; ok {
-		return exists, nil
+	exists, ok := f.existsMap[username]
+	if !ok {
+		return profiles.Profile{}, false, fmt.Errorf("unexpected username: %s", username)
 	}
-	return false, fmt.Errorf("unexpected username: %s", username)
+	if !exists {
+		return profiles.Profile{}, false, nil
+	}
+	uuid, ok := f.uuids[username]
+	if !ok {
+		// Any stable UUID will do for names the test does not care about
+		uuid = OfflinePlayerUUID(username)
+	}
+	return profiles.Profile{UUID: uuid, Name: username}, true, nil
 }
 
 type fakeFileClient struct {
@@ -43,3 +58,8 @@ func (f *fakeFileClient) ReadFile(path string) (string, error) {
 	}
 	return "", fmt.Errorf("file not found: %s", path)
 }
+
+// GetAbsolutePath reports every file as missing, fakeFileClient only serves ReadFile
+func (f *fakeFileClient) GetAbsolutePath(path string) (string, error) {
+	return "", fmt.Errorf("file not found: %s: %w", path, fs.ErrNotExist)
+}
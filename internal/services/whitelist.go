@@ -1,46 +1,120 @@
 package services
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"mc-admin/internal/clients/ashcon"
+	"io/fs"
+	"mc-admin/internal/clients/profiles"
 	"mc-admin/internal/clients/rcon"
 	"mc-admin/internal/utils"
+	"os"
+	"path"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
+const (
+	whitelistFile = "whitelist.json"
+	userCacheFile = "usercache.json"
+	// userCacheTimeFormat is the layout of expiresOn in usercache.json
+	userCacheTimeFormat = "2006-01-02 15:04:05 -0700"
+	// profileLookupTimeout bounds resolving a name through every profile provider
+	profileLookupTimeout = 30 * time.Second
+)
+
+// ErrAlreadyWhitelisted is returned when adding a player who is already on the whitelist
+var ErrAlreadyWhitelisted = errors.New("already whitelisted")
+
+// ErrNotWhitelisted is returned when removing a player who is not on the whitelist
+var ErrNotWhitelisted = errors.New("not whitelisted")
+
 type WhitelistFileSystemAccessor interface {
 	ReadFile(path string) (string, error)
+	GetAbsolutePath(path string) (string, error)
 }
 
 type WhitelistService struct {
 	rconClient           rcon.CommandExecutor
-	mojangClient         ashcon.MojangUserNameChecker
+	profileResolver      profiles.Resolver
 	minecraftFilesClient WhitelistFileSystemAccessor
 	mojangCheckEnabled   bool
+	floodgate            Floodgate
+	xuids                XUIDResolver
+	// lookupInterval spaces Mojang lookups during an import
+	lookupInterval time.Duration
+	sleep          func(time.Duration)
+	now            func() time.Time
+
+	mu         sync.Mutex
+	bulkImport *WhitelistImport
+}
+
+// WhitelistEntry is a whitelisted profile. UUID is empty when whitelist.json could not be read
+// and the entry comes from `whitelist list`.
+type WhitelistEntry struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	// CurrentName is the name the player last joined with, set when it differs from Name
+	CurrentName string    `json:"current_name,omitempty"`
+	LastSeen    time.Time `json:"last_seen"`
+	// Bedrock is true for players joining through Geyser/Floodgate
+	Bedrock bool `json:"bedrock,omitempty"`
 }
 
 type WhitelistInfo struct {
-	PlayerNames []string `json:"player_names"`
-	Enabled     bool     `json:"enabled"`
+	PlayerNames []string         `json:"player_names"`
+	Entries     []WhitelistEntry `json:"entries"`
+	Enabled     bool             `json:"enabled"`
+	OnlineMode  bool             `json:"online_mode"`
+	// FromFile is false when whitelist.json is missing and only names are known
+	FromFile bool `json:"from_file"`
+	// BedrockPrefix starts the names of Bedrock players, empty without Floodgate or when the prefix
+	// does not tell them apart from Java players
+	BedrockPrefix string `json:"bedrock_prefix,omitempty"`
+}
+
+// whitelistFileEntry is an entry of whitelist.json
+type whitelistFileEntry struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
 }
 
-func NewWhitelistService(rconClient rcon.CommandExecutor, mojangClient ashcon.MojangUserNameChecker, minecraftFilesClient WhitelistFileSystemAccessor) *WhitelistService {
-	if mojangClient != nil {
+func NewWhitelistService(rconClient rcon.CommandExecutor, profileResolver profiles.Resolver, minecraftFilesClient WhitelistFileSystemAccessor) *WhitelistService {
+	if profileResolver != nil {
 		return &WhitelistService{
 			rconClient:           rconClient,
 			mojangCheckEnabled:   true,
-			mojangClient:         mojangClient,
+			profileResolver:      profileResolver,
 			minecraftFilesClient: minecraftFilesClient,
+			lookupInterval:       defaultLookupInterval,
+			sleep:                time.Sleep,
+			now:                  time.Now,
 		}
 	}
 	return &WhitelistService{
 		rconClient:           rconClient,
-		mojangClient:         nil,
+		profileResolver:      nil,
 		mojangCheckEnabled:   false,
 		minecraftFilesClient: minecraftFilesClient,
+		lookupInterval:       defaultLookupInterval,
+		sleep:                time.Sleep,
+		now:                  time.Now,
 	}
 }
 
+// UseFloodgate lets the service whitelist Bedrock players. Their UUIDs are derived from the XUID
+// looked up with xuids, or left to fwhitelist when the Floodgate plugin provides it.
+func (s *WhitelistService) UseFloodgate(floodgate Floodgate, xuids XUIDResolver) {
+	s.floodgate = floodgate
+	s.xuids = xuids
+}
+
 func getWhitelistEnabledStatus(fileClient WhitelistFileSystemAccessor) (bool, error) {
 	content, err := fileClient.ReadFile("server.properties")
 	if err != nil {
@@ -91,15 +165,169 @@ func (s *WhitelistService) GetWhitelistInfo() (WhitelistInfo, error) {
 		return WhitelistInfo{}, fmt.Errorf("failed to get whitelist enabled status: %w", err)
 	}
 
-	playerNames, err := getWhitelistPlayerNames(s.rconClient)
+	entries, fromFile, err := s.loadWhitelistEntries()
 	if err != nil {
 		return WhitelistInfo{}, fmt.Errorf("failed to get whitelist player names: %w", err)
 	}
+	if fromFile {
+		s.annotateWhitelistEntries(entries)
+	}
 
-	return WhitelistInfo{
+	playerNames := make([]string, len(entries))
+	for i, entry := range entries {
+		playerNames[i] = entry.Name
+		entries[i].Bedrock = IsFloodgateUUID(entry.UUID) || s.floodgate.IsBedrockName(entry.Name)
+	}
+	info := WhitelistInfo{
 		PlayerNames: playerNames,
+		Entries:     entries,
 		Enabled:     enabled,
-	}, nil
+		OnlineMode:  s.isOnlineMode(),
+		FromFile:    fromFile,
+	}
+	info.BedrockPrefix = s.floodgate.NamePrefix()
+	return info, nil
+}
+
+// loadWhitelistEntries reads whitelist.json, falling back to the names from `whitelist list` when
+// the file does not exist
+func (s *WhitelistService) loadWhitelistEntries() ([]WhitelistEntry, bool, error) {
+	fileEntries, err := s.readWhitelistFile()
+	if errors.Is(err, fs.ErrNotExist) {
+		playerNames, err := getWhitelistPlayerNames(s.rconClient)
+		if err != nil {
+			return nil, false, err
+		}
+		entries := make([]WhitelistEntry, len(playerNames))
+		for i, name := range playerNames {
+			entries[i] = WhitelistEntry{Name: name}
+		}
+		return entries, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	entries := make([]WhitelistEntry, len(fileEntries))
+	for i, entry := range fileEntries {
+		entries[i] = WhitelistEntry{UUID: entry.UUID, Name: entry.Name}
+	}
+	return entries, true, nil
+}
+
+// readWhitelistFile reads whitelist.json directly, ReadFile truncates large files for display
+func (s *WhitelistService) readWhitelistFile() ([]whitelistFileEntry, error) {
+	filePath, err := s.minecraftFilesClient.GetAbsolutePath(whitelistFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var entries []whitelistFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", whitelistFile, err)
+	}
+	return entries, nil
+}
+
+// writeWhitelistFile replaces whitelist.json and tells the server to reload it
+func (s *WhitelistService) writeWhitelistFile(entries []WhitelistEntry) error {
+	fileEntries := make([]whitelistFileEntry, len(entries))
+	for i, entry := range entries {
+		fileEntries[i] = whitelistFileEntry{UUID: entry.UUID, Name: entry.Name}
+	}
+	data, err := json.MarshalIndent(fileEntries, "", "  ")
+	if err != nil {
+		return err
+	}
+	filePath, err := s.minecraftFilesClient.GetAbsolutePath(whitelistFile)
+	if err != nil {
+		return err
+	}
+	// the server reads the file as its own user, so it must not end up private
+	err = writeFileAtomic(filePath, func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", whitelistFile, err)
+	}
+	if _, err := s.rconClient.ExecuteCommand("whitelist reload"); err != nil {
+		return fmt.Errorf("failed to reload whitelist: %w", err)
+	}
+	return nil
+}
+
+// annotateWhitelistEntries fills in name changes and last-seen times from usercache.json and the
+// player data files. Both are best effort, a fresh server has neither.
+func (s *WhitelistService) annotateWhitelistEntries(entries []WhitelistEntry) {
+	userCache := map[string]UserCacheEntry{}
+	if filePath, err := s.minecraftFilesClient.GetAbsolutePath(userCacheFile); err == nil {
+		if data, err := os.ReadFile(filePath); err == nil {
+			var cached []UserCacheEntry
+			if json.Unmarshal(data, &cached) == nil {
+				for _, entry := range cached {
+					userCache[strings.ToLower(entry.UUID)] = entry
+				}
+			}
+		}
+	}
+
+	playerDataDir := path.Join(getLevelName(s.minecraftFilesClient), "playerdata")
+	for i := range entries {
+		entry := &entries[i]
+		if cached, ok := userCache[strings.ToLower(entry.UUID)]; ok {
+			if cached.Name != "" && cached.Name != entry.Name {
+				entry.CurrentName = cached.Name
+			}
+			// The server keeps a player in the cache for a month after they last joined
+			if expires, err := time.Parse(userCacheTimeFormat, cached.ExpiresOn); err == nil {
+				entry.LastSeen = expires.AddDate(0, -1, 0)
+			}
+		}
+		if entry.UUID == "" {
+			continue
+		}
+		// Player data is saved while the player is online, so it is more recent than the join time
+		filePath, err := s.minecraftFilesClient.GetAbsolutePath(path.Join(playerDataDir, entry.UUID+".dat"))
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(filePath); err == nil && info.ModTime().After(entry.LastSeen) {
+			entry.LastSeen = info.ModTime()
+		}
+	}
+}
+
+// isOnlineMode reports whether the server authenticates players with Mojang, which is the default
+func (s *WhitelistService) isOnlineMode() bool {
+	value, ok, err := readServerProperty(s.minecraftFilesClient, "online-mode")
+	return err != nil || !ok || value != "false"
+}
+
+// OfflinePlayerUUID returns the UUID an offline-mode server gives a player, a version 3 UUID of
+// "OfflinePlayer:<name>"
+func OfflinePlayerUUID(name string) string {
+	sum := md5.Sum([]byte("OfflinePlayer:" + name))
+	sum[6] = sum[6]&0x0f | 0x30
+	sum[8] = sum[8]&0x3f | 0x80
+	return formatUUID(sum[:])
+}
+
+// normalizeUUID returns uuid in the lower case, dashed form used by whitelist.json, ok=false if it
+// is not a UUID
+func normalizeUUID(uuid string) (string, bool) {
+	b, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(uuid), "-", ""))
+	if err != nil || len(b) != 16 {
+		return "", false
+	}
+	return formatUUID(b), true
+}
+
+func formatUUID(b []byte) string {
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
 }
 
 func (s *WhitelistService) EnableWhitelist() error {
@@ -124,6 +352,10 @@ func (s *WhitelistService) RemoveNameFromWhitelist(name string) error {
 		return fmt.Errorf("name cannot be empty")
 	}
 
+	if s.floodgate.IsBedrockName(trimmedName) {
+		return s.removeBedrockName(trimmedName)
+	}
+
 	command := fmt.Sprintf("whitelist remove %s", trimmedName)
 	_, err := s.rconClient.ExecuteCommand(command)
 	if err != nil {
@@ -133,6 +365,104 @@ func (s *WhitelistService) RemoveNameFromWhitelist(name string) error {
 	return nil
 }
 
+// RemoveUUIDFromWhitelist removes a player by UUID from whitelist.json. Unlike `whitelist remove`
+// this works after the player changed their name.
+func (s *WhitelistService) RemoveUUIDFromWhitelist(uuid string) (WhitelistEntry, error) {
+	normalized, ok := normalizeUUID(uuid)
+	if !ok {
+		return WhitelistEntry{}, fmt.Errorf("invalid UUID: %q", uuid)
+	}
+	entries, fromFile, err := s.loadWhitelistEntries()
+	if err != nil {
+		return WhitelistEntry{}, fmt.Errorf("failed to get current whitelist: %w", err)
+	}
+	if !fromFile {
+		return WhitelistEntry{}, fmt.Errorf("%s does not exist, remove players by name", whitelistFile)
+	}
+	index := slices.IndexFunc(entries, func(entry WhitelistEntry) bool {
+		id, _ := normalizeUUID(entry.UUID)
+		return id == normalized
+	})
+	if index < 0 {
+		return WhitelistEntry{}, fmt.Errorf("no whitelisted player has UUID %s: %w", normalized, ErrNotWhitelisted)
+	}
+	removed := entries[index]
+	if err := s.writeWhitelistFile(slices.Delete(entries, index, index+1)); err != nil {
+		return WhitelistEntry{}, fmt.Errorf("failed to remove %s from whitelist: %w", removed.Name, err)
+	}
+	return removed, nil
+}
+
+// FindWhitelisted returns the whitelist entry of a player by name. The UUID is only known when
+// whitelist.json exists.
+func (s *WhitelistService) FindWhitelisted(name string) (WhitelistEntry, bool, error) {
+	entries, _, err := s.loadWhitelistEntries()
+	if err != nil {
+		return WhitelistEntry{}, false, fmt.Errorf("failed to get current whitelist: %w", err)
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name, strings.TrimSpace(name)) {
+			return entry, true, nil
+		}
+	}
+	return WhitelistEntry{}, false, nil
+}
+
+// resolveWhitelistEntry fills in the UUID of a player who is not whitelisted yet. It is looked up on
+// Mojang in online mode when the username check is enabled and derived from the name in offline
+// mode, Bedrock players get the UUID of their XUID. A UUID that is already set is only normalized.
+func (s *WhitelistService) resolveWhitelistEntry(entry WhitelistEntry, onlineMode bool) (WhitelistEntry, error) {
+	if entry.UUID != "" {
+		uuid, ok := normalizeUUID(entry.UUID)
+		if !ok {
+			return entry, fmt.Errorf("invalid UUID %q for '%s'", entry.UUID, entry.Name)
+		}
+		entry.UUID = uuid
+		return entry, nil
+	}
+	if s.floodgate.IsBedrockName(entry.Name) {
+		return s.resolveBedrockEntry(entry)
+	}
+	if !onlineMode {
+		entry.UUID = OfflinePlayerUUID(entry.Name)
+		return entry, nil
+	}
+	if !s.mojangCheckEnabled {
+		return entry, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), profileLookupTimeout)
+	defer cancel()
+	profile, exists, err := s.profileResolver.Resolve(ctx, entry.Name)
+	if err != nil {
+		return entry, fmt.Errorf("failed to verify if name exists: %w", err)
+	}
+	if !exists {
+		return entry, fmt.Errorf("name '%s' does not exist", entry.Name)
+	}
+	uuid, ok := normalizeUUID(profile.UUID)
+	if !ok {
+		return entry, fmt.Errorf("invalid UUID %q for '%s'", profile.UUID, entry.Name)
+	}
+	entry.UUID = uuid
+	if profile.Name != "" {
+		entry.Name = profile.Name
+	}
+	return entry, nil
+}
+
+// needsLookup reports whether resolving the entry asks an external API, which imports space out
+func (s *WhitelistService) needsLookup(entry WhitelistEntry, onlineMode bool) bool {
+	if entry.UUID != "" {
+		return false
+	}
+	if s.floodgate.IsBedrockName(entry.Name) {
+		return s.xuids != nil
+	}
+	return onlineMode && s.mojangCheckEnabled
+}
+
+// AddNameToWhitelist whitelists a player, writing the resolved UUID to whitelist.json. Without a
+// UUID the server resolves the name itself via `whitelist add`.
 func (s *WhitelistService) AddNameToWhitelist(name string) error {
 	trimmedName := strings.TrimSpace(name)
 	if trimmedName == "" {
@@ -140,33 +470,112 @@ func (s *WhitelistService) AddNameToWhitelist(name string) error {
 	}
 
 	// Check if the name is already whitelisted
-	currentWhitelist, err := getWhitelistPlayerNames(s.rconClient)
+	currentWhitelist, fromFile, err := s.loadWhitelistEntries()
 	if err != nil {
 		return fmt.Errorf("failed to get current whitelist: %w", err)
 	}
-	for _, existingName := range currentWhitelist {
-		if strings.EqualFold(existingName, trimmedName) {
-			return fmt.Errorf("name '%s' is already whitelisted", trimmedName)
+	for _, existing := range currentWhitelist {
+		if strings.EqualFold(existing.Name, trimmedName) {
+			return fmt.Errorf("name '%s' is %w", trimmedName, ErrAlreadyWhitelisted)
 		}
 	}
 
-	exists := true
-	if s.mojangCheckEnabled {
-		exists, err := s.mojangClient.CheckMojangUsernameExists(trimmedName)
-		fmt.Printf("exists: %v, err: %v\n", exists, err)
+	if s.floodgate.IsBedrockName(trimmedName) && s.floodgate.WhitelistCommand {
+		return s.runFloodgateWhitelist("add", trimmedName)
+	}
+
+	entry, err := s.resolveWhitelistEntry(WhitelistEntry{Name: trimmedName}, s.isOnlineMode())
+	if err != nil {
+		return err
+	}
+
+	if entry.Bedrock && !fromFile {
+		return fmt.Errorf("Bedrock players can only be added to an existing %s without fwhitelist", whitelistFile)
+	}
+	if entry.UUID == "" || !fromFile {
+		command := fmt.Sprintf("whitelist add %s", entry.Name)
+		_, err = s.rconClient.ExecuteCommand(command)
 		if err != nil {
-			return fmt.Errorf("failed to verify if name exists: %w", err)
+			return fmt.Errorf("failed to add name to whitelist: %w", err)
 		}
+		return nil
 	}
-	if !exists {
-		return fmt.Errorf("name '%s' does not exist", trimmedName)
+
+	// A renamed player is still whitelisted under their old name
+	for _, existing := range currentWhitelist {
+		if id, _ := normalizeUUID(existing.UUID); id == entry.UUID {
+			return fmt.Errorf("'%s' is %w as '%s'", trimmedName, ErrAlreadyWhitelisted, existing.Name)
+		}
+	}
+	if err := s.writeWhitelistFile(append(currentWhitelist, entry)); err != nil {
+		return fmt.Errorf("failed to add name to whitelist: %w", err)
 	}
+	return nil
+}
 
-	command := fmt.Sprintf("whitelist add %s", trimmedName)
-	_, err = s.rconClient.ExecuteCommand(command)
+// resolveBedrockEntry derives the Floodgate UUID of a Bedrock player from their XUID. Bedrock
+// players have no Mojang account, so the username check does not apply.
+func (s *WhitelistService) resolveBedrockEntry(entry WhitelistEntry) (WhitelistEntry, error) {
+	entry.Bedrock = true
+	if s.xuids == nil {
+		return entry, fmt.Errorf("cannot look up the XUID of Bedrock player '%s'", entry.Name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), profileLookupTimeout)
+	defer cancel()
+	gamertag := s.floodgate.Gamertag(entry.Name)
+	xuid, found, err := lookupXUID(ctx, s.xuids, gamertag)
 	if err != nil {
-		return fmt.Errorf("failed to add name to whitelist: %w", err)
+		return entry, fmt.Errorf("failed to look up the XUID of '%s': %w", gamertag, err)
 	}
+	if !found {
+		return entry, fmt.Errorf("Bedrock player '%s' is unknown, they need to join a Geyser server once", gamertag)
+	}
+	entry.UUID = FloodgateUUID(xuid)
+	return entry, nil
+}
+
+// removeBedrockName removes a Bedrock player with fwhitelist, or by name from whitelist.json since
+// `whitelist remove` looks the name up on Mojang
+func (s *WhitelistService) removeBedrockName(name string) error {
+	if s.floodgate.WhitelistCommand {
+		return s.runFloodgateWhitelist("remove", name)
+	}
+	entries, fromFile, err := s.loadWhitelistEntries()
+	if err != nil {
+		return fmt.Errorf("failed to get current whitelist: %w", err)
+	}
+	if !fromFile {
+		return fmt.Errorf("%s does not exist, Bedrock players cannot be removed without fwhitelist", whitelistFile)
+	}
+	index := slices.IndexFunc(entries, func(entry WhitelistEntry) bool {
+		return strings.EqualFold(entry.Name, name)
+	})
+	if index < 0 {
+		return fmt.Errorf("'%s' is %w", name, ErrNotWhitelisted)
+	}
+	if err := s.writeWhitelistFile(slices.Delete(entries, index, index+1)); err != nil {
+		return fmt.Errorf("failed to remove name from whitelist: %w", err)
+	}
+	return nil
+}
 
+// runFloodgateWhitelist adds or removes a Bedrock player with the fwhitelist command of Floodgate,
+// which looks up the XUID itself. Floodgate answers after the lookup, so an empty reply is success.
+func (s *WhitelistService) runFloodgateWhitelist(action string, name string) error {
+	gamertag := s.floodgate.Gamertag(name)
+	response, err := s.rconClient.ExecuteCommand(fmt.Sprintf("fwhitelist %s %s", action, gamertag))
+	if err != nil {
+		return fmt.Errorf("failed to %s Bedrock player: %w", action, err)
+	}
+	response = strings.TrimSpace(formattingCodePattern.ReplaceAllString(response, ""))
+	lower := strings.ToLower(response)
+	switch {
+	case strings.Contains(lower, "already whitelisted"):
+		return fmt.Errorf("name '%s' is %w", name, ErrAlreadyWhitelisted)
+	case strings.Contains(lower, "not whitelisted"):
+		return fmt.Errorf("name '%s' is %w", name, ErrNotWhitelisted)
+	case strings.Contains(lower, "unknown") || strings.Contains(lower, "couldn't") || strings.Contains(lower, "error"):
+		return fmt.Errorf("fwhitelist: %s", response)
+	}
 	return nil
 }
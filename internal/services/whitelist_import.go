@@ -0,0 +1,388 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Formats accepted by StartWhitelistImport and produced by ExportWhitelist
+const (
+	WhitelistFormatJSON  = "json"
+	WhitelistFormatCSV   = "csv"
+	WhitelistFormatNames = "names"
+)
+
+// WhitelistFormats lists the import and export formats in the order the page offers them
+var WhitelistFormats = []string{WhitelistFormatJSON, WhitelistFormatCSV, WhitelistFormatNames}
+
+const (
+	// defaultLookupInterval keeps imports under the rate limit of the Mojang API behind Ashcon
+	defaultLookupInterval = 250 * time.Millisecond
+	// maxImportEntries bounds a single import, larger lists take too long to look up
+	maxImportEntries = 2000
+)
+
+// InvalidWhitelistName is an imported player that will not be added
+type InvalidWhitelistName struct {
+	Name   string
+	Reason string
+}
+
+// WhitelistImportPlan is the dry-run diff of an import against the current whitelist
+type WhitelistImportPlan struct {
+	Adds      []WhitelistEntry
+	Removes   []WhitelistEntry
+	Invalid   []InvalidWhitelistName
+	Unchanged int
+}
+
+// HasChanges reports whether applying the plan changes the whitelist
+func (p WhitelistImportPlan) HasChanges() bool {
+	return len(p.Adds) > 0 || len(p.Removes) > 0
+}
+
+// WhitelistImport reports the progress of checking an import, and its plan once checked
+type WhitelistImport struct {
+	Format  string
+	Replace bool
+	Running bool
+	Checked int
+	Total   int
+	Plan    WhitelistImportPlan
+	Applied bool
+
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Progress returns the share of checked players in percent
+func (i WhitelistImport) Progress() int {
+	if i.Total == 0 {
+		return 100
+	}
+	return i.Checked * 100 / i.Total
+}
+
+// ParseWhitelistImport reads players from a whitelist.json, a CSV with name and uuid columns or a
+// list with one name per line. An empty format is detected from the content. Returns the format used.
+func ParseWhitelistImport(content string, format string) ([]WhitelistEntry, string, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	if format == "" {
+		format = detectWhitelistFormat(content)
+	}
+
+	var entries []WhitelistEntry
+	switch format {
+	case WhitelistFormatJSON:
+		var fileEntries []whitelistFileEntry
+		if err := json.Unmarshal([]byte(content), &fileEntries); err != nil {
+			return nil, format, fmt.Errorf("invalid whitelist.json: %w", err)
+		}
+		for _, entry := range fileEntries {
+			entries = append(entries, WhitelistEntry{UUID: strings.TrimSpace(entry.UUID), Name: strings.TrimSpace(entry.Name)})
+		}
+	case WhitelistFormatCSV:
+		var err error
+		if entries, err = parseWhitelistCSV(content); err != nil {
+			return nil, format, err
+		}
+	case WhitelistFormatNames:
+		for _, line := range strings.Split(content, "\n") {
+			name := strings.TrimSpace(line)
+			if name != "" && !strings.HasPrefix(name, "#") {
+				entries = append(entries, WhitelistEntry{Name: name})
+			}
+		}
+	default:
+		return nil, format, fmt.Errorf("unknown whitelist format: %q", format)
+	}
+
+	// Lists pasted together from several sources often repeat names
+	unique := entries[:0]
+	for _, entry := range entries {
+		if findWhitelistEntry(unique, entry) < 0 {
+			unique = append(unique, entry)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, format, fmt.Errorf("no players found in the %s import", format)
+	}
+	if len(unique) > maxImportEntries {
+		return nil, format, fmt.Errorf("the import lists %d players, at most %d are supported", len(unique), maxImportEntries)
+	}
+	return unique, format, nil
+}
+
+func detectWhitelistFormat(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "[") {
+		return WhitelistFormatJSON
+	}
+	firstLine, _, _ := strings.Cut(trimmed, "\n")
+	if strings.Contains(firstLine, ",") {
+		return WhitelistFormatCSV
+	}
+	return WhitelistFormatNames
+}
+
+// parseWhitelistCSV reads the name and uuid columns, found by a header row or else the first two
+func parseWhitelistCSV(content string) ([]WhitelistEntry, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	nameColumn, uuidColumn := 0, 1
+	if len(records) > 0 {
+		headerName, headerUUID := -1, -1
+		for i, cell := range records[0] {
+			switch strings.ToLower(strings.TrimSpace(cell)) {
+			case "name", "username", "player":
+				headerName = i
+			case "uuid", "id":
+				headerUUID = i
+			}
+		}
+		if headerName >= 0 {
+			nameColumn, uuidColumn = headerName, headerUUID
+			records = records[1:]
+		}
+	}
+
+	var entries []WhitelistEntry
+	for _, record := range records {
+		var entry WhitelistEntry
+		if nameColumn < len(record) {
+			entry.Name = strings.TrimSpace(record[nameColumn])
+		}
+		if uuidColumn >= 0 && uuidColumn < len(record) {
+			entry.UUID = strings.TrimSpace(record[uuidColumn])
+		}
+		if entry.Name != "" || entry.UUID != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// findWhitelistEntry returns the index of the entry for the same player, compared by UUID when
+// both have one and by name otherwise, or -1
+func findWhitelistEntry(entries []WhitelistEntry, entry WhitelistEntry) int {
+	uuid, hasUUID := normalizeUUID(entry.UUID)
+	return slices.IndexFunc(entries, func(existing WhitelistEntry) bool {
+		if existingUUID, ok := normalizeUUID(existing.UUID); ok && hasUUID {
+			return existingUUID == uuid
+		}
+		return entry.Name != "" && strings.EqualFold(existing.Name, entry.Name)
+	})
+}
+
+// GetWhitelistImport returns a snapshot of the current or last import, nil if none was started
+func (s *WhitelistService) GetWhitelistImport() *WhitelistImport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bulkImport == nil {
+		return nil
+	}
+	status := *s.bulkImport
+	status.Plan.Adds = slices.Clone(s.bulkImport.Plan.Adds)
+	status.Plan.Removes = slices.Clone(s.bulkImport.Plan.Removes)
+	status.Plan.Invalid = slices.Clone(s.bulkImport.Plan.Invalid)
+	return &status
+}
+
+// StartWhitelistImport parses content and checks every player in the background, looking up
+// names on Mojang one at a time. With replace, players missing from the import are removed.
+// Nothing changes until ApplyWhitelistImport.
+func (s *WhitelistService) StartWhitelistImport(content string, format string, replace bool) error {
+	entries, format, err := ParseWhitelistImport(content, format)
+	if err != nil {
+		return err
+	}
+	current, _, err := s.loadWhitelistEntries()
+	if err != nil {
+		return fmt.Errorf("failed to get current whitelist: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bulkImport != nil && s.bulkImport.Running {
+		return fmt.Errorf("an import is already being checked")
+	}
+	s.bulkImport = &WhitelistImport{
+		Format:    format,
+		Replace:   replace,
+		Running:   true,
+		Total:     len(entries),
+		StartedAt: s.now(),
+	}
+	go s.checkWhitelistImport(entries, current, replace)
+	return nil
+}
+
+func (s *WhitelistService) checkWhitelistImport(entries []WhitelistEntry, current []WhitelistEntry, replace bool) {
+	online := s.isOnlineMode()
+	var plan WhitelistImportPlan
+	kept := make([]bool, len(current))
+	lookups := 0
+	for i, entry := range entries {
+		if index := findWhitelistEntry(current, entry); index >= 0 {
+			kept[index] = true
+			plan.Unchanged++
+		} else if !playerNamePattern.MatchString(entry.Name) {
+			plan.Invalid = append(plan.Invalid, InvalidWhitelistName{Name: entry.Name, Reason: "not a valid Minecraft name"})
+		} else {
+			if s.needsLookup(entry, online) {
+				if lookups > 0 {
+					s.sleep(s.lookupInterval)
+				}
+				lookups++
+			}
+			resolved, err := s.resolveWhitelistEntry(entry, online)
+			if err != nil {
+				plan.Invalid = append(plan.Invalid, InvalidWhitelistName{Name: entry.Name, Reason: err.Error()})
+			} else if index := findWhitelistEntry(current, resolved); index >= 0 {
+				// A renamed player whose UUID is already whitelisted
+				kept[index] = true
+				plan.Unchanged++
+			} else if findWhitelistEntry(plan.Adds, resolved) < 0 {
+				plan.Adds = append(plan.Adds, resolved)
+			}
+		}
+
+		s.mu.Lock()
+		s.bulkImport.Checked = i + 1
+		s.mu.Unlock()
+	}
+	if replace {
+		for i, entry := range current {
+			if !kept[i] {
+				plan.Removes = append(plan.Removes, entry)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bulkImport.Plan = plan
+	s.bulkImport.Running = false
+	s.bulkImport.FinishedAt = s.now()
+}
+
+// ApplyWhitelistImport applies the plan of the last checked import with a single write of
+// whitelist.json, falling back to one `whitelist add` or `whitelist remove` per player when the
+// file is not available
+func (s *WhitelistService) ApplyWhitelistImport() (WhitelistImportPlan, error) {
+	s.mu.Lock()
+	if s.bulkImport == nil || s.bulkImport.Running || s.bulkImport.Applied {
+		s.mu.Unlock()
+		return WhitelistImportPlan{}, fmt.Errorf("there is no checked import to apply")
+	}
+	plan := s.bulkImport.Plan
+	s.mu.Unlock()
+	if !plan.HasChanges() {
+		return plan, fmt.Errorf("the import does not change the whitelist")
+	}
+
+	current, fromFile, err := s.loadWhitelistEntries()
+	if err != nil {
+		return plan, fmt.Errorf("failed to get current whitelist: %w", err)
+	}
+	var byName []string
+	var removeByName []string
+	if fromFile {
+		entries := slices.DeleteFunc(current, func(entry WhitelistEntry) bool {
+			return findWhitelistEntry(plan.Removes, entry) >= 0
+		})
+		for _, add := range plan.Adds {
+			if findWhitelistEntry(entries, add) >= 0 {
+				continue
+			}
+			if add.UUID == "" {
+				byName = append(byName, add.Name)
+				continue
+			}
+			entries = append(entries, add)
+		}
+		if len(plan.Removes) > 0 || len(plan.Adds) > len(byName) {
+			if err := s.writeWhitelistFile(entries); err != nil {
+				return plan, fmt.Errorf("failed to apply import: %w", err)
+			}
+		}
+	} else {
+		for _, add := range plan.Adds {
+			byName = append(byName, add.Name)
+		}
+		for _, remove := range plan.Removes {
+			removeByName = append(removeByName, remove.Name)
+		}
+	}
+	for _, name := range removeByName {
+		if _, err := s.rconClient.ExecuteCommand(fmt.Sprintf("whitelist remove %s", name)); err != nil {
+			return plan, fmt.Errorf("failed to remove %s from whitelist: %w", name, err)
+		}
+	}
+	for _, name := range byName {
+		if _, err := s.rconClient.ExecuteCommand(fmt.Sprintf("whitelist add %s", name)); err != nil {
+			return plan, fmt.Errorf("failed to add %s to whitelist: %w", name, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bulkImport.Applied = true
+	return plan, nil
+}
+
+// DiscardWhitelistImport forgets the last import, a running check keeps going
+func (s *WhitelistService) DiscardWhitelistImport() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bulkImport != nil && !s.bulkImport.Running {
+		s.bulkImport = nil
+	}
+}
+
+// ExportWhitelist renders the whitelist in format, returning the content and a file name for it
+func (s *WhitelistService) ExportWhitelist(format string) ([]byte, string, error) {
+	entries, _, err := s.loadWhitelistEntries()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get current whitelist: %w", err)
+	}
+
+	switch format {
+	case WhitelistFormatJSON:
+		fileEntries := make([]whitelistFileEntry, len(entries))
+		for i, entry := range entries {
+			fileEntries[i] = whitelistFileEntry{UUID: entry.UUID, Name: entry.Name}
+		}
+		data, err := json.MarshalIndent(fileEntries, "", "  ")
+		return data, whitelistFile, err
+	case WhitelistFormatCSV:
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		w.Write([]string{"name", "uuid"})
+		for _, entry := range entries {
+			w.Write([]string{entry.Name, entry.UUID})
+		}
+		w.Flush()
+		return b.Bytes(), "whitelist.csv", w.Error()
+	case WhitelistFormatNames:
+		var b strings.Builder
+		for _, entry := range entries {
+			b.WriteString(entry.Name + "\n")
+		}
+		return []byte(b.String()), "whitelist.txt", nil
+	default:
+		return nil, "", fmt.Errorf("unknown whitelist format: %q", format)
+	}
+}
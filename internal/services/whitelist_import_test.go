@@ -0,0 +1,212 @@
+package services
+
+import (
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWhitelistImport(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		format     string
+		wantFormat string
+		want       []WhitelistEntry
+		wantErr    string
+	}{
+		{
+			name:       "whitelist.json",
+			content:    `[{"uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "name": "Notch"}, {"uuid": "", "name": " jeb_ "}]`,
+			wantFormat: WhitelistFormatJSON,
+			want:       []WhitelistEntry{{UUID: "069a79f4-44e9-4726-a5be-fca90e38aaf5", Name: "Notch"}, {Name: "jeb_"}},
+		},
+		{
+			name:       "csv with header",
+			content:    "\ufeffuuid,Username,class\n069a79f4-44e9-4726-a5be-fca90e38aaf5,Notch,7b\n,Alex,7a\n\n",
+			wantFormat: WhitelistFormatCSV,
+			want:       []WhitelistEntry{{UUID: "069a79f4-44e9-4726-a5be-fca90e38aaf5", Name: "Notch"}, {Name: "Alex"}},
+		},
+		{
+			name:       "csv without header",
+			content:    "Steve, 8667ba71b85a4004af54457a9734eed7\n# teachers\nAlex\n",
+			format:     WhitelistFormatCSV,
+			wantFormat: WhitelistFormatCSV,
+			want:       []WhitelistEntry{{UUID: "8667ba71b85a4004af54457a9734eed7", Name: "Steve"}, {Name: "Alex"}},
+		},
+		{
+			name:       "names with duplicates",
+			content:    "Steve\r\n\r\n# comment\nAlex\nsteve\n",
+			wantFormat: WhitelistFormatNames,
+			want:       []WhitelistEntry{{Name: "Steve"}, {Name: "Alex"}},
+		},
+		{name: "empty", content: "\n# nobody\n", wantErr: "no players found"},
+		{name: "broken json", content: "[{", wantErr: "invalid whitelist.json"},
+		{name: "unknown format", content: "Steve", format: "xml", wantErr: "unknown whitelist format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, format, err := ParseWhitelistImport(tt.content, tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if format != tt.wantFormat || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseWhitelistImport = %q %+v, want %q %+v", format, got, tt.wantFormat, tt.want)
+			}
+		})
+	}
+}
+
+// waitForWhitelistImport waits until the background check of an import finished
+func waitForWhitelistImport(t *testing.T, svc *WhitelistService) *WhitelistImport {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := svc.GetWhitelistImport(); status != nil && !status.Running {
+			return status
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("import check did not finish")
+	return nil
+}
+
+func TestWhitelistService_Import(t *testing.T) {
+	dataDir := writeWhitelistServer(t, "online-mode=true\n", `[
+  {"uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "name": "Notch"},
+  {"uuid": "61699b2e-d327-4a01-9f1e-0ea8c3f06bc6", "name": "Dinnerbone"}
+]`)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{"whitelist reload": {}}}
+	mojang := &fakeMojangChecker{
+		existsMap: map[string]bool{"jeb_": true, "Grumm": true, "Ghost": false, "Notch_": true},
+		uuids: map[string]string{
+			"jeb_":   "853c80ef-3c37-49fd-aa49-938b674adae6",
+			"Grumm":  "e6b5c088-0680-44df-9e1b-9bf11792291b",
+			"Notch_": "069a79f4-44e9-4726-a5be-fca90e38aaf5",
+		},
+	}
+	svc := NewWhitelistService(rconClient, mojang, &fileClient)
+	var sleeps []time.Duration
+	svc.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	content := "notch\njeb_\nGhost\nnot a name\nNotch_\nGrumm\n"
+	if err := svc.StartWhitelistImport(content, "", true); err != nil {
+		t.Fatalf("StartWhitelistImport: %v", err)
+	}
+	status := waitForWhitelistImport(t, svc)
+	if status.Format != WhitelistFormatNames || status.Checked != 6 || status.Total != 6 || status.Progress() != 100 {
+		t.Fatalf("status = %+v", status)
+	}
+	plan := status.Plan
+	// Notch_ is the renamed Notch and counts as already whitelisted
+	if plan.Unchanged != 2 || len(plan.Adds) != 2 || plan.Adds[0].UUID != "853c80ef-3c37-49fd-aa49-938b674adae6" ||
+		len(plan.Removes) != 1 || plan.Removes[0].Name != "Dinnerbone" || len(plan.Invalid) != 2 {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if plan.Invalid[0].Name != "Ghost" || !strings.Contains(plan.Invalid[0].Reason, "does not exist") ||
+		plan.Invalid[1].Reason != "not a valid Minecraft name" {
+		t.Fatalf("invalid = %+v", plan.Invalid)
+	}
+	// Four lookups, spaced by three waits
+	if len(sleeps) != 3 || sleeps[0] != defaultLookupInterval {
+		t.Fatalf("sleeps = %v", sleeps)
+	}
+	if entries := readWhitelistJSON(t, dataDir); len(entries) != 2 {
+		t.Fatalf("dry run changed whitelist.json: %+v", entries)
+	}
+
+	if _, err := svc.ApplyWhitelistImport(); err != nil {
+		t.Fatalf("ApplyWhitelistImport: %v", err)
+	}
+	want := []whitelistFileEntry{
+		{UUID: "069a79f4-44e9-4726-a5be-fca90e38aaf5", Name: "Notch"},
+		{UUID: "853c80ef-3c37-49fd-aa49-938b674adae6", Name: "jeb_"},
+		{UUID: "e6b5c088-0680-44df-9e1b-9bf11792291b", Name: "Grumm"},
+	}
+	if entries := readWhitelistJSON(t, dataDir); !reflect.DeepEqual(entries, want) {
+		t.Fatalf("whitelist.json = %+v", entries)
+	}
+	if !reflect.DeepEqual(rconClient.received, []string{"whitelist reload"}) {
+		t.Fatalf("commands = %v", rconClient.received)
+	}
+	if _, err := svc.ApplyWhitelistImport(); err == nil {
+		t.Fatalf("applying twice succeeded")
+	}
+	svc.DiscardWhitelistImport()
+	if svc.GetWhitelistImport() != nil {
+		t.Fatalf("import not discarded")
+	}
+}
+
+func TestWhitelistService_ImportWithoutWhitelistFile(t *testing.T) {
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{
+		"whitelist list":             {out: formatWhitelistListOutput([]string{"Alex", "Herobrine"})},
+		"whitelist add Steve":        {},
+		"whitelist remove Herobrine": {},
+	}}
+	svc := NewWhitelistService(rconClient, nil, &fakeFileClient{files: map[string]string{"server.properties": "online-mode=false"}})
+	if err := svc.StartWhitelistImport("Alex,\nSteve,\n", WhitelistFormatCSV, true); err != nil {
+		t.Fatalf("StartWhitelistImport: %v", err)
+	}
+	plan := waitForWhitelistImport(t, svc).Plan
+	if plan.Unchanged != 1 || len(plan.Adds) != 1 || plan.Adds[0].UUID != OfflinePlayerUUID("Steve") || len(plan.Removes) != 1 {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if _, err := svc.ApplyWhitelistImport(); err != nil {
+		t.Fatalf("ApplyWhitelistImport: %v", err)
+	}
+	// The list is read once per import, not once per player
+	want := []string{"whitelist list", "whitelist list", "whitelist remove Herobrine", "whitelist add Steve"}
+	if !reflect.DeepEqual(rconClient.received, want) {
+		t.Fatalf("commands = %v", rconClient.received)
+	}
+}
+
+func TestWhitelistService_ExportWhitelist(t *testing.T) {
+	dataDir := writeWhitelistServer(t, "", `[{"uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "name": "Notch"}, {"uuid": "853c80ef-3c37-49fd-aa49-938b674adae6", "name": "jeb_"}]`)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	svc := NewWhitelistService(&fakeRconClient{}, nil, &fileClient)
+
+	for _, tt := range []struct {
+		format, fileName, want string
+	}{
+		{WhitelistFormatCSV, "whitelist.csv", "name,uuid\nNotch,069a79f4-44e9-4726-a5be-fca90e38aaf5\njeb_,853c80ef-3c37-49fd-aa49-938b674adae6\n"},
+		{WhitelistFormatNames, "whitelist.txt", "Notch\njeb_\n"},
+	} {
+		data, fileName, err := svc.ExportWhitelist(tt.format)
+		if err != nil || fileName != tt.fileName || string(data) != tt.want {
+			t.Errorf("ExportWhitelist(%s) = %q, %q, %v", tt.format, data, fileName, err)
+		}
+	}
+
+	// An exported whitelist.json imports into another server unchanged
+	data, fileName, err := svc.ExportWhitelist(WhitelistFormatJSON)
+	if err != nil || fileName != "whitelist.json" {
+		t.Fatalf("ExportWhitelist(json) = %q, %v", fileName, err)
+	}
+	os.WriteFile(filepath.Join(dataDir, "whitelist.json"), data, 0644)
+	if entries := readWhitelistJSON(t, dataDir); len(entries) != 2 || entries[1].Name != "jeb_" {
+		t.Fatalf("exported whitelist.json = %s", data)
+	}
+	if _, _, err := svc.ExportWhitelist("xml"); err == nil {
+		t.Fatalf("exporting an unknown format succeeded")
+	}
+}
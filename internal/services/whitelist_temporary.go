@@ -0,0 +1,352 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxTemporaryWhitelistDuration bounds guest access, longer stays belong on the whitelist
+	maxTemporaryWhitelistDuration = 90 * 24 * time.Hour
+	maxTemporaryWhitelistSponsor  = 100
+	temporaryWhitelistKickReason  = "Your guest access has expired"
+)
+
+// TemporaryWhitelister adds and removes guests
+type TemporaryWhitelister interface {
+	AddNameToWhitelist(name string) error
+	RemoveNameFromWhitelist(name string) error
+	RemoveUUIDFromWhitelist(uuid string) (WhitelistEntry, error)
+	FindWhitelisted(name string) (WhitelistEntry, bool, error)
+}
+
+// TemporaryWhitelistPlayers kicks guests who are online when their access expires
+type TemporaryWhitelistPlayers interface {
+	GetServerPlayerInfo() (ServerPlayerInfo, error)
+	KickPlayerByName(name string, reason string) error
+}
+
+// TemporaryWhitelistEntry is a whitelisted guest who is removed at ExpiresAt
+type TemporaryWhitelistEntry struct {
+	PlayerName string `json:"player_name"`
+	// UUID is the whitelisted account, the guest may change their name before access expires
+	UUID      string    `json:"uuid,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Sponsor notes who vouched for the guest
+	Sponsor      string    `json:"sponsor,omitempty"`
+	KickOnExpiry bool      `json:"kick_on_expiry"`
+	AddedBy      string    `json:"added_by,omitempty"`
+	AddedAt      time.Time `json:"added_at"`
+	// Error is the last failure removing the guest, the reaper tries again
+	Error string `json:"error,omitempty"`
+}
+
+// TemporaryWhitelistRequest adds a guest or extends their access
+type TemporaryWhitelistRequest struct {
+	PlayerName   string
+	Duration     time.Duration
+	Sponsor      string
+	KickOnExpiry bool
+	AddedBy      string
+}
+
+// temporaryWhitelistState is the persisted state of TemporaryWhitelistService
+type temporaryWhitelistState struct {
+	Entries []TemporaryWhitelistEntry `json:"entries"`
+}
+
+// TemporaryWhitelistService whitelists guests for a limited time and removes them when it runs out
+type TemporaryWhitelistService struct {
+	whitelist TemporaryWhitelister
+	players   TemporaryWhitelistPlayers
+	stateFile jsonStateFile
+	now       func() time.Time
+
+	// changeMu serializes changes to the whitelist, mu guards the state
+	changeMu sync.Mutex
+	mu       sync.Mutex
+	state    *temporaryWhitelistState
+}
+
+// NewTemporaryWhitelistService creates a TemporaryWhitelistService persisting guests to statePath
+func NewTemporaryWhitelistService(whitelist TemporaryWhitelister, players TemporaryWhitelistPlayers, statePath string) *TemporaryWhitelistService {
+	return &TemporaryWhitelistService{
+		whitelist: whitelist,
+		players:   players,
+		stateFile: jsonStateFile{path: statePath},
+		now:       time.Now,
+	}
+}
+
+// loadLocked reads the state once. An unreadable file is left alone and reported on every call,
+// starting over would leave the guests in it whitelisted for good.
+func (s *TemporaryWhitelistService) loadLocked() error {
+	if s.state != nil {
+		return nil
+	}
+	state := &temporaryWhitelistState{}
+	if err := s.stateFile.load(state); err != nil {
+		return err
+	}
+	s.state = state
+	return nil
+}
+
+func (s *TemporaryWhitelistService) saveLocked() error {
+	return s.stateFile.save(s.state)
+}
+
+// Entries returns the guests, the first to expire first
+func (s *TemporaryWhitelistService) Entries() ([]TemporaryWhitelistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	entries := slices.Clone(s.state.Entries)
+	slices.SortFunc(entries, func(a, b TemporaryWhitelistEntry) int {
+		return a.ExpiresAt.Compare(b.ExpiresAt)
+	})
+	return entries, nil
+}
+
+// Add whitelists a guest until the duration runs out. A guest who already has temporary access
+// gets the new expiry, players on the whitelist for good are left alone.
+func (s *TemporaryWhitelistService) Add(request TemporaryWhitelistRequest) (TemporaryWhitelistEntry, error) {
+	name := strings.TrimSpace(request.PlayerName)
+	if err := validatePlayerName(name); err != nil {
+		return TemporaryWhitelistEntry{}, err
+	}
+	if request.Duration < time.Minute || request.Duration > maxTemporaryWhitelistDuration {
+		return TemporaryWhitelistEntry{}, fmt.Errorf("the duration must be between 1 minute and %d days", maxTemporaryWhitelistDuration/(24*time.Hour))
+	}
+	sponsor := strings.TrimSpace(request.Sponsor)
+	if utf8.RuneCountInString(sponsor) > maxTemporaryWhitelistSponsor {
+		return TemporaryWhitelistEntry{}, fmt.Errorf("the sponsor note is longer than %d characters", maxTemporaryWhitelistSponsor)
+	}
+
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	now := s.now()
+	entry := TemporaryWhitelistEntry{
+		PlayerName:   name,
+		ExpiresAt:    now.Add(request.Duration).Truncate(time.Second),
+		Sponsor:      sponsor,
+		KickOnExpiry: request.KickOnExpiry,
+		AddedBy:      request.AddedBy,
+		AddedAt:      now,
+	}
+
+	s.mu.Lock()
+	if err := s.loadLocked(); err != nil {
+		s.mu.Unlock()
+		return TemporaryWhitelistEntry{}, err
+	}
+	if index := s.indexLocked(name); index >= 0 {
+		existing := s.state.Entries[index]
+		entry.PlayerName = existing.PlayerName
+		entry.UUID = existing.UUID
+		entry.AddedAt = existing.AddedAt
+		s.state.Entries[index] = entry
+		err := s.saveLocked()
+		s.mu.Unlock()
+		return entry, err
+	}
+	s.mu.Unlock()
+
+	if err := s.whitelist.AddNameToWhitelist(name); err != nil {
+		if errors.Is(err, ErrAlreadyWhitelisted) {
+			return TemporaryWhitelistEntry{}, fmt.Errorf("%s is already whitelisted permanently", name)
+		}
+		return TemporaryWhitelistEntry{}, err
+	}
+	// without whitelist.json the UUID is unknown and the guest is removed by name
+	if whitelisted, found, err := s.whitelist.FindWhitelisted(name); err != nil {
+		log.Printf("Failed to look up the UUID of guest %s: %v", name, err)
+	} else if found {
+		entry.UUID = whitelisted.UUID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Entries = append(s.state.Entries, entry)
+	return entry, s.saveLocked()
+}
+
+// MakePermanent keeps a guest on the whitelist for good
+func (s *TemporaryWhitelistService) MakePermanent(name string) (TemporaryWhitelistEntry, error) {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+	return s.remove(name)
+}
+
+// Forget stops tracking a player who was removed from the whitelist by other means, so a later
+// permanent entry is not removed when the old expiry passes
+func (s *TemporaryWhitelistService) Forget(name string) error {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	index := s.indexLocked(strings.TrimSpace(name))
+	if index < 0 {
+		return nil
+	}
+	s.state.Entries = slices.Delete(s.state.Entries, index, index+1)
+	return s.saveLocked()
+}
+
+// Revoke ends a guest's access now
+func (s *TemporaryWhitelistService) Revoke(name string) (TemporaryWhitelistEntry, error) {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	s.mu.Lock()
+	if err := s.loadLocked(); err != nil {
+		s.mu.Unlock()
+		return TemporaryWhitelistEntry{}, err
+	}
+	index := s.indexLocked(strings.TrimSpace(name))
+	if index < 0 {
+		s.mu.Unlock()
+		return TemporaryWhitelistEntry{}, fmt.Errorf("%s has no temporary access", name)
+	}
+	entry := s.state.Entries[index]
+	s.mu.Unlock()
+
+	if err := s.expire(entry); err != nil {
+		return entry, err
+	}
+	return s.remove(entry.PlayerName)
+}
+
+// Reap removes the guests whose access expired and returns their names. Guests who cannot be
+// removed keep their entry and are tried again on the next run.
+func (s *TemporaryWhitelistService) Reap() ([]string, error) {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	var removed []string
+	var saveErr error
+	for _, entry := range entries {
+		if entry.ExpiresAt.After(now) {
+			break
+		}
+		err := s.expire(entry)
+
+		s.mu.Lock()
+		if index := s.indexLocked(entry.PlayerName); index >= 0 {
+			if err != nil {
+				s.state.Entries[index].Error = err.Error()
+			} else {
+				s.state.Entries = slices.Delete(s.state.Entries, index, index+1)
+				removed = append(removed, entry.PlayerName)
+			}
+			if err := s.saveLocked(); err != nil {
+				saveErr = err
+			}
+		}
+		s.mu.Unlock()
+	}
+	return removed, saveErr
+}
+
+// expire removes a guest from the whitelist and kicks them if they are online and asked for it.
+// A guest someone already removed by hand has expired all the same.
+func (s *TemporaryWhitelistService) expire(entry TemporaryWhitelistEntry) error {
+	if err := s.removeGuest(entry); err != nil {
+		return err
+	}
+	if !entry.KickOnExpiry || s.players == nil {
+		return nil
+	}
+	info, err := s.players.GetServerPlayerInfo()
+	if err != nil {
+		log.Printf("Failed to check whether guest %s is online: %v", entry.PlayerName, err)
+		return nil
+	}
+	for _, player := range info.PlayerNames {
+		if strings.EqualFold(player, entry.PlayerName) {
+			if err := s.players.KickPlayerByName(player, temporaryWhitelistKickReason); err != nil {
+				log.Printf("Failed to kick guest %s: %v", player, err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// removeGuest removes a guest by UUID, which still matches after a rename. Guests added without
+// whitelist.json are removed by name, and only count as removed once the name is gone from the list.
+func (s *TemporaryWhitelistService) removeGuest(entry TemporaryWhitelistEntry) error {
+	if entry.UUID != "" {
+		if _, err := s.whitelist.RemoveUUIDFromWhitelist(entry.UUID); err != nil && !errors.Is(err, ErrNotWhitelisted) {
+			return err
+		}
+		return nil
+	}
+	if err := s.whitelist.RemoveNameFromWhitelist(entry.PlayerName); err != nil && !errors.Is(err, ErrNotWhitelisted) {
+		return err
+	}
+	_, found, err := s.whitelist.FindWhitelisted(entry.PlayerName)
+	if err != nil {
+		return fmt.Errorf("failed to check that %s was removed: %w", entry.PlayerName, err)
+	}
+	if found {
+		return fmt.Errorf("%s is still whitelisted after removing them", entry.PlayerName)
+	}
+	return nil
+}
+
+func (s *TemporaryWhitelistService) remove(name string) (TemporaryWhitelistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return TemporaryWhitelistEntry{}, err
+	}
+	index := s.indexLocked(strings.TrimSpace(name))
+	if index < 0 {
+		return TemporaryWhitelistEntry{}, fmt.Errorf("%s has no temporary access", name)
+	}
+	entry := s.state.Entries[index]
+	s.state.Entries = slices.Delete(s.state.Entries, index, index+1)
+	return entry, s.saveLocked()
+}
+
+// StartReaper removes expired guests every interval in the background
+func (s *TemporaryWhitelistService) StartReaper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			removed, err := s.Reap()
+			if err != nil {
+				log.Printf("Failed to remove expired guests: %v", err)
+			}
+			if len(removed) > 0 {
+				log.Printf("Removed expired guests from the whitelist: %s", strings.Join(removed, ", "))
+			}
+		}
+	}()
+}
+
+func (s *TemporaryWhitelistService) indexLocked(name string) int {
+	return slices.IndexFunc(s.state.Entries, func(entry TemporaryWhitelistEntry) bool {
+		return strings.EqualFold(entry.PlayerName, name)
+	})
+}
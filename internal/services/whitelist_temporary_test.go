@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeGuestPlayers struct {
+	online []string
+	kicked []string
+}
+
+func (f *fakeGuestPlayers) GetServerPlayerInfo() (ServerPlayerInfo, error) {
+	return ServerPlayerInfo{PlayerNames: f.online, OnlineCount: len(f.online)}, nil
+}
+
+func (f *fakeGuestPlayers) KickPlayerByName(name string, reason string) error {
+	f.kicked = append(f.kicked, name+": "+reason)
+	return nil
+}
+
+// fakeGuestWhitelist keeps whitelist entries with offline UUIDs
+type fakeGuestWhitelist struct {
+	entries   []WhitelistEntry
+	removeErr error
+	// ignoreRemoveByName makes removal by name do nothing, like `whitelist remove` for an unknown name
+	ignoreRemoveByName bool
+}
+
+func newFakeGuestWhitelist(names ...string) *fakeGuestWhitelist {
+	f := &fakeGuestWhitelist{}
+	for _, name := range names {
+		f.AddNameToWhitelist(name)
+	}
+	return f
+}
+
+func (f *fakeGuestWhitelist) names() []string {
+	var names []string
+	for _, entry := range f.entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func (f *fakeGuestWhitelist) AddNameToWhitelist(name string) error {
+	if _, found, _ := f.FindWhitelisted(name); found {
+		return fmt.Errorf("name '%s' is %w", name, ErrAlreadyWhitelisted)
+	}
+	f.entries = append(f.entries, WhitelistEntry{Name: name, UUID: OfflinePlayerUUID(name)})
+	return nil
+}
+
+func (f *fakeGuestWhitelist) RemoveNameFromWhitelist(name string) error {
+	if f.removeErr != nil {
+		return f.removeErr
+	}
+	if !f.ignoreRemoveByName {
+		f.entries = slices.DeleteFunc(f.entries, func(entry WhitelistEntry) bool { return strings.EqualFold(entry.Name, name) })
+	}
+	return nil
+}
+
+func (f *fakeGuestWhitelist) RemoveUUIDFromWhitelist(uuid string) (WhitelistEntry, error) {
+	if f.removeErr != nil {
+		return WhitelistEntry{}, f.removeErr
+	}
+	index := slices.IndexFunc(f.entries, func(entry WhitelistEntry) bool { return entry.UUID == uuid })
+	if index < 0 {
+		return WhitelistEntry{}, fmt.Errorf("no whitelisted player has UUID %s: %w", uuid, ErrNotWhitelisted)
+	}
+	removed := f.entries[index]
+	f.entries = slices.Delete(f.entries, index, index+1)
+	return removed, nil
+}
+
+func (f *fakeGuestWhitelist) FindWhitelisted(name string) (WhitelistEntry, bool, error) {
+	for _, entry := range f.entries {
+		if strings.EqualFold(entry.Name, name) {
+			return entry, true, nil
+		}
+	}
+	return WhitelistEntry{}, false, nil
+}
+
+func TestTemporaryWhitelistService_AddAndReap(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "temporary_whitelist.json")
+	whitelist := newFakeGuestWhitelist("Admin")
+	players := &fakeGuestPlayers{online: []string{"Steve", "Alex"}}
+	svc := NewTemporaryWhitelistService(whitelist, players, statePath)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	steve, err := svc.Add(TemporaryWhitelistRequest{PlayerName: "Steve", Duration: time.Hour, Sponsor: "Alex", KickOnExpiry: true, AddedBy: "admin"})
+	if err != nil || !steve.ExpiresAt.Equal(now.Add(time.Hour)) || steve.Sponsor != "Alex" {
+		t.Fatalf("entry = %+v, err = %v", steve, err)
+	}
+	if _, err := svc.Add(TemporaryWhitelistRequest{PlayerName: "Alex", Duration: 2 * time.Hour}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := svc.Add(TemporaryWhitelistRequest{PlayerName: "Admin", Duration: time.Hour}); err == nil || !strings.Contains(err.Error(), "permanently") {
+		t.Fatalf("permanent player: err = %v", err)
+	}
+	// Adding a guest again extends their access without touching the whitelist
+	if alex, err := svc.Add(TemporaryWhitelistRequest{PlayerName: "alex", Duration: 3 * time.Hour}); err != nil || alex.PlayerName != "Alex" || !alex.ExpiresAt.Equal(now.Add(3*time.Hour)) {
+		t.Fatalf("extended entry = %+v, err = %v", alex, err)
+	}
+	if !slices.Equal(whitelist.names(), []string{"Admin", "Steve", "Alex"}) {
+		t.Fatalf("whitelist = %v", whitelist.names())
+	}
+
+	if removed, _ := svc.Reap(); len(removed) != 0 {
+		t.Fatalf("reaped too early: %v", removed)
+	}
+	now = now.Add(time.Hour)
+	if removed, _ := svc.Reap(); !slices.Equal(removed, []string{"Steve"}) {
+		t.Fatalf("removed = %v", removed)
+	}
+	if !slices.Equal(whitelist.names(), []string{"Admin", "Alex"}) || !slices.Equal(players.kicked, []string{"Steve: Your guest access has expired"}) {
+		t.Fatalf("whitelist = %v, kicked = %v", whitelist.names(), players.kicked)
+	}
+
+	// A failed removal is kept and tried again
+	now = now.Add(2 * time.Hour)
+	whitelist.removeErr = errors.New("rcon down")
+	if removed, _ := svc.Reap(); len(removed) != 0 {
+		t.Fatalf("removed = %v", removed)
+	}
+	reloaded := NewTemporaryWhitelistService(whitelist, players, statePath)
+	reloaded.now = svc.now
+	if entries, _ := reloaded.Entries(); len(entries) != 1 || entries[0].Error != "rcon down" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	// An admin removed the guest by hand in the meantime
+	whitelist.removeErr = nil
+	whitelist.entries = whitelist.entries[:1]
+	if removed, _ := reloaded.Reap(); !slices.Equal(removed, []string{"Alex"}) || len(players.kicked) != 1 {
+		t.Fatalf("removed = %v, kicked = %v", removed, players.kicked)
+	}
+}
+
+func TestTemporaryWhitelistService_RevokeAndMakePermanent(t *testing.T) {
+	whitelist := newFakeGuestWhitelist()
+	svc := NewTemporaryWhitelistService(whitelist, nil, filepath.Join(t.TempDir(), "temporary_whitelist.json"))
+
+	if _, err := svc.Add(TemporaryWhitelistRequest{PlayerName: "Steve", Duration: 30 * time.Second}); err == nil {
+		t.Fatal("expected an error for a duration below a minute")
+	}
+	svc.Add(TemporaryWhitelistRequest{PlayerName: "Steve", Duration: time.Hour})
+	svc.Add(TemporaryWhitelistRequest{PlayerName: "Alex", Duration: time.Hour})
+
+	if _, err := svc.Revoke("steve"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := svc.MakePermanent("Alex"); err != nil {
+		t.Fatalf("MakePermanent: %v", err)
+	}
+	if entries, _ := svc.Entries(); !slices.Equal(whitelist.names(), []string{"Alex"}) || len(entries) != 0 {
+		t.Fatalf("whitelist = %v, entries = %+v", whitelist.names(), entries)
+	}
+	if _, err := svc.Revoke("Alex"); err == nil {
+		t.Fatal("expected an error revoking a permanent player")
+	}
+}
+
+func TestTemporaryWhitelistService_KeepsBrokenState(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "temporary_whitelist.json")
+	os.WriteFile(statePath, []byte(`{"entries": [`), 0644)
+	whitelist := newFakeGuestWhitelist()
+	svc := NewTemporaryWhitelistService(whitelist, nil, statePath)
+
+	if _, err := svc.Add(TemporaryWhitelistRequest{PlayerName: "Steve", Duration: time.Hour}); err == nil {
+		t.Fatal("Add succeeded with a broken state file")
+	}
+	if _, err := svc.Reap(); err == nil {
+		t.Fatal("Reap succeeded with a broken state file")
+	}
+	if len(whitelist.names()) != 0 {
+		t.Fatalf("whitelist = %v", whitelist.names())
+	}
+	if data, _ := os.ReadFile(statePath); string(data) != `{"entries": [` {
+		t.Fatalf("broken state file was overwritten: %q", data)
+	}
+}
+
+func TestTemporaryWhitelistService_ExpiresRenamedGuests(t *testing.T) {
+	whitelist := newFakeGuestWhitelist()
+	svc := NewTemporaryWhitelistService(whitelist, nil, filepath.Join(t.TempDir(), "temporary_whitelist.json"))
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	steve, err := svc.Add(TemporaryWhitelistRequest{PlayerName: "Steve", Duration: time.Hour})
+	if err != nil || steve.UUID != OfflinePlayerUUID("Steve") {
+		t.Fatalf("entry = %+v, err = %v", steve, err)
+	}
+	// Steve renamed himself, the server updated whitelist.json
+	whitelist.entries[0].Name = "Steve2"
+	now = now.Add(time.Hour)
+	if removed, err := svc.Reap(); err != nil || !slices.Equal(removed, []string{"Steve"}) || len(whitelist.entries) != 0 {
+		t.Fatalf("removed = %v, err = %v, whitelist = %v", removed, err, whitelist.names())
+	}
+}
+
+func TestTemporaryWhitelistService_KeepsUnconfirmedRemovals(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "temporary_whitelist.json")
+	// an entry from before UUIDs were recorded
+	os.WriteFile(statePath, []byte(`{"entries": [{"player_name": "Steve", "expires_at": "2026-05-01T12:00:00Z"}]}`), 0644)
+	whitelist := newFakeGuestWhitelist("Steve")
+	whitelist.ignoreRemoveByName = true
+	svc := NewTemporaryWhitelistService(whitelist, nil, statePath)
+	svc.now = func() time.Time { return time.Date(2026, 5, 1, 13, 0, 0, 0, time.UTC) }
+
+	if removed, _ := svc.Reap(); len(removed) != 0 {
+		t.Fatalf("removed = %v although Steve is still whitelisted", removed)
+	}
+	if entries, _ := svc.Entries(); len(entries) != 1 || !strings.Contains(entries[0].Error, "still whitelisted") {
+		t.Fatalf("entries = %+v", entries)
+	}
+	whitelist.ignoreRemoveByName = false
+	if removed, _ := svc.Reap(); !slices.Equal(removed, []string{"Steve"}) || len(whitelist.entries) != 0 {
+		t.Fatalf("removed = %v, whitelist = %v", removed, whitelist.names())
+	}
+}
@@ -1,10 +1,16 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mc-admin/internal/clients/files"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestWhitelistService_GetServerPlayerInfo(t *testing.T) {
@@ -255,3 +261,178 @@ func joinNames(names []string) string {
 	}
 	return result
 }
+
+func TestOfflinePlayerUUID(t *testing.T) {
+	if got := OfflinePlayerUUID("Notch"); got != "b50ad385-829d-3141-a216-7e7d7539ba7f" {
+		t.Fatalf("OfflinePlayerUUID(Notch) = %s", got)
+	}
+}
+
+// writeWhitelistServer creates a data directory with server.properties and whitelist.json
+func writeWhitelistServer(t *testing.T, properties string, entries string) string {
+	t.Helper()
+	dataDir := t.TempDir()
+	os.WriteFile(filepath.Join(dataDir, "server.properties"), []byte(properties), 0644)
+	os.WriteFile(filepath.Join(dataDir, "whitelist.json"), []byte(entries), 0644)
+	return dataDir
+}
+
+func readWhitelistJSON(t *testing.T, dataDir string) []whitelistFileEntry {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dataDir, "whitelist.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []whitelistFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("whitelist.json: %v", err)
+	}
+	return entries
+}
+
+func TestWhitelistService_GetWhitelistInfoFromFile(t *testing.T) {
+	dataDir := writeWhitelistServer(t, "white-list=true\nlevel-name=survival\n", `[
+  {"uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "name": "Notch"},
+  {"uuid": "853c80ef-3c37-49fd-aa49-938b674adae6", "name": "jeb_"},
+  {"uuid": "61699b2e-d327-4a01-9f1e-0ea8c3f06bc6", "name": "Dinnerbone"}
+]`)
+	os.WriteFile(filepath.Join(dataDir, "usercache.json"), []byte(`[
+  {"name": "Notch", "uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "expiresOn": "2026-11-01 12:00:00 +0000"},
+  {"name": "jeb", "uuid": "853C80EF-3C37-49FD-AA49-938B674ADAE6", "expiresOn": "2026-10-15 08:30:00 +0000"}
+]`), 0644)
+	os.MkdirAll(filepath.Join(dataDir, "survival", "playerdata"), 0755)
+	playerData := filepath.Join(dataDir, "survival", "playerdata", "069a79f4-44e9-4726-a5be-fca90e38aaf5.dat")
+	os.WriteFile(playerData, []byte{}, 0644)
+	saved := time.Date(2026, 10, 3, 18, 0, 0, 0, time.UTC)
+	os.Chtimes(playerData, saved, saved)
+
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	// RCON is not needed when whitelist.json exists
+	svc := NewWhitelistService(&fakeRconClient{}, nil, &fileClient)
+	info, err := svc.GetWhitelistInfo()
+	if err != nil {
+		t.Fatalf("GetWhitelistInfo: %v", err)
+	}
+	if !info.FromFile || !info.Enabled || !info.OnlineMode || !reflect.DeepEqual(info.PlayerNames, []string{"Notch", "jeb_", "Dinnerbone"}) {
+		t.Fatalf("info = %+v", info)
+	}
+	notch, jeb, dinnerbone := info.Entries[0], info.Entries[1], info.Entries[2]
+	if notch.CurrentName != "" || !notch.LastSeen.Equal(saved) {
+		t.Fatalf("notch = %+v", notch)
+	}
+	if jeb.CurrentName != "jeb" || !jeb.LastSeen.Equal(time.Date(2026, 9, 15, 8, 30, 0, 0, time.UTC)) {
+		t.Fatalf("jeb = %+v", jeb)
+	}
+	if !dinnerbone.LastSeen.IsZero() || dinnerbone.UUID != "61699b2e-d327-4a01-9f1e-0ea8c3f06bc6" {
+		t.Fatalf("dinnerbone = %+v", dinnerbone)
+	}
+}
+
+func TestWhitelistService_AddToFile(t *testing.T) {
+	const existing = `[{"uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "name": "Notch"}]`
+	reload := map[string]struct {
+		out string
+		err error
+	}{"whitelist reload": {out: "Reloaded the whitelist"}}
+
+	t.Run("offline mode", func(t *testing.T) {
+		dataDir := writeWhitelistServer(t, "online-mode=false\n", existing)
+		fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+		rconClient := &fakeRconClient{responses: reload}
+		svc := NewWhitelistService(rconClient, nil, &fileClient)
+		if err := svc.AddNameToWhitelist("Steve"); err != nil {
+			t.Fatalf("AddNameToWhitelist: %v", err)
+		}
+		entries := readWhitelistJSON(t, dataDir)
+		if len(entries) != 2 || entries[1] != (whitelistFileEntry{UUID: OfflinePlayerUUID("Steve"), Name: "Steve"}) {
+			t.Fatalf("whitelist.json = %+v", entries)
+		}
+		// the server may run as another user and must still be able to read the file
+		info, err := os.Stat(filepath.Join(dataDir, "whitelist.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0644 {
+			t.Fatalf("whitelist.json mode = %v, want 0644", info.Mode().Perm())
+		}
+		if !reflect.DeepEqual(rconClient.received, []string{"whitelist reload"}) {
+			t.Fatalf("commands = %v", rconClient.received)
+		}
+	})
+
+	t.Run("online mode resolves the UUID", func(t *testing.T) {
+		dataDir := writeWhitelistServer(t, "online-mode=true\n", existing)
+		fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+		mojang := &fakeMojangChecker{
+			existsMap: map[string]bool{"jeb_": true, "Notch2": true},
+			uuids:     map[string]string{"jeb_": "853C80EF3C3749FDAA49938B674ADAE6", "Notch2": "069a79f4-44e9-4726-a5be-fca90e38aaf5"},
+		}
+		svc := NewWhitelistService(&fakeRconClient{responses: reload}, mojang, &fileClient)
+		if err := svc.AddNameToWhitelist("jeb_"); err != nil {
+			t.Fatalf("AddNameToWhitelist: %v", err)
+		}
+		entries := readWhitelistJSON(t, dataDir)
+		if len(entries) != 2 || entries[1] != (whitelistFileEntry{UUID: "853c80ef-3c37-49fd-aa49-938b674adae6", Name: "jeb_"}) {
+			t.Fatalf("whitelist.json = %+v", entries)
+		}
+		// Notch renamed to Notch2, the old entry already covers the account
+		if err := svc.AddNameToWhitelist("Notch2"); err == nil || !strings.Contains(err.Error(), "already whitelisted as 'Notch'") {
+			t.Fatalf("adding a renamed player: err = %v", err)
+		}
+	})
+
+	t.Run("online mode without Mojang lookups", func(t *testing.T) {
+		dataDir := writeWhitelistServer(t, "", existing)
+		fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+		rconClient := &fakeRconClient{responses: map[string]struct {
+			out string
+			err error
+		}{"whitelist add Alex": {out: "Added Alex to the whitelist"}}}
+		svc := NewWhitelistService(rconClient, nil, &fileClient)
+		if err := svc.AddNameToWhitelist("Alex"); err != nil {
+			t.Fatalf("AddNameToWhitelist: %v", err)
+		}
+		if err := svc.AddNameToWhitelist("notch"); err == nil {
+			t.Fatalf("adding an existing name succeeded")
+		}
+	})
+}
+
+func TestWhitelistService_RemoveUUIDFromWhitelist(t *testing.T) {
+	dataDir := writeWhitelistServer(t, "", `[
+  {"uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "name": "Notch"},
+  {"uuid": "853c80ef-3c37-49fd-aa49-938b674adae6", "name": "jeb_"}
+]`)
+	fileClient := files.NewMinecraftFilesClient(dataDir, 0)
+	rconClient := &fakeRconClient{responses: map[string]struct {
+		out string
+		err error
+	}{"whitelist reload": {}}}
+	svc := NewWhitelistService(rconClient, nil, &fileClient)
+
+	removed, err := svc.RemoveUUIDFromWhitelist("069A79F444E94726A5BEFCA90E38AAF5")
+	if err != nil || removed.Name != "Notch" {
+		t.Fatalf("RemoveUUIDFromWhitelist = %+v, %v", removed, err)
+	}
+	if entries := readWhitelistJSON(t, dataDir); len(entries) != 1 || entries[0].Name != "jeb_" {
+		t.Fatalf("whitelist.json = %+v", entries)
+	}
+	if _, err := svc.RemoveUUIDFromWhitelist("069a79f4-44e9-4726-a5be-fca90e38aaf5"); err == nil || !strings.Contains(err.Error(), "no whitelisted player") {
+		t.Fatalf("removing twice: err = %v", err)
+	}
+	if _, err := svc.RemoveUUIDFromWhitelist("not-a-uuid"); err == nil {
+		t.Fatalf("removing an invalid UUID succeeded")
+	}
+	if dir, _ := os.ReadDir(dataDir); len(dir) != 2 {
+		t.Fatalf("temporary files left behind: %v", dir)
+	}
+
+	rconClient.responses["whitelist list"] = struct {
+		out string
+		err error
+	}{out: formatWhitelistListOutput([]string{"Notch"})}
+	missing := NewWhitelistService(rconClient, nil, &fakeFileClient{})
+	if _, err := missing.RemoveUUIDFromWhitelist("069a79f4-44e9-4726-a5be-fca90e38aaf5"); err == nil || !strings.Contains(err.Error(), "remove players by name") {
+		t.Fatalf("removing without whitelist.json: err = %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"mc-admin/internal/clients/rcon"
+	"mc-admin/internal/textcomponent"
 	"strings"
 )
 
@@ -135,9 +136,9 @@ func (s *WorldService) Say(message string) (string, error) {
 	return s.rconClient.ExecuteCommand(cmd)
 }
 
-// Title displays a title to a player or all players
+// Title displays a title to a player or all players. The text is shown as is.
 func (s *WorldService) Title(player string, titleType string, text string) (string, error) {
-	cmd := fmt.Sprintf("title %s %s {\"text\":\"%s\"}", player, titleType, text)
+	cmd := fmt.Sprintf("title %s %s %s", player, titleType, textcomponent.Text(text).Encode(textcomponent.Modern))
 	return s.rconClient.ExecuteCommand(cmd)
 }
 
@@ -299,6 +299,7 @@ func TestWorldService_SayAndTitle(t *testing.T) {
 	}{
 		"say hello":                           {out: "ok", err: nil},
 		"title Steve title {\"text\":\"Hi\"}": {out: "ok", err: nil},
+		`title @a subtitle {"text":"\"quoted\" \\ \"},{\"text\":\"x"}`: {out: "ok", err: nil},
 	}}
 
 	svc := NewWorldService(fake)
@@ -309,7 +310,16 @@ func TestWorldService_SayAndTitle(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	want := []string{"say hello", "title Steve title {\"text\":\"Hi\"}"}
+	// quotes and backslashes cannot end the component early
+	if _, err := svc.Title("@a", "subtitle", `"quoted" \ "},{"text":"x`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"say hello",
+		"title Steve title {\"text\":\"Hi\"}",
+		`title @a subtitle {"text":"\"quoted\" \\ \"},{\"text\":\"x"}`,
+	}
 	if !reflect.DeepEqual(fake.received, want) {
 		t.Fatalf("commands = %v, want %v", fake.received, want)
 	}
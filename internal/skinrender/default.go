@@ -0,0 +1,49 @@
+package skinrender
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+var (
+	skinColor  = color.NRGBA{R: 0xb7, G: 0x83, B: 0x67, A: 0xff}
+	hairColor  = color.NRGBA{R: 0x3b, G: 0x26, B: 0x1a, A: 0xff}
+	eyeColor   = color.NRGBA{R: 0x4e, G: 0x3a, B: 0x8a, A: 0xff}
+	mouthColor = color.NRGBA{R: 0x6a, G: 0x40, B: 0x30, A: 0xff}
+	shirtColor = color.NRGBA{R: 0x00, G: 0xa8, B: 0xa8, A: 0xff}
+	pantsColor = color.NRGBA{R: 0x3c, G: 0x3a, B: 0x8c, A: 0xff}
+	shoesColor = color.NRGBA{R: 0x6b, G: 0x6b, B: 0x6b, A: 0xff}
+	eyeWhite   = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+// DefaultSkin returns a skin resembling Steve for players without a skin of their own. Only the
+// front faces used by Head and Body are painted.
+func DefaultSkin() *image.NRGBA {
+	skin := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	fill := func(r rect, c color.NRGBA) {
+		draw.Draw(skin, image.Rect(r.x, r.y, r.x+r.w, r.y+r.h), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	fill(face, skinColor)
+	fill(rect{8, 8, 8, 2}, hairColor)
+	fill(rect{8, 10, 1, 1}, hairColor)
+	fill(rect{15, 10, 1, 1}, hairColor)
+	fill(rect{9, 12, 1, 1}, eyeWhite)
+	fill(rect{10, 12, 1, 1}, eyeColor)
+	fill(rect{13, 12, 1, 1}, eyeColor)
+	fill(rect{14, 12, 1, 1}, eyeWhite)
+	fill(rect{11, 13, 2, 1}, mouthColor)
+	fill(rect{10, 14, 4, 1}, mouthColor)
+
+	fill(rect{20, 20, 8, 12}, shirtColor)
+	for _, arm := range []rect{{44, 20, 4, 12}, {36, 52, 4, 12}} {
+		fill(arm, skinColor)
+		fill(rect{arm.x, arm.y, arm.w, 4}, shirtColor)
+	}
+	for _, leg := range []rect{{4, 20, 4, 12}, {20, 52, 4, 12}} {
+		fill(leg, pantsColor)
+		fill(rect{leg.x, leg.y + 10, leg.w, 2}, shoesColor)
+	}
+	return skin
+}
@@ -0,0 +1,177 @@
+// Package skinrender draws player heads and front views from Minecraft skin textures.
+package skinrender
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+const (
+	// MinHeadSize and MaxHeadSize bound the edge length of head renders in pixels
+	MinHeadSize = 8
+	MaxHeadSize = 512
+	// MaxBodyScale bounds the pixels per skin pixel of body renders
+	MaxBodyScale = 16
+	// maxSkinWidth allows HD skins of resource packs while bounding the decoded size
+	maxSkinWidth = 1024
+)
+
+// rect is an area of a skin in pixels of a 64 pixel wide texture
+type rect struct {
+	x, y, w, h int
+}
+
+// part is a body part as seen from the front. The overlay is the second layer added in 1.8,
+// legacy 64x32 skins only have the one of the head.
+type part struct {
+	base    rect
+	overlay rect
+	// mirror flips the part, legacy skins reuse the right arm and leg for the left ones
+	mirror bool
+	// dx and dy place the part on the 16x32 body canvas
+	dx, dy int
+}
+
+var (
+	face = rect{8, 8, 8, 8}
+	hat  = rect{40, 8, 8, 8}
+)
+
+// Decode reads a skin texture, which must be 64 pixels wide or an HD multiple of that and either
+// square or, for legacy skins, half as high
+func Decode(data []byte) (image.Image, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid skin: %w", err)
+	}
+	if config.Width < 64 || config.Width > maxSkinWidth || config.Width%64 != 0 ||
+		(config.Height != config.Width && config.Height != config.Width/2) {
+		return nil, fmt.Errorf("invalid skin: unsupported size %dx%d", config.Width, config.Height)
+	}
+	skin, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid skin: %w", err)
+	}
+	return skin, nil
+}
+
+// Head renders the face with the hat layer as a size x size image
+func Head(skin image.Image, size int) *image.NRGBA {
+	unit := skin.Bounds().Dx() / 64
+	canvas := image.NewNRGBA(image.Rect(0, 0, 8*unit, 8*unit))
+	drawBase(canvas, skin, face, false, 0, 0)
+	if !isLegacy(skin) || hasTransparency(skin, hat) {
+		drawOverlay(canvas, skin, hat, false, 0, 0)
+	}
+	return scaleNearest(canvas, size, size)
+}
+
+// Body renders the front view of the player, 16 x 32 skin pixels of scale x scale pixels each.
+// Slim skins have three pixel wide arms.
+func Body(skin image.Image, slim bool, scale int) *image.NRGBA {
+	unit := skin.Bounds().Dx() / 64
+	canvas := image.NewNRGBA(image.Rect(0, 0, 16*unit, 32*unit))
+	legacy := isLegacy(skin)
+	for _, p := range bodyParts(slim, legacy) {
+		drawBase(canvas, skin, p.base, p.mirror, p.dx*unit, p.dy*unit)
+		if p.overlay.w > 0 && (p.overlay != hat || !legacy || hasTransparency(skin, hat)) {
+			drawOverlay(canvas, skin, p.overlay, p.mirror, p.dx*unit, p.dy*unit)
+		}
+	}
+	return scaleNearest(canvas, 16*scale, 32*scale)
+}
+
+// bodyParts lists the front faces of the body parts. The player faces the viewer, so their right
+// arm and leg are on the left of the image.
+func bodyParts(slim bool, legacy bool) []part {
+	arm := 4
+	if slim {
+		arm = 3
+	}
+	parts := []part{
+		{base: face, overlay: hat, dx: 4, dy: 0},
+		{base: rect{20, 20, 8, 12}, overlay: rect{20, 36, 8, 12}, dx: 4, dy: 8},
+		{base: rect{44, 20, arm, 12}, overlay: rect{44, 36, arm, 12}, dx: 4 - arm, dy: 8},
+		{base: rect{4, 20, 4, 12}, overlay: rect{4, 36, 4, 12}, dx: 4, dy: 20},
+	}
+	if legacy {
+		for i := 1; i < len(parts); i++ {
+			parts[i].overlay = rect{}
+		}
+		return append(parts,
+			part{base: rect{44, 20, arm, 12}, mirror: true, dx: 12, dy: 8},
+			part{base: rect{4, 20, 4, 12}, mirror: true, dx: 8, dy: 20},
+		)
+	}
+	return append(parts,
+		part{base: rect{36, 52, arm, 12}, overlay: rect{52, 52, arm, 12}, dx: 12, dy: 8},
+		part{base: rect{20, 52, 4, 12}, overlay: rect{4, 52, 4, 12}, dx: 8, dy: 20},
+	)
+}
+
+func isLegacy(skin image.Image) bool {
+	return skin.Bounds().Dy() < skin.Bounds().Dx()
+}
+
+// hasTransparency tells whether a legacy skin uses its hat layer. The game ignores a fully
+// opaque one, old skin editors filled the unused area with a solid color.
+func hasTransparency(skin image.Image, r rect) bool {
+	unit := skin.Bounds().Dx() / 64
+	min := skin.Bounds().Min
+	for y := r.y * unit; y < (r.y+r.h)*unit; y++ {
+		for x := r.x * unit; x < (r.x+r.w)*unit; x++ {
+			if _, _, _, a := skin.At(min.X+x, min.Y+y).RGBA(); a < 0x8000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// drawBase copies a part of the first layer, which the game draws without transparency
+func drawBase(dst *image.NRGBA, skin image.Image, r rect, mirror bool, dx, dy int) {
+	copyPart(dst, skin, r, mirror, dx, dy, true)
+}
+
+// drawOverlay blends a part of the second layer over what is already drawn
+func drawOverlay(dst *image.NRGBA, skin image.Image, r rect, mirror bool, dx, dy int) {
+	unit := skin.Bounds().Dx() / 64
+	layer := image.NewNRGBA(image.Rect(0, 0, r.w*unit, r.h*unit))
+	copyPart(layer, skin, r, mirror, 0, 0, false)
+	draw.Draw(dst, layer.Bounds().Add(image.Pt(dx, dy)), layer, image.Point{}, draw.Over)
+}
+
+func copyPart(dst *image.NRGBA, skin image.Image, r rect, mirror bool, dx, dy int, opaque bool) {
+	unit := skin.Bounds().Dx() / 64
+	min := skin.Bounds().Min
+	w, h := r.w*unit, r.h*unit
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx := x
+			if mirror {
+				sx = w - 1 - x
+			}
+			c := color.NRGBAModel.Convert(skin.At(min.X+r.x*unit+sx, min.Y+r.y*unit+y)).(color.NRGBA)
+			if opaque {
+				c.A = 255
+			}
+			dst.SetNRGBA(dx+x, dy+y, c)
+		}
+	}
+}
+
+// scaleNearest resizes without smoothing, which keeps skin pixels sharp
+func scaleNearest(src *image.NRGBA, width, height int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.SetNRGBA(x, y, src.NRGBAAt(x*sw/width, y*sh/height))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,118 @@
+package skinrender
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+var (
+	red   = color.NRGBA{R: 255, A: 255}
+	green = color.NRGBA{G: 255, A: 255}
+	blue  = color.NRGBA{B: 255, A: 255}
+	black = color.NRGBA{A: 255}
+)
+
+func newSkin(height int) *image.NRGBA {
+	return image.NewNRGBA(image.Rect(0, 0, 64, height))
+}
+
+func fillRect(img *image.NRGBA, r rect, c color.NRGBA) {
+	for y := r.y; y < r.y+r.h; y++ {
+		for x := r.x; x < r.x+r.w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+}
+
+func TestHead(t *testing.T) {
+	skin := newSkin(64)
+	fillRect(skin, face, red)
+	skin.SetNRGBA(40, 8, green)
+
+	head := Head(skin, 16)
+	if head.Bounds().Dx() != 16 || head.Bounds().Dy() != 16 {
+		t.Fatalf("size = %v", head.Bounds())
+	}
+	// the hat pixel covers the top left 2x2 block, the rest is the face
+	if got := head.NRGBAAt(1, 1); got != green {
+		t.Fatalf("hat pixel = %v", got)
+	}
+	if got := head.NRGBAAt(2, 0); got != red {
+		t.Fatalf("face pixel = %v", got)
+	}
+}
+
+func TestHead_legacyOpaqueHat(t *testing.T) {
+	skin := newSkin(32)
+	fillRect(skin, face, red)
+	fillRect(skin, hat, black)
+	if got := Head(skin, 8).NRGBAAt(0, 0); got != red {
+		t.Fatalf("pixel = %v, an opaque legacy hat must be ignored", got)
+	}
+
+	skin.SetNRGBA(41, 8, color.NRGBA{})
+	if got := Head(skin, 8).NRGBAAt(0, 0); got != black {
+		t.Fatalf("pixel = %v, a legacy hat with transparency must be drawn", got)
+	}
+}
+
+func TestBody(t *testing.T) {
+	skin := newSkin(64)
+	fillRect(skin, rect{44, 20, 4, 12}, red)
+	fillRect(skin, rect{36, 52, 4, 12}, blue)
+	fillRect(skin, rect{20, 20, 8, 12}, green)
+
+	body := Body(skin, false, 2)
+	if body.Bounds().Dx() != 32 || body.Bounds().Dy() != 64 {
+		t.Fatalf("size = %v", body.Bounds())
+	}
+	// the right arm is on the left of the image
+	for x, want := range map[int]color.NRGBA{0: red, 8: green, 24: blue} {
+		if got := body.NRGBAAt(x, 16); got != want {
+			t.Fatalf("pixel %d = %v, want %v", x, got, want)
+		}
+	}
+
+	slim := Body(skin, true, 1)
+	if got := slim.NRGBAAt(0, 8); got.A != 0 {
+		t.Fatalf("pixel = %v, slim arms leave the outer column empty", got)
+	}
+}
+
+func TestBody_legacyMirrorsArm(t *testing.T) {
+	skin := newSkin(32)
+	// the outer column of the right arm front
+	fillRect(skin, rect{44, 20, 1, 12}, red)
+	body := Body(skin, false, 1)
+	if got := body.NRGBAAt(0, 8); got != red {
+		t.Fatalf("right arm outer pixel = %v", got)
+	}
+	if got := body.NRGBAAt(15, 8); got != red {
+		t.Fatalf("left arm outer pixel = %v, legacy skins mirror the right arm", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	encode := func(img image.Image) []byte {
+		var b bytes.Buffer
+		png.Encode(&b, img)
+		return b.Bytes()
+	}
+	if _, err := Decode(encode(DefaultSkin())); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if _, err := Decode(encode(image.NewNRGBA(image.Rect(0, 0, 128, 64)))); err != nil {
+		t.Fatalf("Decode HD legacy skin: %v", err)
+	}
+	for _, size := range []image.Rectangle{image.Rect(0, 0, 64, 48), image.Rect(0, 0, 100, 100), image.Rect(0, 0, 2048, 2048)} {
+		if _, err := Decode(encode(image.NewNRGBA(size))); err == nil {
+			t.Fatalf("expected an error for %v", size)
+		}
+	}
+	if _, err := Decode([]byte("not a png")); err == nil {
+		t.Fatal("expected an error for invalid data")
+	}
+}
@@ -0,0 +1,207 @@
+// Package textcomponent builds Minecraft JSON text components for tellraw, title and similar
+// commands.
+package textcomponent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Format selects the click and hover event layout. 1.21.5 renamed clickEvent and hoverEvent
+// to click_event and hover_event and gave each action its own value field.
+type Format int
+
+const (
+	Modern Format = iota
+	Legacy
+)
+
+// modernDataVersion is the DataVersion of 1.21.5
+const modernDataVersion = 4325
+
+// FormatForDataVersion returns the format understood by a world saved with dataVersion
+func FormatForDataVersion(dataVersion int) Format {
+	if dataVersion > 0 && dataVersion < modernDataVersion {
+		return Legacy
+	}
+	return Modern
+}
+
+const (
+	ClickOpenURL         = "open_url"
+	ClickRunCommand      = "run_command"
+	ClickSuggestCommand  = "suggest_command"
+	ClickCopyToClipboard = "copy_to_clipboard"
+)
+
+// ClickActions are the supported click event actions
+var ClickActions = []string{ClickOpenURL, ClickRunCommand, ClickSuggestCommand, ClickCopyToClipboard}
+
+// Colors maps the named text colors to their RGB values
+var Colors = map[string]string{
+	"black": "#000000", "dark_blue": "#0000AA", "dark_green": "#00AA00", "dark_aqua": "#00AAAA",
+	"dark_red": "#AA0000", "dark_purple": "#AA00AA", "gold": "#FFAA00", "gray": "#AAAAAA",
+	"dark_gray": "#555555", "blue": "#5555FF", "green": "#55FF55", "aqua": "#55FFFF",
+	"red": "#FF5555", "light_purple": "#FF55FF", "yellow": "#FFFF55", "white": "#FFFFFF",
+}
+
+// ColorNames lists the named colors in the order of the color codes
+var ColorNames = []string{
+	"black", "dark_blue", "dark_green", "dark_aqua", "dark_red", "dark_purple", "gold", "gray",
+	"dark_gray", "blue", "green", "aqua", "red", "light_purple", "yellow", "white",
+}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// ClickEvent runs an action when the component is clicked in chat
+type ClickEvent struct {
+	Action string
+	Value  string
+}
+
+// Component is a text or selector component with optional style, events and children.
+// Children inherit the style of their parent.
+type Component struct {
+	Text          string
+	Selector      string
+	Color         string
+	Bold          bool
+	Italic        bool
+	Underlined    bool
+	Strikethrough bool
+	Obfuscated    bool
+	Click         *ClickEvent
+	// Hover is shown as a tooltip when not empty
+	Hover *Component
+	Extra []Component
+}
+
+// Text returns a plain text component
+func Text(text string) Component {
+	return Component{Text: text}
+}
+
+// Join returns a component without text of its own that shows parts one after another.
+// Parts do not inherit each other's style.
+func Join(parts ...Component) Component {
+	return Component{Extra: parts}
+}
+
+// Validate checks colors, selectors and click events of the component and its children
+func (c Component) Validate() error {
+	if c.Selector != "" && !strings.HasPrefix(c.Selector, "@") {
+		return fmt.Errorf("selector %q must start with @", c.Selector)
+	}
+	if c.Color != "" && Colors[c.Color] == "" && !hexColorPattern.MatchString(c.Color) {
+		return fmt.Errorf("unknown color %q", c.Color)
+	}
+	if c.Click != nil {
+		if !slices.Contains(ClickActions, c.Click.Action) {
+			return fmt.Errorf("unknown click action %q", c.Click.Action)
+		}
+		if c.Click.Value == "" {
+			return fmt.Errorf("click action %s needs a value", c.Click.Action)
+		}
+		if c.Click.Action == ClickOpenURL {
+			u, err := url.Parse(c.Click.Value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("click URL must be an http or https link")
+			}
+		}
+	}
+	if c.Hover != nil {
+		if err := c.Hover.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, child := range c.Extra {
+		if err := child.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encode returns the component as JSON for a command argument
+func (c Component) Encode(format Format) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	// <, > and & are fine in commands and easier to read unescaped
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(c.toJSON(format))
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// toJSON builds the JSON object. Go maps are encoded with sorted keys, which keeps the
+// output stable.
+func (c Component) toJSON(format Format) map[string]any {
+	out := map[string]any{}
+	if c.Selector != "" {
+		out["selector"] = c.Selector
+	} else {
+		out["text"] = c.Text
+	}
+	if c.Color != "" {
+		out["color"] = c.Color
+	}
+	for name, set := range map[string]bool{
+		"bold": c.Bold, "italic": c.Italic, "underlined": c.Underlined,
+		"strikethrough": c.Strikethrough, "obfuscated": c.Obfuscated,
+	} {
+		if set {
+			out[name] = true
+		}
+	}
+	if c.Click != nil {
+		if format == Legacy {
+			out["clickEvent"] = map[string]any{"action": c.Click.Action, "value": c.Click.Value}
+		} else {
+			out["click_event"] = map[string]any{"action": c.Click.Action, modernClickField(c.Click.Action): c.Click.Value}
+		}
+	}
+	if c.Hover != nil {
+		if format == Legacy {
+			out["hoverEvent"] = map[string]any{"action": "show_text", "contents": c.Hover.toJSON(format)}
+		} else {
+			out["hover_event"] = map[string]any{"action": "show_text", "value": c.Hover.toJSON(format)}
+		}
+	}
+	if len(c.Extra) > 0 {
+		extra := make([]map[string]any, len(c.Extra))
+		for i, child := range c.Extra {
+			extra[i] = child.toJSON(format)
+		}
+		out["extra"] = extra
+	}
+	return out
+}
+
+// modernClickField names the field holding the value of a 1.21.5+ click event
+func modernClickField(action string) string {
+	switch action {
+	case ClickOpenURL:
+		return "url"
+	case ClickRunCommand, ClickSuggestCommand:
+		return "command"
+	}
+	return "value"
+}
+
+// PlainText returns the text of the component and its children. Selectors are shown as written.
+func (c Component) PlainText() string {
+	var b strings.Builder
+	if c.Selector != "" {
+		b.WriteString(c.Selector)
+	} else {
+		b.WriteString(c.Text)
+	}
+	for _, child := range c.Extra {
+		b.WriteString(child.PlainText())
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package textcomponent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComponent_Encode(t *testing.T) {
+	message := Join(
+		Component{Text: `Say "hi" \ <3`, Color: "gold", Bold: true},
+		Component{Selector: "@p", Italic: true},
+		Component{
+			Text:  " [rules]",
+			Color: "#3366ff",
+			Click: &ClickEvent{Action: ClickOpenURL, Value: "https://example.com/rules"},
+			Hover: &Component{Text: "Open the rules"},
+		},
+		Component{Text: " /spawn", Click: &ClickEvent{Action: ClickSuggestCommand, Value: "/spawn"}},
+	)
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{Modern, `{"extra":[` +
+			`{"bold":true,"color":"gold","text":"Say \"hi\" \\ <3"},` +
+			`{"italic":true,"selector":"@p"},` +
+			`{"click_event":{"action":"open_url","url":"https://example.com/rules"},"color":"#3366ff","hover_event":{"action":"show_text","value":{"text":"Open the rules"}},"text":" [rules]"},` +
+			`{"click_event":{"action":"suggest_command","command":"/spawn"},"text":" /spawn"}` +
+			`],"text":""}`},
+		{Legacy, `{"extra":[` +
+			`{"bold":true,"color":"gold","text":"Say \"hi\" \\ <3"},` +
+			`{"italic":true,"selector":"@p"},` +
+			`{"clickEvent":{"action":"open_url","value":"https://example.com/rules"},"color":"#3366ff","hoverEvent":{"action":"show_text","contents":{"text":"Open the rules"}},"text":" [rules]"},` +
+			`{"clickEvent":{"action":"suggest_command","value":"/spawn"},"text":" /spawn"}` +
+			`],"text":""}`},
+	}
+	for _, tt := range tests {
+		if got := message.Encode(tt.format); got != tt.want {
+			t.Errorf("Encode(%d) =\n%s\nwant\n%s", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestComponent_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       Component
+		wantErr bool
+	}{
+		{"plain", Text("hello"), false},
+		{"hex color", Component{Text: "x", Color: "#A0b1C2"}, false},
+		{"unknown color", Component{Text: "x", Color: "orange"}, true},
+		{"selector without @", Component{Selector: "Steve"}, true},
+		{"javascript url", Component{Text: "x", Click: &ClickEvent{Action: ClickOpenURL, Value: "javascript:alert(1)"}}, true},
+		{"unknown click", Component{Text: "x", Click: &ClickEvent{Action: "change_page", Value: "2"}}, true},
+		{"empty click value", Component{Text: "x", Click: &ClickEvent{Action: ClickRunCommand}}, true},
+		{"invalid child", Join(Text("ok"), Component{Text: "x", Color: "pink"}), true},
+	}
+	for _, tt := range tests {
+		if err := tt.c.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestComponent_Segments(t *testing.T) {
+	c := Component{Text: "A", Color: "red", Bold: true, Extra: []Component{
+		{Text: "B", Italic: true},
+		{Selector: "@a", Color: "#123456", Hover: &Component{Text: "tip"}},
+	}}
+	want := []Segment{
+		{Text: "A", Color: "#FF5555", Bold: true},
+		{Text: "B", Color: "#FF5555", Bold: true, Italic: true},
+		{Text: "@a", Color: "#123456", Bold: true, Selector: true, Hover: "tip"},
+	}
+	if got := c.Segments(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Segments() = %+v, want %+v", got, want)
+	}
+	if got := c.PlainText(); got != "AB@a" {
+		t.Fatalf("PlainText() = %q", got)
+	}
+}
+
+func TestFormatForDataVersion(t *testing.T) {
+	if FormatForDataVersion(3955) != Legacy || FormatForDataVersion(4325) != Modern || FormatForDataVersion(0) != Modern {
+		t.Fatalf("unexpected formats")
+	}
+}
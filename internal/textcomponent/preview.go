@@ -0,0 +1,63 @@
+package textcomponent
+
+// Segment is a run of text with the style it is shown in, used to preview components
+type Segment struct {
+	Text string
+	// Color is an RGB value like #FF5555, "" for the default color
+	Color         string
+	Bold          bool
+	Italic        bool
+	Underlined    bool
+	Strikethrough bool
+	Obfuscated    bool
+	// Selector is true when Text is a selector the server resolves to names
+	Selector bool
+	Click    *ClickEvent
+	Hover    string
+}
+
+// Segments flattens the component into styled runs, applying inherited styles
+func (c Component) Segments() []Segment {
+	return c.segments(Segment{})
+}
+
+func (c Component) segments(parent Segment) []Segment {
+	s := Segment{
+		Color:         parent.Color,
+		Bold:          parent.Bold || c.Bold,
+		Italic:        parent.Italic || c.Italic,
+		Underlined:    parent.Underlined || c.Underlined,
+		Strikethrough: parent.Strikethrough || c.Strikethrough,
+		Obfuscated:    parent.Obfuscated || c.Obfuscated,
+		Click:         parent.Click,
+		Hover:         parent.Hover,
+	}
+	if c.Color != "" {
+		if rgb, ok := Colors[c.Color]; ok {
+			s.Color = rgb
+		} else {
+			s.Color = c.Color
+		}
+	}
+	if c.Click != nil {
+		s.Click = c.Click
+	}
+	if c.Hover != nil {
+		s.Hover = c.Hover.PlainText()
+	}
+
+	var out []Segment
+	if c.Selector != "" {
+		s.Text = c.Selector
+		s.Selector = true
+	} else {
+		s.Text = c.Text
+	}
+	if s.Text != "" {
+		out = append(out, s)
+	}
+	for _, child := range c.Extra {
+		out = append(out, child.segments(s)...)
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package utils
+
+import "sync"
+
+// KeyedMutex locks by key, so work on one key does not wait for other keys. A key's lock is
+// forgotten once nobody holds or waits for it. The zero value is ready to use.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	// users counts the holder and the waiters
+	users int
+}
+
+// Lock locks key and returns the function unlocking it
+func (m *KeyedMutex) Lock(key string) (unlock func()) {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = map[string]*keyedLock{}
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.users++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.mu.Lock()
+		l.users--
+		if l.users == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
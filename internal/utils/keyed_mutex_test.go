@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	var m KeyedMutex
+	var wg sync.WaitGroup
+	counts := map[string]int{}
+	for i := 0; i < 100; i++ {
+		key := []string{"a", "b", "c"}[i%3]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := m.Lock(key)
+			defer unlock()
+			counts[key]++
+		}()
+	}
+	wg.Wait()
+	if counts["a"] != 34 || counts["b"] != 33 || counts["c"] != 33 {
+		t.Fatalf("counts = %v", counts)
+	}
+	if len(m.locks) != 0 {
+		t.Fatalf("%d locks kept after use", len(m.locks))
+	}
+
+	// a key can be locked again while another one is held
+	unlockA := m.Lock("a")
+	m.Lock("b")()
+	unlockA()
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"log"
 	"mc-admin/internal/api"
-	"mc-admin/internal/clients/ashcon"
+	"mc-admin/internal/clients/profiles"
 	"mc-admin/internal/clients/rcon"
 	"mc-admin/internal/config"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -28,17 +29,25 @@ func main() {
 		{Name: "SESSION_SECRET", Required: true, FeatureFlag: "ENABLE_DISCORD_OAUTH", ValidationFunc: config.IsNotEmpty},
 	}))
 
-	var ashconClient ashcon.MojangUserNameChecker
+	var profileResolver profiles.Resolver
 	enableUsernameCheck := strings.ToLower(os.Getenv("ENABLE_MINECRAFT_USERNAME_CHECK")) == "true"
 	if enableUsernameCheck {
-		ashconClient = ashcon.NewMojangUserNameChecker()
+		var userCachePath string
+		if dataDir := os.Getenv("MINECRAFT_DATA_DIR"); dataDir != "" {
+			userCachePath = filepath.Join(dataDir, "usercache.json")
+		}
+		resolver, err := profiles.NewResolverFromEnv(userCachePath)
+		if err != nil {
+			log.Fatalf("failed to configure profile lookups: %v", err)
+		}
+		profileResolver = resolver
 	}
 	rconClient := rcon.BuildMinecraftRconClientFromEnv()
 	defer rconClient.Close() // Ensure RCON connection is closed on exit
 
 	r, err := api.InitializeWebServer(api.WebServerOptions{
 		MinecraftRconClient: rconClient,
-		AshconClient:        ashconClient,
+		ProfileResolver:     profileResolver,
 		AuthConfig:          api.BuildAuthConfigFromEnv(),
 	})
 	if err != nil {